@@ -0,0 +1,70 @@
+// Package audit emits structured records for security-relevant authorization events, such as a
+// super-admin acting as another user. Records are written as JSON log lines with an "audit" field
+// so they can be routed to long-term storage by a CloudWatch subscription filter independently of
+// the authorizer's operational logs.
+package audit
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type Severity string
+
+const (
+	SeverityInfo Severity = "info"
+	SeverityHigh Severity = "high"
+)
+
+type Outcome string
+
+const (
+	OutcomeAllowed Outcome = "allowed"
+	OutcomeDenied  Outcome = "denied"
+)
+
+// Record is a single audit event. ActorNodeId is the principal that made the request; SubjectNodeId
+// is the principal the request was evaluated as, if different (e.g. the impersonated user).
+// Resource is the node id of the resource requested and Justification the reason the actor gave
+// for the request, for events that record them (e.g. break-glass access). PurposeOfUse is the
+// purpose of use the request declared, if any.
+type Record struct {
+	Event         string    `json:"event"`
+	Outcome       Outcome   `json:"outcome"`
+	Severity      Severity  `json:"severity"`
+	ActorNodeId   string    `json:"actorNodeId,omitempty"`
+	SubjectNodeId string    `json:"subjectNodeId,omitempty"`
+	Method        string    `json:"method,omitempty"`
+	Route         string    `json:"route,omitempty"`
+	Reason        string    `json:"reason,omitempty"`
+	Resource      string    `json:"resource,omitempty"`
+	Justification string    `json:"justification,omitempty"`
+	PurposeOfUse  string    `json:"purposeOfUse,omitempty"`
+	Time          time.Time `json:"time"`
+}
+
+// Logger emits audit records. Implementations must not drop records silently.
+type Logger interface {
+	Log(record Record)
+}
+
+type logrusLogger struct{}
+
+// NewLogger returns a Logger that writes records to the standard (JSON formatted) logrus logger.
+// High severity records are logged at Warn level so they can be alarmed on.
+func NewLogger() Logger {
+	return &logrusLogger{}
+}
+
+func (l *logrusLogger) Log(record Record) {
+	if record.Time.IsZero() {
+		record.Time = time.Now().UTC()
+	}
+	entry := log.WithField("audit", record)
+	if record.Severity == SeverityHigh {
+		entry.Warn("audit event")
+		return
+	}
+	entry.Info("audit event")
+}
@@ -0,0 +1,58 @@
+package authorizers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
+)
+
+// AuthPolicyLookup is the subset of manager.IdentityManager needed to enforce an organization's authentication
+// policy.
+type AuthPolicyLookup interface {
+	GetAuthentication() manager.Authentication
+	GetOrganizationAuthPolicy(ctx context.Context, orgId int64) (*manager.AuthPolicy, error)
+}
+
+// CheckAuthPolicy denies the request if the organization's authentication policy does not allow how it was
+// authenticated: by a callback token, an API token, or a user who signed in through an identity provider the
+// organization does not allow.
+//
+// Like CheckSourceIp, it applies only to requests with request metadata in ctx. Direct Lambda-to-Lambda
+// invocations are authenticated by IAM, not by any credential the policy restricts.
+func CheckAuthPolicy(ctx context.Context, lookup AuthPolicyLookup, orgId int64) error {
+	request, ok := manager.RequestFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	authentication := lookup.GetAuthentication()
+	if authentication.Method == manager.AuthenticationNodeId && !request.Callback {
+		return nil
+	}
+
+	policy, err := lookup.GetOrganizationAuthPolicy(ctx, orgId)
+	if err != nil {
+		return NewIndeterminateError(fmt.Errorf("unable to get authentication policy for organization %d: %w", orgId, err))
+	}
+
+	switch {
+	case request.Callback:
+		if policy.CallbackTokensDisabled {
+			return NewDenyError(ReasonCallbackTokensDisabled, fmt.Errorf("organization %d does not allow callback tokens", orgId))
+		}
+	case authentication.Method == manager.AuthenticationApiToken:
+		if policy.ApiTokensDisabled {
+			return NewDenyError(ReasonApiTokensDisabled, fmt.Errorf("organization %d does not allow API tokens", orgId))
+		}
+	case len(policy.AllowedIdentityProviders) > 0:
+		for _, allowed := range policy.AllowedIdentityProviders {
+			if strings.EqualFold(allowed, authentication.IdentityProvider) {
+				return nil
+			}
+		}
+		return NewDenyError(ReasonIdentityProviderNotAllowed, fmt.Errorf("organization %d does not allow sign-in through identity provider %q",
+			orgId, authentication.IdentityProvider))
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package authorizers_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/authorizers"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/test"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/test/mocks"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/organization"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// authPolicyLookup is an authorizers.AuthPolicyLookup for a principal that authenticated as given.
+type authPolicyLookup struct {
+	*mocks.MockPennsievePgAPI
+	authentication manager.Authentication
+}
+
+func (l authPolicyLookup) GetAuthentication() manager.Authentication {
+	return l.authentication
+}
+
+func TestCheckAuthPolicy(t *testing.T) {
+	orgId := int64(1001)
+	user := manager.Authentication{Method: manager.AuthenticationUser, IdentityProvider: manager.IdentityProviderCognito}
+	federated := manager.Authentication{Method: manager.AuthenticationUser, IdentityProvider: "UPennSAML"}
+	apiToken := manager.Authentication{Method: manager.AuthenticationApiToken}
+	nodeId := manager.Authentication{Method: manager.AuthenticationNodeId}
+	federatedOnly := &manager.AuthPolicy{AllowedIdentityProviders: []string{"upennsaml"}}
+	locked := &manager.AuthPolicy{AllowedIdentityProviders: []string{"UPennSAML"}, ApiTokensDisabled: true, CallbackTokensDisabled: true}
+
+	for scenario, params := range map[string]struct {
+		request        *manager.Request
+		authentication manager.Authentication
+		policy         *manager.AuthPolicy
+		lookupErr      error
+		expectedReason string
+		indeterminate  bool
+	}{
+		"no request metadata":           {request: nil, authentication: apiToken},
+		"direct invocation":             {request: &manager.Request{}, authentication: nodeId},
+		"no policy":                     {request: &manager.Request{}, authentication: user, policy: &manager.AuthPolicy{}},
+		"allowed identity provider":     {request: &manager.Request{}, authentication: federated, policy: federatedOnly},
+		"disallowed identity provider":  {request: &manager.Request{}, authentication: user, policy: federatedOnly, expectedReason: authorizers.ReasonIdentityProviderNotAllowed},
+		"api tokens allowed":            {request: &manager.Request{}, authentication: apiToken, policy: federatedOnly},
+		"api tokens disabled":           {request: &manager.Request{}, authentication: apiToken, policy: locked, expectedReason: authorizers.ReasonApiTokensDisabled},
+		"callback tokens allowed":       {request: &manager.Request{Callback: true}, authentication: nodeId, policy: federatedOnly},
+		"callback tokens disabled":      {request: &manager.Request{Callback: true}, authentication: nodeId, policy: locked, expectedReason: authorizers.ReasonCallbackTokensDisabled},
+		"federated user, locked policy": {request: &manager.Request{}, authentication: federated, policy: locked},
+		"lookup failure":                {request: &manager.Request{}, authentication: user, lookupErr: errors.New("connection refused"), indeterminate: true},
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			lookup := authPolicyLookup{MockPennsievePgAPI: mocks.NewMockPennsievePgAPI(), authentication: params.authentication}
+			if params.policy != nil || params.lookupErr != nil {
+				lookup.OnGetOrganizationAuthPolicy(orgId).Return(params.policy, params.lookupErr)
+			}
+			ctx := context.Background()
+			if params.request != nil {
+				ctx = manager.WithRequest(ctx, *params.request)
+			}
+
+			err := authorizers.CheckAuthPolicy(ctx, lookup, orgId)
+
+			switch {
+			case params.indeterminate:
+				assertIndeterminate(t, err)
+			case params.expectedReason != "":
+				assert.Equal(t, params.expectedReason, authorizers.DenyReason(err))
+				assertNotIndeterminate(t, err)
+			default:
+				assert.NoError(t, err)
+			}
+			lookup.AssertExpectations(t)
+		})
+	}
+}
+
+func TestWorkspaceAuthorizerAuthPolicy(t *testing.T) {
+	managerParams := mocks.NewClaimsManagerParams(t)
+	currentUser := test.NewUser(101, 1001)
+	claimsManager := managerParams.WithUserQueryMocked(t, currentUser).BuildClaimsManager()
+	orgNodeId := fmt.Sprintf("N:organization:%s", uuid.NewString())
+	orgId := currentUser.PreferredOrg
+	managerParams.MockPennsievePg.OnGetOrganizationClaimByNodeId(currentUser.Id, orgNodeId).Return(&organization.Claim{Role: pgdb.Read, IntId: orgId}, nil)
+	managerParams.MockPennsievePg.OnGetOrganizationIpAllowlist(orgId).Return([]string(nil), nil)
+	managerParams.MockPennsievePg.OnGetOrganizationAuthPolicy(orgId).Return(&manager.AuthPolicy{AllowedIdentityProviders: []string{"UPennSAML"}}, nil)
+	ctx := manager.WithRequest(context.Background(), manager.Request{SourceIp: "198.51.100.1", Method: "GET"})
+
+	// The test token's username is a UUID, as a Pennsieve password user's is.
+	claims, err := authorizers.NewWorkspaceAuthorizer(orgNodeId).GenerateClaims(ctx, claimsManager, "")
+
+	assert.Nil(t, claims)
+	require.Error(t, err)
+	assert.Equal(t, authorizers.ReasonIdentityProviderNotAllowed, authorizers.DenyReason(err))
+	managerParams.AssertMockExpectations(t)
+}
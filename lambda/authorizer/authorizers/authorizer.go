@@ -2,10 +2,58 @@ package authorizers
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 
 	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	corePgdb "github.com/pennsieve/pennsieve-go-core/pkg/queries/pgdb"
 )
 
 type Authorizer interface {
 	GenerateClaims(context.Context, manager.IdentityManager, string) (map[string]interface{}, error)
 }
+
+// Scope returns a short, stable name for the kind of resource an Authorizer authorizes, for use in
+// limits, logs and metrics.
+func Scope(authorizer Authorizer) string {
+	switch authorizer.(type) {
+	case *DatasetAuthorizer:
+		return "dataset"
+	case *WorkspaceAuthorizer:
+		return "workspace"
+	case *ManifestAuthorizer:
+		return "manifest"
+	case *UserAuthorizer:
+		return "user"
+	case *CompositeAuthorizer:
+		return "composite"
+	default:
+		return "unknown"
+	}
+}
+
+// getCurrentUser returns the principal's user. A principal that is not a Pennsieve user is denied with
+// ReasonUnknownUser; failing to look it up is indeterminate.
+func getCurrentUser(ctx context.Context, claimsManager manager.IdentityManager) (*pgdb.User, error) {
+	currentUser, err := claimsManager.GetCurrentUser(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, NewDenyError(ReasonUnknownUser, fmt.Errorf("no user found for principal: %w", err))
+		}
+		return nil, NewIndeterminateError(fmt.Errorf("unable to get current user: %w", err))
+	}
+	return currentUser, nil
+}
+
+// orgClaimError classifies err, from looking up the user's claim on an organization that the request requires
+// membership of. A user who is not a member, or an organization that does not exist, is denied with
+// ReasonNotOrgMember; any other failure is indeterminate.
+func orgClaimError(err error) error {
+	var notOrgMember corePgdb.OrganizationUserNotFoundError
+	if errors.As(err, &notOrgMember) {
+		return NewDenyError(ReasonNotOrgMember, fmt.Errorf("user has no access to organization: %w", err))
+	}
+	return NewIndeterminateError(fmt.Errorf("unable to get Organization Role: %w", err))
+}
@@ -0,0 +1,86 @@
+package authorizers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	claimsClient "github.com/pennsieve/pennsieve-go-api/authorizer/claims"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
+	coreAuthorizer "github.com/pennsieve/pennsieve-go-core/pkg/authorizer"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dataset"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/organization"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/role"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/teamUser"
+	corePgdb "github.com/pennsieve/pennsieve-go-core/pkg/queries/pgdb"
+)
+
+// BreakGlassClaims returns the claims of an operator granted emergency read access to the dataset until expiresAt,
+// whatever their role on it: a viewer role on the dataset, flagged with claimsClient.LabelBreakGlass, and no role
+// in its organization. Whoever may break glass, and for which requests, is for the caller to decide.
+//
+// Only roles are bypassed. The organization's IP allowlist and authentication policy, and the dataset's data-use
+// agreement and purpose-of-use requirement, still apply.
+func BreakGlassClaims(ctx context.Context, claimsManager manager.IdentityManager, datasetId string, expiresAt time.Time, authorizerMode string) (map[string]interface{}, error) {
+	currentUser, err := getCurrentUser(ctx, claimsManager)
+	if err != nil {
+		return nil, err
+	}
+
+	orgInt, err := claimsManager.GetOrganizationIdForDataset(ctx, datasetId)
+	if err != nil {
+		var notFound corePgdb.DatasetOrganizationNotFoundError
+		if errors.As(err, &notFound) {
+			return nil, NewDenyError(ReasonDatasetNotFound, fmt.Errorf("no organization found for dataset %s: %w", datasetId, err))
+		}
+		return nil, NewIndeterminateError(fmt.Errorf("unable to resolve organization for dataset %s: %w", datasetId, err))
+	}
+	if err := CheckSourceIp(ctx, claimsManager, currentUser, orgInt); err != nil {
+		return nil, err
+	}
+	if err := CheckAuthPolicy(ctx, claimsManager, orgInt); err != nil {
+		return nil, err
+	}
+
+	identity, err := claimsManager.GetDatasetIdentity(ctx, datasetId, orgInt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, NewDenyError(ReasonDatasetNotFound, fmt.Errorf("no dataset %s in organization %d: %w", datasetId, orgInt, err))
+		}
+		return nil, NewIndeterminateError(fmt.Errorf("unable to get dataset %s: %w", datasetId, err))
+	}
+	if err := CheckDataUseAgreement(ctx, claimsManager, currentUser.Id, datasetId, orgInt); err != nil {
+		return nil, err
+	}
+	datasetState, err := CheckDatasetState(ctx, claimsManager, datasetId, orgInt)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckPurposeOfUse(ctx, datasetId, datasetState); err != nil {
+		return nil, err
+	}
+
+	claims := map[string]interface{}{
+		coreAuthorizer.LabelUserClaim: claimsManager.GetUserClaim(ctx, currentUser),
+		coreAuthorizer.LabelOrganizationClaim: &organization.Claim{
+			Role:   pgdb.NoPermission,
+			IntId:  orgInt,
+			NodeId: identity.OrganizationNodeId,
+		},
+		coreAuthorizer.LabelDatasetClaim: &dataset.Claim{
+			Role:   role.Viewer,
+			NodeId: datasetId,
+			IntId:  identity.DatasetId,
+		},
+		claimsClient.LabelDatasetState: datasetState,
+		claimsClient.LabelGrantExpiry:  &claimsClient.GrantExpiry{Dataset: &expiresAt},
+		claimsClient.LabelBreakGlass:   true,
+	}
+	if authorizerMode == "LEGACY" {
+		claims[coreAuthorizer.LabelTeamClaims] = []teamUser.Claim{}
+	}
+	return claims, nil
+}
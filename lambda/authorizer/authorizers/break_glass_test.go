@@ -0,0 +1,94 @@
+package authorizers_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/authorizers"
+	claimsClient "github.com/pennsieve/pennsieve-go-api/authorizer/claims"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/test"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/test/mocks"
+	coreAuthorizer "github.com/pennsieve/pennsieve-go-core/pkg/authorizer"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dataset"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/organization"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/role"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreakGlassClaims(t *testing.T) {
+	for _, authorizerMode := range []string{"", "LEGACY"} {
+		t.Run(fmt.Sprintf("mode %q", authorizerMode), func(t *testing.T) {
+			managerParams := mocks.NewClaimsManagerParams(t)
+			currentUser := test.NewUser(101, 1001)
+			claimsManager := managerParams.WithUserQueryMocked(t, currentUser).BuildClaimsManager()
+
+			datasetNodeId := fmt.Sprintf("N:dataset:%s", uuid.NewString())
+			datasetOrgId := int64(6001)
+			identity := &manager.DatasetIdentity{OrganizationNodeId: fmt.Sprintf("N:organization:%s", uuid.NewString()), DatasetId: 999}
+			expiresAt := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+			managerParams.MockPennsievePg.OnGetOrganizationIdForDataset(datasetNodeId).Return(datasetOrgId, nil)
+			managerParams.MockPennsievePg.OnGetDatasetIdentity(datasetNodeId, datasetOrgId).Return(identity, nil)
+			managerParams.MockPennsievePg.OnGetDatasetState(datasetNodeId, datasetOrgId).Return(&claimsClient.DatasetState{}, nil)
+			managerParams.MockPennsievePg.OnGetDataUseAgreementStatus(currentUser.Id, datasetNodeId, datasetOrgId).Return(&manager.DataUseAgreementStatus{}, nil)
+
+			claims, err := authorizers.BreakGlassClaims(context.Background(), claimsManager, datasetNodeId, expiresAt, authorizerMode)
+
+			require.NoError(t, err)
+			assert.Equal(t, expectedUserClaim(currentUser), claims[coreAuthorizer.LabelUserClaim])
+			assert.Equal(t, &dataset.Claim{Role: role.Viewer, NodeId: datasetNodeId, IntId: identity.DatasetId}, claims[coreAuthorizer.LabelDatasetClaim])
+			assert.Equal(t, &organization.Claim{Role: pgdb.NoPermission, IntId: datasetOrgId, NodeId: identity.OrganizationNodeId},
+				claims[coreAuthorizer.LabelOrganizationClaim])
+			assert.Equal(t, true, claims[claimsClient.LabelBreakGlass])
+			assert.Equal(t, &claimsClient.GrantExpiry{Dataset: &expiresAt}, claims[claimsClient.LabelGrantExpiry])
+			if authorizerMode == "LEGACY" {
+				assert.Empty(t, claims[coreAuthorizer.LabelTeamClaims])
+				assert.Contains(t, claims, coreAuthorizer.LabelTeamClaims)
+			}
+			managerParams.MockPennsievePg.AssertNotCalled(t, "GetDatasetClaim")
+			managerParams.MockPennsievePg.AssertNotCalled(t, "GetOrganizationClaim")
+			managerParams.AssertMockExpectations(t)
+		})
+	}
+}
+
+// TestBreakGlassClaimsDatasetLookup: a dataset missing from its organization is a deny, while a DB failure looking
+// it up is indeterminate.
+func TestBreakGlassClaimsDatasetLookup(t *testing.T) {
+	for scenario, params := range map[string]struct {
+		lookupErr     error
+		indeterminate bool
+	}{
+		"dataset not found":     {lookupErr: sql.ErrNoRows},
+		"dataset lookup failed": {lookupErr: errors.New("connection refused"), indeterminate: true},
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			managerParams := mocks.NewClaimsManagerParams(t)
+			currentUser := test.NewUser(101, 1001)
+			claimsManager := managerParams.WithUserQueryMocked(t, currentUser).BuildClaimsManager()
+
+			datasetNodeId := fmt.Sprintf("N:dataset:%s", uuid.NewString())
+			datasetOrgId := int64(6001)
+			managerParams.MockPennsievePg.OnGetOrganizationIdForDataset(datasetNodeId).Return(datasetOrgId, nil)
+			managerParams.MockPennsievePg.OnGetDatasetIdentity(datasetNodeId, datasetOrgId).Return((*manager.DatasetIdentity)(nil), params.lookupErr)
+
+			_, err := authorizers.BreakGlassClaims(context.Background(), claimsManager, datasetNodeId, time.Now(), "")
+
+			require.Error(t, err)
+			if params.indeterminate {
+				assertIndeterminate(t, err)
+			} else {
+				assertNotIndeterminate(t, err)
+				assert.Equal(t, authorizers.ReasonDatasetNotFound, authorizers.DenyReason(err))
+			}
+			managerParams.AssertMockExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package authorizers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	claimsClient "github.com/pennsieve/pennsieve-go-api/authorizer/claims"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
+	coreAuthorizer "github.com/pennsieve/pennsieve-go-core/pkg/authorizer"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dataset"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/organization"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/role"
+)
+
+// CompositeResource is one of the resources a CompositeAuthorizer authorizes.
+type CompositeResource struct {
+	// Name identifies the resource in the claims, e.g. the parameter it was named by.
+	Name       string
+	Authorizer Authorizer
+	// MinRole is the least role the user must have on the resource: on the dataset, if the resource's claims
+	// include one, and otherwise on the organization. role.None requires only what Authorizer itself does.
+	MinRole role.Role
+}
+
+// CompositeAuthorizer authorizes a request for several resources, e.g. moving a package from one dataset to
+// another. Every resource must pass its own authorizer and minimum role. The claims are those of the first
+// resource, so that consumers of single-resource claims see its user and organization, plus every resource's
+// claims under claims.LabelResourceClaims.
+type CompositeAuthorizer struct {
+	Resources []CompositeResource
+}
+
+func NewCompositeAuthorizer(resources ...CompositeResource) Authorizer {
+	return &CompositeAuthorizer{Resources: resources}
+}
+
+func (c *CompositeAuthorizer) GenerateClaims(ctx context.Context, claimsManager manager.IdentityManager, authorizerMode string) (map[string]interface{}, error) {
+	if len(c.Resources) == 0 {
+		return nil, NewDenyError(ReasonNoResources, errors.New("no resources to authorize"))
+	}
+
+	var claims map[string]interface{}
+	resourceClaims := map[string]map[string]interface{}{}
+	for _, resource := range c.Resources {
+		generated, err := resource.Authorizer.GenerateClaims(ctx, claimsManager, authorizerMode)
+		if err != nil {
+			return nil, fmt.Errorf("resource %s: %w", resource.Name, err)
+		}
+		if err := checkMinRole(resource, generated); err != nil {
+			return nil, err
+		}
+		resourceClaims[resource.Name] = generated
+		if claims == nil {
+			claims = make(map[string]interface{}, len(generated)+1)
+			for label, claim := range generated {
+				claims[label] = claim
+			}
+		}
+	}
+	claims[claimsClient.LabelResourceClaims] = resourceClaims
+	return claims, nil
+}
+
+// checkMinRole denies a resource whose claims do not grant its minimum role.
+func checkMinRole(resource CompositeResource, claims map[string]interface{}) error {
+	if resource.MinRole == role.None {
+		return nil
+	}
+	if datasetClaim, ok := claims[coreAuthorizer.LabelDatasetClaim].(*dataset.Claim); ok {
+		if !datasetClaim.Role.Implies(resource.MinRole) {
+			return NewDenyError(ReasonInsufficientRole, fmt.Errorf("resource %s: dataset role %s is less than the required %s",
+				resource.Name, datasetClaim.Role, resource.MinRole))
+		}
+		return nil
+	}
+	if orgClaim, ok := claims[coreAuthorizer.LabelOrganizationClaim].(*organization.Claim); ok {
+		if !orgClaim.HasRole(resource.MinRole) {
+			return NewDenyError(ReasonInsufficientRole, fmt.Errorf("resource %s: organization role %s is less than the required %s",
+				resource.Name, orgClaim.Role, resource.MinRole))
+		}
+		return nil
+	}
+	return NewDenyError(ReasonInsufficientRole, fmt.Errorf("resource %s: no dataset or organization claim to check the required %s against",
+		resource.Name, resource.MinRole))
+}
@@ -0,0 +1,157 @@
+package authorizers_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/authorizers"
+	claimsClient "github.com/pennsieve/pennsieve-go-api/authorizer/claims"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/factory"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/test"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/test/mocks"
+	coreAuthorizer "github.com/pennsieve/pennsieve-go-core/pkg/authorizer"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dataset"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/organization"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/role"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/teamUser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// compositeFixture mocks two datasets in the same organization, on which the user has the given roles.
+type compositeFixture struct {
+	params        *mocks.ClaimsManagerParams
+	sourceDataset *dataset.Claim
+	targetDataset *dataset.Claim
+	orgClaim      *organization.Claim
+}
+
+func newCompositeFixture(t *testing.T, sourceRole role.Role, targetRole role.Role) compositeFixture {
+	params := mocks.NewClaimsManagerParams(t)
+	currentUser := test.NewUser(101, 1001)
+	params.WithUserQueryMocked(t, currentUser)
+
+	orgId := int64(2001)
+	f := compositeFixture{
+		params:        params,
+		sourceDataset: &dataset.Claim{Role: sourceRole, NodeId: fmt.Sprintf("N:dataset:%s", uuid.NewString()), IntId: 1},
+		targetDataset: &dataset.Claim{Role: targetRole, NodeId: fmt.Sprintf("N:dataset:%s", uuid.NewString()), IntId: 2},
+		orgClaim:      &organization.Claim{Role: pgdb.Read, IntId: orgId, NodeId: fmt.Sprintf("N:organization:%s", uuid.NewString())},
+	}
+	params.MockPennsievePg.OnGetOrganizationClaim(currentUser.Id, orgId).Return(f.orgClaim, nil)
+	for _, claim := range []*dataset.Claim{f.sourceDataset, f.targetDataset} {
+		params.MockPennsievePg.OnGetOrganizationIdForDataset(claim.NodeId).Return(orgId, nil)
+		params.MockPennsievePg.OnGetDatasetClaim(currentUser, claim.NodeId, orgId).Return(claim, nil)
+		params.MockPennsievePg.OnGetDatasetState(claim.NodeId, orgId).Return(&claimsClient.DatasetState{}, nil)
+		params.MockPennsievePg.OnGetDataUseAgreementStatus(currentUser.Id, claim.NodeId, orgId).Return(&manager.DataUseAgreementStatus{}, nil)
+	}
+	return f
+}
+
+func (f compositeFixture) authorizer(targetMinRole role.Role) authorizers.Authorizer {
+	return authorizers.NewCompositeAuthorizer(
+		authorizers.CompositeResource{Name: "source_dataset_id", Authorizer: authorizers.NewDatasetAuthorizer(f.sourceDataset.NodeId), MinRole: role.Viewer},
+		authorizers.CompositeResource{Name: "target_dataset_id", Authorizer: authorizers.NewDatasetAuthorizer(f.targetDataset.NodeId), MinRole: targetMinRole},
+	)
+}
+
+func TestCompositeAuthorizer(t *testing.T) {
+	f := newCompositeFixture(t, role.Viewer, role.Editor)
+
+	claims, err := f.authorizer(role.Editor).GenerateClaims(context.Background(), f.params.BuildClaimsManager(), "")
+	require.NoError(t, err)
+
+	assert.Equal(t, f.sourceDataset, claims[coreAuthorizer.LabelDatasetClaim])
+	assert.Equal(t, f.orgClaim, claims[coreAuthorizer.LabelOrganizationClaim])
+	resourceClaims, ok := claims[claimsClient.LabelResourceClaims].(map[string]map[string]interface{})
+	require.True(t, ok)
+	assert.Equal(t, f.sourceDataset, resourceClaims["source_dataset_id"][coreAuthorizer.LabelDatasetClaim])
+	assert.Equal(t, f.targetDataset, resourceClaims["target_dataset_id"][coreAuthorizer.LabelDatasetClaim])
+	f.params.AssertMockExpectations(t)
+}
+
+func TestCompositeAuthorizerInsufficientRole(t *testing.T) {
+	f := newCompositeFixture(t, role.Viewer, role.Viewer)
+
+	claims, err := f.authorizer(role.Editor).GenerateClaims(context.Background(), f.params.BuildClaimsManager(), "")
+
+	assert.Nil(t, claims)
+	assert.Equal(t, authorizers.ReasonInsufficientRole, authorizers.DenyReason(err))
+	assert.ErrorContains(t, err, "target_dataset_id")
+}
+
+func TestCompositeAuthorizerFromDefaultRegistry(t *testing.T) {
+	for scenario, params := range map[string]struct {
+		targetRole     role.Role
+		expectedReason string
+	}{
+		"editor on the target": {role.Editor, ""},
+		"viewer on the target": {role.Viewer, authorizers.ReasonInsufficientRole},
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			f := newCompositeFixture(t, role.Viewer, params.targetRole)
+			authorizer, err := factory.NewCustomAuthorizerFactory().Build(factory.Event{
+				IdentitySource: []string{"Bearer eyJra.some.random.string", f.sourceDataset.NodeId, f.targetDataset.NodeId},
+				QueryStringParameters: map[string]string{
+					"source_dataset_id": f.sourceDataset.NodeId,
+					"target_dataset_id": f.targetDataset.NodeId,
+				},
+			})
+			require.NoError(t, err)
+
+			claims, err := authorizer.GenerateClaims(context.Background(), f.params.BuildClaimsManager(), "")
+			if params.expectedReason != "" {
+				assert.Nil(t, claims)
+				assert.Equal(t, params.expectedReason, authorizers.DenyReason(err))
+				return
+			}
+			require.NoError(t, err)
+			resourceClaims, ok := claims[claimsClient.LabelResourceClaims].(map[string]map[string]interface{})
+			require.True(t, ok)
+			assert.Equal(t, f.targetDataset, resourceClaims["target_dataset_id"][coreAuthorizer.LabelDatasetClaim])
+			f.params.AssertMockExpectations(t)
+		})
+	}
+}
+
+func TestCompositeAuthorizerResourceDenied(t *testing.T) {
+	params := mocks.NewClaimsManagerParams(t)
+	currentUser := test.NewUser(101, 1001)
+	params.WithUserQueryMocked(t, currentUser)
+	orgClaim := &organization.Claim{Role: pgdb.Read, IntId: 2001, NodeId: fmt.Sprintf("N:organization:%s", uuid.NewString())}
+	missingDatasetNodeId := fmt.Sprintf("N:dataset:%s", uuid.NewString())
+	params.MockPennsievePg.OnGetOrganizationClaimByNodeId(currentUser.Id, orgClaim.NodeId).Return(orgClaim, nil)
+	params.MockPennsievePg.OnGetTeamClaimsForOrg(currentUser.Id, orgClaim.IntId).Return([]teamUser.Claim(nil), errors.New("connection refused"))
+
+	authorizer := authorizers.NewCompositeAuthorizer(
+		authorizers.CompositeResource{Name: "collection_org_id", Authorizer: authorizers.NewWorkspaceAuthorizer(orgClaim.NodeId)},
+		authorizers.CompositeResource{Name: "dataset_id", Authorizer: authorizers.NewDatasetAuthorizer(missingDatasetNodeId)},
+	)
+	claims, err := authorizer.GenerateClaims(context.Background(), params.BuildClaimsManager(), "")
+
+	// The first failing resource ends authorization; later resources are not looked up.
+	assert.Nil(t, claims)
+	assert.ErrorContains(t, err, "resource collection_org_id")
+	assert.ErrorContains(t, err, "connection refused")
+	params.MockPennsievePg.AssertNotCalled(t, "GetOrganizationIdForDataset", mock.Anything, missingDatasetNodeId)
+}
+
+func TestCompositeAuthorizerNoResources(t *testing.T) {
+	params := mocks.NewClaimsManagerParams(t)
+
+	claims, err := authorizers.NewCompositeAuthorizer().GenerateClaims(context.Background(), params.BuildClaimsManager(), "")
+
+	assert.Nil(t, claims)
+	assert.Equal(t, authorizers.ReasonNoResources, authorizers.DenyReason(err))
+	params.AssertMockExpectations(t)
+}
+
+func TestCompositeAuthorizerScope(t *testing.T) {
+	assert.Equal(t, "composite", authorizers.Scope(authorizers.NewCompositeAuthorizer()))
+}
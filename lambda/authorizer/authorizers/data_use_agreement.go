@@ -0,0 +1,35 @@
+package authorizers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
+)
+
+// DataUseAgreementLookup is the subset of manager.IdentityManager needed to enforce data-use agreements.
+type DataUseAgreementLookup interface {
+	GetDataUseAgreementStatus(ctx context.Context, userId int64, datasetId string, orgId int64) (*manager.DataUseAgreementStatus, error)
+}
+
+// CheckDataUseAgreement denies the request if the dataset requires its data-use agreement to be accepted, as
+// datasets carrying PHI do, and the user has not accepted it, or if the route's decisions are cached, so that an
+// acceptance takes effect at once and the deny's reason, ReasonDuaRequired, is never replayed from the cache. Every
+// request is checked, including reads, direct invocations and those of super-admins.
+func CheckDataUseAgreement(ctx context.Context, lookup DataUseAgreementLookup, userId int64, datasetId string, orgId int64) error {
+	status, err := lookup.GetDataUseAgreementStatus(ctx, userId, datasetId, orgId)
+	if err != nil {
+		return NewIndeterminateError(fmt.Errorf("unable to get data-use agreement status of dataset %s: %w", datasetId, err))
+	}
+	if !status.Required {
+		return nil
+	}
+	if request, ok := manager.RequestFromContext(ctx); ok && !request.Uncached {
+		return NewDenyError(ReasonUncachedAuthorizerRequired, fmt.Errorf("dataset %s requires its data-use agreement to be accepted and a route whose authorizer decisions are not cached", datasetId))
+	}
+	if !status.Accepted {
+		return NewDenyError(ReasonDuaRequired, fmt.Errorf("user %d has not accepted data-use agreement %d of dataset %s",
+			userId, status.AgreementId, datasetId))
+	}
+	return nil
+}
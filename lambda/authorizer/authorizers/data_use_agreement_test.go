@@ -0,0 +1,62 @@
+package authorizers_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/pennsieve/pennsieve-go-api/authorizer/authorizers"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/test/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckDataUseAgreement(t *testing.T) {
+	userId := int64(101)
+	orgId := int64(1001)
+	datasetNodeId := "N:dataset:1"
+
+	for scenario, params := range map[string]struct {
+		status *manager.DataUseAgreementStatus
+		// request is the API Gateway request, if any.
+		request        *manager.Request
+		lookupErr      error
+		expectedReason string
+		indeterminate  bool
+	}{
+		"no agreement required":            {status: &manager.DataUseAgreementStatus{}},
+		"no agreement required, cached":    {status: &manager.DataUseAgreementStatus{}, request: &manager.Request{}},
+		"agreement accepted":               {status: &manager.DataUseAgreementStatus{Required: true, AgreementId: 7, Accepted: true}},
+		"agreement accepted, uncached":     {status: &manager.DataUseAgreementStatus{Required: true, AgreementId: 7, Accepted: true}, request: &manager.Request{Uncached: true}},
+		"agreement accepted, cached":       {status: &manager.DataUseAgreementStatus{Required: true, AgreementId: 7, Accepted: true}, request: &manager.Request{}, expectedReason: authorizers.ReasonUncachedAuthorizerRequired},
+		"agreement not accepted":           {status: &manager.DataUseAgreementStatus{Required: true, AgreementId: 7}, expectedReason: authorizers.ReasonDuaRequired},
+		"agreement not accepted, uncached": {status: &manager.DataUseAgreementStatus{Required: true, AgreementId: 7}, request: &manager.Request{Uncached: true}, expectedReason: authorizers.ReasonDuaRequired},
+		"agreement not accepted, cached":   {status: &manager.DataUseAgreementStatus{Required: true, AgreementId: 7}, request: &manager.Request{}, expectedReason: authorizers.ReasonUncachedAuthorizerRequired},
+		"lookup failure":                   {status: (*manager.DataUseAgreementStatus)(nil), lookupErr: errors.New("connection refused"), indeterminate: true},
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			mockPg := mocks.NewMockPennsievePgAPI()
+			mockPg.OnGetDataUseAgreementStatus(userId, datasetNodeId, orgId).Return(params.status, params.lookupErr)
+			ctx := context.Background()
+			if params.request != nil {
+				ctx = manager.WithRequest(ctx, *params.request)
+			}
+
+			err := authorizers.CheckDataUseAgreement(ctx, mockPg, userId, datasetNodeId, orgId)
+
+			switch {
+			case params.indeterminate:
+				var indeterminate *authorizers.IndeterminateError
+				assert.ErrorAs(t, err, &indeterminate)
+			case params.expectedReason == authorizers.ReasonDuaRequired:
+				assert.Equal(t, params.expectedReason, authorizers.DenyReason(err))
+				assert.ErrorContains(t, err, "data-use agreement 7")
+			case params.expectedReason != "":
+				assert.Equal(t, params.expectedReason, authorizers.DenyReason(err))
+			default:
+				assert.NoError(t, err)
+			}
+			mockPg.AssertExpectations(t)
+		})
+	}
+}
@@ -2,12 +2,17 @@ package authorizers
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 
+	claimsClient "github.com/pennsieve/pennsieve-go-api/authorizer/claims"
 	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
 	coreAuthorizer "github.com/pennsieve/pennsieve-go-core/pkg/authorizer"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/organization"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/role"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/teamUser"
 	corePgdb "github.com/pennsieve/pennsieve-go-core/pkg/queries/pgdb"
 )
 
@@ -21,9 +26,9 @@ func NewDatasetAuthorizer(datasetId string) Authorizer {
 
 func (d *DatasetAuthorizer) GenerateClaims(ctx context.Context, claimsManager manager.IdentityManager, authorizerMode string) (map[string]interface{}, error) {
 	// Get current user
-	currentUser, err := claimsManager.GetCurrentUser(ctx)
+	currentUser, err := getCurrentUser(ctx, claimsManager)
 	if err != nil {
-		return nil, fmt.Errorf("unable to get current user: %w", err)
+		return nil, err
 	}
 
 	// Always resolve the dataset's org from the request via the dataset_organization map,
@@ -33,7 +38,7 @@ func (d *DatasetAuthorizer) GenerateClaims(ctx context.Context, claimsManager ma
 		var notFound corePgdb.DatasetOrganizationNotFoundError
 		if errors.As(err, &notFound) {
 			// Genuine map miss: the dataset doesn't exist. Clean, cacheable deny.
-			return nil, fmt.Errorf("no organization found for dataset %s: %w", d.DatasetId, err)
+			return nil, NewDenyError(ReasonDatasetNotFound, fmt.Errorf("no organization found for dataset %s: %w", d.DatasetId, err))
 		}
 		// DB/connection failure resolving the map: indeterminate, must not be cached as a deny.
 		return nil, NewIndeterminateError(fmt.Errorf("unable to resolve organization for dataset %s: %w", d.DatasetId, err))
@@ -44,20 +49,27 @@ func (d *DatasetAuthorizer) GenerateClaims(ctx context.Context, claimsManager ma
 	// has access to that dataset in its actual org.
 	if tokenWorkspace, hasTokenWorkspace := claimsManager.GetTokenWorkspace(); hasTokenWorkspace {
 		if tokenWorkspace.Id != orgInt {
-			return nil, fmt.Errorf("token workspace %d does not match organization %d for dataset %s",
-				tokenWorkspace.Id, orgInt, d.DatasetId)
+			return nil, NewDenyError(ReasonTokenWorkspaceMismatch, fmt.Errorf("token workspace %d does not match organization %d for dataset %s",
+				tokenWorkspace.Id, orgInt, d.DatasetId))
 		}
 	}
 
+	if err := CheckSourceIp(ctx, claimsManager, currentUser, orgInt); err != nil {
+		return nil, err
+	}
+	if err := CheckAuthPolicy(ctx, claimsManager, orgInt); err != nil {
+		return nil, err
+	}
+
 	// Get Workspace Claim
 	orgClaim, err := claimsManager.GetOrgClaim(ctx, currentUser.Id, orgInt)
 	if err != nil {
 		var notOrgMember corePgdb.OrganizationUserNotFoundError
 		if errors.As(err, &notOrgMember) {
-			// The user is not a member of this org: a clean, authoritative (cacheable) deny,
-			// not a DB failure. GetOrganizationClaim's inner join returns this error rather
-			// than a NoPermission claim when there's no organization_user row for the user.
-			return nil, fmt.Errorf("user has no access to organization %d: %w", orgInt, err)
+			// The user is not a member of this org. GetOrganizationClaim's inner join returns this
+			// error rather than a NoPermission claim when there's no organization_user row for the
+			// user. They may still be an external collaborator with a grant on the dataset itself.
+			return d.externalCollaboratorClaims(ctx, claimsManager, currentUser, orgInt, authorizerMode, err)
 		}
 		return nil, NewIndeterminateError(fmt.Errorf("unable to get Organization Role: %w", err))
 	}
@@ -69,12 +81,27 @@ func (d *DatasetAuthorizer) GenerateClaims(ctx context.Context, claimsManager ma
 	}
 	// If user has no role on provided dataset --> return
 	if datasetClaim.Role == role.None {
-		return nil, errors.New("user has no access to dataset")
+		return nil, NewDenyError(ReasonNoDatasetRole, errors.New("user has no access to dataset"))
+	}
+
+	datasetState, err := checkDataset(ctx, claimsManager, currentUser, d.DatasetId, orgInt)
+	if err != nil {
+		return nil, err
 	}
 
 	// Get User Claim
 	userClaim := claimsManager.GetUserClaim(ctx, currentUser)
 
+	claims := map[string]interface{}{
+		coreAuthorizer.LabelUserClaim:         userClaim,
+		coreAuthorizer.LabelOrganizationClaim: orgClaim,
+		coreAuthorizer.LabelDatasetClaim:      datasetClaim,
+		claimsClient.LabelDatasetState:        datasetState,
+	}
+	if err := AddGrantExpiry(ctx, claimsManager, claims, currentUser.Id, d.DatasetId, orgInt); err != nil {
+		return nil, err
+	}
+
 	if authorizerMode == "LEGACY" {
 		// Get Publisher's Claim
 		teamClaims, err := claimsManager.GetTeamClaimsForOrg(ctx, currentUser.Id, orgInt)
@@ -82,18 +109,65 @@ func (d *DatasetAuthorizer) GenerateClaims(ctx context.Context, claimsManager ma
 			return nil, NewIndeterminateError(fmt.Errorf("unable to get Team Claims for user: %d organization: %d: %w",
 				currentUser.Id, orgInt, err))
 		}
+		claims[coreAuthorizer.LabelTeamClaims] = teamClaims
+	}
+	return claims, nil
+}
+
+// checkDataset applies the checks every user with a role on the dataset must pass, members, external collaborators
+// and manifest requests alike: the dataset's data-use agreement must have been accepted, a locked dataset must not
+// be modified, and a protected one must be requested for a purpose of use. It returns the dataset's state.
+func checkDataset(ctx context.Context, claimsManager manager.IdentityManager, currentUser *pgdb.User, datasetId string, orgInt int64) (*claimsClient.DatasetState, error) {
+	if err := CheckDataUseAgreement(ctx, claimsManager, currentUser.Id, datasetId, orgInt); err != nil {
+		return nil, err
+	}
+	datasetState, err := CheckDatasetState(ctx, claimsManager, datasetId, orgInt)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckPurposeOfUse(ctx, datasetId, datasetState); err != nil {
+		return nil, err
+	}
+	return datasetState, nil
+}
 
-		return map[string]interface{}{
-			coreAuthorizer.LabelUserClaim:         userClaim,
-			coreAuthorizer.LabelOrganizationClaim: orgClaim,
-			coreAuthorizer.LabelDatasetClaim:      datasetClaim,
-			coreAuthorizer.LabelTeamClaims:        teamClaims,
-		}, nil
+// externalCollaboratorClaims returns the claims of a user who is not a member of the dataset's organization, which
+// they only have if the dataset was shared with them directly. Their organization claim grants no role in the
+// organization, they have no teams there, and only the expiry of their grant on the dataset applies.
+func (d *DatasetAuthorizer) externalCollaboratorClaims(ctx context.Context, claimsManager manager.IdentityManager, currentUser *pgdb.User,
+	orgInt int64, authorizerMode string, notOrgMember error) (map[string]interface{}, error) {
+	grant, err := claimsManager.GetExternalDatasetGrant(ctx, currentUser.Id, d.DatasetId, orgInt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// Neither a member nor a collaborator: a clean, authoritative (cacheable) deny, not a DB failure.
+			return nil, NewDenyError(ReasonNotOrgMember, fmt.Errorf("user has no access to organization %d: %w", orgInt, notOrgMember))
+		}
+		return nil, NewIndeterminateError(fmt.Errorf("unable to get external grant on dataset %s: %w", d.DatasetId, err))
+	}
+	if grant.Dataset.Role == role.None {
+		return nil, NewDenyError(ReasonNoDatasetRole, errors.New("user has no access to dataset"))
+	}
+	datasetState, err := checkDataset(ctx, claimsManager, currentUser, d.DatasetId, orgInt)
+	if err != nil {
+		return nil, err
 	}
 
-	return map[string]interface{}{
-		coreAuthorizer.LabelUserClaim:         userClaim,
-		coreAuthorizer.LabelOrganizationClaim: orgClaim,
-		coreAuthorizer.LabelDatasetClaim:      datasetClaim,
-	}, nil
+	claims := map[string]interface{}{
+		coreAuthorizer.LabelUserClaim: claimsManager.GetUserClaim(ctx, currentUser),
+		coreAuthorizer.LabelOrganizationClaim: &organization.Claim{
+			Role:   pgdb.NoPermission,
+			IntId:  orgInt,
+			NodeId: grant.OrganizationNodeId,
+		},
+		coreAuthorizer.LabelDatasetClaim:       &grant.Dataset,
+		claimsClient.LabelExternalCollaborator: true,
+		claimsClient.LabelDatasetState:         datasetState,
+	}
+	if grant.ExpiresAt != nil {
+		claims[claimsClient.LabelGrantExpiry] = &claimsClient.GrantExpiry{Dataset: grant.ExpiresAt}
+	}
+	if authorizerMode == "LEGACY" {
+		claims[coreAuthorizer.LabelTeamClaims] = []teamUser.Claim{}
+	}
+	return claims, nil
 }
@@ -2,9 +2,11 @@ package authorizers_test
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	claimsClient "github.com/pennsieve/pennsieve-go-api/authorizer/claims"
 	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
 	"github.com/pennsieve/pennsieve-go-api/authorizer/test"
 	"github.com/pennsieve/pennsieve-go-api/authorizer/test/mocks"
@@ -17,6 +19,7 @@ import (
 	corePgdb "github.com/pennsieve/pennsieve-go-core/pkg/queries/pgdb"
 	"github.com/stretchr/testify/require"
 	"testing"
+	"time"
 
 	"github.com/pennsieve/pennsieve-go-api/authorizer/authorizers"
 	"github.com/stretchr/testify/assert"
@@ -73,6 +76,8 @@ func testDatasetGenerateClaims(t *testing.T, managerParams *mocks.ClaimsManagerP
 	managerParams.MockPennsievePg.OnGetOrganizationIdForDataset(datasetNodeId).Return(expectedOrgId, nil)
 	managerParams.MockPennsievePg.OnGetOrganizationClaim(currentUser.Id, expectedOrgId).Return(orgClaim, nil)
 	managerParams.MockPennsievePg.OnGetDatasetClaim(currentUser, datasetNodeId, expectedOrgId).Return(datasetClaim, nil)
+	managerParams.MockPennsievePg.OnGetDatasetState(datasetNodeId, expectedOrgId).Return(&claimsClient.DatasetState{}, nil)
+	managerParams.MockPennsievePg.OnGetDataUseAgreementStatus(currentUser.Id, datasetNodeId, expectedOrgId).Return(&manager.DataUseAgreementStatus{}, nil)
 
 	// Test
 	authorizer := authorizers.NewDatasetAuthorizer(datasetNodeId)
@@ -81,7 +86,7 @@ func testDatasetGenerateClaims(t *testing.T, managerParams *mocks.ClaimsManagerP
 	// Checking results
 	require.NoError(t, err)
 
-	assert.Equal(t, 3, len(claims))
+	assert.Equal(t, 4, len(claims))
 	assert.Equal(t,
 		expectedUserClaim(currentUser),
 		claims[coreAuthorizer.LabelUserClaim])
@@ -119,6 +124,8 @@ func testDatasetGenerateClaimsLegacy(t *testing.T, managerParams *mocks.ClaimsMa
 	managerParams.MockPennsievePg.OnGetOrganizationIdForDataset(datasetNodeId).Return(expectedOrgId, nil)
 	managerParams.MockPennsievePg.OnGetOrganizationClaim(currentUser.Id, expectedOrgId).Return(orgClaim, nil)
 	managerParams.MockPennsievePg.OnGetDatasetClaim(currentUser, datasetNodeId, expectedOrgId).Return(datasetClaim, nil)
+	managerParams.MockPennsievePg.OnGetDatasetState(datasetNodeId, expectedOrgId).Return(&claimsClient.DatasetState{}, nil)
+	managerParams.MockPennsievePg.OnGetDataUseAgreementStatus(currentUser.Id, datasetNodeId, expectedOrgId).Return(&manager.DataUseAgreementStatus{}, nil)
 	managerParams.MockPennsievePg.OnGetTeamClaimsForOrg(currentUser.Id, expectedOrgId).Return(teamClaims, nil)
 
 	// Test
@@ -128,7 +135,7 @@ func testDatasetGenerateClaimsLegacy(t *testing.T, managerParams *mocks.ClaimsMa
 	// Checking results
 	require.NoError(t, err)
 
-	assert.Equal(t, 4, len(claims))
+	assert.Equal(t, 5, len(claims))
 	assert.Equal(t,
 		expectedUserClaim(currentUser),
 		claims[coreAuthorizer.LabelUserClaim])
@@ -170,6 +177,7 @@ func testDatasetGenerateClaimsNoDatasetPermission(t *testing.T, managerParams *m
 
 	// Checking results: a clean, authoritative (cacheable) deny, not indeterminate.
 	assert.ErrorContains(t, err, "user has no access to dataset")
+	assert.Equal(t, authorizers.ReasonNoDatasetRole, authorizers.DenyReason(err))
 	assertNotIndeterminate(t, err)
 }
 
@@ -201,6 +209,8 @@ func TestDatasetOrgDoesNotMatchPreferredOrg(t *testing.T) {
 	managerParams.MockPennsievePg.OnGetOrganizationIdForDataset(datasetNodeId).Return(datasetOrgId, nil)
 	managerParams.MockPennsievePg.OnGetOrganizationClaim(currentUser.Id, datasetOrgId).Return(orgClaim, nil)
 	managerParams.MockPennsievePg.OnGetDatasetClaim(currentUser, datasetNodeId, datasetOrgId).Return(datasetClaim, nil)
+	managerParams.MockPennsievePg.OnGetDatasetState(datasetNodeId, datasetOrgId).Return(&claimsClient.DatasetState{}, nil)
+	managerParams.MockPennsievePg.OnGetDataUseAgreementStatus(currentUser.Id, datasetNodeId, datasetOrgId).Return(&manager.DataUseAgreementStatus{}, nil)
 
 	// Test
 	authorizer := authorizers.NewDatasetAuthorizer(datasetNodeId)
@@ -208,7 +218,7 @@ func TestDatasetOrgDoesNotMatchPreferredOrg(t *testing.T) {
 
 	// Checking results
 	require.NoError(t, err)
-	assert.Equal(t, 3, len(claims))
+	assert.Equal(t, 4, len(claims))
 	assert.Equal(t, orgClaim, claims[coreAuthorizer.LabelOrganizationClaim])
 	assert.Equal(t, datasetClaim, claims[coreAuthorizer.LabelDatasetClaim])
 	managerParams.AssertMockExpectations(t)
@@ -239,6 +249,7 @@ func TestDatasetOrgDoesNotMatchTokenOrg(t *testing.T) {
 	assert.Nil(t, claims)
 	assert.ErrorContains(t, err,
 		fmt.Sprintf("token workspace %d does not match organization %d", tokenWorkspace.Id, datasetOrgId))
+	assert.Equal(t, authorizers.ReasonTokenWorkspaceMismatch, authorizers.DenyReason(err))
 	assertNotIndeterminate(t, err)
 	managerParams.AssertMockExpectations(t)
 }
@@ -259,6 +270,7 @@ func TestDatasetOrganizationMapMiss(t *testing.T) {
 
 	assert.Nil(t, claims)
 	require.Error(t, err)
+	assert.Equal(t, authorizers.ReasonDatasetNotFound, authorizers.DenyReason(err))
 	assertNotIndeterminate(t, err)
 	managerParams.AssertMockExpectations(t)
 }
@@ -318,16 +330,87 @@ func TestDatasetUserNotOrgMember(t *testing.T) {
 	managerParams.MockPennsievePg.OnGetOrganizationIdForDataset(datasetNodeId).Return(expectedOrgId, nil)
 	managerParams.MockPennsievePg.OnGetOrganizationClaim(currentUser.Id, expectedOrgId).
 		Return((*organization.Claim)(nil), corePgdb.OrganizationUserNotFoundError{ErrorMessage: "no rows"})
+	managerParams.MockPennsievePg.OnGetExternalDatasetGrant(currentUser.Id, datasetNodeId, expectedOrgId).
+		Return((*manager.ExternalGrant)(nil), sql.ErrNoRows)
 
 	authorizer := authorizers.NewDatasetAuthorizer(datasetNodeId)
 	claims, err := authorizer.GenerateClaims(context.Background(), claimsManager, "")
 
 	assert.Nil(t, claims)
 	require.Error(t, err)
+	assert.Equal(t, authorizers.ReasonNotOrgMember, authorizers.DenyReason(err))
 	assertNotIndeterminate(t, err)
 	managerParams.AssertMockExpectations(t)
 }
 
+// TestDatasetExternalCollaborator: a user who is not a member of the dataset's org, but to whom the
+// dataset was shared directly, gets the dataset claim and an org claim with no role in the org.
+func TestDatasetExternalCollaborator(t *testing.T) {
+	for _, authorizerMode := range []string{"", "LEGACY"} {
+		t.Run(fmt.Sprintf("mode %q", authorizerMode), func(t *testing.T) {
+			managerParams := mocks.NewClaimsManagerParams(t)
+			currentUser := test.NewUser(101, 1001)
+			claimsManager := managerParams.WithUserQueryMocked(t, currentUser).BuildClaimsManager()
+
+			datasetNodeId := fmt.Sprintf("N:dataset:%s", uuid.NewString())
+			datasetOrgId := int64(6001)
+			expiresAt := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+			grant := &manager.ExternalGrant{
+				OrganizationNodeId: fmt.Sprintf("N:organization:%s", uuid.NewString()),
+				Dataset:            dataset.Claim{Role: role.Editor, NodeId: datasetNodeId, IntId: 999},
+				ExpiresAt:          &expiresAt,
+			}
+			managerParams.MockPennsievePg.OnGetOrganizationIdForDataset(datasetNodeId).Return(datasetOrgId, nil)
+			managerParams.MockPennsievePg.OnGetOrganizationClaim(currentUser.Id, datasetOrgId).
+				Return((*organization.Claim)(nil), corePgdb.OrganizationUserNotFoundError{ErrorMessage: "no rows"})
+			managerParams.MockPennsievePg.OnGetExternalDatasetGrant(currentUser.Id, datasetNodeId, datasetOrgId).Return(grant, nil)
+			managerParams.MockPennsievePg.OnGetDatasetState(datasetNodeId, datasetOrgId).Return(&claimsClient.DatasetState{}, nil)
+			managerParams.MockPennsievePg.OnGetDataUseAgreementStatus(currentUser.Id, datasetNodeId, datasetOrgId).Return(&manager.DataUseAgreementStatus{}, nil)
+
+			authorizer := authorizers.NewDatasetAuthorizer(datasetNodeId)
+			claims, err := authorizer.GenerateClaims(context.Background(), claimsManager, authorizerMode)
+
+			require.NoError(t, err)
+			assert.Equal(t, expectedUserClaim(currentUser), claims[coreAuthorizer.LabelUserClaim])
+			assert.Equal(t, &grant.Dataset, claims[coreAuthorizer.LabelDatasetClaim])
+			assert.Equal(t, &organization.Claim{Role: pgdb.NoPermission, IntId: datasetOrgId, NodeId: grant.OrganizationNodeId},
+				claims[coreAuthorizer.LabelOrganizationClaim])
+			assert.Equal(t, true, claims[claimsClient.LabelExternalCollaborator])
+			assert.Equal(t, &claimsClient.GrantExpiry{Dataset: &expiresAt}, claims[claimsClient.LabelGrantExpiry])
+			if authorizerMode == "LEGACY" {
+				assert.Empty(t, claims[coreAuthorizer.LabelTeamClaims])
+				assert.Contains(t, claims, coreAuthorizer.LabelTeamClaims)
+			}
+			managerParams.MockPennsievePg.AssertNotCalled(t, "GetDatasetClaim")
+			managerParams.AssertMockExpectations(t)
+		})
+	}
+}
+
+// TestDatasetExternalGrantDBError: a DB failure looking up a non-member's grant on the dataset is
+// indeterminate, not a deny.
+func TestDatasetExternalGrantDBError(t *testing.T) {
+	managerParams := mocks.NewClaimsManagerParams(t)
+	currentUser := test.NewUser(101, 1001)
+	claimsManager := managerParams.WithUserQueryMocked(t, currentUser).BuildClaimsManager()
+
+	datasetNodeId := fmt.Sprintf("N:dataset:%s", uuid.NewString())
+	expectedOrgId := managerParams.GetExpectedOrgId(currentUser)
+	managerParams.MockPennsievePg.OnGetOrganizationIdForDataset(datasetNodeId).Return(expectedOrgId, nil)
+	managerParams.MockPennsievePg.OnGetOrganizationClaim(currentUser.Id, expectedOrgId).
+		Return((*organization.Claim)(nil), corePgdb.OrganizationUserNotFoundError{ErrorMessage: "no rows"})
+	managerParams.MockPennsievePg.OnGetExternalDatasetGrant(currentUser.Id, datasetNodeId, expectedOrgId).
+		Return((*manager.ExternalGrant)(nil), errors.New("connection refused"))
+
+	authorizer := authorizers.NewDatasetAuthorizer(datasetNodeId)
+	claims, err := authorizer.GenerateClaims(context.Background(), claimsManager, "")
+
+	assert.Nil(t, claims)
+	require.Error(t, err)
+	assertIndeterminate(t, err)
+	managerParams.AssertMockExpectations(t)
+}
+
 // TestDatasetClaimDBError: a DB failure fetching the dataset role is also indeterminate.
 func TestDatasetClaimDBError(t *testing.T) {
 	managerParams := mocks.NewClaimsManagerParams(t)
@@ -380,6 +463,8 @@ func TestDatasetTeamClaimsForOrgDBError(t *testing.T) {
 	managerParams.MockPennsievePg.OnGetOrganizationIdForDataset(datasetNodeId).Return(expectedOrgId, nil)
 	managerParams.MockPennsievePg.OnGetOrganizationClaim(currentUser.Id, expectedOrgId).Return(orgClaim, nil)
 	managerParams.MockPennsievePg.OnGetDatasetClaim(currentUser, datasetNodeId, expectedOrgId).Return(datasetClaim, nil)
+	managerParams.MockPennsievePg.OnGetDatasetState(datasetNodeId, expectedOrgId).Return(&claimsClient.DatasetState{}, nil)
+	managerParams.MockPennsievePg.OnGetDataUseAgreementStatus(currentUser.Id, datasetNodeId, expectedOrgId).Return(&manager.DataUseAgreementStatus{}, nil)
 	managerParams.MockPennsievePg.OnGetTeamClaimsForOrg(currentUser.Id, expectedOrgId).
 		Return([]teamUser.Claim(nil), errors.New("connection refused"))
 
@@ -0,0 +1,40 @@
+package authorizers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	claimsClient "github.com/pennsieve/pennsieve-go-api/authorizer/claims"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/helpers"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
+)
+
+// DatasetStateLookup is the subset of manager.IdentityManager needed to gate requests on a dataset's state.
+type DatasetStateLookup interface {
+	GetDatasetState(ctx context.Context, datasetId string, orgId int64) (*claimsClient.DatasetState, error)
+}
+
+// CheckDatasetState returns the state of the dataset, or denies the request if it would modify a locked dataset:
+// one with a publication request in progress, or one that has been deleted. Read-only requests are allowed
+// whatever the state.
+//
+// Requests without request metadata in ctx (direct Lambda-to-Lambda invocations) have no method and are not
+// denied. Routes listed in Settings.DatasetLockExemptRoutes may modify locked datasets, so that the services that
+// lock and unlock them keep working.
+func CheckDatasetState(ctx context.Context, lookup DatasetStateLookup, datasetId string, orgId int64) (*claimsClient.DatasetState, error) {
+	datasetState, err := lookup.GetDatasetState(ctx, datasetId, orgId)
+	if err != nil {
+		return nil, NewIndeterminateError(fmt.Errorf("unable to get state of dataset %s: %w", datasetId, err))
+	}
+
+	request, ok := manager.RequestFromContext(ctx)
+	if !ok || helpers.IsReadOnlyMethod(request.Method) || !datasetState.Locked() || slices.Contains(settings.DatasetLockExemptRoutes, request.Route) {
+		return datasetState, nil
+	}
+	if datasetState.Deleted {
+		return nil, NewDenyError(ReasonDatasetLocked, fmt.Errorf("dataset %s has been deleted", datasetId))
+	}
+	return nil, NewDenyError(ReasonDatasetLocked, fmt.Errorf("dataset %s is locked while its publication is %s",
+		datasetId, datasetState.PublicationStatus))
+}
@@ -0,0 +1,69 @@
+package authorizers_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/pennsieve/pennsieve-go-api/authorizer/authorizers"
+	claimsClient "github.com/pennsieve/pennsieve-go-api/authorizer/claims"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/test/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckDatasetState(t *testing.T) {
+	orgId := int64(1001)
+	datasetNodeId := "N:dataset:1"
+	unlocked := &claimsClient.DatasetState{Status: "NO_STATUS", PublicationStatus: "completed"}
+	publishing := &claimsClient.DatasetState{Status: "NO_STATUS", PublicationStatus: "requested", PublicationLocked: true}
+	deleted := &claimsClient.DatasetState{Status: "NO_STATUS", Deleted: true}
+	exemptRoute := "POST /datasets/{id}/publication/cancel"
+
+	for scenario, params := range map[string]struct {
+		request        *manager.Request
+		state          *claimsClient.DatasetState
+		lookupErr      error
+		settings       authorizers.Settings
+		expectedReason string
+		indeterminate  bool
+	}{
+		"read of unlocked dataset":       {request: &manager.Request{Method: "GET"}, state: unlocked},
+		"write to unlocked dataset":      {request: &manager.Request{Method: "PUT"}, state: unlocked},
+		"read of publishing dataset":     {request: &manager.Request{Method: "GET"}, state: publishing},
+		"write to publishing dataset":    {request: &manager.Request{Method: "POST"}, state: publishing, expectedReason: authorizers.ReasonDatasetLocked},
+		"read of deleted dataset":        {request: &manager.Request{Method: "HEAD"}, state: deleted},
+		"write to deleted dataset":       {request: &manager.Request{Method: "DELETE"}, state: deleted, expectedReason: authorizers.ReasonDatasetLocked},
+		"no request metadata":            {request: nil, state: publishing},
+		"lookup failure":                 {request: &manager.Request{Method: "GET"}, lookupErr: errors.New("connection refused"), indeterminate: true},
+		"exempt route":                   {request: &manager.Request{Method: "POST", Route: exemptRoute}, state: publishing, settings: authorizers.Settings{DatasetLockExemptRoutes: []string{"GET /other", exemptRoute}}},
+		"route not among exempt routes":  {request: &manager.Request{Method: "POST", Route: "POST /datasets/{id}"}, state: publishing, settings: authorizers.Settings{DatasetLockExemptRoutes: []string{exemptRoute}}, expectedReason: authorizers.ReasonDatasetLocked},
+		"no route with exemptions given": {request: &manager.Request{Method: "POST"}, state: deleted, settings: authorizers.Settings{DatasetLockExemptRoutes: []string{exemptRoute}}, expectedReason: authorizers.ReasonDatasetLocked},
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			useSettings(t, params.settings)
+			mockPg := mocks.NewMockPennsievePgAPI()
+			mockPg.OnGetDatasetState(datasetNodeId, orgId).Return(params.state, params.lookupErr)
+			ctx := context.Background()
+			if params.request != nil {
+				ctx = manager.WithRequest(ctx, *params.request)
+			}
+
+			datasetState, err := authorizers.CheckDatasetState(ctx, mockPg, datasetNodeId, orgId)
+
+			switch {
+			case params.indeterminate:
+				var indeterminate *authorizers.IndeterminateError
+				assert.ErrorAs(t, err, &indeterminate)
+			case params.expectedReason != "":
+				assert.Nil(t, datasetState)
+				assert.Equal(t, params.expectedReason, authorizers.DenyReason(err))
+			default:
+				require.NoError(t, err)
+				assert.Equal(t, params.state, datasetState)
+			}
+			mockPg.AssertExpectations(t)
+		})
+	}
+}
@@ -1,5 +1,7 @@
 package authorizers
 
+import "errors"
+
 // IndeterminateError marks an error for which no authorization decision could be reached — a DB
 // failure, timeout, or other unexpected lookup error — as opposed to an authoritative access
 // decision (a genuine deny). The caller can propagate it as an uncached HTTP 500 instead of
@@ -20,3 +22,94 @@ func (e *IndeterminateError) Error() string {
 func (e *IndeterminateError) Unwrap() error {
 	return e.err
 }
+
+// Reason codes carried by DenyError, for denies that clients or operators need to tell apart.
+const (
+	// ReasonIpNotAllowed: the request's source IP is outside the organization's allowlist.
+	ReasonIpNotAllowed = "ip_not_allowed"
+	// ReasonThrottled: the principal has exceeded its request rate limit.
+	ReasonThrottled = "throttled"
+	// ReasonDatasetNotFound: the requested dataset does not exist.
+	ReasonDatasetNotFound = "dataset_not_found"
+	// ReasonTokenWorkspaceMismatch: an API key scoped to one organization was used for a resource in another.
+	ReasonTokenWorkspaceMismatch = "token_workspace_mismatch"
+	// ReasonNotOrgMember: the user is not a member of the organization that owns the resource.
+	ReasonNotOrgMember = "not_org_member"
+	// ReasonNoDatasetRole: the user is an organization member but has no role on the dataset.
+	ReasonNoDatasetRole = "no_dataset_role"
+	// ReasonUnknownUser: the principal does not correspond to a Pennsieve user.
+	ReasonUnknownUser = "unknown_user"
+	// ReasonOrganizationMismatch: the request names an organization that does not own the requested resource.
+	ReasonOrganizationMismatch = "organization_mismatch"
+	// ReasonInsufficientRole: the user's role on one of several requested resources is below the role it requires.
+	ReasonInsufficientRole = "insufficient_role"
+	// ReasonDatasetLocked: the request would modify a dataset that is being published or has been deleted.
+	ReasonDatasetLocked = "dataset_locked"
+	// ReasonDuaRequired: the dataset requires its data-use agreement to be accepted, and the user has not accepted it.
+	ReasonDuaRequired = "dua_required"
+	// ReasonManifestNotFound: the requested manifest does not exist.
+	ReasonManifestNotFound = "manifest_not_found"
+	// ReasonIdentityProviderNotAllowed: the user signed in through an identity provider the organization does not allow.
+	ReasonIdentityProviderNotAllowed = "identity_provider_not_allowed"
+	// ReasonApiTokensDisabled: an API token was used for an organization that has disabled them.
+	ReasonApiTokensDisabled = "api_tokens_disabled"
+	// ReasonCallbackTokensDisabled: a callback token was used for an organization that has disabled them.
+	ReasonCallbackTokensDisabled = "callback_tokens_disabled"
+	// ReasonBreakGlassNotAllowed: the principal, request or justification does not qualify for break-glass access.
+	ReasonBreakGlassNotAllowed = "break_glass_not_allowed"
+	// ReasonInvalidPurposeOfUse: the X-Purpose-Of-Use header is not one of the purposes of use a client may declare.
+	ReasonInvalidPurposeOfUse = "invalid_purpose_of_use"
+	// ReasonPurposeOfUseRequired: a dataset holding protected health information was requested without a purpose.
+	ReasonPurposeOfUseRequired = "purpose_of_use_required"
+	// ReasonNoResources: a composite authorizer was given no resources to authorize, so there is nothing to allow.
+	ReasonNoResources = "no_resources"
+	// ReasonUncachedAuthorizerRequired: the request needs a route whose decisions API Gateway does not cache.
+	ReasonUncachedAuthorizerRequired = "uncached_authorizer_required"
+	// ReasonImpersonationNotAllowed: the principal may not act as another user, or not for this request.
+	ReasonImpersonationNotAllowed = "impersonation_not_allowed"
+	// ReasonBlocked: the user, API token or organization is on the block list.
+	ReasonBlocked = "blocked"
+)
+
+// DenyError marks an authoritative deny that carries a machine-readable reason code, so that the
+// handlers can log and surface why a request was denied without parsing error text.
+type DenyError struct {
+	Reason      string
+	err         error
+	uncacheable bool
+}
+
+// NewDenyError wraps err as an authoritative deny with the given reason code.
+func NewDenyError(reason string, err error) *DenyError {
+	return &DenyError{Reason: reason, err: err}
+}
+
+// NewUncacheableDenyError wraps err as a deny that must not be cached. A deny that depends on something other than
+// an identity source, such as a header, would otherwise be served to the principal's later requests for the resource
+// for up to the result TTL, whatever they send. Every deny that cannot be cached is one of these.
+func NewUncacheableDenyError(reason string, err error) *DenyError {
+	return &DenyError{Reason: reason, err: err, uncacheable: true}
+}
+
+func (e *DenyError) Error() string {
+	return e.err.Error()
+}
+
+func (e *DenyError) Unwrap() error {
+	return e.err
+}
+
+// DenyReason returns the reason code of the first DenyError in err's chain, or "" if there is none.
+func DenyReason(err error) string {
+	var denied *DenyError
+	if errors.As(err, &denied) {
+		return denied.Reason
+	}
+	return ""
+}
+
+// Uncacheable reports whether the first DenyError in err's chain must not be cached.
+func Uncacheable(err error) bool {
+	var denied *DenyError
+	return errors.As(err, &denied) && denied.uncacheable
+}
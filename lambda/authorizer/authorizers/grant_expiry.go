@@ -0,0 +1,27 @@
+package authorizers
+
+import (
+	"context"
+	"fmt"
+
+	claimsClient "github.com/pennsieve/pennsieve-go-api/authorizer/claims"
+)
+
+// GrantExpiryLookup is the subset of manager.IdentityManager needed to find when a user's roles expire.
+type GrantExpiryLookup interface {
+	GetGrantExpiry(ctx context.Context, userId int64, datasetId string, orgId int64) (*claimsClient.GrantExpiry, error)
+}
+
+// AddGrantExpiry adds to claims when the user's membership of the organization and, if datasetId is not empty, role
+// on the dataset expire, under claimsClient.LabelGrantExpiry. Nothing is added if neither expires. Expired grants
+// have already been ignored in computing the roles in claims.
+func AddGrantExpiry(ctx context.Context, lookup GrantExpiryLookup, claims map[string]interface{}, userId int64, datasetId string, orgId int64) error {
+	expiry, err := lookup.GetGrantExpiry(ctx, userId, datasetId, orgId)
+	if err != nil {
+		return NewIndeterminateError(fmt.Errorf("unable to get grant expiry for user: %d organization: %d: %w", userId, orgId, err))
+	}
+	if expiry.Expires() {
+		claims[claimsClient.LabelGrantExpiry] = expiry
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package authorizers_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pennsieve/pennsieve-go-api/authorizer/authorizers"
+	claimsClient "github.com/pennsieve/pennsieve-go-api/authorizer/claims"
+	"github.com/stretchr/testify/assert"
+)
+
+// grantExpiryLookup is a GrantExpiryLookup that returns the same expiry and error for every grant.
+type grantExpiryLookup struct {
+	expiry *claimsClient.GrantExpiry
+	err    error
+}
+
+func (l grantExpiryLookup) GetGrantExpiry(context.Context, int64, string, int64) (*claimsClient.GrantExpiry, error) {
+	return l.expiry, l.err
+}
+
+func TestAddGrantExpiry(t *testing.T) {
+	userId := int64(101)
+	orgId := int64(1001)
+	datasetNodeId := "N:dataset:1"
+	expiresAt := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+
+	for scenario, params := range map[string]struct {
+		expiry        *claimsClient.GrantExpiry
+		lookupErr     error
+		expectLabel   bool
+		indeterminate bool
+	}{
+		"nothing expires":    {expiry: &claimsClient.GrantExpiry{}},
+		"membership expires": {expiry: &claimsClient.GrantExpiry{Organization: &expiresAt}, expectLabel: true},
+		"dataset expires":    {expiry: &claimsClient.GrantExpiry{Dataset: &expiresAt}, expectLabel: true},
+		"lookup failure":     {expiry: (*claimsClient.GrantExpiry)(nil), lookupErr: errors.New("connection refused"), indeterminate: true},
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			lookup := grantExpiryLookup{expiry: params.expiry, err: params.lookupErr}
+			claims := map[string]interface{}{}
+
+			err := authorizers.AddGrantExpiry(context.Background(), lookup, claims, userId, datasetNodeId, orgId)
+
+			if params.indeterminate {
+				var indeterminate *authorizers.IndeterminateError
+				assert.ErrorAs(t, err, &indeterminate)
+			} else {
+				assert.NoError(t, err)
+			}
+			if params.expectLabel {
+				assert.Equal(t, params.expiry, claims[claimsClient.LabelGrantExpiry])
+			} else {
+				assert.NotContains(t, claims, claimsClient.LabelGrantExpiry)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package authorizers
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+
+	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
+	pgModels "github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	log "github.com/sirupsen/logrus"
+)
+
+// IpAllowlistLookup is the subset of manager.IdentityManager (and manager.PennsievePgAPI) needed to
+// enforce an organization's source IP allowlist.
+type IpAllowlistLookup interface {
+	GetOrganizationIpAllowlist(ctx context.Context, orgId int64) ([]string, error)
+}
+
+// CheckSourceIp denies the request if the organization has a source IP allowlist and the request's
+// source IP is not within it. Organizations without an allowlist accept any source IP.
+//
+// Requests without request metadata in ctx (direct Lambda-to-Lambda invocations) carry no caller
+// network identity and are not checked. A request that has metadata but no parseable source IP is
+// denied. Super-admins and callback tokens are exempt when Settings.IpAllowlistExemptSuperAdmins or
+// Settings.IpAllowlistExemptCallbacks, respectively, is set.
+func CheckSourceIp(ctx context.Context, lookup IpAllowlistLookup, currentUser *pgModels.User, orgId int64) error {
+	request, ok := manager.RequestFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	if currentUser.IsSuperAdmin && settings.IpAllowlistExemptSuperAdmins {
+		return nil
+	}
+	if request.Callback && settings.IpAllowlistExemptCallbacks {
+		return nil
+	}
+
+	allowlist, err := lookup.GetOrganizationIpAllowlist(ctx, orgId)
+	if err != nil {
+		return NewIndeterminateError(fmt.Errorf("unable to get IP allowlist for organization %d: %w", orgId, err))
+	}
+	if len(allowlist) == 0 {
+		return nil
+	}
+
+	sourceIp, err := netip.ParseAddr(request.SourceIp)
+	if err != nil {
+		return NewDenyError(ReasonIpNotAllowed, fmt.Errorf("invalid source IP %q for organization %d with IP allowlist", request.SourceIp, orgId))
+	}
+	sourceIp = sourceIp.Unmap()
+	for _, entry := range allowlist {
+		prefix, err := parseAllowlistEntry(entry)
+		if err != nil {
+			// A malformed entry can never match; the remaining entries still apply.
+			log.WithFields(log.Fields{"orgId": orgId, "entry": entry}).Warn("ignoring malformed IP allowlist entry")
+			continue
+		}
+		if prefix.Contains(sourceIp) {
+			return nil
+		}
+	}
+	return NewDenyError(ReasonIpNotAllowed, fmt.Errorf("source IP %s is not in the IP allowlist of organization %d", sourceIp, orgId))
+}
+
+// parseAllowlistEntry accepts either CIDR notation or a bare address, which is treated as a single-host prefix.
+func parseAllowlistEntry(entry string) (netip.Prefix, error) {
+	if prefix, err := netip.ParsePrefix(entry); err == nil {
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(entry)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
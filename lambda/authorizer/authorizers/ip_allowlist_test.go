@@ -0,0 +1,115 @@
+package authorizers_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/authorizers"
+	claimsClient "github.com/pennsieve/pennsieve-go-api/authorizer/claims"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/test"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/test/mocks"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dataset"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/organization"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/role"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useSettings configures the authorizers with settings for the rest of the test.
+func useSettings(t *testing.T, settings authorizers.Settings) {
+	authorizers.Configure(settings)
+	t.Cleanup(func() { authorizers.Configure(authorizers.Settings{}) })
+}
+
+func TestCheckSourceIp(t *testing.T) {
+	orgId := int64(1001)
+	allowlist := []string{"10.0.0.0/8", "192.0.2.7", "2001:db8::/32", "not-a-cidr"}
+
+	for scenario, params := range map[string]struct {
+		request        *manager.Request
+		superAdmin     bool
+		settings       authorizers.Settings
+		allowlist      []string
+		lookupErr      error
+		expectLookup   bool
+		expectedReason string
+		indeterminate  bool
+	}{
+		"no request metadata":          {request: nil},
+		"no allowlist":                 {request: &manager.Request{SourceIp: "198.51.100.1"}, allowlist: nil, expectLookup: true},
+		"ip in range":                  {request: &manager.Request{SourceIp: "10.1.2.3"}, allowlist: allowlist, expectLookup: true},
+		"single address entry":         {request: &manager.Request{SourceIp: "192.0.2.7"}, allowlist: allowlist, expectLookup: true},
+		"ipv6 in range":                {request: &manager.Request{SourceIp: "2001:db8::1"}, allowlist: allowlist, expectLookup: true},
+		"ipv4-mapped ipv6 in range":    {request: &manager.Request{SourceIp: "::ffff:10.1.2.3"}, allowlist: allowlist, expectLookup: true},
+		"ip outside range":             {request: &manager.Request{SourceIp: "198.51.100.1"}, allowlist: allowlist, expectLookup: true, expectedReason: authorizers.ReasonIpNotAllowed},
+		"missing source ip":            {request: &manager.Request{}, allowlist: allowlist, expectLookup: true, expectedReason: authorizers.ReasonIpNotAllowed},
+		"lookup failure":               {request: &manager.Request{SourceIp: "10.1.2.3"}, lookupErr: errors.New("connection refused"), expectLookup: true, indeterminate: true},
+		"super-admin not exempt":       {request: &manager.Request{SourceIp: "198.51.100.1"}, superAdmin: true, allowlist: allowlist, expectLookup: true, expectedReason: authorizers.ReasonIpNotAllowed},
+		"super-admin exempt":           {request: &manager.Request{SourceIp: "198.51.100.1"}, superAdmin: true, settings: authorizers.Settings{IpAllowlistExemptSuperAdmins: true}},
+		"callback not exempt":          {request: &manager.Request{SourceIp: "198.51.100.1", Callback: true}, allowlist: allowlist, expectLookup: true, expectedReason: authorizers.ReasonIpNotAllowed},
+		"callback exempt":              {request: &manager.Request{SourceIp: "198.51.100.1", Callback: true}, settings: authorizers.Settings{IpAllowlistExemptCallbacks: true}},
+		"callback exemption not users": {request: &manager.Request{SourceIp: "198.51.100.1"}, settings: authorizers.Settings{IpAllowlistExemptCallbacks: true}, allowlist: allowlist, expectLookup: true, expectedReason: authorizers.ReasonIpNotAllowed},
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			useSettings(t, params.settings)
+			currentUser := test.NewUser(101, orgId)
+			currentUser.IsSuperAdmin = params.superAdmin
+			mockPg := mocks.NewMockPennsievePgAPI()
+			if params.expectLookup {
+				mockPg.OnGetOrganizationIpAllowlist(orgId).Return(params.allowlist, params.lookupErr)
+			}
+			ctx := context.Background()
+			if params.request != nil {
+				ctx = manager.WithRequest(ctx, *params.request)
+			}
+
+			err := authorizers.CheckSourceIp(ctx, mockPg, currentUser, orgId)
+
+			switch {
+			case params.indeterminate:
+				var indeterminate *authorizers.IndeterminateError
+				assert.ErrorAs(t, err, &indeterminate)
+			case params.expectedReason != "":
+				assert.Equal(t, params.expectedReason, authorizers.DenyReason(err))
+			default:
+				assert.NoError(t, err)
+			}
+			mockPg.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDatasetAuthorizerIpAllowlist(t *testing.T) {
+	managerParams := mocks.NewClaimsManagerParams(t)
+	currentUser := test.NewUser(101, 1001)
+	claimsManager := managerParams.WithUserQueryMocked(t, currentUser).BuildClaimsManager()
+	datasetNodeId := fmt.Sprintf("N:dataset:%s", uuid.NewString())
+	orgId := currentUser.PreferredOrg
+	managerParams.MockPennsievePg.OnGetOrganizationIdForDataset(datasetNodeId).Return(orgId, nil)
+	managerParams.MockPennsievePg.OnGetOrganizationIpAllowlist(orgId).Return([]string{"10.0.0.0/8"}, nil)
+
+	t.Run("outside allowlist", func(t *testing.T) {
+		ctx := manager.WithRequest(context.Background(), manager.Request{SourceIp: "198.51.100.1", Method: "GET"})
+		_, err := authorizers.NewDatasetAuthorizer(datasetNodeId).GenerateClaims(ctx, claimsManager, "")
+		require.Error(t, err)
+		assert.Equal(t, authorizers.ReasonIpNotAllowed, authorizers.DenyReason(err))
+	})
+
+	t.Run("inside allowlist", func(t *testing.T) {
+		managerParams.MockPennsievePg.OnGetOrganizationAuthPolicy(orgId).Return(&manager.AuthPolicy{}, nil)
+		managerParams.MockPennsievePg.OnGetOrganizationClaim(currentUser.Id, orgId).Return(&organization.Claim{Role: pgdb.Read, IntId: orgId}, nil)
+		managerParams.MockPennsievePg.OnGetDatasetClaim(currentUser, datasetNodeId, orgId).Return(&dataset.Claim{Role: role.Viewer, NodeId: datasetNodeId, IntId: 999}, nil)
+		managerParams.MockPennsievePg.OnGetDatasetState(datasetNodeId, orgId).Return(&claimsClient.DatasetState{}, nil)
+		managerParams.MockPennsievePg.OnGetDataUseAgreementStatus(currentUser.Id, datasetNodeId, orgId).Return(&manager.DataUseAgreementStatus{}, nil)
+		ctx := manager.WithRequest(context.Background(), manager.Request{SourceIp: "10.20.30.40", Method: "GET"})
+		_, err := authorizers.NewDatasetAuthorizer(datasetNodeId).GenerateClaims(ctx, claimsManager, "")
+		assert.NoError(t, err)
+	})
+
+	managerParams.AssertMockExpectations(t)
+}
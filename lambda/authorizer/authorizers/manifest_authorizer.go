@@ -7,6 +7,7 @@ import (
 	coreAuthorizer "github.com/pennsieve/pennsieve-go-core/pkg/authorizer"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
 
+	claimsClient "github.com/pennsieve/pennsieve-go-api/authorizer/claims"
 	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/role"
 )
@@ -22,65 +23,80 @@ func NewManifestAuthorizer(manifestId string) Authorizer {
 
 func (m *ManifestAuthorizer) GenerateClaims(ctx context.Context, claimsManager manager.IdentityManager, authorizerMode string) (map[string]interface{}, error) {
 	// Get current user
-	currentUser, err := claimsManager.GetCurrentUser(ctx)
+	currentUser, err := getCurrentUser(ctx, claimsManager)
 	if err != nil {
-		return nil, fmt.Errorf("unable to get current user: %w", err)
+		return nil, err
 	}
 
 	// Get Manifest
 	manifest, err := claimsManager.GetManifest(ctx, m.ManifestID)
 	if err != nil {
-		return nil, fmt.Errorf("error getting manifest %s: %w", m.ManifestID, err)
+		var notFound manager.ManifestNotFoundError
+		if errors.As(err, &notFound) {
+			return nil, NewDenyError(ReasonManifestNotFound, err)
+		}
+		return nil, NewIndeterminateError(fmt.Errorf("error getting manifest %s: %w", m.ManifestID, err))
 	}
 	manifestOrgId := manifest.OrganizationId
 	if tokenWorkspace, hasTokenWorkspace := claimsManager.GetTokenWorkspace(); hasTokenWorkspace && tokenWorkspace.Id != manifestOrgId {
-		return nil, fmt.Errorf("manifest workspace id %d does not match API token workspace id %d",
+		return nil, NewDenyError(ReasonTokenWorkspaceMismatch, fmt.Errorf("manifest workspace id %d does not match API token workspace id %d",
 			manifestOrgId,
-			tokenWorkspace.Id)
+			tokenWorkspace.Id))
 	}
 	datasetID := manifest.DatasetNodeId
 
+	if err := CheckSourceIp(ctx, claimsManager, currentUser, manifestOrgId); err != nil {
+		return nil, err
+	}
+	if err := CheckAuthPolicy(ctx, claimsManager, manifestOrgId); err != nil {
+		return nil, err
+	}
+
 	// Get Workspace Claim
 	orgClaim, err := claimsManager.GetOrgClaim(ctx, currentUser.Id, manifestOrgId)
 	if err != nil {
-		return nil, fmt.Errorf("unable to get Organization Role: %w", err)
+		return nil, orgClaimError(err)
 	}
 	if orgClaim.Role == pgdb.NoPermission {
-		return nil, errors.New("user has no access to workspace")
+		return nil, NewDenyError(ReasonNotOrgMember, errors.New("user has no access to workspace"))
 	}
 
 	// Get Dataset Claim
 	datasetClaim, err := claimsManager.GetDatasetClaim(ctx, currentUser, datasetID, manifestOrgId)
 	if err != nil {
-		return nil, fmt.Errorf("unable to get Dataset Role: %w", err)
+		return nil, NewIndeterminateError(fmt.Errorf("unable to get Dataset Role: %w", err))
 	}
 	// If user has no role on provided dataset --> return
 	if datasetClaim.Role == role.None {
-		return nil, errors.New("user has no access to dataset")
+		return nil, NewDenyError(ReasonNoDatasetRole, errors.New("user has no access to dataset"))
+	}
+
+	datasetState, err := checkDataset(ctx, claimsManager, currentUser, datasetID, manifestOrgId)
+	if err != nil {
+		return nil, err
 	}
 
 	// Get User Claim
 	userClaim := claimsManager.GetUserClaim(ctx, currentUser)
 
+	claims := map[string]interface{}{
+		coreAuthorizer.LabelUserClaim:         userClaim,
+		coreAuthorizer.LabelOrganizationClaim: orgClaim,
+		coreAuthorizer.LabelDatasetClaim:      datasetClaim,
+		claimsClient.LabelDatasetState:        datasetState,
+	}
+	if err := AddGrantExpiry(ctx, claimsManager, claims, currentUser.Id, datasetID, manifestOrgId); err != nil {
+		return nil, err
+	}
+
 	if authorizerMode == "LEGACY" {
 		// Get Publisher's Claim
 		teamClaims, err := claimsManager.GetTeamClaimsForOrg(ctx, currentUser.Id, manifestOrgId)
 		if err != nil {
-			return nil, fmt.Errorf("unable to get Team Claims for user: %d organization: %d: %w",
-				currentUser.Id, manifestOrgId, err)
+			return nil, NewIndeterminateError(fmt.Errorf("unable to get Team Claims for user: %d organization: %d: %w",
+				currentUser.Id, manifestOrgId, err))
 		}
-
-		return map[string]interface{}{
-			coreAuthorizer.LabelUserClaim:         userClaim,
-			coreAuthorizer.LabelOrganizationClaim: orgClaim,
-			coreAuthorizer.LabelDatasetClaim:      datasetClaim,
-			coreAuthorizer.LabelTeamClaims:        teamClaims,
-		}, nil
+		claims[coreAuthorizer.LabelTeamClaims] = teamClaims
 	}
-
-	return map[string]interface{}{
-		coreAuthorizer.LabelUserClaim:         userClaim,
-		coreAuthorizer.LabelOrganizationClaim: orgClaim,
-		coreAuthorizer.LabelDatasetClaim:      datasetClaim,
-	}, nil
+	return claims, nil
 }
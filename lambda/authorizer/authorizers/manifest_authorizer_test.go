@@ -2,8 +2,10 @@ package authorizers_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	claimsClient "github.com/pennsieve/pennsieve-go-api/authorizer/claims"
 	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
 	"github.com/pennsieve/pennsieve-go-api/authorizer/test"
 	"github.com/pennsieve/pennsieve-go-api/authorizer/test/mocks"
@@ -15,6 +17,7 @@ import (
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/role"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/teamUser"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/user"
+	corePgdb "github.com/pennsieve/pennsieve-go-core/pkg/queries/pgdb"
 	"github.com/stretchr/testify/require"
 	"testing"
 
@@ -83,6 +86,8 @@ func testGenerateClaims(t *testing.T, managerParams *mocks.ClaimsManagerParams)
 	managerParams.MockPennsievePg.OnGetOrganizationClaim(currentUser.Id, expectedOrgId).Return(orgClaim, nil)
 	managerParams.MockPennsieveDy.OnGetManifestById(managerParams.ManifestTableName, manifestId).Return(manifest, nil)
 	managerParams.MockPennsievePg.OnGetDatasetClaim(currentUser, datasetNodeId, expectedOrgId).Return(datasetClaim, nil)
+	managerParams.MockPennsievePg.OnGetDataUseAgreementStatus(currentUser.Id, datasetNodeId, expectedOrgId).Return(&manager.DataUseAgreementStatus{}, nil)
+	managerParams.MockPennsievePg.OnGetDatasetState(datasetNodeId, expectedOrgId).Return(&claimsClient.DatasetState{}, nil)
 
 	// Test
 	authorizer := authorizers.NewManifestAuthorizer(manifestId)
@@ -91,7 +96,8 @@ func testGenerateClaims(t *testing.T, managerParams *mocks.ClaimsManagerParams)
 	// Checking results
 	require.NoError(t, err)
 
-	assert.Equal(t, 3, len(claims))
+	assert.Equal(t, 4, len(claims))
+	assert.Equal(t, &claimsClient.DatasetState{}, claims[claimsClient.LabelDatasetState])
 	assert.Equal(t,
 		expectedUserClaim(currentUser),
 		claims[coreAuthorizer.LabelUserClaim])
@@ -148,6 +154,8 @@ func testGenerateClaimsLegacy(t *testing.T, managerParams *mocks.ClaimsManagerPa
 	managerParams.MockPennsievePg.OnGetOrganizationClaim(currentUser.Id, expectedOrgId).Return(orgClaim, nil)
 	managerParams.MockPennsieveDy.OnGetManifestById(managerParams.ManifestTableName, manifestId).Return(manifest, nil)
 	managerParams.MockPennsievePg.OnGetDatasetClaim(currentUser, datasetNodeId, expectedOrgId).Return(datasetClaim, nil)
+	managerParams.MockPennsievePg.OnGetDataUseAgreementStatus(currentUser.Id, datasetNodeId, expectedOrgId).Return(&manager.DataUseAgreementStatus{}, nil)
+	managerParams.MockPennsievePg.OnGetDatasetState(datasetNodeId, expectedOrgId).Return(&claimsClient.DatasetState{}, nil)
 	managerParams.MockPennsievePg.OnGetTeamClaimsForOrg(currentUser.Id, expectedOrgId).Return(teamClaims, nil)
 
 	// Test
@@ -157,7 +165,7 @@ func testGenerateClaimsLegacy(t *testing.T, managerParams *mocks.ClaimsManagerPa
 	// Checking results
 	require.NoError(t, err)
 
-	assert.Equal(t, 4, len(claims))
+	assert.Equal(t, 5, len(claims))
 	assert.Equal(t,
 		expectedUserClaim(currentUser),
 		claims[coreAuthorizer.LabelUserClaim])
@@ -208,6 +216,7 @@ func testGenerateClaimsNoDatasetPermission(t *testing.T, managerParams *mocks.Cl
 
 	// Checking results
 	assert.ErrorContains(t, err, "user has no access to dataset")
+	assert.Equal(t, authorizers.ReasonNoDatasetRole, authorizers.DenyReason(err))
 }
 
 func testGenerateClaimsNoOrgPermission(t *testing.T, managerParams *mocks.ClaimsManagerParams) {
@@ -240,6 +249,7 @@ func testGenerateClaimsNoOrgPermission(t *testing.T, managerParams *mocks.Claims
 
 	// Checking results
 	assert.ErrorContains(t, err, "user has no access to workspace")
+	assert.Equal(t, authorizers.ReasonNotOrgMember, authorizers.DenyReason(err))
 }
 
 // TestManifestOrgDoesNotMatchPreferredOrg is not part of main test above, since it only applies to
@@ -278,6 +288,8 @@ func TestManifestOrgDoesNotMatchPreferredOrg(t *testing.T) {
 	managerParams.MockPennsievePg.OnGetOrganizationClaim(currentUser.Id, manifestOrgId).Return(orgClaim, nil)
 	managerParams.MockPennsieveDy.OnGetManifestById(managerParams.ManifestTableName, manifestId).Return(manifest, nil)
 	managerParams.MockPennsievePg.OnGetDatasetClaim(currentUser, datasetNodeId, manifestOrgId).Return(datasetClaim, nil)
+	managerParams.MockPennsievePg.OnGetDataUseAgreementStatus(currentUser.Id, datasetNodeId, manifestOrgId).Return(&manager.DataUseAgreementStatus{}, nil)
+	managerParams.MockPennsievePg.OnGetDatasetState(datasetNodeId, manifestOrgId).Return(&claimsClient.DatasetState{}, nil)
 
 	// Test
 	authorizer := authorizers.NewManifestAuthorizer(manifestId)
@@ -286,7 +298,7 @@ func TestManifestOrgDoesNotMatchPreferredOrg(t *testing.T) {
 	// Checking results
 	require.NoError(t, err)
 
-	assert.Equal(t, 3, len(claims))
+	assert.Equal(t, 4, len(claims))
 	assert.Equal(t,
 		expectedUserClaim(currentUser),
 		claims[coreAuthorizer.LabelUserClaim])
@@ -341,3 +353,158 @@ func expectedUserClaim(currentUser *pgdb.User) *user.Claim {
 		IsSuperAdmin: currentUser.IsSuperAdmin,
 	}
 }
+
+// TestManifestAuthorizerLookupErrors: a missing manifest or a user who is not a member of its workspace is denied,
+// while a failed lookup is indeterminate and must not be cached as a deny.
+func TestManifestAuthorizerLookupErrors(t *testing.T) {
+	connectionRefused := errors.New("connection refused")
+	for scenario, params := range map[string]struct {
+		manifestErr           error
+		orgClaimErr           error
+		datasetClaimErr       error
+		teamClaimsErr         error
+		expectedReason        string
+		expectedIndeterminate bool
+	}{
+		"manifest not found":          {manifestErr: errors.New("GetItem: Manifest not found.\n"), expectedReason: authorizers.ReasonManifestNotFound},
+		"manifest lookup failed":      {manifestErr: errors.New("GetItem: operation error DynamoDB: GetItem, timeout"), expectedIndeterminate: true},
+		"not a member":                {orgClaimErr: corePgdb.OrganizationUserNotFoundError{ErrorMessage: "no rows"}, expectedReason: authorizers.ReasonNotOrgMember},
+		"org claim lookup failed":     {orgClaimErr: connectionRefused, expectedIndeterminate: true},
+		"dataset claim lookup failed": {datasetClaimErr: connectionRefused, expectedIndeterminate: true},
+		"team lookup failed":          {teamClaimsErr: connectionRefused, expectedIndeterminate: true},
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			managerParams := mocks.NewClaimsManagerParams(t)
+			currentUser := test.NewUser(101, 1001)
+			claimsManager := managerParams.WithUserQueryMocked(t, currentUser).BuildClaimsManager()
+			orgId := int64(6001)
+			datasetNodeId := fmt.Sprintf("N:dataset:%s", uuid.NewString())
+			manifest := &dydb.ManifestTable{ManifestId: uuid.NewString(), DatasetNodeId: datasetNodeId, OrganizationId: orgId}
+			orgClaim := &organization.Claim{Role: pgdb.Read, IntId: orgId, NodeId: managerParams.GetExpectedOrgNodeId()}
+			managerParams.MockPennsieveDy.OnGetManifestById(managerParams.ManifestTableName, manifest.ManifestId).Return(manifest, params.manifestErr)
+			if params.manifestErr == nil {
+				managerParams.MockPennsievePg.OnGetOrganizationClaim(currentUser.Id, orgId).Return(orgClaim, params.orgClaimErr)
+			}
+			if params.manifestErr == nil && params.orgClaimErr == nil {
+				managerParams.MockPennsievePg.OnGetDatasetClaim(currentUser, datasetNodeId, orgId).
+					Return(&dataset.Claim{Role: role.Viewer, NodeId: datasetNodeId}, params.datasetClaimErr)
+			}
+			if params.teamClaimsErr != nil {
+				managerParams.MockPennsievePg.OnGetDataUseAgreementStatus(currentUser.Id, datasetNodeId, orgId).Return(&manager.DataUseAgreementStatus{}, nil)
+				managerParams.MockPennsievePg.OnGetDatasetState(datasetNodeId, orgId).Return(&claimsClient.DatasetState{}, nil)
+				managerParams.MockPennsievePg.OnGetTeamClaimsForOrg(currentUser.Id, orgId).Return([]teamUser.Claim(nil), params.teamClaimsErr)
+			}
+
+			claims, err := authorizers.NewManifestAuthorizer(manifest.ManifestId).GenerateClaims(context.Background(), claimsManager, "LEGACY")
+
+			assert.Nil(t, claims)
+			require.Error(t, err)
+			assert.Equal(t, params.expectedReason, authorizers.DenyReason(err))
+			if params.expectedIndeterminate {
+				assertIndeterminate(t, err)
+			} else {
+				assertNotIndeterminate(t, err)
+			}
+			managerParams.AssertMockExpectations(t)
+		})
+	}
+}
+
+func TestManifestLockedDataset(t *testing.T) {
+	managerParams := mocks.NewClaimsManagerParams(t)
+	currentUser := test.NewUser(101, 1001)
+	claimsManager := managerParams.WithUserQueryMocked(t, currentUser).BuildClaimsManager()
+	orgId := int64(6001)
+	datasetNodeId := fmt.Sprintf("N:dataset:%s", uuid.NewString())
+	manifest := &dydb.ManifestTable{ManifestId: uuid.NewString(), DatasetNodeId: datasetNodeId, OrganizationId: orgId}
+	orgClaim := &organization.Claim{Role: pgdb.Read, IntId: orgId, NodeId: managerParams.GetExpectedOrgNodeId()}
+	managerParams.MockPennsieveDy.OnGetManifestById(managerParams.ManifestTableName, manifest.ManifestId).Return(manifest, nil)
+	managerParams.MockPennsievePg.OnGetOrganizationIpAllowlist(orgId).Return([]string(nil), nil)
+	managerParams.MockPennsievePg.OnGetOrganizationAuthPolicy(orgId).Return(&manager.AuthPolicy{}, nil)
+	managerParams.MockPennsievePg.OnGetOrganizationClaim(currentUser.Id, orgId).Return(orgClaim, nil)
+	managerParams.MockPennsievePg.OnGetDatasetClaim(currentUser, datasetNodeId, orgId).Return(&dataset.Claim{Role: role.Editor, NodeId: datasetNodeId}, nil)
+	managerParams.MockPennsievePg.OnGetDataUseAgreementStatus(currentUser.Id, datasetNodeId, orgId).Return(&manager.DataUseAgreementStatus{}, nil)
+	managerParams.MockPennsievePg.OnGetDatasetState(datasetNodeId, orgId).Return(&claimsClient.DatasetState{Deleted: true}, nil)
+
+	ctx := manager.WithRequest(context.Background(), manager.Request{SourceIp: "192.0.2.1", Method: "POST", Route: "POST /manifest/{id}/files"})
+	claims, err := authorizers.NewManifestAuthorizer(manifest.ManifestId).GenerateClaims(ctx, claimsManager, "")
+
+	assert.Nil(t, claims)
+	assert.Equal(t, authorizers.ReasonDatasetLocked, authorizers.DenyReason(err))
+	managerParams.AssertMockExpectations(t)
+}
+
+func TestManifestDataUseAgreement(t *testing.T) {
+	for scenario, params := range map[string]struct {
+		uncached       bool
+		expectedReason string
+	}{
+		"not accepted": {uncached: true, expectedReason: authorizers.ReasonDuaRequired},
+		"cached route": {expectedReason: authorizers.ReasonUncachedAuthorizerRequired},
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			managerParams := mocks.NewClaimsManagerParams(t)
+			currentUser := test.NewUser(101, 1001)
+			claimsManager := managerParams.WithUserQueryMocked(t, currentUser).BuildClaimsManager()
+			orgId := int64(6001)
+			datasetNodeId := fmt.Sprintf("N:dataset:%s", uuid.NewString())
+			manifest := &dydb.ManifestTable{ManifestId: uuid.NewString(), DatasetNodeId: datasetNodeId, OrganizationId: orgId}
+			orgClaim := &organization.Claim{Role: pgdb.Read, IntId: orgId, NodeId: managerParams.GetExpectedOrgNodeId()}
+			managerParams.MockPennsieveDy.OnGetManifestById(managerParams.ManifestTableName, manifest.ManifestId).Return(manifest, nil)
+			managerParams.MockPennsievePg.OnGetOrganizationIpAllowlist(orgId).Return([]string(nil), nil)
+			managerParams.MockPennsievePg.OnGetOrganizationAuthPolicy(orgId).Return(&manager.AuthPolicy{}, nil)
+			managerParams.MockPennsievePg.OnGetOrganizationClaim(currentUser.Id, orgId).Return(orgClaim, nil)
+			managerParams.MockPennsievePg.OnGetDatasetClaim(currentUser, datasetNodeId, orgId).Return(&dataset.Claim{Role: role.Editor, NodeId: datasetNodeId}, nil)
+			managerParams.MockPennsievePg.OnGetDataUseAgreementStatus(currentUser.Id, datasetNodeId, orgId).
+				Return(&manager.DataUseAgreementStatus{Required: true, AgreementId: 7}, nil)
+
+			ctx := manager.WithRequest(context.Background(), manager.Request{SourceIp: "192.0.2.1", Method: "POST", Route: "POST /manifest/{id}/files", Uncached: params.uncached})
+			claims, err := authorizers.NewManifestAuthorizer(manifest.ManifestId).GenerateClaims(ctx, claimsManager, "")
+
+			assert.Nil(t, claims)
+			assert.Equal(t, params.expectedReason, authorizers.DenyReason(err))
+			managerParams.AssertMockExpectations(t)
+		})
+	}
+}
+
+func TestManifestPurposeOfUse(t *testing.T) {
+	for scenario, params := range map[string]struct {
+		request        manager.Request
+		expectedReason string
+	}{
+		"purpose declared": {request: manager.Request{Uncached: true, PurposeOfUse: claimsClient.PurposeOfUseResearch}},
+		"no purpose":       {request: manager.Request{Uncached: true}, expectedReason: authorizers.ReasonPurposeOfUseRequired},
+		"cached route":     {request: manager.Request{PurposeOfUse: claimsClient.PurposeOfUseResearch}, expectedReason: authorizers.ReasonUncachedAuthorizerRequired},
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			managerParams := mocks.NewClaimsManagerParams(t)
+			currentUser := test.NewUser(101, 1001)
+			claimsManager := managerParams.WithUserQueryMocked(t, currentUser).BuildClaimsManager()
+			orgId := int64(6001)
+			datasetNodeId := fmt.Sprintf("N:dataset:%s", uuid.NewString())
+			manifest := &dydb.ManifestTable{ManifestId: uuid.NewString(), DatasetNodeId: datasetNodeId, OrganizationId: orgId}
+			orgClaim := &organization.Claim{Role: pgdb.Read, IntId: orgId, NodeId: managerParams.GetExpectedOrgNodeId()}
+			managerParams.MockPennsieveDy.OnGetManifestById(managerParams.ManifestTableName, manifest.ManifestId).Return(manifest, nil)
+			managerParams.MockPennsievePg.OnGetOrganizationIpAllowlist(orgId).Return([]string(nil), nil)
+			managerParams.MockPennsievePg.OnGetOrganizationAuthPolicy(orgId).Return(&manager.AuthPolicy{}, nil)
+			managerParams.MockPennsievePg.OnGetOrganizationClaim(currentUser.Id, orgId).Return(orgClaim, nil)
+			managerParams.MockPennsievePg.OnGetDatasetClaim(currentUser, datasetNodeId, orgId).Return(&dataset.Claim{Role: role.Editor, NodeId: datasetNodeId}, nil)
+			managerParams.MockPennsievePg.OnGetDataUseAgreementStatus(currentUser.Id, datasetNodeId, orgId).Return(&manager.DataUseAgreementStatus{}, nil)
+			managerParams.MockPennsievePg.OnGetDatasetState(datasetNodeId, orgId).Return(&claimsClient.DatasetState{Protected: true}, nil)
+
+			params.request.SourceIp, params.request.Method, params.request.Route = "192.0.2.1", "POST", "POST /manifest/{id}/files"
+			ctx := manager.WithRequest(context.Background(), params.request)
+			claims, err := authorizers.NewManifestAuthorizer(manifest.ManifestId).GenerateClaims(ctx, claimsManager, "")
+
+			if params.expectedReason == "" {
+				assert.NoError(t, err)
+				assert.NotNil(t, claims)
+			} else {
+				assert.Nil(t, claims)
+				assert.Equal(t, params.expectedReason, authorizers.DenyReason(err))
+			}
+			managerParams.AssertMockExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package authorizers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	claimsClient "github.com/pennsieve/pennsieve-go-api/authorizer/claims"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
+)
+
+// purposesOfUse are the values the X-Purpose-Of-Use header may take.
+var purposesOfUse = map[string]bool{
+	claimsClient.PurposeOfUseTreatment:    true,
+	claimsClient.PurposeOfUsePayment:      true,
+	claimsClient.PurposeOfUseOperations:   true,
+	claimsClient.PurposeOfUseResearch:     true,
+	claimsClient.PurposeOfUsePublicHealth: true,
+	claimsClient.PurposeOfUseEmergency:    true,
+	claimsClient.PurposeOfUseLegal:        true,
+}
+
+// ParsePurposeOfUse returns the purpose of use the X-Purpose-Of-Use header declares, matched case-insensitively.
+func ParsePurposeOfUse(header string) (string, error) {
+	purposeOfUse := strings.ToLower(strings.TrimSpace(header))
+	if purposeOfUse == "" || purposesOfUse[purposeOfUse] {
+		return purposeOfUse, nil
+	}
+	return "", NewUncacheableDenyError(ReasonInvalidPurposeOfUse, fmt.Errorf("unknown purpose of use %q", header))
+}
+
+// CheckPurposeOfUse denies an uncached request without a purpose, or any cached one, for a protected dataset.
+func CheckPurposeOfUse(ctx context.Context, datasetId string, datasetState *claimsClient.DatasetState) error {
+	// Direct invocations carry no request; their callers account for their own disclosures.
+	request, ok := manager.RequestFromContext(ctx)
+	if !ok || !datasetState.Protected {
+		return nil
+	}
+	if !request.Uncached {
+		return NewDenyError(ReasonUncachedAuthorizerRequired, fmt.Errorf("dataset %s holds protected health information and requires a route whose authorizer decisions are not cached", datasetId))
+	}
+	if request.PurposeOfUse == "" {
+		return NewDenyError(ReasonPurposeOfUseRequired, fmt.Errorf("dataset %s holds protected health information and requires a purpose of use", datasetId))
+	}
+	return nil
+}
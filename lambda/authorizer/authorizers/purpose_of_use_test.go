@@ -0,0 +1,99 @@
+package authorizers_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/authorizers"
+	claimsClient "github.com/pennsieve/pennsieve-go-api/authorizer/claims"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/test"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/test/mocks"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dataset"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/organization"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/role"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePurposeOfUse(t *testing.T) {
+	for header, expected := range map[string]string{
+		"":              "",
+		"treatment":     claimsClient.PurposeOfUseTreatment,
+		" Research ":    claimsClient.PurposeOfUseResearch,
+		"PUBLIC_HEALTH": claimsClient.PurposeOfUsePublicHealth,
+		"operations":    claimsClient.PurposeOfUseOperations,
+	} {
+		t.Run(fmt.Sprintf("%q", header), func(t *testing.T) {
+			purposeOfUse, err := authorizers.ParsePurposeOfUse(header)
+			require.NoError(t, err)
+			assert.Equal(t, expected, purposeOfUse)
+		})
+	}
+
+	for _, header := range []string{"curiosity", "public health", "treatment,research"} {
+		t.Run(fmt.Sprintf("%q", header), func(t *testing.T) {
+			_, err := authorizers.ParsePurposeOfUse(header)
+			assert.Equal(t, authorizers.ReasonInvalidPurposeOfUse, authorizers.DenyReason(err))
+			assert.True(t, authorizers.Uncacheable(err))
+		})
+	}
+}
+
+func TestCheckPurposeOfUse(t *testing.T) {
+	protected := &claimsClient.DatasetState{Protected: true}
+
+	for scenario, params := range map[string]struct {
+		request        *manager.Request
+		datasetState   *claimsClient.DatasetState
+		expectedReason string
+	}{
+		"unprotected dataset":         {request: &manager.Request{}, datasetState: &claimsClient.DatasetState{}},
+		"protected with purpose":      {request: &manager.Request{PurposeOfUse: claimsClient.PurposeOfUseResearch, Uncached: true}, datasetState: protected},
+		"protected without purpose":   {request: &manager.Request{Uncached: true}, datasetState: protected, expectedReason: authorizers.ReasonPurposeOfUseRequired},
+		"callback without purpose":    {request: &manager.Request{Callback: true, Uncached: true}, datasetState: protected, expectedReason: authorizers.ReasonPurposeOfUseRequired},
+		"protected on cached route":   {request: &manager.Request{PurposeOfUse: claimsClient.PurposeOfUseResearch}, datasetState: protected, expectedReason: authorizers.ReasonUncachedAuthorizerRequired},
+		"unprotected on cached route": {request: &manager.Request{PurposeOfUse: claimsClient.PurposeOfUseResearch}, datasetState: &claimsClient.DatasetState{}},
+		"direct invocation":           {request: nil, datasetState: protected},
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			ctx := context.Background()
+			if params.request != nil {
+				ctx = manager.WithRequest(ctx, *params.request)
+			}
+			err := authorizers.CheckPurposeOfUse(ctx, "N:dataset:abc", params.datasetState)
+			if params.expectedReason == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Equal(t, params.expectedReason, authorizers.DenyReason(err))
+			assertNotIndeterminate(t, err)
+		})
+	}
+}
+
+func TestDatasetAuthorizerPurposeOfUse(t *testing.T) {
+	managerParams := mocks.NewClaimsManagerParams(t)
+	currentUser := test.NewUser(101, 1001)
+	claimsManager := managerParams.WithUserQueryMocked(t, currentUser).BuildClaimsManager()
+
+	datasetNodeId := fmt.Sprintf("N:dataset:%s", uuid.NewString())
+	orgId := int64(1001)
+	orgClaim := &organization.Claim{Role: pgdb.Read, IntId: orgId, NodeId: fmt.Sprintf("N:organization:%s", uuid.NewString())}
+	managerParams.MockPennsievePg.OnGetOrganizationIdForDataset(datasetNodeId).Return(orgId, nil)
+	managerParams.MockPennsievePg.OnGetOrganizationIpAllowlist(orgId).Return([]string(nil), nil)
+	managerParams.MockPennsievePg.OnGetOrganizationAuthPolicy(orgId).Return(&manager.AuthPolicy{}, nil)
+	managerParams.MockPennsievePg.OnGetOrganizationClaim(currentUser.Id, orgId).Return(orgClaim, nil)
+	managerParams.MockPennsievePg.OnGetDatasetClaim(currentUser, datasetNodeId, orgId).Return(&dataset.Claim{Role: role.Viewer, NodeId: datasetNodeId}, nil)
+	managerParams.MockPennsievePg.OnGetDataUseAgreementStatus(currentUser.Id, datasetNodeId, orgId).Return(&manager.DataUseAgreementStatus{}, nil)
+	managerParams.MockPennsievePg.OnGetDatasetState(datasetNodeId, orgId).Return(&claimsClient.DatasetState{Protected: true}, nil)
+
+	ctx := manager.WithRequest(context.Background(), manager.Request{SourceIp: "192.0.2.1", Method: "GET", Uncached: true})
+	_, err := authorizers.NewDatasetAuthorizer(datasetNodeId).GenerateClaims(ctx, claimsManager, "")
+
+	assert.Equal(t, authorizers.ReasonPurposeOfUseRequired, authorizers.DenyReason(err))
+	managerParams.AssertMockExpectations(t)
+}
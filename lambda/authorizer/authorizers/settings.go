@@ -0,0 +1,20 @@
+package authorizers
+
+// Settings are the deployment's options for how the authorizers decide, from config.Config.
+type Settings struct {
+	// IpAllowlistExemptSuperAdmins exempts super-admins from organization IP allowlists.
+	IpAllowlistExemptSuperAdmins bool
+	// IpAllowlistExemptCallbacks exempts callback tokens from organization IP allowlists.
+	IpAllowlistExemptCallbacks bool
+	// DatasetLockExemptRoutes are the route keys, e.g. "POST /datasets/{id}/publication/cancel", that may modify
+	// locked datasets.
+	DatasetLockExemptRoutes []string
+}
+
+// settings are the Settings every authorizer in the process uses. The zero value grants no exemptions.
+var settings Settings
+
+// Configure sets the Settings every authorizer in the process uses. Lambdas call it once, on cold start.
+func Configure(s Settings) {
+	settings = s
+}
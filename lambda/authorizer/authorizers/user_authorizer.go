@@ -16,9 +16,9 @@ func NewUserAuthorizer() Authorizer {
 
 func (u *UserAuthorizer) GenerateClaims(ctx context.Context, claimsManager manager.IdentityManager, authorizerMode string) (map[string]interface{}, error) {
 	// Get current user
-	currentUser, err := claimsManager.GetCurrentUser(ctx)
+	currentUser, err := getCurrentUser(ctx, claimsManager)
 	if err != nil {
-		return nil, fmt.Errorf("unable to get current user: %w", err)
+		return nil, err
 	}
 	// Get User Claim
 	userClaim := claimsManager.GetUserClaim(ctx, currentUser)
@@ -30,15 +30,17 @@ func (u *UserAuthorizer) GenerateClaims(ctx context.Context, claimsManager manag
 		// Get Workspace Claim
 		orgClaim, err := claimsManager.GetOrgClaim(ctx, currentUser.Id, orgInt)
 		if err != nil {
-			return nil, fmt.Errorf("unable to get Organization Role: %w", err)
-
+			return nil, orgClaimError(err)
+		}
+		if err := CheckAuthPolicy(ctx, claimsManager, orgInt); err != nil {
+			return nil, err
 		}
 
 		// Get Publisher's Claim
 		teamClaims, err := claimsManager.GetTeamClaims(ctx, currentUser.Id)
 		if err != nil {
-			return nil, fmt.Errorf("unable to get Team Claims for user: %d organization: %d: %w",
-				currentUser.Id, orgInt, err)
+			return nil, NewIndeterminateError(fmt.Errorf("unable to get Team Claims for user: %d organization: %d: %w",
+				currentUser.Id, orgInt, err))
 		}
 
 		return map[string]interface{}{
@@ -2,9 +2,17 @@ package authorizers_test
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/test"
 	"github.com/pennsieve/pennsieve-go-api/authorizer/test/mocks"
 	coreAuthorizer "github.com/pennsieve/pennsieve-go-core/pkg/authorizer"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/organization"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/teamUser"
+	corePgdb "github.com/pennsieve/pennsieve-go-core/pkg/queries/pgdb"
+	"github.com/stretchr/testify/require"
 	"testing"
 
 	"github.com/pennsieve/pennsieve-go-api/authorizer/authorizers"
@@ -44,3 +52,49 @@ func TestUserAuthorizerLegacy(t *testing.T) {
 		expectedTeamClaims,
 		fmt.Sprintf("%s", claims[coreAuthorizer.LabelTeamClaims]))
 }
+
+// TestUserAuthorizerLookupErrors: a user who is not a member of their active workspace is denied, while a failed
+// lookup is indeterminate and must not be cached as a deny.
+func TestUserAuthorizerLookupErrors(t *testing.T) {
+	connectionRefused := errors.New("connection refused")
+	for scenario, params := range map[string]struct {
+		userErr               error
+		orgClaimErr           error
+		teamClaimsErr         error
+		expectedReason        string
+		expectedIndeterminate bool
+	}{
+		"unknown user":            {userErr: sql.ErrNoRows, expectedReason: authorizers.ReasonUnknownUser},
+		"user lookup failed":      {userErr: connectionRefused, expectedIndeterminate: true},
+		"not a member":            {orgClaimErr: corePgdb.OrganizationUserNotFoundError{ErrorMessage: "no rows"}, expectedReason: authorizers.ReasonNotOrgMember},
+		"org claim lookup failed": {orgClaimErr: connectionRefused, expectedIndeterminate: true},
+		"team lookup failed":      {teamClaimsErr: connectionRefused, expectedIndeterminate: true},
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			managerParams := mocks.NewClaimsManagerParams(t)
+			currentUser := test.NewUser(101, 1001)
+			if params.userErr != nil {
+				managerParams.MockPennsievePg.OnGetByCognitoId(managerParams.TestJWT.Username).Return((*pgdb.User)(nil), params.userErr)
+			} else {
+				managerParams.WithUserQueryMocked(t, currentUser)
+				orgClaim := &organization.Claim{Role: pgdb.Read, IntId: currentUser.PreferredOrg}
+				managerParams.MockPennsievePg.OnGetOrganizationClaim(currentUser.Id, currentUser.PreferredOrg).Return(orgClaim, params.orgClaimErr)
+				if params.orgClaimErr == nil {
+					managerParams.MockPennsievePg.OnGetTeamClaims(currentUser.Id).Return([]teamUser.Claim(nil), params.teamClaimsErr)
+				}
+			}
+
+			claims, err := authorizers.NewUserAuthorizer().GenerateClaims(context.Background(), managerParams.BuildClaimsManager(), "LEGACY")
+
+			assert.Nil(t, claims)
+			require.Error(t, err)
+			assert.Equal(t, params.expectedReason, authorizers.DenyReason(err))
+			if params.expectedIndeterminate {
+				assertIndeterminate(t, err)
+			} else {
+				assertNotIndeterminate(t, err)
+			}
+			managerParams.AssertMockExpectations(t)
+		})
+	}
+}
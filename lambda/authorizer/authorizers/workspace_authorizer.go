@@ -20,39 +20,50 @@ func NewWorkspaceAuthorizer(workspaceID string) Authorizer {
 
 func (w *WorkspaceAuthorizer) GenerateClaims(ctx context.Context, claimsManager manager.IdentityManager, authorizerMode string) (map[string]interface{}, error) {
 	// Get current user
-	currentUser, err := claimsManager.GetCurrentUser(ctx)
+	currentUser, err := getCurrentUser(ctx, claimsManager)
 	if err != nil {
-		return nil, fmt.Errorf("unable to get current user: %w", err)
+		return nil, err
 	}
 
 	if tokenWorkspace, hasTokenWorkspace := claimsManager.GetTokenWorkspace(); hasTokenWorkspace && tokenWorkspace.NodeId != w.WorkspaceID {
-		return nil, fmt.Errorf("provided workspace id %s does not match API token workspace id %s",
+		return nil, NewDenyError(ReasonTokenWorkspaceMismatch, fmt.Errorf("provided workspace id %s does not match API token workspace id %s",
 			w.WorkspaceID,
-			tokenWorkspace.NodeId)
+			tokenWorkspace.NodeId))
 	}
 
 	// Get Workspace Claim
 	orgClaim, err := claimsManager.GetOrgClaimByNodeId(ctx, currentUser.Id, w.WorkspaceID)
 	if err != nil {
-		return nil, fmt.Errorf("unable to get Organization Role: %w", err)
+		return nil, orgClaimError(err)
 	}
 	if orgClaim.Role == pgModels.NoPermission {
-		return nil, errors.New("user has no access to workspace")
+		return nil, NewDenyError(ReasonNotOrgMember, errors.New("user has no access to workspace"))
+	}
+
+	if err := CheckSourceIp(ctx, claimsManager, currentUser, orgClaim.IntId); err != nil {
+		return nil, err
+	}
+	if err := CheckAuthPolicy(ctx, claimsManager, orgClaim.IntId); err != nil {
+		return nil, err
 	}
 
 	// Get Publisher's Claim
 	teamClaims, err := claimsManager.GetTeamClaimsForOrg(ctx, currentUser.Id, orgClaim.IntId)
 	if err != nil {
-		return nil, fmt.Errorf("unable to get Team Claims for user: %d organization: %s: %w",
-			currentUser.Id, w.WorkspaceID, err)
+		return nil, NewIndeterminateError(fmt.Errorf("unable to get Team Claims for user: %d organization: %s: %w",
+			currentUser.Id, w.WorkspaceID, err))
 	}
 
 	// Get User Claim
 	userClaim := claimsManager.GetUserClaim(ctx, currentUser)
 
-	return map[string]interface{}{
+	claims := map[string]interface{}{
 		coreAuthorizer.LabelUserClaim:         userClaim,
 		coreAuthorizer.LabelOrganizationClaim: orgClaim,
 		coreAuthorizer.LabelTeamClaims:        teamClaims,
-	}, nil
+	}
+	if err := AddGrantExpiry(ctx, claimsManager, claims, currentUser.Id, "", orgClaim.IntId); err != nil {
+		return nil, err
+	}
+	return claims, nil
 }
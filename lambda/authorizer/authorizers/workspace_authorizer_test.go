@@ -3,14 +3,17 @@ package authorizers_test
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/pennsieve/pennsieve-go-api/authorizer/authorizers"
 	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
 	"github.com/pennsieve/pennsieve-go-api/authorizer/test"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/test/mocks"
 	coreAuthorizer "github.com/pennsieve/pennsieve-go-core/pkg/authorizer"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/organization"
 	pgModels "github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/teamUser"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/user"
 	"github.com/pennsieve/pennsieve-go-core/pkg/queries/pgdb"
 	"github.com/stretchr/testify/assert"
@@ -53,6 +56,7 @@ func TestWorkspaceAuthorizer(t *testing.T) {
 		}
 	})
 	require.NoError(t, pgDB.Ping())
+	test.Migrate(t, pgDB)
 	for scenario, testFunc := range map[string]func(*testing.T, *sql.DB){
 		"user not in workspace":                        testUserNotInWorkspace,
 		"api token does not match requested workspace": testAPIKeyNotInRequestedWorkspace,
@@ -74,7 +78,7 @@ func TestWorkspaceAuthorizer(t *testing.T) {
 }
 
 func testUserNotInWorkspace(t *testing.T, pgDB *sql.DB) {
-	pgQueries := pgdb.New(pgDB)
+	pgQueries := manager.NewQueries(pgDB, 0)
 	token := test.NewJWTBuilder().WithUsername(testUser.cognitoUsername).Build(t)
 	claimsManager := manager.NewClaimsManager(pgQueries, nil, token.Token, uuid.NewString(), uuid.NewString())
 
@@ -85,7 +89,7 @@ func testUserNotInWorkspace(t *testing.T, pgDB *sql.DB) {
 }
 
 func testAPIKeyNotInRequestedWorkspace(t *testing.T, pgDB *sql.DB) {
-	pgQueries := pgdb.New(pgDB)
+	pgQueries := manager.NewQueries(pgDB, 0)
 
 	// API token that is for seed workspace 2
 	tokenWorkspaceId := int64(2)
@@ -111,7 +115,7 @@ func testUserWithDeleteInWorkspace(t *testing.T, pgDB *sql.DB) {
 	orgNodeId := seedOrgIdToNodeId[orgId]
 	test.AddOrgUser(t, pgDB, orgId, testUser.user.Id, pgModels.Delete)
 
-	pgQueries := pgdb.New(pgDB)
+	pgQueries := manager.NewQueries(pgDB, 0)
 	token := test.NewJWTBuilder().WithUsername(testUser.cognitoUsername).Build(t)
 
 	claimsManager := manager.NewClaimsManager(pgQueries, nil, token.Token, uuid.NewString(), uuid.NewString())
@@ -152,7 +156,7 @@ func testUserWithNoPermissionInWorkspace(t *testing.T, pgDB *sql.DB) {
 	orgNodeId := seedOrgIdToNodeId[orgId]
 	test.AddOrgUser(t, pgDB, orgId, testUser.user.Id, pgModels.NoPermission)
 
-	pgQueries := pgdb.New(pgDB)
+	pgQueries := manager.NewQueries(pgDB, 0)
 	token := test.NewJWTBuilder().WithUsername(testUser.cognitoUsername).Build(t)
 
 	claimsManager := manager.NewClaimsManager(pgQueries, nil, token.Token, uuid.NewString(), uuid.NewString())
@@ -160,6 +164,7 @@ func testUserWithNoPermissionInWorkspace(t *testing.T, pgDB *sql.DB) {
 	workspaceAuthorizer := authorizers.NewWorkspaceAuthorizer(orgNodeId)
 	_, err := workspaceAuthorizer.GenerateClaims(context.Background(), claimsManager, "")
 	assert.ErrorContains(t, err, "user has no access to workspace")
+	assert.Equal(t, authorizers.ReasonNotOrgMember, authorizers.DenyReason(err))
 }
 
 func testAPIKeyWithDeleteInWorkspace(t *testing.T, pgDB *sql.DB) {
@@ -172,7 +177,7 @@ func testAPIKeyWithDeleteInWorkspace(t *testing.T, pgDB *sql.DB) {
 	test.AddOrgUser(t, pgDB, orgId, testUser.user.Id, pgModels.Delete)
 	test.AddAPIToken(t, pgDB, orgId, testUser.user.Id, testUser.cognitoUsername, token.ClientId)
 
-	pgQueries := pgdb.New(pgDB)
+	pgQueries := manager.NewQueries(pgDB, 0)
 
 	claimsManager := manager.NewClaimsManager(pgQueries, nil, token.Token, token.ClientId, uuid.NewString())
 
@@ -205,3 +210,52 @@ func testAPIKeyWithDeleteInWorkspace(t *testing.T, pgDB *sql.DB) {
 	assert.Empty(t, claims[coreAuthorizer.LabelTeamClaims])
 
 }
+
+// TestWorkspaceAuthorizerLookupErrors: a user who is not a member of the workspace is denied, while a failed
+// lookup is indeterminate and must not be cached as a deny.
+func TestWorkspaceAuthorizerLookupErrors(t *testing.T) {
+	connectionRefused := errors.New("connection refused")
+	for scenario, params := range map[string]struct {
+		userErr               error
+		orgClaimErr           error
+		teamClaimsErr         error
+		expectedReason        string
+		expectedIndeterminate bool
+	}{
+		"unknown user":            {userErr: sql.ErrNoRows, expectedReason: authorizers.ReasonUnknownUser},
+		"user lookup failed":      {userErr: connectionRefused, expectedIndeterminate: true},
+		"not a member":            {orgClaimErr: pgdb.OrganizationUserNotFoundError{ErrorMessage: "no rows"}, expectedReason: authorizers.ReasonNotOrgMember},
+		"org claim lookup failed": {orgClaimErr: connectionRefused, expectedIndeterminate: true},
+		"team lookup failed":      {teamClaimsErr: connectionRefused, expectedIndeterminate: true},
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			managerParams := mocks.NewClaimsManagerParams(t)
+			currentUser := test.NewUser(101, 1001)
+			orgNodeId := managerParams.GetExpectedOrgNodeId()
+			orgClaim := &organization.Claim{Role: pgModels.Read, IntId: 2001, NodeId: orgNodeId}
+			if params.userErr != nil {
+				managerParams.MockPennsievePg.OnGetByCognitoId(managerParams.TestJWT.Username).Return((*pgModels.User)(nil), params.userErr)
+			} else {
+				managerParams.WithUserQueryMocked(t, currentUser)
+				if params.orgClaimErr != nil {
+					managerParams.MockPennsievePg.OnGetOrganizationClaimByNodeId(currentUser.Id, orgNodeId).Return((*organization.Claim)(nil), params.orgClaimErr)
+				} else {
+					managerParams.MockPennsievePg.OnGetOrganizationClaimByNodeId(currentUser.Id, orgNodeId).Return(orgClaim, nil)
+					managerParams.MockPennsievePg.OnGetTeamClaimsForOrg(currentUser.Id, orgClaim.IntId).Return([]teamUser.Claim(nil), params.teamClaimsErr)
+				}
+			}
+
+			claims, err := authorizers.NewWorkspaceAuthorizer(orgNodeId).GenerateClaims(context.Background(), managerParams.BuildClaimsManager(), "")
+
+			assert.Nil(t, claims)
+			require.Error(t, err)
+			assert.Equal(t, params.expectedReason, authorizers.DenyReason(err))
+			if params.expectedIndeterminate {
+				assertIndeterminate(t, err)
+			} else {
+				assertNotIndeterminate(t, err)
+			}
+			managerParams.AssertMockExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,108 @@
+// Package blocklist denies requests from principals and organizations blocked during incident response. Entries
+// live behind the Store interface: DynamoStore shares them across Lambda instances, MemoryStore keeps them
+// in-process for tests and local runs. A List holds the entries in memory and reloads them from its Store once
+// they are older than its TTL, so that checking a request does not cost a DynamoDB call.
+package blocklist
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Entry kinds.
+const (
+	// KindUser blocks a user by node id, however they authenticate.
+	KindUser = "user"
+	// KindUsername blocks a user-pool Cognito username.
+	KindUsername = "username"
+	// KindToken blocks an API token by its client id, the username of the token in the token pool.
+	KindToken = "token"
+	// KindOrganization blocks every request for an organization's resources, by organization node id.
+	KindOrganization = "organization"
+)
+
+// Entry is a principal or organization on the block list. Reason is why it was blocked, for the decision log; it
+// plays no part in matching.
+type Entry struct {
+	Kind   string
+	Id     string
+	Reason string
+}
+
+// Key identifies the entry in a Store.
+func (e Entry) Key() string {
+	return e.Kind + ":" + e.Id
+}
+
+func (e Entry) String() string {
+	return e.Key()
+}
+
+// Store holds the block list.
+type Store interface {
+	// Load returns every entry on the block list.
+	Load(ctx context.Context) ([]Entry, error)
+}
+
+// List checks requests against the entries of a Store, reloaded at most once per TTL.
+type List struct {
+	store Store
+	ttl   time.Duration
+	now   func() time.Time
+
+	mu       sync.Mutex
+	entries  map[string]Entry
+	loadedAt time.Time
+}
+
+// NewList returns a List of the entries in store. Entries added to or removed from store take up to ttl to be
+// seen.
+func NewList(store Store, ttl time.Duration) *List {
+	return &List{store: store, ttl: ttl, now: time.Now}
+}
+
+// WithClock makes the List read the time from now, for tests.
+func (l *List) WithClock(now func() time.Time) *List {
+	l.now = now
+	return l
+}
+
+// Blocked returns the first of candidates that is on the block list, if any. Candidates with an empty Id are
+// ignored. If the list is due to be reloaded and the Store fails, Blocked checks candidates against the entries it
+// last loaded, if any, and also returns the Store's error, which the caller should log: a failing Store must
+// neither unblock what was blocked nor deny every request.
+func (l *List) Blocked(ctx context.Context, candidates ...Entry) (Entry, bool, error) {
+	entries, err := l.current(ctx)
+	for _, candidate := range candidates {
+		if candidate.Id == "" {
+			continue
+		}
+		if entry, ok := entries[candidate.Key()]; ok {
+			return entry, true, err
+		}
+	}
+	return Entry{}, false, err
+}
+
+// current returns the entries, reloading them first if they are older than the TTL.
+func (l *List) current(ctx context.Context) (map[string]Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if l.entries != nil && now.Sub(l.loadedAt) < l.ttl {
+		return l.entries, nil
+	}
+	loaded, err := l.store.Load(ctx)
+	if err != nil {
+		return l.entries, fmt.Errorf("unable to load block list: %w", err)
+	}
+	l.entries = make(map[string]Entry, len(loaded))
+	for _, entry := range loaded {
+		l.entries[entry.Key()] = entry
+	}
+	l.loadedAt = now
+	return l.entries, nil
+}
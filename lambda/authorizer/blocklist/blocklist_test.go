@@ -0,0 +1,110 @@
+package blocklist_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pennsieve/pennsieve-go-api/authorizer/blocklist"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyStore wraps a MemoryStore, counting loads and failing them while err is set.
+type flakyStore struct {
+	*blocklist.MemoryStore
+	loads int
+	err   error
+}
+
+func (s *flakyStore) Load(ctx context.Context) ([]blocklist.Entry, error) {
+	s.loads++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.MemoryStore.Load(ctx)
+}
+
+func TestListBlocked(t *testing.T) {
+	blockedUser := blocklist.Entry{Kind: blocklist.KindUser, Id: "N:user:1", Reason: "INC-42 compromised account"}
+	list := blocklist.NewList(blocklist.NewMemoryStore(blockedUser), time.Minute)
+
+	entry, blocked, err := list.Blocked(context.Background(),
+		blocklist.Entry{Kind: blocklist.KindUsername, Id: "N:user:1"},
+		blocklist.Entry{Kind: blocklist.KindUser, Id: "N:user:1"})
+	require.NoError(t, err)
+	assert.True(t, blocked)
+	assert.Equal(t, blockedUser, entry)
+
+	_, blocked, err = list.Blocked(context.Background(), blocklist.Entry{Kind: blocklist.KindUser, Id: "N:user:2"})
+	require.NoError(t, err)
+	assert.False(t, blocked)
+
+	_, blocked, err = list.Blocked(context.Background())
+	require.NoError(t, err)
+	assert.False(t, blocked)
+}
+
+func TestListIgnoresEmptyIds(t *testing.T) {
+	list := blocklist.NewList(blocklist.NewMemoryStore(blocklist.Entry{Kind: blocklist.KindOrganization, Id: ""}), time.Minute)
+
+	_, blocked, err := list.Blocked(context.Background(), blocklist.Entry{Kind: blocklist.KindOrganization})
+	require.NoError(t, err)
+	assert.False(t, blocked)
+}
+
+func TestListReloadsAfterTtl(t *testing.T) {
+	store := &flakyStore{MemoryStore: blocklist.NewMemoryStore()}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	list := blocklist.NewList(store, 15*time.Second).WithClock(func() time.Time { return now })
+	token := blocklist.Entry{Kind: blocklist.KindToken, Id: "api-key"}
+
+	_, blocked, _ := list.Blocked(context.Background(), token)
+	assert.False(t, blocked)
+
+	// Entries added within the TTL are not seen until the list is reloaded.
+	store.Add(token)
+	now = now.Add(14 * time.Second)
+	_, blocked, _ = list.Blocked(context.Background(), token)
+	assert.False(t, blocked)
+	assert.Equal(t, 1, store.loads)
+
+	now = now.Add(time.Second)
+	_, blocked, _ = list.Blocked(context.Background(), token)
+	assert.True(t, blocked)
+	assert.Equal(t, 2, store.loads)
+}
+
+func TestListKeepsEntriesWhenStoreFails(t *testing.T) {
+	token := blocklist.Entry{Kind: blocklist.KindToken, Id: "api-key"}
+	store := &flakyStore{MemoryStore: blocklist.NewMemoryStore(token)}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	list := blocklist.NewList(store, 15*time.Second).WithClock(func() time.Time { return now })
+
+	_, blocked, err := list.Blocked(context.Background(), token)
+	require.NoError(t, err)
+	assert.True(t, blocked)
+
+	store.err = errors.New("connection refused")
+	now = now.Add(time.Minute)
+	_, blocked, err = list.Blocked(context.Background(), token)
+	assert.ErrorContains(t, err, "connection refused")
+	assert.True(t, blocked)
+
+	// The reload is retried on the next check, and its result replaces the old entries.
+	store.err = nil
+	store.Remove(token.Kind, token.Id)
+	_, blocked, err = list.Blocked(context.Background(), token)
+	require.NoError(t, err)
+	assert.False(t, blocked)
+}
+
+func TestListStoreFailsBeforeFirstLoad(t *testing.T) {
+	store := &flakyStore{MemoryStore: blocklist.NewMemoryStore(), err: errors.New("connection refused")}
+	list := blocklist.NewList(store, time.Minute)
+
+	_, blocked, err := list.Blocked(context.Background(), blocklist.Entry{Kind: blocklist.KindUser, Id: "N:user:1"})
+	assert.ErrorContains(t, err, "unable to load block list")
+	assert.False(t, blocked)
+}
@@ -0,0 +1,74 @@
+package blocklist
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// DynamoAPI is the subset of *dynamodb.Client used by DynamoStore.
+type DynamoAPI interface {
+	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+}
+
+// DynamoStore keeps the block list in a DynamoDB table with string partition key "blockKey", an Entry's Key, e.g.
+// "user:N:user:...". Each item may hold a "reason" and an "expiresAt" time in Unix seconds, after which the entry
+// no longer applies, for use as the table's TTL attribute. The list is expected to stay small enough to scan.
+type DynamoStore struct {
+	client    DynamoAPI
+	tableName string
+	now       func() time.Time
+}
+
+func NewDynamoStore(client DynamoAPI, tableName string) *DynamoStore {
+	return &DynamoStore{client: client, tableName: tableName, now: time.Now}
+}
+
+func (s *DynamoStore) Load(ctx context.Context) ([]Entry, error) {
+	var entries []Entry
+	input := &dynamodb.ScanInput{TableName: aws.String(s.tableName), ConsistentRead: aws.Bool(true)}
+	for {
+		output, err := s.client.Scan(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range output.Items {
+			if entry, ok := s.entry(item); ok {
+				entries = append(entries, entry)
+			}
+		}
+		if len(output.LastEvaluatedKey) == 0 {
+			return entries, nil
+		}
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+}
+
+// entry returns the Entry in item, and whether it applies. Items without a well-formed key are ignored, so that one
+// bad item cannot hold up the rest of the list, while an entry with a malformed expiresAt still applies. DynamoDB
+// deletes expired items some time after they expire, so those are ignored here.
+func (s *DynamoStore) entry(item map[string]types.AttributeValue) (Entry, bool) {
+	key, ok := item["blockKey"].(*types.AttributeValueMemberS)
+	if !ok {
+		return Entry{}, false
+	}
+	kind, id, ok := strings.Cut(key.Value, ":")
+	if !ok || kind == "" || id == "" {
+		return Entry{}, false
+	}
+	entry := Entry{Kind: kind, Id: id}
+	if reason, ok := item["reason"].(*types.AttributeValueMemberS); ok {
+		entry.Reason = reason.Value
+	}
+	if expiresAt, ok := item["expiresAt"].(*types.AttributeValueMemberN); ok {
+		if seconds, err := strconv.ParseInt(expiresAt.Value, 10, 64); err == nil && !s.now().Before(time.Unix(seconds, 0)) {
+			return entry, false
+		}
+	}
+	return entry, true
+}
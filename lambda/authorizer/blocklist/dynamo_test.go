@@ -0,0 +1,71 @@
+package blocklist_test
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/blocklist"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDynamo returns its items from Scan, pageSize at a time.
+type fakeDynamo struct {
+	items    []map[string]types.AttributeValue
+	pageSize int
+	scans    int
+}
+
+func (f *fakeDynamo) Scan(_ context.Context, params *dynamodb.ScanInput, _ ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	f.scans++
+	start := 0
+	if params.ExclusiveStartKey != nil {
+		start, _ = strconv.Atoi(params.ExclusiveStartKey["page"].(*types.AttributeValueMemberN).Value)
+	}
+	end := min(start+f.pageSize, len(f.items))
+	output := &dynamodb.ScanOutput{Items: f.items[start:end]}
+	if end < len(f.items) {
+		output.LastEvaluatedKey = map[string]types.AttributeValue{"page": &types.AttributeValueMemberN{Value: strconv.Itoa(end)}}
+	}
+	return output, nil
+}
+
+func item(key string, attributes ...string) map[string]types.AttributeValue {
+	item := map[string]types.AttributeValue{"blockKey": &types.AttributeValueMemberS{Value: key}}
+	for i := 0; i+1 < len(attributes); i += 2 {
+		if attributes[i] == "expiresAt" {
+			item[attributes[i]] = &types.AttributeValueMemberN{Value: attributes[i+1]}
+		} else {
+			item[attributes[i]] = &types.AttributeValueMemberS{Value: attributes[i+1]}
+		}
+	}
+	return item
+}
+
+func TestDynamoStoreLoad(t *testing.T) {
+	past := fmt.Sprint(time.Now().Add(-time.Hour).Unix())
+	future := fmt.Sprint(time.Now().Add(time.Hour).Unix())
+	client := &fakeDynamo{pageSize: 2, items: []map[string]types.AttributeValue{
+		item("user:N:user:1", "reason", "INC-42"),
+		item("organization:N:organization:1", "expiresAt", future),
+		item("token:expired-key", "expiresAt", past),
+		item("no-kind"),
+		{"reason": &types.AttributeValueMemberS{Value: "no key"}},
+		item("username:jdoe", "expiresAt", "soon"),
+	}}
+
+	entries, err := blocklist.NewDynamoStore(client, "block-list").Load(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 3, client.scans)
+	assert.Equal(t, []blocklist.Entry{
+		{Kind: blocklist.KindUser, Id: "N:user:1", Reason: "INC-42"},
+		{Kind: blocklist.KindOrganization, Id: "N:organization:1"},
+		// A malformed expiry does not unblock the entry.
+		{Kind: blocklist.KindUsername, Id: "jdoe"},
+	}, entries)
+}
@@ -0,0 +1,46 @@
+package blocklist
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryStore is an in-process Store. Entries are not shared between Lambda instances, so it is only suitable for
+// tests and local runs.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]Entry
+}
+
+// NewMemoryStore returns a MemoryStore holding entries.
+func NewMemoryStore(entries ...Entry) *MemoryStore {
+	s := &MemoryStore{entries: map[string]Entry{}}
+	for _, entry := range entries {
+		s.Add(entry)
+	}
+	return s
+}
+
+// Add puts entry on the block list.
+func (s *MemoryStore) Add(entry Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[entry.Key()] = entry
+}
+
+// Remove takes the entry of the given kind and id off the block list.
+func (s *MemoryStore) Remove(kind string, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, Entry{Kind: kind, Id: id}.Key())
+}
+
+func (s *MemoryStore) Load(context.Context) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := make([]Entry, 0, len(s.entries))
+	for _, entry := range s.entries {
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
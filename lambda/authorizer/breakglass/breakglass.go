@@ -0,0 +1,36 @@
+// Package breakglass records when each break-glass grant started, so that a grant lasts a fixed time from its first
+// request rather than from each request. Starts live behind the Store interface: DynamoStore shares them across
+// Lambda instances, MemoryStore keeps them in-process for tests and local runs.
+package breakglass
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// Retention is how long a grant's start is kept after the grant has expired. Until then the same justification
+// cannot start another grant for the operator on the dataset.
+const Retention = 30 * 24 * time.Hour
+
+// Grant is an operator's emergency access to a dataset for one justification. A new justification starts a new
+// grant, and is audited as such.
+type Grant struct {
+	OperatorNodeId string
+	DatasetNodeId  string
+	Justification  string
+}
+
+// Key identifies the grant in a Store. The justification is hashed, so that it is kept only in the audit log.
+func (g Grant) Key() string {
+	sum := sha256.Sum256([]byte(g.Justification))
+	return g.OperatorNodeId + ":" + g.DatasetNodeId + ":" + hex.EncodeToString(sum[:])
+}
+
+// Store keeps the start of each grant.
+type Store interface {
+	// Start records that grant started at now, unless it already had, and returns when it started. The record may
+	// be deleted after deleteAfter.
+	Start(ctx context.Context, grant Grant, now time.Time, deleteAfter time.Time) (time.Time, error)
+}
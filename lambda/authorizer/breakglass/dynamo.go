@@ -0,0 +1,71 @@
+package breakglass
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// DynamoAPI is the subset of *dynamodb.Client used by DynamoStore.
+type DynamoAPI interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+}
+
+// DynamoStore keeps grant starts in a DynamoDB table with string partition key "grantKey", a Grant's Key. Each item
+// holds the "startedAt" time in Unix milliseconds and an "expiresAt" time in Unix seconds after which it may be
+// deleted, for use as the table's TTL attribute.
+type DynamoStore struct {
+	client    DynamoAPI
+	tableName string
+}
+
+func NewDynamoStore(client DynamoAPI, tableName string) *DynamoStore {
+	return &DynamoStore{client: client, tableName: tableName}
+}
+
+// Start writes the item only if there is none, so that concurrent first requests agree on one start, and otherwise
+// reads the start already recorded.
+func (s *DynamoStore) Start(ctx context.Context, grant Grant, now time.Time, deleteAfter time.Time) (time.Time, error) {
+	key := map[string]types.AttributeValue{"grantKey": &types.AttributeValueMemberS{Value: grant.Key()}}
+	_, err := s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item: map[string]types.AttributeValue{
+			"grantKey":  key["grantKey"],
+			"startedAt": &types.AttributeValueMemberN{Value: strconv.FormatInt(now.UnixMilli(), 10)},
+			"expiresAt": &types.AttributeValueMemberN{Value: strconv.FormatInt(deleteAfter.Unix(), 10)},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(grantKey)"),
+	})
+	if err == nil {
+		return now, nil
+	}
+	var exists *types.ConditionalCheckFailedException
+	if !errors.As(err, &exists) {
+		return time.Time{}, err
+	}
+
+	output, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(s.tableName),
+		Key:            key,
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	startedAt, ok := output.Item["startedAt"].(*types.AttributeValueMemberN)
+	if !ok {
+		return time.Time{}, fmt.Errorf("break-glass grant item attribute startedAt missing or not a number")
+	}
+	millis, err := strconv.ParseInt(startedAt.Value, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("break-glass grant item attribute startedAt: %w", err)
+	}
+	return time.UnixMilli(millis), nil
+}
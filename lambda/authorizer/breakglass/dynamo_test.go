@@ -0,0 +1,120 @@
+package breakglass_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/breakglass"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDynamo stores items by grantKey and understands the condition expression DynamoStore uses. err, if set, fails
+// every call.
+type fakeDynamo struct {
+	mu    sync.Mutex
+	items map[string]map[string]types.AttributeValue
+	err   error
+}
+
+func newFakeDynamo() *fakeDynamo {
+	return &fakeDynamo{items: map[string]map[string]types.AttributeValue{}}
+}
+
+func (f *fakeDynamo) GetItem(_ context.Context, params *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	key := params.Key["grantKey"].(*types.AttributeValueMemberS).Value
+	return &dynamodb.GetItemOutput{Item: f.items[key]}, nil
+}
+
+func (f *fakeDynamo) PutItem(_ context.Context, params *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	key := params.Item["grantKey"].(*types.AttributeValueMemberS).Value
+	if _, exists := f.items[key]; exists && *params.ConditionExpression == "attribute_not_exists(grantKey)" {
+		return nil, &types.ConditionalCheckFailedException{}
+	}
+	f.items[key] = params.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func TestDynamoStoreStart(t *testing.T) {
+	dynamo := newFakeDynamo()
+	store := breakglass.NewDynamoStore(dynamo, "break-glass")
+	grant := breakglass.Grant{OperatorNodeId: "N:user:1", DatasetNodeId: "N:dataset:1", Justification: "INC-1234: restoring files"}
+	first := time.UnixMilli(time.Now().UnixMilli())
+	deleteAfter := first.Add(time.Hour + breakglass.Retention)
+
+	started, err := store.Start(context.Background(), grant, first, deleteAfter)
+	require.NoError(t, err)
+	assert.Equal(t, first, started)
+	item := dynamo.items[grant.Key()]
+	require.NotNil(t, item)
+	assert.Equal(t, deleteAfter.Unix(), mustUnix(t, item["expiresAt"]))
+
+	started, err = store.Start(context.Background(), grant, first.Add(10*time.Minute), first.Add(10*time.Minute+time.Hour+breakglass.Retention))
+	require.NoError(t, err)
+	assert.Equal(t, first, started, "a later request keeps the first start")
+	assert.Equal(t, deleteAfter.Unix(), mustUnix(t, dynamo.items[grant.Key()]["expiresAt"]))
+
+	other := grant
+	other.Justification = "INC-5678: a different incident"
+	started, err = store.Start(context.Background(), other, first.Add(10*time.Minute), deleteAfter)
+	require.NoError(t, err)
+	assert.Equal(t, first.Add(10*time.Minute), started, "a new justification starts a new grant")
+}
+
+func TestDynamoStoreStartFailure(t *testing.T) {
+	dynamo := newFakeDynamo()
+	dynamo.err = errors.New("throttled")
+	store := breakglass.NewDynamoStore(dynamo, "break-glass")
+
+	_, err := store.Start(context.Background(), breakglass.Grant{OperatorNodeId: "N:user:1", DatasetNodeId: "N:dataset:1"}, time.Now(), time.Now())
+	assert.ErrorContains(t, err, "throttled")
+}
+
+func TestDynamoStoreMalformedItem(t *testing.T) {
+	dynamo := newFakeDynamo()
+	store := breakglass.NewDynamoStore(dynamo, "break-glass")
+	grant := breakglass.Grant{OperatorNodeId: "N:user:1", DatasetNodeId: "N:dataset:1"}
+	dynamo.items[grant.Key()] = map[string]types.AttributeValue{"grantKey": &types.AttributeValueMemberS{Value: grant.Key()}}
+
+	_, err := store.Start(context.Background(), grant, time.Now(), time.Now())
+	assert.ErrorContains(t, err, "startedAt")
+}
+
+func TestGrantKey(t *testing.T) {
+	grant := breakglass.Grant{OperatorNodeId: "N:user:1", DatasetNodeId: "N:dataset:1", Justification: "INC-1234: restoring files"}
+	assert.NotContains(t, grant.Key(), grant.Justification)
+	assert.Equal(t, grant.Key(), breakglass.Grant{OperatorNodeId: "N:user:1", DatasetNodeId: "N:dataset:1", Justification: "INC-1234: restoring files"}.Key())
+
+	for _, other := range []breakglass.Grant{
+		{OperatorNodeId: "N:user:2", DatasetNodeId: "N:dataset:1", Justification: grant.Justification},
+		{OperatorNodeId: "N:user:1", DatasetNodeId: "N:dataset:2", Justification: grant.Justification},
+		{OperatorNodeId: "N:user:1", DatasetNodeId: "N:dataset:1", Justification: "INC-5678: a different incident"},
+	} {
+		assert.NotEqual(t, grant.Key(), other.Key())
+	}
+}
+
+func mustUnix(t *testing.T, value types.AttributeValue) int64 {
+	t.Helper()
+	number, ok := value.(*types.AttributeValueMemberN)
+	require.True(t, ok)
+	parsed, err := strconv.ParseInt(number.Value, 10, 64)
+	require.NoError(t, err)
+	return parsed
+}
@@ -0,0 +1,29 @@
+package breakglass
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore is an in-process Store. Starts are not shared between Lambda instances, so it is only suitable for
+// tests and local runs.
+type MemoryStore struct {
+	mu     sync.Mutex
+	starts map[string]time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{starts: map[string]time.Time{}}
+}
+
+func (s *MemoryStore) Start(_ context.Context, grant Grant, now time.Time, _ time.Time) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	started, ok := s.starts[grant.Key()]
+	if !ok {
+		started = now
+		s.starts[grant.Key()] = started
+	}
+	return started, nil
+}
@@ -0,0 +1,167 @@
+// Package cache keeps the results of slow or immutable lookups in memory for the lifetime of a warm
+// Lambda container, so that requests the API Gateway authorizer cache misses (a new dataset, a direct
+// or callback invocation) need not repeat them.
+package cache
+
+import (
+	"container/list"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Default bounds, used when the corresponding CLAIMS_CACHE_* variable is unset.
+const (
+	DefaultMaxEntries = 10000
+	DefaultUserTtl    = 30 * time.Second
+	DefaultClaimTtl   = 30 * time.Second
+	// DatasetOrganizationTtl bounds how long a dataset's organization is kept. The mapping never changes, so
+	// this only limits how long an entry for a deleted dataset lingers.
+	DatasetOrganizationTtl = time.Hour
+)
+
+// Config bounds the process-local claims cache.
+type Config struct {
+	// Enabled is false when CLAIMS_CACHE_ENABLED is "false"; every lookup then goes to its source.
+	Enabled bool
+	// MaxEntries is the most entries each kind of lookup keeps; the least recently used are evicted first.
+	MaxEntries int
+	// UserTtl is how long users looked up by Cognito or node id are kept. It is no longer than ClaimTtl, since a
+	// user's is_super_admin grants access as their roles do, and impersonation besides.
+	UserTtl time.Duration
+	// ClaimTtl is how long organization and team claims are kept. A change to a user's role or membership can
+	// take this long to be seen.
+	ClaimTtl time.Duration
+}
+
+// ConfigFromEnv reads the Config from CLAIMS_CACHE_ENABLED, CLAIMS_CACHE_MAX_ENTRIES, CLAIMS_CACHE_USER_TTL and
+// CLAIMS_CACHE_CLAIM_TTL (both in seconds), of which the first must not exceed the second. The cache is enabled
+// unless disabled explicitly. On error, the returned Config is disabled.
+func ConfigFromEnv() (Config, error) {
+	config := Config{Enabled: true, MaxEntries: DefaultMaxEntries, UserTtl: DefaultUserTtl, ClaimTtl: DefaultClaimTtl}
+	if raw := os.Getenv("CLAIMS_CACHE_ENABLED"); raw != "" {
+		enabled, err := strconv.ParseBool(raw)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid CLAIMS_CACHE_ENABLED: %q is not a boolean", raw)
+		}
+		config.Enabled = enabled
+	}
+	if raw := os.Getenv("CLAIMS_CACHE_MAX_ENTRIES"); raw != "" {
+		maxEntries, err := strconv.Atoi(raw)
+		if err != nil || maxEntries < 1 {
+			return Config{}, fmt.Errorf("invalid CLAIMS_CACHE_MAX_ENTRIES: %q is not a positive number", raw)
+		}
+		config.MaxEntries = maxEntries
+	}
+	for name, target := range map[string]*time.Duration{"CLAIMS_CACHE_USER_TTL": &config.UserTtl, "CLAIMS_CACHE_CLAIM_TTL": &config.ClaimTtl} {
+		raw := os.Getenv(name)
+		if raw == "" {
+			continue
+		}
+		seconds, err := strconv.Atoi(raw)
+		if err != nil || seconds < 0 {
+			return Config{}, fmt.Errorf("invalid %s: %q is not a number of seconds", name, raw)
+		}
+		*target = time.Duration(seconds) * time.Second
+	}
+	if config.UserTtl > config.ClaimTtl {
+		return Config{}, fmt.Errorf("invalid CLAIMS_CACHE_USER_TTL: %s exceeds CLAIMS_CACHE_CLAIM_TTL, %s", config.UserTtl, config.ClaimTtl)
+	}
+	return config, nil
+}
+
+type entry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+}
+
+// LRU is a size-bounded map whose entries expire. It is safe for concurrent use.
+type LRU[K comparable, V any] struct {
+	mu         sync.Mutex
+	maxEntries int
+	ttl        time.Duration
+	order      *list.List
+	entries    map[K]*list.Element
+	now        func() time.Time
+}
+
+// NewLRU returns an LRU that keeps at most maxEntries entries, each for ttl.
+func NewLRU[K comparable, V any](maxEntries int, ttl time.Duration) *LRU[K, V] {
+	return &LRU[K, V]{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		order:      list.New(),
+		entries:    map[K]*list.Element{},
+		now:        time.Now,
+	}
+}
+
+// WithClock makes the LRU read the time from now, for tests.
+func (l *LRU[K, V]) WithClock(now func() time.Time) *LRU[K, V] {
+	l.now = now
+	return l
+}
+
+// Get returns the value stored under key, if there is one and it has not expired.
+func (l *LRU[K, V]) Get(key K) (V, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var zero V
+	element, ok := l.entries[key]
+	if !ok {
+		return zero, false
+	}
+	e := element.Value.(*entry[K, V])
+	if !l.now().Before(e.expires) {
+		l.remove(element)
+		return zero, false
+	}
+	l.order.MoveToFront(element)
+	return e.value, true
+}
+
+// Add stores value under key, evicting the least recently used entry if the LRU is full. It reports whether an
+// entry was evicted.
+func (l *LRU[K, V]) Add(key K, value V) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	expires := l.now().Add(l.ttl)
+	if element, ok := l.entries[key]; ok {
+		e := element.Value.(*entry[K, V])
+		e.value, e.expires = value, expires
+		l.order.MoveToFront(element)
+		return false
+	}
+	l.entries[key] = l.order.PushFront(&entry[K, V]{key: key, value: value, expires: expires})
+	if l.order.Len() <= l.maxEntries {
+		return false
+	}
+	l.remove(l.order.Back())
+	return true
+}
+
+// Remove removes the entry stored under key, if there is one.
+func (l *LRU[K, V]) Remove(key K) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if element, ok := l.entries[key]; ok {
+		l.remove(element)
+	}
+}
+
+// Len returns the number of entries, including any that have expired but not yet been removed.
+func (l *LRU[K, V]) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len()
+}
+
+func (l *LRU[K, V]) remove(element *list.Element) {
+	l.order.Remove(element)
+	delete(l.entries, element.Value.(*entry[K, V]).key)
+}
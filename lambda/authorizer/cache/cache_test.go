@@ -0,0 +1,79 @@
+package cache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pennsieve/pennsieve-go-api/authorizer/cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRU(t *testing.T) {
+	now := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	lru := cache.NewLRU[string, int](2, time.Minute).WithClock(func() time.Time { return now })
+
+	assert.False(t, lru.Add("a", 1))
+	assert.False(t, lru.Add("b", 2))
+	value, ok := lru.Get("a")
+	require.True(t, ok)
+	assert.Equal(t, 1, value)
+
+	// "b" is now the least recently used.
+	assert.True(t, lru.Add("c", 3))
+	_, ok = lru.Get("b")
+	assert.False(t, ok)
+	assert.Equal(t, 2, lru.Len())
+
+	// Replacing a value does not evict and restarts its TTL.
+	now = now.Add(30 * time.Second)
+	assert.False(t, lru.Add("a", 10))
+	now = now.Add(45 * time.Second)
+	_, ok = lru.Get("c")
+	assert.False(t, ok, "expired")
+	value, ok = lru.Get("a")
+	require.True(t, ok)
+	assert.Equal(t, 10, value)
+	assert.Equal(t, 1, lru.Len())
+
+	lru.Remove("a")
+	_, ok = lru.Get("a")
+	assert.False(t, ok, "removed")
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	config, err := cache.ConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, cache.Config{Enabled: true, MaxEntries: cache.DefaultMaxEntries, UserTtl: cache.DefaultUserTtl, ClaimTtl: cache.DefaultClaimTtl}, config)
+
+	t.Setenv("CLAIMS_CACHE_MAX_ENTRIES", "500")
+	t.Setenv("CLAIMS_CACHE_USER_TTL", "0")
+	t.Setenv("CLAIMS_CACHE_CLAIM_TTL", "60")
+	config, err = cache.ConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, cache.Config{Enabled: true, MaxEntries: 500, ClaimTtl: time.Minute}, config)
+
+	t.Setenv("CLAIMS_CACHE_USER_TTL", "61")
+	config, err = cache.ConfigFromEnv()
+	assert.ErrorContains(t, err, "invalid CLAIMS_CACHE_USER_TTL: 1m1s exceeds CLAIMS_CACHE_CLAIM_TTL")
+	assert.False(t, config.Enabled)
+	t.Setenv("CLAIMS_CACHE_USER_TTL", "0")
+
+	t.Setenv("CLAIMS_CACHE_ENABLED", "false")
+	config, err = cache.ConfigFromEnv()
+	require.NoError(t, err)
+	assert.False(t, config.Enabled)
+
+	for name, value := range map[string]string{
+		"CLAIMS_CACHE_ENABLED":     "sometimes",
+		"CLAIMS_CACHE_MAX_ENTRIES": "0",
+		"CLAIMS_CACHE_CLAIM_TTL":   "30s",
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, value)
+			config, err := cache.ConfigFromEnv()
+			assert.ErrorContains(t, err, "invalid "+name)
+			assert.False(t, config.Enabled)
+		})
+	}
+}
@@ -0,0 +1,363 @@
+// Package claims is the consumer-side client for the claims this authorizer produces.
+//
+// The authorizer hands its claims to downstream services in two different shapes:
+//
+//   - HTTP API (payload format 2.0) routes receive the nested claims map produced by the
+//     authorizers package, keyed by the pennsieve-go-core `authorizer.Label*` constants, under
+//     `requestContext.authorizer.lambda`.
+//   - WebSocket routes receive the flattened, scalar-only context built by the WebSocket
+//     authorizer (payload format 1.0 cannot carry nested values), where each claim is a JSON
+//     string under the Key* constants below.
+//
+// Both shapes are parsed into the same ResolvedClaims so consumers never need to know which
+// route type, or which authentication flow, produced them.
+package claims
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	coreAuthorizer "github.com/pennsieve/pennsieve-go-core/pkg/authorizer"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dataset"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/organization"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/role"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/teamUser"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/user"
+)
+
+// Keys of the flattened WebSocket authorizer context. The WebSocket authorizer writes these and
+// FromWebSocketAuthorizer reads them, so they are the single definition of that contract.
+const (
+	KeyUserNodeId           = "userNodeId"
+	KeyOrgNodeId            = "orgNodeId"
+	KeyDatasetNodeId        = "datasetNodeId"
+	KeyDatasetRole          = "datasetRole"
+	KeyUserClaim            = "userClaim"
+	KeyOrgClaim             = "orgClaim"
+	KeyDatasetClaim         = "datasetClaim"
+	KeyTeamClaims           = "teamClaims"
+	KeyComputeNodeAccess    = "computeNodeAccess"
+	KeyTraceId              = "traceId"
+	KeyExternalCollaborator = "externalCollaborator"
+	KeyDatasetState         = "datasetState"
+	KeyGrantExpiry          = "grantExpiry"
+	KeyPurposeOfUse         = "purposeOfUse"
+	KeyImpersonatorClaim    = "impersonatorClaim"
+	KeyStale                = "stale"
+	KeyBreakGlass           = "breakGlass"
+	KeyResourceClaims       = "resourceClaims"
+	KeyErrorReason          = "errorReason"
+)
+
+// LabelImpersonatorClaim is the claims map key under which the authorizer identifies the super-admin
+// acting as the user in LabelUserClaim. It is only present on impersonated requests.
+const LabelImpersonatorClaim = "impersonator_claim"
+
+// LabelTraceId is the claims map key holding the ID of the trace the authorization was part of, so
+// downstream services can join their spans to it. It is absent when the request carried no trace context.
+const LabelTraceId = "trace_id"
+
+// LabelExternalCollaborator is the claims map key that is true when the user's access to the dataset comes from a
+// grant on the dataset alone, not from membership of its organization. The organization claim of such a user has
+// no role in the organization.
+const LabelExternalCollaborator = "external_collaborator"
+
+// LabelDatasetState is the claims map key holding the DatasetState of the dataset in LabelDatasetClaim.
+const LabelDatasetState = "dataset_state"
+
+// DatasetState is the lifecycle state of a dataset, as far as it restricts what may be done to it.
+type DatasetState struct {
+	// Status is the name of the dataset's workflow status, e.g. "NO_STATUS".
+	Status string
+	// PublicationStatus is the status of the dataset's latest publication request, e.g. "requested", or empty if
+	// there has been none.
+	PublicationStatus string
+	// PublicationLocked is true while a publication request is being processed, when the dataset must not change.
+	PublicationLocked bool
+	// Deleted is true once the dataset has been deleted; its data is then being removed.
+	Deleted bool
+	// Protected is true if the dataset holds protected health information, so that every request for it must
+	// declare a purpose of use.
+	Protected bool
+}
+
+// Locked reports whether the dataset must not be modified.
+func (s DatasetState) Locked() bool {
+	return s.PublicationLocked || s.Deleted
+}
+
+// LabelGrantExpiry is the claims map key holding the GrantExpiry of the roles in the organization and dataset
+// claims. It is only present when one of those roles expires.
+const LabelGrantExpiry = "grant_expiry"
+
+// GrantExpiry is when the roles granted to the user expire. A nil time means the role does not expire.
+type GrantExpiry struct {
+	// Organization is when the user's membership of the organization in the organization claim expires.
+	Organization *time.Time
+	// Dataset is when the role in the dataset claim expires. The user may keep a lower role after that, e.g. the
+	// dataset's default role for organization members.
+	Dataset *time.Time
+}
+
+// Expires reports whether any of the roles expire.
+func (e GrantExpiry) Expires() bool {
+	return e.Organization != nil || e.Dataset != nil
+}
+
+// LabelStale is the claims map key that is true when the claims were served from the last claims resolved for the
+// same user and resource because Postgres was unavailable. Only read-only requests are served stale claims.
+const LabelStale = "stale"
+
+// LabelBreakGlass is the claims map key that is true when the dataset claim is a time-limited viewer role granted
+// to an operator for an emergency, not a role the user holds. Its expiry is in LabelGrantExpiry.
+const LabelBreakGlass = "break_glass"
+
+// LabelPurposeOfUse is the claims map key holding the purpose of use the client declared for the request in the
+// X-Purpose-Of-Use header, one of the PurposeOfUse* values. It is absent when the client declared none.
+const LabelPurposeOfUse = "purpose_of_use"
+
+// Purposes of use a client may declare, recorded to account for disclosures of protected health information.
+const (
+	PurposeOfUseTreatment    = "treatment"
+	PurposeOfUsePayment      = "payment"
+	PurposeOfUseOperations   = "operations"
+	PurposeOfUseResearch     = "research"
+	PurposeOfUsePublicHealth = "public_health"
+	PurposeOfUseEmergency    = "emergency"
+	PurposeOfUseLegal        = "legal"
+)
+
+// LabelResourceClaims is the claims map key holding, on routes that authorize several resources, the claims of
+// each resource keyed by resource name (the parameter that named it).
+const LabelResourceClaims = "resource_claims"
+
+// ErrNoClaims is returned when the event carries no authorizer context at all, e.g. a route
+// that is not protected by this authorizer.
+var ErrNoClaims = errors.New("no authorizer claims found in request context")
+
+// ResolvedClaims is the typed form of the claims produced by the authorizer. Any claim the
+// authorizer did not produce for the route (e.g. Dataset on a workspace route) is nil/empty.
+type ResolvedClaims struct {
+	User         *user.Claim
+	Organization *organization.Claim
+	Dataset      *dataset.Claim
+	Teams        []teamUser.Claim
+	// Impersonator is the super-admin acting as User, or nil if the request is not impersonated.
+	Impersonator *user.Claim
+	// ComputeNodeAccess is only set on WebSocket connections that ran a compute-node access check.
+	ComputeNodeAccess string
+	// TraceId is the OpenTelemetry trace ID of the authorization, or empty if it was not traced.
+	TraceId string
+	// ExternalCollaborator is true if the user is not a member of the dataset's organization and has access only
+	// through a grant on the dataset.
+	ExternalCollaborator bool
+	// DatasetState is the state of Dataset, or nil if there is no dataset claim.
+	DatasetState *DatasetState
+	// GrantExpiry is when the roles in Organization and Dataset expire, or nil if neither does.
+	GrantExpiry *GrantExpiry
+	// Stale is true if the claims were resolved earlier and served again because Postgres was unavailable. They may
+	// not reflect changes since.
+	Stale bool
+	// BreakGlass is true if Dataset is an emergency viewer role granted to an operator, which expires at
+	// GrantExpiry.Dataset, rather than a role the user holds.
+	BreakGlass bool
+	// PurposeOfUse is the purpose of use the client declared for the request, or empty if it declared none.
+	PurposeOfUse string
+	// Resources holds the claims of each resource on routes that authorize several, keyed by resource name. The
+	// top-level claims are then those of the first resource.
+	Resources map[string]*ResolvedClaims
+}
+
+// FromHTTPRequestContext parses the claims of an HTTP API (payload format 2.0) request.
+func FromHTTPRequestContext(requestContext events.APIGatewayV2HTTPRequestContext) (*ResolvedClaims, error) {
+	if requestContext.Authorizer == nil || requestContext.Authorizer.Lambda == nil {
+		return nil, ErrNoClaims
+	}
+	return FromLambdaContext(requestContext.Authorizer.Lambda)
+}
+
+// FromLambdaContext parses the nested claims map that the authorizer returns as the context of a
+// simple response, i.e. the value of `requestContext.authorizer.lambda`.
+func FromLambdaContext(lambdaContext map[string]interface{}) (*ResolvedClaims, error) {
+	if len(lambdaContext) == 0 {
+		return nil, ErrNoClaims
+	}
+
+	resolved := &ResolvedClaims{}
+	for label, target := range map[string]interface{}{
+		coreAuthorizer.LabelUserClaim:         &resolved.User,
+		coreAuthorizer.LabelOrganizationClaim: &resolved.Organization,
+		coreAuthorizer.LabelDatasetClaim:      &resolved.Dataset,
+		coreAuthorizer.LabelTeamClaims:        &resolved.Teams,
+		LabelImpersonatorClaim:                &resolved.Impersonator,
+		LabelTraceId:                          &resolved.TraceId,
+		LabelExternalCollaborator:             &resolved.ExternalCollaborator,
+		LabelDatasetState:                     &resolved.DatasetState,
+		LabelGrantExpiry:                      &resolved.GrantExpiry,
+		LabelStale:                            &resolved.Stale,
+		LabelBreakGlass:                       &resolved.BreakGlass,
+		LabelPurposeOfUse:                     &resolved.PurposeOfUse,
+	} {
+		value, ok := lambdaContext[label]
+		if !ok || value == nil {
+			continue
+		}
+		if err := remarshal(value, target); err != nil {
+			return nil, fmt.Errorf("unable to parse %s: %w", label, err)
+		}
+	}
+
+	if value, ok := lambdaContext[LabelResourceClaims]; ok && value != nil {
+		var resources map[string]map[string]interface{}
+		if err := remarshal(value, &resources); err != nil {
+			return nil, fmt.Errorf("unable to parse %s: %w", LabelResourceClaims, err)
+		}
+		var err error
+		if resolved.Resources, err = fromResourceContexts(resources); err != nil {
+			return nil, err
+		}
+	}
+	return resolved, nil
+}
+
+// fromResourceContexts parses the nested claims map of each resource on a route that authorizes several.
+func fromResourceContexts(resources map[string]map[string]interface{}) (map[string]*ResolvedClaims, error) {
+	resolved := make(map[string]*ResolvedClaims, len(resources))
+	for name, resourceContext := range resources {
+		resource, err := FromLambdaContext(resourceContext)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse %s of resource %s: %w", LabelResourceClaims, name, err)
+		}
+		resolved[name] = resource
+	}
+	return resolved, nil
+}
+
+// FromWebSocketRequest parses the claims of a WebSocket (payload format 1.0) request.
+func FromWebSocketRequest(request events.APIGatewayWebsocketProxyRequest) (*ResolvedClaims, error) {
+	authorizer, ok := request.RequestContext.Authorizer.(map[string]interface{})
+	if !ok {
+		return nil, ErrNoClaims
+	}
+	return FromWebSocketAuthorizer(authorizer)
+}
+
+// FromWebSocketAuthorizer parses the flattened context produced by the WebSocket authorizer, i.e.
+// the value of `requestContext.authorizer` on WebSocket route events.
+func FromWebSocketAuthorizer(authorizer map[string]interface{}) (*ResolvedClaims, error) {
+	if len(authorizer) == 0 {
+		return nil, ErrNoClaims
+	}
+	if reason, ok := authorizer[KeyErrorReason]; ok {
+		return nil, fmt.Errorf("request was not authorized: %v", reason)
+	}
+
+	resolved := &ResolvedClaims{}
+	for key, target := range map[string]interface{}{
+		KeyUserClaim:         &resolved.User,
+		KeyOrgClaim:          &resolved.Organization,
+		KeyDatasetClaim:      &resolved.Dataset,
+		KeyTeamClaims:        &resolved.Teams,
+		KeyDatasetState:      &resolved.DatasetState,
+		KeyGrantExpiry:       &resolved.GrantExpiry,
+		KeyImpersonatorClaim: &resolved.Impersonator,
+	} {
+		value, ok := authorizer[key]
+		if !ok || value == nil {
+			continue
+		}
+		encoded, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("unable to parse %s: expected JSON string, got %T", key, value)
+		}
+		if err := json.Unmarshal([]byte(encoded), target); err != nil {
+			return nil, fmt.Errorf("unable to parse %s: %w", key, err)
+		}
+	}
+	if access, ok := authorizer[KeyComputeNodeAccess].(string); ok {
+		resolved.ComputeNodeAccess = access
+	}
+	if traceId, ok := authorizer[KeyTraceId].(string); ok {
+		resolved.TraceId = traceId
+	}
+	if external, ok := authorizer[KeyExternalCollaborator].(string); ok {
+		resolved.ExternalCollaborator = external == "true"
+	}
+	if purposeOfUse, ok := authorizer[KeyPurposeOfUse].(string); ok {
+		resolved.PurposeOfUse = purposeOfUse
+	}
+	if stale, ok := authorizer[KeyStale].(string); ok {
+		resolved.Stale = stale == "true"
+	}
+	if breakGlass, ok := authorizer[KeyBreakGlass].(string); ok {
+		resolved.BreakGlass = breakGlass == "true"
+	}
+	if encoded, ok := authorizer[KeyResourceClaims].(string); ok {
+		var resources map[string]map[string]interface{}
+		if err := json.Unmarshal([]byte(encoded), &resources); err != nil {
+			return nil, fmt.Errorf("unable to parse %s: %w", KeyResourceClaims, err)
+		}
+		var err error
+		if resolved.Resources, err = fromResourceContexts(resources); err != nil {
+			return nil, err
+		}
+	}
+	return resolved, nil
+}
+
+// UserNodeId returns the node id of the authorized user, or an empty string if there is no user claim.
+func (c *ResolvedClaims) UserNodeId() string {
+	if c.User == nil {
+		return ""
+	}
+	return c.User.NodeId
+}
+
+// IsSuperAdmin reports whether the authorized user is a Pennsieve super-admin.
+func (c *ResolvedClaims) IsSuperAdmin() bool {
+	return c.User != nil && c.User.IsSuperAdmin
+}
+
+// IsImpersonated reports whether a super-admin is acting as the authorized user.
+func (c *ResolvedClaims) IsImpersonated() bool {
+	return c.Impersonator != nil
+}
+
+// HasDatasetRole returns true if the dataset claim grants at least minRole. It returns false if
+// there is no dataset claim.
+func (c *ResolvedClaims) HasDatasetRole(minRole role.Role) bool {
+	return c.Dataset != nil && c.Dataset.Role.Implies(minRole)
+}
+
+// HasOrgRole returns true if the organization claim grants at least minRole. It returns false if
+// there is no organization claim.
+func (c *ResolvedClaims) HasOrgRole(minRole role.Role) bool {
+	return c.Organization != nil && c.Organization.HasRole(minRole)
+}
+
+// Resource returns the claims of the named resource on a route that authorizes several, or nil if there is none.
+func (c *ResolvedClaims) Resource(name string) *ResolvedClaims {
+	return c.Resources[name]
+}
+
+// InTeam returns true if the user is a member of the team with the given node id.
+func (c *ResolvedClaims) InTeam(teamNodeId string) bool {
+	for _, team := range c.Teams {
+		if team.NodeId == teamNodeId {
+			return true
+		}
+	}
+	return false
+}
+
+// remarshal converts a decoded JSON value (or, in-process, the original claim struct) into target.
+func remarshal(value interface{}, target interface{}) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(encoded, target)
+}
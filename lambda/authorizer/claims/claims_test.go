@@ -0,0 +1,144 @@
+package claims_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/claims"
+	coreAuthorizer "github.com/pennsieve/pennsieve-go-core/pkg/authorizer"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dataset"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/organization"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/role"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/teamUser"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolvedClaimsHelpers(t *testing.T) {
+	resolved := &claims.ResolvedClaims{
+		User:         &user.Claim{Id: 101, NodeId: "N:user:abc", IsSuperAdmin: true},
+		Organization: &organization.Claim{Role: pgdb.Write, IntId: 2, NodeId: "N:organization:abc"},
+		Dataset:      &dataset.Claim{Role: role.Editor, IntId: 5, NodeId: "N:dataset:abc"},
+		Teams:        []teamUser.Claim{{IntId: 3, NodeId: "N:team:abc", Name: "publishers"}},
+	}
+
+	assert.Equal(t, "N:user:abc", resolved.UserNodeId())
+	assert.True(t, resolved.IsSuperAdmin())
+	assert.True(t, resolved.HasDatasetRole(role.Viewer))
+	assert.True(t, resolved.HasDatasetRole(role.Editor))
+	assert.False(t, resolved.HasDatasetRole(role.Manager))
+	assert.True(t, resolved.HasOrgRole(role.Editor))
+	assert.False(t, resolved.HasOrgRole(role.Manager))
+	assert.True(t, resolved.InTeam("N:team:abc"))
+	assert.False(t, resolved.InTeam("N:team:other"))
+}
+
+func TestResolvedClaimsHelpersMissingClaims(t *testing.T) {
+	resolved := &claims.ResolvedClaims{}
+
+	assert.Empty(t, resolved.UserNodeId())
+	assert.False(t, resolved.IsSuperAdmin())
+	assert.False(t, resolved.HasDatasetRole(role.None))
+	assert.False(t, resolved.HasOrgRole(role.None))
+}
+
+func TestFromHTTPRequestContextNoAuthorizer(t *testing.T) {
+	_, err := claims.FromHTTPRequestContext(events.APIGatewayV2HTTPRequestContext{})
+	assert.ErrorIs(t, err, claims.ErrNoClaims)
+}
+
+func TestFromLambdaContextMalformedClaim(t *testing.T) {
+	_, err := claims.FromLambdaContext(map[string]interface{}{
+		coreAuthorizer.LabelDatasetClaim: "not an object",
+	})
+	assert.ErrorContains(t, err, coreAuthorizer.LabelDatasetClaim)
+}
+
+func TestFromLambdaContextStale(t *testing.T) {
+	resolved, err := claims.FromLambdaContext(map[string]interface{}{
+		coreAuthorizer.LabelUserClaim: map[string]interface{}{"Id": 101, "NodeId": "N:user:abc"},
+		claims.LabelStale:             true,
+	})
+	require.NoError(t, err)
+	assert.True(t, resolved.Stale)
+}
+
+func TestFromLambdaContextBreakGlass(t *testing.T) {
+	resolved, err := claims.FromLambdaContext(map[string]interface{}{
+		coreAuthorizer.LabelUserClaim:    map[string]interface{}{"Id": 101, "NodeId": "N:user:abc"},
+		coreAuthorizer.LabelDatasetClaim: map[string]interface{}{"Role": role.Viewer, "NodeId": "N:dataset:abc"},
+		claims.LabelGrantExpiry:          map[string]interface{}{"Dataset": "2026-11-01T00:00:00Z"},
+		claims.LabelBreakGlass:           true,
+	})
+	require.NoError(t, err)
+	assert.True(t, resolved.BreakGlass)
+	require.NotNil(t, resolved.GrantExpiry)
+	assert.Equal(t, time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC), *resolved.GrantExpiry.Dataset)
+}
+
+func TestFromLambdaContextResourceClaims(t *testing.T) {
+	resolved, err := claims.FromLambdaContext(map[string]interface{}{
+		coreAuthorizer.LabelUserClaim:    map[string]interface{}{"Id": 101, "NodeId": "N:user:abc"},
+		coreAuthorizer.LabelDatasetClaim: map[string]interface{}{"Role": role.Viewer, "NodeId": "N:dataset:source"},
+		claims.LabelResourceClaims: map[string]interface{}{
+			"source_dataset_id": map[string]interface{}{
+				coreAuthorizer.LabelDatasetClaim: map[string]interface{}{"Role": role.Viewer, "NodeId": "N:dataset:source"},
+			},
+			"target_dataset_id": map[string]interface{}{
+				coreAuthorizer.LabelDatasetClaim: map[string]interface{}{"Role": role.Editor, "NodeId": "N:dataset:target"},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.False(t, resolved.ExternalCollaborator)
+	assert.False(t, resolved.Stale)
+	assert.False(t, resolved.BreakGlass)
+	assert.Equal(t, "N:dataset:source", resolved.Dataset.NodeId)
+	require.NotNil(t, resolved.Resource("target_dataset_id"))
+	assert.Equal(t, "N:dataset:target", resolved.Resource("target_dataset_id").Dataset.NodeId)
+	assert.True(t, resolved.Resource("target_dataset_id").HasDatasetRole(role.Editor))
+	assert.False(t, resolved.Resource("source_dataset_id").HasDatasetRole(role.Editor))
+	assert.Nil(t, resolved.Resource("dataset_id"))
+}
+
+func TestFromWebSocketAuthorizer(t *testing.T) {
+	for scenario, params := range map[string]struct {
+		authorizer        map[string]interface{}
+		expectedErrorText string
+	}{
+		"no context":         {map[string]interface{}{}, claims.ErrNoClaims.Error()},
+		"deny context":       {map[string]interface{}{claims.KeyErrorReason: "invalid_token"}, "invalid_token"},
+		"claim not a string": {map[string]interface{}{claims.KeyUserClaim: 5.0}, "expected JSON string"},
+		"claim not JSON":     {map[string]interface{}{claims.KeyUserClaim: "{"}, claims.KeyUserClaim},
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			_, err := claims.FromWebSocketAuthorizer(params.authorizer)
+			assert.ErrorContains(t, err, params.expectedErrorText)
+		})
+	}
+
+	resolved, err := claims.FromWebSocketAuthorizer(map[string]interface{}{
+		claims.KeyUserNodeId:           "N:user:abc",
+		claims.KeyUserClaim:            `{"Id":101,"NodeId":"N:user:abc","IsSuperAdmin":false}`,
+		claims.KeyComputeNodeAccess:    "owner",
+		claims.KeyExternalCollaborator: "true",
+		claims.KeyDatasetState:         `{"Status":"NO_STATUS","PublicationStatus":"accepted","PublicationLocked":true}`,
+		claims.KeyGrantExpiry:          `{"Organization":null,"Dataset":"2026-11-01T00:00:00Z"}`,
+		claims.KeyPurposeOfUse:         claims.PurposeOfUseTreatment,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &user.Claim{Id: 101, NodeId: "N:user:abc"}, resolved.User)
+	assert.Nil(t, resolved.Dataset)
+	assert.Equal(t, "owner", resolved.ComputeNodeAccess)
+	assert.True(t, resolved.ExternalCollaborator)
+	assert.Equal(t, claims.PurposeOfUseTreatment, resolved.PurposeOfUse)
+	require.NotNil(t, resolved.DatasetState)
+	assert.True(t, resolved.DatasetState.Locked())
+	require.NotNil(t, resolved.GrantExpiry)
+	assert.Nil(t, resolved.GrantExpiry.Organization)
+	assert.Equal(t, time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC), *resolved.GrantExpiry.Dataset)
+}
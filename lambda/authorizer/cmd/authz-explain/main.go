@@ -0,0 +1,97 @@
+// Command authz-explain runs the authorizer chain for a user and resource against Postgres and DynamoDB and prints
+// each lookup, the resulting claims, and the final decision with its reason code.
+//
+// The user is given either by node id (-user) or by Cognito username (-cognito-username, with -token-pool for API
+// tokens). The resource is at most one of -dataset, -org, -manifest, or -package together with -org. Database and
+// AWS settings are read from the same environment variables as the authorizer Lambdas (ENV, RDS_PROXY_ENDPOINT or
+// POSTGRES_*, REGION, TOKEN_CLIENT, MANIFEST_TABLE).
+//
+// Exits 0 if the request would be allowed, 1 if it would be denied or indeterminate, and 2 on a usage error.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/authorizers"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/config"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/explain"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
+	"github.com/pennsieve/pennsieve-go-core/pkg/queries/dydb"
+	"github.com/pennsieve/pennsieve-go-core/pkg/queries/pgdb"
+)
+
+func main() {
+	var request explain.Request
+	flag.StringVar(&request.Subject.UserNodeId, "user", "", "node id of the user, e.g. N:user:...")
+	flag.StringVar(&request.Subject.CognitoUsername, "cognito-username", "", "Cognito username of the user, instead of -user")
+	flag.BoolVar(&request.Subject.TokenPool, "token-pool", false, "the Cognito username belongs to the API token pool")
+	flag.StringVar(&request.Resource.DatasetNodeId, "dataset", "", "node id of a dataset")
+	flag.StringVar(&request.Resource.OrganizationNodeId, "org", "", "node id of an organization, or of a package's organization")
+	flag.StringVar(&request.Resource.PackageNodeId, "package", "", "node id of a package (requires -org)")
+	flag.StringVar(&request.Resource.ManifestId, "manifest", "", "id of an upload manifest")
+	flag.StringVar(&request.Mode, "mode", "", "authorizer mode, e.g. LEGACY")
+	flag.StringVar(&request.SourceIp, "source-ip", "", "check organization IP allowlists as for a request from this address")
+	flag.StringVar(&request.PurposeOfUse, "purpose-of-use", "", "check protected datasets as for a request declaring this purpose of use (requires -source-ip)")
+	flag.Parse()
+
+	if err := request.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	os.Exit(run(context.Background(), request))
+}
+
+func run(ctx context.Context, request explain.Request) int {
+	appConfig := config.FromEnv()
+	if err := appConfig.Validate(config.BinaryDirect); err != nil {
+		fmt.Fprintln(os.Stderr, "invalid configuration:", err)
+		return 1
+	}
+	if err := appConfig.SettingErrors(); err != nil {
+		fmt.Fprintln(os.Stderr, "invalid optional settings, using their defaults:", err)
+	}
+	authorizers.Configure(appConfig.AuthorizerSettings())
+
+	db, err := pgdb.ConnectRDS()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "unable to connect to Postgres:", err)
+		return 1
+	}
+	defer db.Close()
+	queries := manager.NewQueries(db, appConfig.ResultTtl).WithOptionalAuthPolicies(appConfig.AuthPoliciesOptional).
+		WithOptionalIpAllowlists(appConfig.IpAllowlistsOptional)
+
+	awsConfig, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "unable to load AWS config:", err)
+		return 1
+	}
+
+	explainer := &explain.Explainer{
+		PostgresDB:        queries,
+		DynamoDB:          dydb.New(dynamodb.NewFromConfig(awsConfig)),
+		Packages:          queries,
+		TokenClientId:     appConfig.TokenClientId,
+		ManifestTableName: appConfig.ManifestTable,
+	}
+	report, err := explainer.Explain(ctx, request)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if err := report.Write(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if report.Decision != explain.DecisionAllow {
+		return 1
+	}
+	return 0
+}
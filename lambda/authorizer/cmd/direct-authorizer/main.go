@@ -2,9 +2,10 @@ package main
 
 import (
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/config"
 	"github.com/pennsieve/pennsieve-go-api/authorizer/handler"
 )
 
 func main() {
-	lambda.Start(handler.DirectHandler)
+	lambda.Start(handler.WithSelfTest(config.BinaryDirect, handler.DirectHandler))
 }
@@ -10,9 +10,10 @@ package main
 
 import (
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/config"
 	"github.com/pennsieve/pennsieve-go-api/authorizer/handler"
 )
 
 func main() {
-	lambda.Start(handler.WebSocketHandler)
+	lambda.Start(handler.WithSelfTest(config.BinaryWebSocket, handler.WebSocketHandler))
 }
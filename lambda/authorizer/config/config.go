@@ -0,0 +1,357 @@
+// Package config loads the authorizer Lambdas' environment into a typed Config and validates it once, at cold
+// start, so that a missing or malformed setting is reported as such rather than surfacing later as denies.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pennsieve/pennsieve-go-api/authorizer/authorizers"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/cache"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/deadline"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/fault"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/ratelimit"
+)
+
+// Binary identifies which of the authorizer Lambdas is running; each requires a different subset of Config.
+type Binary string
+
+const (
+	// BinaryHTTP is the HTTP API Gateway authorizer (JWT and callback tokens).
+	BinaryHTTP Binary = "http"
+	// BinaryDirect is the Lambda-to-Lambda direct authorizer.
+	BinaryDirect Binary = "direct"
+	// BinaryWebSocket is the WebSocket API Gateway REQUEST authorizer.
+	BinaryWebSocket Binary = "websocket"
+)
+
+// dockerEnv is the ENV value (and default) under which pennsieve-go-core connects to Postgres with POSTGRES_*
+// variables instead of through the RDS proxy.
+const dockerEnv = "DOCKER"
+
+// defaultResultTtl is how long API Gateway caches authorization results unless AUTHORIZER_RESULT_TTL says
+// otherwise. It matches authorizerResultTtlInSeconds in the API definitions.
+const defaultResultTtl = 300 * time.Second
+
+// defaultBlockListTtl is how long the block list is kept in memory unless BLOCK_LIST_CACHE_TTL says otherwise.
+const defaultBlockListTtl = 15 * time.Second
+
+// defaultBreakGlassDuration is how long break-glass access lasts unless BREAK_GLASS_DURATION says otherwise.
+const defaultBreakGlassDuration = time.Hour
+
+// callbackValidatorPrefix prefixes the environment variables that hold callback validator Lambda ARNs.
+const callbackValidatorPrefix = "CALLBACK_VALIDATOR_"
+
+// Config is the environment of an authorizer Lambda.
+type Config struct {
+	Env              string
+	Region           string
+	RdsProxyEndpoint string
+
+	UserPoolId    string
+	UserClientId  string
+	TokenPoolId   string
+	TokenClientId string
+
+	ManifestTable  string
+	AuthorizerMode string
+
+	// CallbackValidators maps the environment variable suffix of each CALLBACK_VALIDATOR_* variable (e.g.
+	// WORKFLOW_SERVICE) to its validator Lambda ARN.
+	CallbackValidators map[string]string
+	// CheckAccessLambdaName is account-service's compute-node check-access Lambda, used by the WebSocket authorizer.
+	// Empty, compute-node access checks fail.
+	CheckAccessLambdaName string
+
+	RateLimitEnabled bool
+	RateLimit        ratelimit.Config
+	RateLimitTable   string
+
+	// BlockListTable is the DynamoDB table of blocked principals and organizations, from BLOCK_LIST_TABLE. Empty,
+	// the default, disables the block list.
+	BlockListTable string
+	// BlockListTtl is how long the block list is kept in memory, from BLOCK_LIST_CACHE_TTL in seconds.
+	BlockListTtl time.Duration
+
+	// ResultTtl is how long API Gateway caches the authorizer's results, from AUTHORIZER_RESULT_TTL in seconds. A
+	// grant that expires within ResultTtl is no longer honored, so that no cached result outlives it.
+	ResultTtl time.Duration
+
+	// ClaimsCache bounds the process-local claims cache, from the CLAIMS_CACHE_* variables.
+	ClaimsCache cache.Config
+
+	// StaleClaimsWindow is how old last-known-good claims may be to be served to read-only requests on uncached
+	// routes while Postgres is unavailable, from STALE_CLAIMS_WINDOW in seconds. Zero, the default, never serves
+	// stale claims.
+	StaleClaimsWindow time.Duration
+
+	// Deadlines are the invocation's reserve and each dependency's timeout, from DEADLINE_RESERVE_MS and the
+	// TIMEOUT_*_MS variables.
+	Deadlines deadline.Config
+
+	// BreakGlassGroup is the Cognito group whose members may request emergency read access to datasets, from
+	// BREAK_GLASS_GROUP. Empty, the default, disables break-glass access.
+	BreakGlassGroup string
+	// BreakGlassDuration is how long break-glass access lasts from an operator's first request, from
+	// BREAK_GLASS_DURATION in seconds.
+	BreakGlassDuration time.Duration
+	// BreakGlassTable is the DynamoDB table that records when each break-glass grant started, from BREAK_GLASS_TABLE.
+	// It must be set for BREAK_GLASS_GROUP to take effect.
+	BreakGlassTable string
+
+	// ImpersonationAllowWrites lets super-admins acting as another user make requests with methods other than GET,
+	// HEAD and OPTIONS, from IMPERSONATION_ALLOW_WRITES. Off by default.
+	ImpersonationAllowWrites bool
+
+	// IpAllowlistExemptSuperAdmins exempts super-admins from organization IP allowlists, from
+	// IP_ALLOWLIST_EXEMPT_SUPER_ADMINS. Off by default.
+	IpAllowlistExemptSuperAdmins bool
+	// IpAllowlistExemptCallbacks exempts callback tokens from organization IP allowlists, from
+	// IP_ALLOWLIST_EXEMPT_CALLBACKS. Off by default.
+	IpAllowlistExemptCallbacks bool
+
+	// AuthPoliciesOptional reads organizations as having no authentication policy while
+	// pennsieve.organization_auth_policies does not exist, from AUTH_POLICIES_OPTIONAL. Off by default: until the
+	// table exists, every request for an organization's resources fails.
+	AuthPoliciesOptional bool
+	// IpAllowlistsOptional reads organizations as having no IP allowlist while
+	// pennsieve.organization_ip_allowlists does not exist, from IP_ALLOWLISTS_OPTIONAL. Off by default: until the
+	// table exists, every request for an organization's resources fails.
+	IpAllowlistsOptional bool
+
+	// DatasetLockExemptRoutes are the route keys, e.g. "POST /datasets/{id}/publication/cancel", that may modify
+	// locked datasets, from the comma-separated DATASET_LOCK_EXEMPT_ROUTES.
+	DatasetLockExemptRoutes []string
+
+	// FaultsEnabled is set, and Faults are injected into Postgres, DynamoDB and Lambda calls, when FAULT_INJECTION
+	// is. It is only allowed in the local Docker environment.
+	FaultsEnabled bool
+	Faults        fault.Config
+
+	// settingErrs are the malformed optional settings, each replaced by its default. They are reported by
+	// SettingErrors.
+	settingErrs []error
+}
+
+// FromEnv loads a Config from the environment. It does not fail; call Validate to check the result.
+func FromEnv() *Config {
+	c := &Config{
+		Env:                   os.Getenv("ENV"),
+		Region:                os.Getenv("REGION"),
+		RdsProxyEndpoint:      os.Getenv("RDS_PROXY_ENDPOINT"),
+		UserPoolId:            os.Getenv("USER_POOL"),
+		UserClientId:          os.Getenv("USER_CLIENT"),
+		TokenPoolId:           os.Getenv("TOKEN_POOL"),
+		TokenClientId:         os.Getenv("TOKEN_CLIENT"),
+		ManifestTable:         os.Getenv("MANIFEST_TABLE"),
+		AuthorizerMode:        os.Getenv("AUTHORIZER_MODE"),
+		CheckAccessLambdaName: os.Getenv("CHECK_ACCESS_LAMBDA_NAME"),
+		RateLimitTable:        os.Getenv("RATE_LIMIT_TABLE"),
+		BlockListTable:        os.Getenv("BLOCK_LIST_TABLE"),
+		BreakGlassGroup:       os.Getenv("BREAK_GLASS_GROUP"),
+		BreakGlassTable:       os.Getenv("BREAK_GLASS_TABLE"),
+		CallbackValidators:    map[string]string{},
+	}
+	for _, entry := range os.Environ() {
+		key, value, _ := strings.Cut(entry, "=")
+		if suffix, ok := strings.CutPrefix(key, callbackValidatorPrefix); ok {
+			c.CallbackValidators[suffix] = value
+		}
+	}
+	collect := func(err error) {
+		if err != nil {
+			c.settingErrs = append(c.settingErrs, err)
+		}
+	}
+	var err error
+	c.RateLimit, c.RateLimitEnabled, err = ratelimit.ConfigFromEnv()
+	collect(err)
+	c.ResultTtl, err = secondsFromEnv("AUTHORIZER_RESULT_TTL", defaultResultTtl)
+	collect(err)
+	c.ClaimsCache, err = cache.ConfigFromEnv()
+	collect(err)
+	c.StaleClaimsWindow, err = secondsFromEnv("STALE_CLAIMS_WINDOW", 0)
+	collect(err)
+	c.Deadlines, err = deadline.ConfigFromEnv()
+	collect(err)
+	c.BlockListTtl, err = secondsFromEnv("BLOCK_LIST_CACHE_TTL", defaultBlockListTtl)
+	collect(err)
+	c.BreakGlassDuration, err = secondsFromEnv("BREAK_GLASS_DURATION", defaultBreakGlassDuration)
+	collect(err)
+	c.Faults, c.FaultsEnabled, err = fault.ConfigFromEnv()
+	collect(err)
+	c.ImpersonationAllowWrites, err = boolFromEnv("IMPERSONATION_ALLOW_WRITES")
+	collect(err)
+	c.IpAllowlistExemptSuperAdmins, err = boolFromEnv("IP_ALLOWLIST_EXEMPT_SUPER_ADMINS")
+	collect(err)
+	c.IpAllowlistExemptCallbacks, err = boolFromEnv("IP_ALLOWLIST_EXEMPT_CALLBACKS")
+	collect(err)
+	c.AuthPoliciesOptional, err = boolFromEnv("AUTH_POLICIES_OPTIONAL")
+	collect(err)
+	c.IpAllowlistsOptional, err = boolFromEnv("IP_ALLOWLISTS_OPTIONAL")
+	collect(err)
+	c.DatasetLockExemptRoutes, err = routeKeysFromEnv("DATASET_LOCK_EXEMPT_ROUTES")
+	collect(err)
+
+	if c.RateLimitEnabled && c.RateLimitTable == "" {
+		collect(errors.New("RATE_LIMIT_TABLE is not set: rate limiting is disabled"))
+		c.RateLimitEnabled = false
+	}
+	if c.BreakGlassGroup != "" && c.BreakGlassDuration == 0 {
+		collect(fmt.Errorf("BREAK_GLASS_DURATION must be positive when BREAK_GLASS_GROUP is set: using %s", defaultBreakGlassDuration))
+		c.BreakGlassDuration = defaultBreakGlassDuration
+	}
+	if c.BreakGlassGroup != "" && c.BreakGlassTable == "" {
+		collect(errors.New("BREAK_GLASS_TABLE is not set: break-glass access is disabled"))
+		c.BreakGlassGroup = ""
+	}
+	// Faults are never injected outside the local Docker environment.
+	if c.FaultsEnabled && c.Env != dockerEnv {
+		collect(fmt.Errorf("FAULT_INJECTION is only allowed when ENV is %s, not %q: no faults are injected", dockerEnv, c.Env))
+		c.Faults, c.FaultsEnabled = fault.Config{}, false
+	}
+	return c
+}
+
+// routeKeysFromEnv parses the variable name, a comma-separated list of API Gateway route keys such as
+// "POST /datasets/{id}". Blank entries are ignored.
+func routeKeysFromEnv(name string) ([]string, error) {
+	var routes []string
+	for _, entry := range strings.Split(os.Getenv(name), ",") {
+		route := strings.TrimSpace(entry)
+		if route == "" {
+			continue
+		}
+		if method, path, ok := strings.Cut(route, " "); !ok || method == "" || !strings.HasPrefix(path, "/") {
+			return nil, fmt.Errorf("invalid %s: %q is not a route key such as \"POST /datasets/{id}\"", name, route)
+		}
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+// boolFromEnv parses the variable name, a boolean, defaulting to false.
+func boolFromEnv(name string) (bool, error) {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return false, nil
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %q is not a boolean", name, raw)
+	}
+	return value, nil
+}
+
+// secondsFromEnv parses the variable name, a whole number of seconds, defaulting to fallback.
+func secondsFromEnv(name string, fallback time.Duration) (time.Duration, error) {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return fallback, nil
+	}
+	seconds, err := strconv.Atoi(raw)
+	if err != nil || seconds < 0 {
+		return fallback, fmt.Errorf("invalid %s: %q is not a number of seconds", name, raw)
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// Validate returns an error describing every setting that binary requires but is missing or malformed, or nil if
+// the Config is usable. Malformed optional settings are reported by SettingErrors instead.
+func (c *Config) Validate(binary Binary) error {
+	var problems []error
+	required := map[string]bool{}
+	require := func(name string, value string) {
+		if value == "" && !required[name] {
+			problems = append(problems, fmt.Errorf("%s is not set", name))
+		}
+		required[name] = true
+	}
+
+	// Postgres: pennsieve-go-core uses POSTGRES_* (all defaulted) under ENV=DOCKER, and the RDS proxy otherwise.
+	if c.Env != "" && c.Env != dockerEnv {
+		require("RDS_PROXY_ENDPOINT", c.RdsProxyEndpoint)
+		require("REGION", c.Region)
+	}
+
+	switch binary {
+	case BinaryHTTP, BinaryWebSocket:
+		require("REGION", c.Region)
+		require("USER_POOL", c.UserPoolId)
+		require("USER_CLIENT", c.UserClientId)
+		require("TOKEN_POOL", c.TokenPoolId)
+		require("TOKEN_CLIENT", c.TokenClientId)
+		require("MANIFEST_TABLE", c.ManifestTable)
+	case BinaryDirect:
+	default:
+		return fmt.Errorf("unknown binary %q", binary)
+	}
+
+	if binary == BinaryHTTP {
+		for _, suffix := range c.validatorSuffixes() {
+			if arn := c.CallbackValidators[suffix]; !strings.HasPrefix(arn, "arn:") {
+				problems = append(problems, fmt.Errorf("%s%s is not a Lambda ARN: %q", callbackValidatorPrefix, suffix, arn))
+			}
+		}
+	}
+	return errors.Join(problems...)
+}
+
+// SettingErrors returns an error describing every optional setting that was malformed and replaced by its default,
+// or nil if there is none.
+func (c *Config) SettingErrors() error {
+	return errors.Join(c.settingErrs...)
+}
+
+// ValidatorArn returns the ARN of the callback validator Lambda for the given service, configured in
+// CALLBACK_VALIDATOR_<SERVICE_NAME_UPPERCASED_WITH_UNDERSCORES>, e.g. CALLBACK_VALIDATOR_WORKFLOW_SERVICE for
+// "workflow-service".
+func (c *Config) ValidatorArn(serviceName string) (string, error) {
+	arn := c.CallbackValidators[strings.ToUpper(strings.ReplaceAll(serviceName, "-", "_"))]
+	if arn == "" {
+		return "", fmt.Errorf("no callback validator configured for service: %s", serviceName)
+	}
+	return arn, nil
+}
+
+// Validators returns the configured callback validator ARNs keyed by environment variable name, for reporting.
+func (c *Config) Validators() map[string]string {
+	validators := make(map[string]string, len(c.CallbackValidators))
+	for suffix, arn := range c.CallbackValidators {
+		validators[callbackValidatorPrefix+suffix] = arn
+	}
+	return validators
+}
+
+// AuthorizerSettings returns the settings to configure the authorizers with (see authorizers.Configure).
+func (c *Config) AuthorizerSettings() authorizers.Settings {
+	return authorizers.Settings{
+		IpAllowlistExemptSuperAdmins: c.IpAllowlistExemptSuperAdmins,
+		IpAllowlistExemptCallbacks:   c.IpAllowlistExemptCallbacks,
+		DatasetLockExemptRoutes:      c.DatasetLockExemptRoutes,
+	}
+}
+
+// Issuer returns the JWT issuer of the Cognito user pool with the given id.
+func (c *Config) Issuer(poolId string) string {
+	return fmt.Sprintf("https://cognito-idp.%s.amazonaws.com/%s", c.Region, poolId)
+}
+
+// JWKSURL returns the URL of the key set of the Cognito user pool with the given id.
+func (c *Config) JWKSURL(poolId string) string {
+	return c.Issuer(poolId) + "/.well-known/jwks.json"
+}
+
+func (c *Config) validatorSuffixes() []string {
+	suffixes := make([]string, 0, len(c.CallbackValidators))
+	for suffix := range c.CallbackValidators {
+		suffixes = append(suffixes, suffix)
+	}
+	sort.Strings(suffixes)
+	return suffixes
+}
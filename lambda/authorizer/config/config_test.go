@@ -0,0 +1,364 @@
+package config_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pennsieve/pennsieve-go-api/authorizer/authorizers"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/config"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/deadline"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setValidEnv(t *testing.T) {
+	t.Setenv("ENV", "dev")
+	t.Setenv("REGION", "us-east-1")
+	t.Setenv("RDS_PROXY_ENDPOINT", "proxy.example.com")
+	t.Setenv("USER_POOL", "us-east-1_user")
+	t.Setenv("USER_CLIENT", "user-client")
+	t.Setenv("TOKEN_POOL", "us-east-1_token")
+	t.Setenv("TOKEN_CLIENT", "token-client")
+	t.Setenv("MANIFEST_TABLE", "manifests")
+	t.Setenv("CHECK_ACCESS_LAMBDA_NAME", "check-access")
+	t.Setenv("CALLBACK_VALIDATOR_WORKFLOW_SERVICE", "arn:aws:lambda:us-east-1:123:function:workflow-validator")
+}
+
+func TestFromEnv(t *testing.T) {
+	setValidEnv(t)
+	t.Setenv("RATE_LIMIT_CONFIG", `{"default":{"burst":10,"perSecond":1}}`)
+	t.Setenv("RATE_LIMIT_TABLE", "buckets")
+
+	c := config.FromEnv()
+
+	assert.Equal(t, "us-east-1_user", c.UserPoolId)
+	assert.Equal(t, "token-client", c.TokenClientId)
+	assert.Equal(t, "manifests", c.ManifestTable)
+	assert.Equal(t, map[string]string{"WORKFLOW_SERVICE": "arn:aws:lambda:us-east-1:123:function:workflow-validator"}, c.CallbackValidators)
+	assert.True(t, c.RateLimitEnabled)
+	assert.Equal(t, float64(10), c.RateLimit.Default.Burst)
+	assert.Equal(t, 300*time.Second, c.ResultTtl)
+	assert.True(t, c.ClaimsCache.Enabled)
+	assert.Zero(t, c.StaleClaimsWindow)
+	assert.Equal(t, deadline.DefaultConfig(), c.Deadlines)
+	assert.Equal(t, "https://cognito-idp.us-east-1.amazonaws.com/us-east-1_user", c.Issuer(c.UserPoolId))
+	assert.Equal(t, "https://cognito-idp.us-east-1.amazonaws.com/us-east-1_token/.well-known/jwks.json", c.JWKSURL(c.TokenPoolId))
+
+	for _, binary := range []config.Binary{config.BinaryHTTP, config.BinaryDirect, config.BinaryWebSocket} {
+		assert.NoError(t, c.Validate(binary), binary)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for name, params := range map[string]struct {
+		env            map[string]string
+		binary         config.Binary
+		expectedErrors []string
+	}{
+		"missing user pool": {
+			env:            map[string]string{"USER_POOL": ""},
+			binary:         config.BinaryHTTP,
+			expectedErrors: []string{"USER_POOL is not set"},
+		},
+		"missing token client and manifest table": {
+			env:            map[string]string{"TOKEN_CLIENT": "", "MANIFEST_TABLE": ""},
+			binary:         config.BinaryWebSocket,
+			expectedErrors: []string{"TOKEN_CLIENT is not set", "MANIFEST_TABLE is not set"},
+		},
+		"missing region is reported once": {
+			env:            map[string]string{"REGION": ""},
+			binary:         config.BinaryHTTP,
+			expectedErrors: []string{"REGION is not set"},
+		},
+		"malformed callback validator": {
+			env:            map[string]string{"CALLBACK_VALIDATOR_OTHER_SERVICE": ""},
+			binary:         config.BinaryHTTP,
+			expectedErrors: []string{`CALLBACK_VALIDATOR_OTHER_SERVICE is not a Lambda ARN: ""`},
+		},
+		"direct needs the RDS proxy outside docker": {
+			env:            map[string]string{"RDS_PROXY_ENDPOINT": "", "USER_POOL": ""},
+			binary:         config.BinaryDirect,
+			expectedErrors: []string{"RDS_PROXY_ENDPOINT is not set"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			setValidEnv(t)
+			for key, value := range params.env {
+				t.Setenv(key, value)
+			}
+
+			err := config.FromEnv().Validate(params.binary)
+
+			require.Error(t, err)
+			for _, expected := range params.expectedErrors {
+				assert.ErrorContains(t, err, expected)
+			}
+			assert.Len(t, err.(interface{ Unwrap() []error }).Unwrap(), len(params.expectedErrors))
+		})
+	}
+}
+
+func TestSettingErrors(t *testing.T) {
+	for name, params := range map[string]struct {
+		env           map[string]string
+		expectedError string
+		check         func(t *testing.T, c *config.Config)
+	}{
+		"invalid rate limit config": {
+			env:           map[string]string{"RATE_LIMIT_CONFIG": "{"},
+			expectedError: "invalid RATE_LIMIT_CONFIG",
+			check:         func(t *testing.T, c *config.Config) { assert.False(t, c.RateLimitEnabled) },
+		},
+		"rate limit config without table": {
+			env:           map[string]string{"RATE_LIMIT_CONFIG": `{"default":{"burst":1,"perSecond":1}}`},
+			expectedError: "RATE_LIMIT_TABLE is not set",
+			check:         func(t *testing.T, c *config.Config) { assert.False(t, c.RateLimitEnabled) },
+		},
+		"invalid result ttl": {
+			env:           map[string]string{"AUTHORIZER_RESULT_TTL": "5m"},
+			expectedError: `invalid AUTHORIZER_RESULT_TTL: "5m"`,
+			check:         func(t *testing.T, c *config.Config) { assert.Equal(t, 300*time.Second, c.ResultTtl) },
+		},
+		"invalid claims cache size": {
+			env:           map[string]string{"CLAIMS_CACHE_MAX_ENTRIES": "none"},
+			expectedError: "invalid CLAIMS_CACHE_MAX_ENTRIES",
+			check:         func(t *testing.T, c *config.Config) { assert.False(t, c.ClaimsCache.Enabled) },
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			setValidEnv(t)
+			for key, value := range params.env {
+				t.Setenv(key, value)
+			}
+
+			c := config.FromEnv()
+
+			assert.ErrorContains(t, c.SettingErrors(), params.expectedError)
+			for _, binary := range []config.Binary{config.BinaryHTTP, config.BinaryDirect, config.BinaryWebSocket} {
+				assert.NoError(t, c.Validate(binary), binary)
+			}
+			params.check(t, c)
+		})
+	}
+}
+
+func TestSettingErrorsCollectsEveryProblem(t *testing.T) {
+	setValidEnv(t)
+	t.Setenv("STALE_CLAIMS_WINDOW", "15m")
+	t.Setenv("IMPERSONATION_ALLOW_WRITES", "sometimes")
+	t.Setenv("BLOCK_LIST_CACHE_TTL", "soon")
+
+	err := config.FromEnv().SettingErrors()
+
+	require.Error(t, err)
+	assert.Len(t, err.(interface{ Unwrap() []error }).Unwrap(), 3)
+	assert.NoError(t, (&config.Config{}).SettingErrors())
+}
+
+func TestCheckAccessLambdaIsOptional(t *testing.T) {
+	setValidEnv(t)
+	t.Setenv("CHECK_ACCESS_LAMBDA_NAME", "")
+	c := config.FromEnv()
+	assert.NoError(t, c.Validate(config.BinaryWebSocket))
+	assert.NoError(t, c.SettingErrors())
+}
+
+func TestResultTtl(t *testing.T) {
+	setValidEnv(t)
+	t.Setenv("AUTHORIZER_RESULT_TTL", "60")
+	assert.Equal(t, 60*time.Second, config.FromEnv().ResultTtl)
+
+	t.Setenv("AUTHORIZER_RESULT_TTL", "0")
+	assert.Equal(t, time.Duration(0), config.FromEnv().ResultTtl)
+}
+
+func TestStaleClaimsWindow(t *testing.T) {
+	setValidEnv(t)
+	t.Setenv("STALE_CLAIMS_WINDOW", "900")
+	assert.Equal(t, 15*time.Minute, config.FromEnv().StaleClaimsWindow)
+
+	t.Setenv("STALE_CLAIMS_WINDOW", "15m")
+	c := config.FromEnv()
+	assert.ErrorContains(t, c.SettingErrors(), "invalid STALE_CLAIMS_WINDOW")
+	assert.Zero(t, c.StaleClaimsWindow)
+}
+
+func TestDeadlines(t *testing.T) {
+	setValidEnv(t)
+	t.Setenv("TIMEOUT_POSTGRES_MS", "750")
+	assert.Equal(t, 750*time.Millisecond, config.FromEnv().Deadlines.Postgres)
+
+	t.Setenv("TIMEOUT_POSTGRES_MS", "-1")
+	c := config.FromEnv()
+	assert.ErrorContains(t, c.SettingErrors(), "invalid TIMEOUT_POSTGRES_MS")
+	assert.Equal(t, deadline.DefaultConfig(), c.Deadlines)
+}
+
+func TestBreakGlass(t *testing.T) {
+	setValidEnv(t)
+	c := config.FromEnv()
+	assert.Empty(t, c.BreakGlassGroup)
+	assert.Equal(t, time.Hour, c.BreakGlassDuration)
+
+	t.Setenv("BREAK_GLASS_GROUP", "on-call")
+	t.Setenv("BREAK_GLASS_DURATION", "900")
+	t.Setenv("BREAK_GLASS_TABLE", "authorizer-break-glass")
+	c = config.FromEnv()
+	assert.Equal(t, "on-call", c.BreakGlassGroup)
+	assert.Equal(t, 15*time.Minute, c.BreakGlassDuration)
+	assert.Equal(t, "authorizer-break-glass", c.BreakGlassTable)
+	assert.NoError(t, c.Validate(config.BinaryHTTP))
+
+	t.Setenv("BREAK_GLASS_DURATION", "0")
+	c = config.FromEnv()
+	assert.ErrorContains(t, c.SettingErrors(), "BREAK_GLASS_DURATION must be positive")
+	assert.Equal(t, time.Hour, c.BreakGlassDuration)
+
+	t.Setenv("BREAK_GLASS_TABLE", "")
+	c = config.FromEnv()
+	assert.ErrorContains(t, c.SettingErrors(), "BREAK_GLASS_TABLE is not set")
+	assert.Empty(t, c.BreakGlassGroup)
+}
+
+func TestBlockList(t *testing.T) {
+	setValidEnv(t)
+	c := config.FromEnv()
+	assert.Empty(t, c.BlockListTable)
+	assert.Equal(t, 15*time.Second, c.BlockListTtl)
+
+	t.Setenv("BLOCK_LIST_TABLE", "authorizer-block-list")
+	t.Setenv("BLOCK_LIST_CACHE_TTL", "5")
+	c = config.FromEnv()
+	assert.Equal(t, "authorizer-block-list", c.BlockListTable)
+	assert.Equal(t, 5*time.Second, c.BlockListTtl)
+	assert.NoError(t, c.Validate(config.BinaryDirect))
+
+	t.Setenv("BLOCK_LIST_CACHE_TTL", "soon")
+	c = config.FromEnv()
+	assert.ErrorContains(t, c.SettingErrors(), "BLOCK_LIST_CACHE_TTL")
+	assert.Equal(t, 15*time.Second, c.BlockListTtl)
+}
+
+func TestIpAllowlistExemptions(t *testing.T) {
+	setValidEnv(t)
+	c := config.FromEnv()
+	assert.False(t, c.IpAllowlistExemptSuperAdmins)
+	assert.False(t, c.IpAllowlistExemptCallbacks)
+
+	t.Setenv("IP_ALLOWLIST_EXEMPT_SUPER_ADMINS", "false")
+	t.Setenv("IP_ALLOWLIST_EXEMPT_CALLBACKS", "true")
+	c = config.FromEnv()
+	assert.False(t, c.IpAllowlistExemptSuperAdmins)
+	assert.True(t, c.IpAllowlistExemptCallbacks)
+	assert.Equal(t, authorizers.Settings{IpAllowlistExemptCallbacks: true}, c.AuthorizerSettings())
+	assert.NoError(t, c.Validate(config.BinaryHTTP))
+
+	t.Setenv("IP_ALLOWLIST_EXEMPT_SUPER_ADMINS", "yes")
+	c = config.FromEnv()
+	assert.False(t, c.IpAllowlistExemptSuperAdmins)
+	assert.ErrorContains(t, c.SettingErrors(), `invalid IP_ALLOWLIST_EXEMPT_SUPER_ADMINS: "yes" is not a boolean`)
+}
+
+func TestImpersonationAllowWrites(t *testing.T) {
+	setValidEnv(t)
+	assert.False(t, config.FromEnv().ImpersonationAllowWrites)
+
+	t.Setenv("IMPERSONATION_ALLOW_WRITES", "true")
+	c := config.FromEnv()
+	assert.True(t, c.ImpersonationAllowWrites)
+	assert.NoError(t, c.Validate(config.BinaryHTTP))
+
+	t.Setenv("IMPERSONATION_ALLOW_WRITES", "sometimes")
+	c = config.FromEnv()
+	assert.ErrorContains(t, c.SettingErrors(), "invalid IMPERSONATION_ALLOW_WRITES")
+	assert.False(t, c.ImpersonationAllowWrites)
+}
+
+func TestAuthPoliciesOptional(t *testing.T) {
+	setValidEnv(t)
+	assert.False(t, config.FromEnv().AuthPoliciesOptional)
+
+	t.Setenv("AUTH_POLICIES_OPTIONAL", "true")
+	c := config.FromEnv()
+	assert.True(t, c.AuthPoliciesOptional)
+	assert.NoError(t, c.Validate(config.BinaryHTTP))
+
+	t.Setenv("AUTH_POLICIES_OPTIONAL", "maybe")
+	c = config.FromEnv()
+	assert.ErrorContains(t, c.SettingErrors(), "invalid AUTH_POLICIES_OPTIONAL")
+	assert.False(t, c.AuthPoliciesOptional)
+}
+
+func TestIpAllowlistsOptional(t *testing.T) {
+	setValidEnv(t)
+	assert.False(t, config.FromEnv().IpAllowlistsOptional)
+
+	t.Setenv("IP_ALLOWLISTS_OPTIONAL", "true")
+	c := config.FromEnv()
+	assert.True(t, c.IpAllowlistsOptional)
+	assert.NoError(t, c.Validate(config.BinaryHTTP))
+
+	t.Setenv("IP_ALLOWLISTS_OPTIONAL", "maybe")
+	c = config.FromEnv()
+	assert.ErrorContains(t, c.SettingErrors(), "invalid IP_ALLOWLISTS_OPTIONAL")
+	assert.False(t, c.IpAllowlistsOptional)
+}
+
+func TestDatasetLockExemptRoutes(t *testing.T) {
+	setValidEnv(t)
+	assert.Empty(t, config.FromEnv().DatasetLockExemptRoutes)
+
+	t.Setenv("DATASET_LOCK_EXEMPT_ROUTES", "POST /datasets/{id}/publication/cancel, ,DELETE /datasets/{id}/lock,")
+	c := config.FromEnv()
+	assert.Equal(t, []string{"POST /datasets/{id}/publication/cancel", "DELETE /datasets/{id}/lock"}, c.DatasetLockExemptRoutes)
+	assert.Equal(t, c.DatasetLockExemptRoutes, c.AuthorizerSettings().DatasetLockExemptRoutes)
+	assert.NoError(t, c.Validate(config.BinaryHTTP))
+
+	t.Setenv("DATASET_LOCK_EXEMPT_ROUTES", "/datasets/{id}/publication/cancel")
+	c = config.FromEnv()
+	assert.ErrorContains(t, c.SettingErrors(), "invalid DATASET_LOCK_EXEMPT_ROUTES")
+	assert.Empty(t, c.DatasetLockExemptRoutes)
+}
+
+func TestFaultInjection(t *testing.T) {
+	setValidEnv(t)
+	assert.False(t, config.FromEnv().FaultsEnabled)
+
+	// Faults are never injected outside the local Docker environment.
+	t.Setenv("FAULT_INJECTION", `{"rules":[{"dependency":"postgres","kind":"error","probability":0.1}]}`)
+	c := config.FromEnv()
+	assert.False(t, c.FaultsEnabled)
+	assert.Empty(t, c.Faults.Rules)
+	assert.ErrorContains(t, c.SettingErrors(), `FAULT_INJECTION is only allowed when ENV is DOCKER, not "dev"`)
+
+	// Nor when ENV is unset, although pennsieve-go-core then connects to Postgres as under DOCKER.
+	t.Setenv("ENV", "")
+	assert.ErrorContains(t, config.FromEnv().SettingErrors(), `FAULT_INJECTION is only allowed when ENV is DOCKER, not ""`)
+
+	t.Setenv("ENV", "DOCKER")
+	c = config.FromEnv()
+	assert.True(t, c.FaultsEnabled)
+	assert.Len(t, c.Faults.Rules, 1)
+	assert.NoError(t, c.SettingErrors())
+
+	t.Setenv("FAULT_INJECTION", `{"rules":[{"kind":"crash"}]}`)
+	c = config.FromEnv()
+	assert.False(t, c.FaultsEnabled)
+	assert.ErrorContains(t, c.SettingErrors(), "invalid FAULT_INJECTION rule 0")
+}
+
+func TestValidateDockerNeedsNoPostgresSettings(t *testing.T) {
+	c := &config.Config{Env: "DOCKER"}
+	assert.NoError(t, c.Validate(config.BinaryDirect))
+}
+
+func TestValidatorArn(t *testing.T) {
+	c := &config.Config{CallbackValidators: map[string]string{"WORKFLOW_SERVICE": "arn:aws:lambda:us-east-1:123:function:workflow-validator"}}
+
+	arn, err := c.ValidatorArn("workflow-service")
+	assert.NoError(t, err)
+	assert.Equal(t, "arn:aws:lambda:us-east-1:123:function:workflow-validator", arn)
+
+	_, err = c.ValidatorArn("unknown-service")
+	assert.ErrorContains(t, err, "no callback validator configured for service: unknown-service")
+}
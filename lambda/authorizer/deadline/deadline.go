@@ -0,0 +1,135 @@
+// Package deadline budgets an invocation's time between the dependencies it calls, so that a hung dependency
+// fails its own call, which is then indeterminate, instead of consuming the Lambda's whole timeout and leaving
+// API Gateway to return an opaque error.
+package deadline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Default timeouts, used when the corresponding variable is unset.
+const (
+	DefaultReserve           = 500 * time.Millisecond
+	DefaultJWKS              = 3 * time.Second
+	DefaultPostgres          = 2 * time.Second
+	DefaultDynamoDB          = time.Second
+	DefaultCallbackValidator = 3 * time.Second
+	DefaultCheckAccess       = 3 * time.Second
+)
+
+// Config is how long each dependency call may take. A zero timeout leaves the call bounded only by the budget.
+type Config struct {
+	// Reserve is kept back from the invocation deadline to report a failure in time: the budget ends Reserve
+	// before the Lambda times out.
+	Reserve time.Duration
+	// JWKS bounds fetching each Cognito key set on cold start.
+	JWKS time.Duration
+	// Postgres bounds each Postgres lookup.
+	Postgres time.Duration
+	// DynamoDB bounds each DynamoDB lookup: a manifest or a rate limit check.
+	DynamoDB time.Duration
+	// CallbackValidator bounds invoking a service's callback validator Lambda.
+	CallbackValidator time.Duration
+	// CheckAccess bounds invoking account-service's check-access Lambda.
+	CheckAccess time.Duration
+}
+
+// DefaultConfig returns the Config used when no variable is set.
+func DefaultConfig() Config {
+	return Config{
+		Reserve:           DefaultReserve,
+		JWKS:              DefaultJWKS,
+		Postgres:          DefaultPostgres,
+		DynamoDB:          DefaultDynamoDB,
+		CallbackValidator: DefaultCallbackValidator,
+		CheckAccess:       DefaultCheckAccess,
+	}
+}
+
+// ConfigFromEnv reads the Config from DEADLINE_RESERVE_MS, TIMEOUT_JWKS_MS, TIMEOUT_POSTGRES_MS,
+// TIMEOUT_DYNAMODB_MS, TIMEOUT_CALLBACK_VALIDATOR_MS and TIMEOUT_CHECK_ACCESS_MS, all in milliseconds. On error,
+// the returned Config is DefaultConfig.
+func ConfigFromEnv() (Config, error) {
+	config := DefaultConfig()
+	for name, target := range map[string]*time.Duration{
+		"DEADLINE_RESERVE_MS":           &config.Reserve,
+		"TIMEOUT_JWKS_MS":               &config.JWKS,
+		"TIMEOUT_POSTGRES_MS":           &config.Postgres,
+		"TIMEOUT_DYNAMODB_MS":           &config.DynamoDB,
+		"TIMEOUT_CALLBACK_VALIDATOR_MS": &config.CallbackValidator,
+		"TIMEOUT_CHECK_ACCESS_MS":       &config.CheckAccess,
+	} {
+		raw := os.Getenv(name)
+		if raw == "" {
+			continue
+		}
+		milliseconds, err := strconv.Atoi(raw)
+		if err != nil || milliseconds < 0 {
+			return DefaultConfig(), fmt.Errorf("invalid %s: %q is not a number of milliseconds", name, raw)
+		}
+		*target = time.Duration(milliseconds) * time.Millisecond
+	}
+	return config, nil
+}
+
+// WithBudget returns ctx bounded by the invocation's budget: its deadline, which the Lambda runtime sets to the
+// invocation's, less reserve. A ctx without a deadline has no budget.
+func WithBudget(ctx context.Context, reserve time.Duration) (context.Context, context.CancelFunc) {
+	invocationDeadline, ok := ctx.Deadline()
+	if !ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadline(ctx, invocationDeadline.Add(-reserve))
+}
+
+// Remaining returns how much of the budget of ctx is left, and false if ctx has no budget.
+func Remaining(ctx context.Context) (time.Duration, bool) {
+	budgetDeadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+	return time.Until(budgetDeadline), true
+}
+
+// Bound returns ctx bounded by timeout as well as by its budget. A zero timeout leaves ctx bounded by its budget
+// alone.
+func Bound(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// TimeoutError is a call to a dependency that did not complete within its timeout or the invocation's budget. It
+// matches context.DeadlineExceeded as well as the error the call returned.
+type TimeoutError struct {
+	Operation string
+	Err       error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s timed out: %v", e.Operation, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() []error {
+	return []error{context.DeadlineExceeded, e.Err}
+}
+
+// Check returns err, the result of calling operation with ctx, as a *TimeoutError if ctx expired before the call
+// completed. Drivers report the cancellation each in their own way, e.g. as a cancelled Postgres statement.
+func Check(ctx context.Context, operation string, err error) error {
+	if err == nil || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	return &TimeoutError{Operation: operation, Err: err}
+}
+
+// IsTimeout reports whether err is, or wraps, a timeout.
+func IsTimeout(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded)
+}
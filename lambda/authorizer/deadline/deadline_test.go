@@ -0,0 +1,73 @@
+package deadline_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pennsieve/pennsieve-go-api/authorizer/deadline"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	config, err := deadline.ConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, deadline.DefaultConfig(), config)
+
+	t.Setenv("DEADLINE_RESERVE_MS", "250")
+	t.Setenv("TIMEOUT_POSTGRES_MS", "1500")
+	t.Setenv("TIMEOUT_CHECK_ACCESS_MS", "0")
+	config, err = deadline.ConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, 250*time.Millisecond, config.Reserve)
+	assert.Equal(t, 1500*time.Millisecond, config.Postgres)
+	assert.Zero(t, config.CheckAccess)
+	assert.Equal(t, deadline.DefaultJWKS, config.JWKS)
+
+	t.Setenv("TIMEOUT_DYNAMODB_MS", "1s")
+	config, err = deadline.ConfigFromEnv()
+	assert.ErrorContains(t, err, "invalid TIMEOUT_DYNAMODB_MS")
+	assert.Equal(t, deadline.DefaultConfig(), config)
+}
+
+func TestWithBudget(t *testing.T) {
+	invocation, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	budget, cancelBudget := deadline.WithBudget(invocation, 2*time.Second)
+	defer cancelBudget()
+	remaining, ok := deadline.Remaining(budget)
+	require.True(t, ok)
+	assert.InDelta(t, 8*time.Second, remaining, float64(time.Second))
+
+	// A dependency's timeout never extends the budget.
+	bounded, cancelBounded := deadline.Bound(budget, time.Minute)
+	defer cancelBounded()
+	remaining, _ = deadline.Remaining(bounded)
+	assert.InDelta(t, 8*time.Second, remaining, float64(time.Second))
+
+	unbounded, cancelUnbounded := deadline.WithBudget(context.Background(), time.Second)
+	defer cancelUnbounded()
+	_, ok = deadline.Remaining(unbounded)
+	assert.False(t, ok)
+}
+
+func TestCheck(t *testing.T) {
+	failed := errors.New("pq: canceling statement due to user request")
+	expired, cancel := deadline.Bound(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-expired.Done()
+
+	err := deadline.Check(expired, "GetDatasetClaim", failed)
+	var timeout *deadline.TimeoutError
+	require.ErrorAs(t, err, &timeout)
+	assert.Equal(t, "GetDatasetClaim", timeout.Operation)
+	assert.ErrorIs(t, err, failed)
+	assert.True(t, deadline.IsTimeout(err))
+
+	assert.NoError(t, deadline.Check(expired, "GetDatasetClaim", nil))
+	assert.Same(t, failed, deadline.Check(context.Background(), "GetDatasetClaim", failed))
+	assert.False(t, deadline.IsTimeout(failed))
+}
@@ -0,0 +1,304 @@
+// Package explain runs the authorizer chain for a given user and resource outside API Gateway and reports each
+// step, so that support can see why a request was allowed or denied without reconstructing it by hand.
+package explain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/authorizers"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/pipeline"
+)
+
+// Decision outcomes, matching the outcomes the handlers record in metrics.
+const (
+	DecisionAllow         = "allow"
+	DecisionDeny          = "deny"
+	DecisionIndeterminate = "indeterminate"
+)
+
+// reasonDenied is reported for denies that carry no DenyError reason code.
+const reasonDenied = "denied"
+
+// explainClientId is the client id put in the synthetic token of a user-pool subject. It only needs to differ
+// from the token pool's client id.
+const explainClientId = "authz-explain"
+
+// Subject identifies the user whose access is explained: either UserNodeId, or CognitoUsername together with
+// TokenPool, which says whether the username belongs to the API token pool rather than the user pool.
+type Subject struct {
+	UserNodeId      string
+	CognitoUsername string
+	TokenPool       bool
+}
+
+// Resource is the resource the subject is requesting. At most one of DatasetNodeId, OrganizationNodeId and
+// ManifestId may be set, except that PackageNodeId requires OrganizationNodeId, the package's organization. An
+// empty Resource explains a user-only route.
+type Resource struct {
+	DatasetNodeId      string
+	OrganizationNodeId string
+	PackageNodeId      string
+	ManifestId         string
+}
+
+// Request is a single explain request. Mode is the authorizer mode passed to GenerateClaims (e.g. "LEGACY").
+// If SourceIp is set, organization IP allowlists are checked as for a request from that address, and protected
+// datasets as for a request declaring PurposeOfUse.
+type Request struct {
+	Subject      Subject
+	Resource     Resource
+	Mode         string
+	SourceIp     string
+	PurposeOfUse string
+}
+
+// PackageLookup resolves a package to the dataset that contains it.
+type PackageLookup interface {
+	GetDatasetNodeIdForPackage(ctx context.Context, organizationNodeId string, packageNodeId string) (string, error)
+}
+
+// Step is one step of an explanation: a lookup or check, what it was given and returned, and its error, if any.
+type Step struct {
+	Name   string
+	Detail string
+	Err    error
+}
+
+// Report is the result of an explanation.
+type Report struct {
+	Authorizer string
+	Steps      []Step
+	Claims     map[string]interface{}
+	Decision   string
+	Reason     string
+	Err        error
+}
+
+// Explainer runs the authorizer chain against the given Postgres and DynamoDB APIs.
+type Explainer struct {
+	PostgresDB        manager.PennsievePgAPI
+	DynamoDB          manager.PennsieveDyAPI
+	Packages          PackageLookup
+	TokenClientId     string
+	ManifestTableName string
+}
+
+// Explain authorizes request through the same claims pipeline as the authorizer Lambdas, for a valid token
+// belonging to the subject, and reports each step. An error is returned only for an invalid request; a failure to
+// authorize is reported as the Report's Decision.
+func (e *Explainer) Explain(ctx context.Context, request Request) (*Report, error) {
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+	report := &Report{}
+	if request.SourceIp != "" {
+		purposeOfUse, _ := authorizers.ParsePurposeOfUse(request.PurposeOfUse)
+		// Explained as for a route whose decisions are not cached, the only kind on which protected datasets are allowed.
+		ctx = manager.WithRequest(ctx, manager.Request{SourceIp: request.SourceIp, PurposeOfUse: purposeOfUse, Uncached: true})
+	}
+
+	principal, err := e.subjectManager(ctx, request.Subject, report)
+	if err != nil {
+		return report.decide(err), nil
+	}
+
+	var datasetId string
+	if request.Resource.PackageNodeId != "" {
+		datasetId, err = e.Packages.GetDatasetNodeIdForPackage(ctx, request.Resource.OrganizationNodeId, request.Resource.PackageNodeId)
+		report.Steps = append(report.Steps, Step{
+			Name:   "ResolvePackage",
+			Detail: fmt.Sprintf("package=%s org=%s -> dataset=%s", request.Resource.PackageNodeId, request.Resource.OrganizationNodeId, datasetId),
+			Err:    err,
+		})
+		if err != nil {
+			return report.decide(authorizers.NewIndeterminateError(err)), nil
+		}
+	}
+
+	resource := pipeline.Resource{
+		DatasetNodeId:      request.Resource.DatasetNodeId,
+		OrganizationNodeId: request.Resource.OrganizationNodeId,
+		ManifestId:         request.Resource.ManifestId,
+	}
+	if request.Resource.PackageNodeId != "" {
+		// The organization only locates the package; the dataset found in its schema is the resource.
+		resource = pipeline.Resource{DatasetNodeId: datasetId}
+	}
+	report.Authorizer = resource.Scope()
+	report.Steps = append(report.Steps, Step{Name: "SelectAuthorizer", Detail: report.Authorizer})
+
+	claims, err := pipeline.Resolve(ctx, &recordingManager{IdentityManager: principal, report: report}, resource, request.Mode)
+	report.Claims = claims
+	return report.decide(err), nil
+}
+
+// Validate returns an error if request does not name exactly one subject and at most one resource, or declares a
+// purpose of use that is unknown or without a source IP.
+func (r Request) Validate() error {
+	if (r.Subject.UserNodeId == "") == (r.Subject.CognitoUsername == "") {
+		return errors.New("exactly one of a user node id and a Cognito username must be given")
+	}
+	if r.PurposeOfUse != "" && r.SourceIp == "" {
+		return errors.New("a purpose of use requires a source IP")
+	}
+	if _, err := authorizers.ParsePurposeOfUse(r.PurposeOfUse); err != nil {
+		return err
+	}
+	return validateResource(r.Resource)
+}
+
+// validateResource returns an error if resource names more than one resource, or a package without its organization.
+func validateResource(resource Resource) error {
+	if resource.PackageNodeId != "" {
+		if resource.OrganizationNodeId == "" || resource.DatasetNodeId != "" || resource.ManifestId != "" {
+			return errors.New("a package requires its organization and no other resource")
+		}
+		return nil
+	}
+
+	named := 0
+	for _, id := range []string{resource.DatasetNodeId, resource.OrganizationNodeId, resource.ManifestId} {
+		if id != "" {
+			named++
+		}
+	}
+	if named > 1 {
+		return errors.New("at most one of dataset, organization and manifest may be given")
+	}
+	return nil
+}
+
+// subjectManager returns the principal for subject: a node id principal, or a Cognito principal backed by a token
+// like the one Cognito would issue to them.
+func (e *Explainer) subjectManager(ctx context.Context, subject Subject, report *Report) (manager.IdentityManager, error) {
+	sources := pipeline.Sources{
+		PostgresDB:    e.PostgresDB,
+		DynamoDB:      e.DynamoDB,
+		TokenClientId: e.TokenClientId,
+		ManifestTable: e.ManifestTableName,
+	}
+	if subject.UserNodeId != "" {
+		report.Steps = append(report.Steps, Step{Name: "ResolveSubject", Detail: fmt.Sprintf("user=%s", subject.UserNodeId)})
+		return sources.NodeIdPrincipal(subject.UserNodeId), nil
+	}
+	token, err := e.subjectToken(ctx, subject, report)
+	if err != nil {
+		return nil, err
+	}
+	return sources.CognitoPrincipal(token), nil
+}
+
+// subjectToken builds the claims of the JWT Cognito would issue for subject. An API token's JWT is scoped to the
+// organization the token belongs to, so that organization is looked up and added as the token workspace.
+func (e *Explainer) subjectToken(ctx context.Context, subject Subject, report *Report) (jwt.Token, error) {
+	token := jwt.New()
+	if err := token.Set("username", subject.CognitoUsername); err != nil {
+		return nil, err
+	}
+	if !subject.TokenPool {
+		report.Steps = append(report.Steps, Step{Name: "ResolveSubject", Detail: fmt.Sprintf("user pool username=%s", subject.CognitoUsername)})
+		return token, token.Set("client_id", explainClientId)
+	}
+	if err := token.Set("client_id", e.TokenClientId); err != nil {
+		return nil, err
+	}
+
+	tokenUser, err := e.PostgresDB.GetUserByCognitoId(ctx, subject.CognitoUsername)
+	if err != nil {
+		report.Steps = append(report.Steps, Step{Name: "ResolveSubject", Detail: fmt.Sprintf("token pool username=%s", subject.CognitoUsername), Err: err})
+		return nil, authorizers.NewIndeterminateError(fmt.Errorf("unable to get user for API token: %w", err))
+	}
+	// For API tokens, PreferredOrg is the token's organization.
+	orgClaim, err := e.PostgresDB.GetOrganizationClaim(ctx, tokenUser.Id, tokenUser.PreferredOrg)
+	if err != nil {
+		report.Steps = append(report.Steps, Step{Name: "ResolveSubject", Detail: fmt.Sprintf("token pool username=%s org=%d", subject.CognitoUsername, tokenUser.PreferredOrg), Err: err})
+		return nil, authorizers.NewIndeterminateError(fmt.Errorf("unable to get organization for API token: %w", err))
+	}
+	report.Steps = append(report.Steps, Step{
+		Name:   "ResolveSubject",
+		Detail: fmt.Sprintf("token pool username=%s -> workspace=%d (%s)", subject.CognitoUsername, orgClaim.IntId, orgClaim.NodeId),
+	})
+	if err := token.Set("custom:organization_id", orgClaim.IntId); err != nil {
+		return nil, err
+	}
+	return token, token.Set("custom:organization_node_id", orgClaim.NodeId)
+}
+
+// decide sets the report's decision from the error, if any, that ended the authorizer chain.
+func (r *Report) decide(err error) *Report {
+	r.Err = err
+	var indeterminate *authorizers.IndeterminateError
+	switch {
+	case err == nil:
+		r.Decision = DecisionAllow
+	case errors.As(err, &indeterminate):
+		r.Decision = DecisionIndeterminate
+	default:
+		r.Decision = DecisionDeny
+		r.Reason = authorizers.DenyReason(err)
+		if r.Reason == "" {
+			r.Reason = reasonDenied
+		}
+	}
+	return r
+}
+
+// Write prints the report in a human-readable form.
+func (r *Report) Write(w io.Writer) error {
+	authorizer := r.Authorizer
+	if authorizer == "" {
+		authorizer = "none"
+	}
+	if _, err := fmt.Fprintf(w, "Authorizer: %s\n\nSteps:\n", authorizer); err != nil {
+		return err
+	}
+	for i, step := range r.Steps {
+		line := fmt.Sprintf("%2d. %s", i+1, step.Name)
+		if step.Detail != "" {
+			line += " " + step.Detail
+		}
+		if step.Err != nil {
+			line += fmt.Sprintf(" [error: %s]", step.Err)
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+
+	if len(r.Claims) > 0 {
+		if _, err := fmt.Fprint(w, "\nClaims:\n"); err != nil {
+			return err
+		}
+		labels := make([]string, 0, len(r.Claims))
+		for label := range r.Claims {
+			labels = append(labels, label)
+		}
+		sort.Strings(labels)
+		for _, label := range labels {
+			if _, err := fmt.Fprintf(w, "  %s: %+v\n", label, r.Claims[label]); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err := fmt.Fprintf(w, "\nDecision: %s\n", r.Decision); err != nil {
+		return err
+	}
+	if r.Reason != "" {
+		if _, err := fmt.Fprintf(w, "Reason: %s\n", r.Reason); err != nil {
+			return err
+		}
+	}
+	if r.Err != nil {
+		if _, err := fmt.Fprintf(w, "Error: %s\n", r.Err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
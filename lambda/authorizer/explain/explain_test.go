@@ -0,0 +1,249 @@
+package explain_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/authorizers"
+	claimsClient "github.com/pennsieve/pennsieve-go-api/authorizer/claims"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/explain"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/test"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/test/mocks"
+	coreAuthorizer "github.com/pennsieve/pennsieve-go-core/pkg/authorizer"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dataset"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/organization"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/role"
+	corePgdb "github.com/pennsieve/pennsieve-go-core/pkg/queries/pgdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type packageLookup map[string]string
+
+func (p packageLookup) GetDatasetNodeIdForPackage(_ context.Context, _ string, packageNodeId string) (string, error) {
+	if datasetNodeId, ok := p[packageNodeId]; ok {
+		return datasetNodeId, nil
+	}
+	return "", errors.New("no such package")
+}
+
+func newExplainer() (*explain.Explainer, *mocks.MockPennsievePgAPI) {
+	pg := mocks.NewMockPennsievePgAPI()
+	return &explain.Explainer{
+		PostgresDB:        pg,
+		DynamoDB:          mocks.NewMockPennsieveDyAPI(),
+		Packages:          packageLookup{},
+		TokenClientId:     uuid.NewString(),
+		ManifestTableName: uuid.NewString(),
+	}, pg
+}
+
+func stepNames(report *explain.Report) []string {
+	var names []string
+	for _, step := range report.Steps {
+		names = append(names, step.Name)
+	}
+	return names
+}
+
+func TestExplainDatasetAllow(t *testing.T) {
+	explainer, pg := newExplainer()
+	currentUser := test.NewUser(101, 1001)
+	orgId := int64(2001)
+	datasetNodeId := fmt.Sprintf("N:dataset:%s", uuid.NewString())
+	datasetClaim := &dataset.Claim{Role: role.Editor, NodeId: datasetNodeId, IntId: 7}
+	pg.OnGetUserByNodeId(currentUser.NodeId).Return(currentUser, nil)
+	pg.OnGetOrganizationIdForDataset(datasetNodeId).Return(orgId, nil)
+	pg.OnGetOrganizationClaim(currentUser.Id, orgId).Return(&organization.Claim{Role: pgdb.Read, IntId: orgId}, nil)
+	pg.OnGetDatasetClaim(currentUser, datasetNodeId, orgId).Return(datasetClaim, nil)
+	pg.OnGetDatasetState(datasetNodeId, orgId).Return(&claimsClient.DatasetState{}, nil)
+	pg.OnGetDataUseAgreementStatus(currentUser.Id, datasetNodeId, orgId).Return(&manager.DataUseAgreementStatus{}, nil)
+
+	report, err := explainer.Explain(context.Background(), explain.Request{
+		Subject:  explain.Subject{UserNodeId: currentUser.NodeId},
+		Resource: explain.Resource{DatasetNodeId: datasetNodeId},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, explain.DecisionAllow, report.Decision)
+	assert.Empty(t, report.Reason)
+	assert.Equal(t, "dataset", report.Authorizer)
+	assert.Equal(t, datasetClaim, report.Claims[coreAuthorizer.LabelDatasetClaim])
+	assert.Equal(t, []string{
+		"ResolveSubject",
+		"SelectAuthorizer",
+		"IdentityManager.GetCurrentUser",
+		"IdentityManager.GetOrganizationIdForDataset",
+		"IdentityManager.GetTokenWorkspace",
+		"IdentityManager.GetOrgClaim",
+		"IdentityManager.GetDatasetClaim",
+		"IdentityManager.GetDataUseAgreementStatus",
+		"IdentityManager.GetDatasetState",
+		"IdentityManager.GetUserClaim",
+		"IdentityManager.GetGrantExpiry",
+	}, stepNames(report))
+	pg.AssertExpectations(t)
+}
+
+func TestExplainDatasetDenyReasons(t *testing.T) {
+	currentUser := test.NewUser(101, 1001)
+	orgId := int64(2001)
+	datasetNodeId := fmt.Sprintf("N:dataset:%s", uuid.NewString())
+
+	for name, params := range map[string]struct {
+		setup          func(pg *mocks.MockPennsievePgAPI)
+		expectedReason string
+	}{
+		"dataset not found": {
+			setup: func(pg *mocks.MockPennsievePgAPI) {
+				pg.OnGetOrganizationIdForDataset(datasetNodeId).
+					Return(int64(0), corePgdb.DatasetOrganizationNotFoundError{DatasetNodeId: datasetNodeId})
+			},
+			expectedReason: authorizers.ReasonDatasetNotFound,
+		},
+		"not an org member": {
+			setup: func(pg *mocks.MockPennsievePgAPI) {
+				pg.OnGetOrganizationIdForDataset(datasetNodeId).Return(orgId, nil)
+				pg.OnGetOrganizationClaim(currentUser.Id, orgId).
+					Return((*organization.Claim)(nil), corePgdb.OrganizationUserNotFoundError{ErrorMessage: "no rows"})
+				pg.OnGetExternalDatasetGrant(currentUser.Id, datasetNodeId, orgId).Return((*manager.ExternalGrant)(nil), sql.ErrNoRows)
+			},
+			expectedReason: authorizers.ReasonNotOrgMember,
+		},
+		"no dataset role": {
+			setup: func(pg *mocks.MockPennsievePgAPI) {
+				pg.OnGetOrganizationIdForDataset(datasetNodeId).Return(orgId, nil)
+				pg.OnGetOrganizationClaim(currentUser.Id, orgId).Return(&organization.Claim{Role: pgdb.Read, IntId: orgId}, nil)
+				pg.OnGetDatasetClaim(currentUser, datasetNodeId, orgId).Return(&dataset.Claim{Role: role.None}, nil)
+			},
+			expectedReason: authorizers.ReasonNoDatasetRole,
+		},
+		"data-use agreement not accepted": {
+			setup: func(pg *mocks.MockPennsievePgAPI) {
+				pg.OnGetOrganizationIdForDataset(datasetNodeId).Return(orgId, nil)
+				pg.OnGetOrganizationClaim(currentUser.Id, orgId).Return(&organization.Claim{Role: pgdb.Read, IntId: orgId}, nil)
+				pg.OnGetDatasetClaim(currentUser, datasetNodeId, orgId).Return(&dataset.Claim{Role: role.Viewer}, nil)
+				pg.OnGetDataUseAgreementStatus(currentUser.Id, datasetNodeId, orgId).
+					Return(&manager.DataUseAgreementStatus{Required: true, AgreementId: 7}, nil)
+			},
+			expectedReason: authorizers.ReasonDuaRequired,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			explainer, pg := newExplainer()
+			pg.OnGetUserByNodeId(currentUser.NodeId).Return(currentUser, nil)
+			params.setup(pg)
+
+			report, err := explainer.Explain(context.Background(), explain.Request{
+				Subject:  explain.Subject{UserNodeId: currentUser.NodeId},
+				Resource: explain.Resource{DatasetNodeId: datasetNodeId},
+			})
+			require.NoError(t, err)
+
+			assert.Equal(t, explain.DecisionDeny, report.Decision)
+			assert.Equal(t, params.expectedReason, report.Reason)
+			assert.Nil(t, report.Claims)
+
+			var out bytes.Buffer
+			require.NoError(t, report.Write(&out))
+			assert.Contains(t, out.String(), "Decision: deny\nReason: "+params.expectedReason+"\n")
+			pg.AssertExpectations(t)
+		})
+	}
+}
+
+func TestExplainIndeterminate(t *testing.T) {
+	explainer, pg := newExplainer()
+	currentUser := test.NewUser(101, 1001)
+	datasetNodeId := fmt.Sprintf("N:dataset:%s", uuid.NewString())
+	pg.OnGetUserByNodeId(currentUser.NodeId).Return(currentUser, nil)
+	pg.OnGetOrganizationIdForDataset(datasetNodeId).Return(int64(0), errors.New("connection refused"))
+
+	report, err := explainer.Explain(context.Background(), explain.Request{
+		Subject:  explain.Subject{UserNodeId: currentUser.NodeId},
+		Resource: explain.Resource{DatasetNodeId: datasetNodeId},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, explain.DecisionIndeterminate, report.Decision)
+	assert.ErrorContains(t, report.Err, "connection refused")
+	last := report.Steps[len(report.Steps)-1]
+	assert.Equal(t, "IdentityManager.GetOrganizationIdForDataset", last.Name)
+	assert.Error(t, last.Err)
+}
+
+func TestExplainTokenPoolWorkspaceMismatch(t *testing.T) {
+	explainer, pg := newExplainer()
+	username := uuid.NewString()
+	tokenOrgId := int64(3001)
+	tokenUser := test.NewUser(101, tokenOrgId)
+	datasetOrgId := int64(6001)
+	datasetNodeId := fmt.Sprintf("N:dataset:%s", uuid.NewString())
+	pg.OnGetUserByCognitoId(username).Return(tokenUser, nil)
+	pg.OnGetOrganizationClaim(tokenUser.Id, tokenOrgId).
+		Return(&organization.Claim{Role: pgdb.Write, IntId: tokenOrgId, NodeId: "N:organization:token"}, nil)
+	pg.OnGetOrganizationIdForDataset(datasetNodeId).Return(datasetOrgId, nil)
+
+	report, err := explainer.Explain(context.Background(), explain.Request{
+		Subject:  explain.Subject{CognitoUsername: username, TokenPool: true},
+		Resource: explain.Resource{DatasetNodeId: datasetNodeId},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, explain.DecisionDeny, report.Decision)
+	assert.Equal(t, authorizers.ReasonTokenWorkspaceMismatch, report.Reason)
+	pg.AssertExpectations(t)
+}
+
+func TestExplainPackage(t *testing.T) {
+	explainer, pg := newExplainer()
+	currentUser := test.NewUser(101, 1001)
+	orgId := int64(2001)
+	orgNodeId := fmt.Sprintf("N:organization:%s", uuid.NewString())
+	packageNodeId := fmt.Sprintf("N:package:%s", uuid.NewString())
+	datasetNodeId := fmt.Sprintf("N:dataset:%s", uuid.NewString())
+	explainer.Packages = packageLookup{packageNodeId: datasetNodeId}
+	pg.OnGetUserByNodeId(currentUser.NodeId).Return(currentUser, nil)
+	pg.OnGetOrganizationIdForDataset(datasetNodeId).Return(orgId, nil)
+	pg.OnGetOrganizationClaim(currentUser.Id, orgId).Return(&organization.Claim{Role: pgdb.Read, IntId: orgId}, nil)
+	pg.OnGetDatasetClaim(currentUser, datasetNodeId, orgId).Return(&dataset.Claim{Role: role.Viewer, NodeId: datasetNodeId}, nil)
+	pg.OnGetDatasetState(datasetNodeId, orgId).Return(&claimsClient.DatasetState{}, nil)
+	pg.OnGetDataUseAgreementStatus(currentUser.Id, datasetNodeId, orgId).Return(&manager.DataUseAgreementStatus{}, nil)
+
+	report, err := explainer.Explain(context.Background(), explain.Request{
+		Subject:  explain.Subject{UserNodeId: currentUser.NodeId},
+		Resource: explain.Resource{PackageNodeId: packageNodeId, OrganizationNodeId: orgNodeId},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, explain.DecisionAllow, report.Decision)
+	assert.Equal(t, "dataset", report.Authorizer)
+	assert.Equal(t, "ResolvePackage", report.Steps[1].Name)
+	assert.Contains(t, report.Steps[1].Detail, datasetNodeId)
+	pg.AssertExpectations(t)
+}
+
+func TestExplainInvalidRequest(t *testing.T) {
+	for name, request := range map[string]explain.Request{
+		"no subject":             {Resource: explain.Resource{DatasetNodeId: "N:dataset:1"}},
+		"two subjects":           {Subject: explain.Subject{UserNodeId: "N:user:1", CognitoUsername: "someone"}},
+		"two resources":          {Subject: explain.Subject{UserNodeId: "N:user:1"}, Resource: explain.Resource{DatasetNodeId: "N:dataset:1", ManifestId: "m"}},
+		"package without org":    {Subject: explain.Subject{UserNodeId: "N:user:1"}, Resource: explain.Resource{PackageNodeId: "N:package:1"}},
+		"package with a dataset": {Subject: explain.Subject{UserNodeId: "N:user:1"}, Resource: explain.Resource{PackageNodeId: "N:package:1", OrganizationNodeId: "N:organization:1", DatasetNodeId: "N:dataset:1"}},
+		"unknown purpose of use": {Subject: explain.Subject{UserNodeId: "N:user:1"}, SourceIp: "192.0.2.1", PurposeOfUse: "curiosity"},
+		"purpose without ip":     {Subject: explain.Subject{UserNodeId: "N:user:1"}, PurposeOfUse: "research"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			explainer, _ := newExplainer()
+			_, err := explainer.Explain(context.Background(), request)
+			assert.Error(t, err)
+		})
+	}
+}
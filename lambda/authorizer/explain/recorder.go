@@ -0,0 +1,156 @@
+package explain
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pennsieve/pennsieve-go-api/authorizer/claims"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dataset"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dydb"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/organization"
+	pgdbModels "github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/teamUser"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/user"
+)
+
+// recordingManager is an IdentityManager that adds a Step to its report for every call to its delegate, so that the
+// report shows each lookup an authorizer made and what it returned.
+type recordingManager struct {
+	manager.IdentityManager
+	report *Report
+}
+
+func (r *recordingManager) record(method string, args string, result interface{}, err error) {
+	step := Step{Name: "IdentityManager." + method, Err: err}
+	if err == nil {
+		step.Detail = fmt.Sprintf("%s -> %+v", args, result)
+	} else {
+		step.Detail = args
+	}
+	r.report.Steps = append(r.report.Steps, step)
+}
+
+func (r *recordingManager) GetCurrentUser(ctx context.Context) (*pgdbModels.User, error) {
+	currentUser, err := r.IdentityManager.GetCurrentUser(ctx)
+	r.record("GetCurrentUser", "", currentUser, err)
+	return currentUser, err
+}
+
+func (r *recordingManager) GetActiveOrg(ctx context.Context, currentUser *pgdbModels.User) int64 {
+	orgId := r.IdentityManager.GetActiveOrg(ctx, currentUser)
+	r.record("GetActiveOrg", fmt.Sprintf("user=%d", currentUser.Id), orgId, nil)
+	return orgId
+}
+
+func (r *recordingManager) GetUserClaim(ctx context.Context, currentUser *pgdbModels.User) *user.Claim {
+	claim := r.IdentityManager.GetUserClaim(ctx, currentUser)
+	r.record("GetUserClaim", fmt.Sprintf("user=%d", currentUser.Id), claim, nil)
+	return claim
+}
+
+func (r *recordingManager) GetDatasetClaim(ctx context.Context, currentUser *pgdbModels.User, datasetId string, orgId int64) (*dataset.Claim, error) {
+	claim, err := r.IdentityManager.GetDatasetClaim(ctx, currentUser, datasetId, orgId)
+	r.record("GetDatasetClaim", fmt.Sprintf("user=%d dataset=%s org=%d", currentUser.Id, datasetId, orgId), claim, err)
+	return claim, err
+}
+
+func (r *recordingManager) GetOrgClaim(ctx context.Context, userId int64, orgId int64) (*organization.Claim, error) {
+	claim, err := r.IdentityManager.GetOrgClaim(ctx, userId, orgId)
+	r.record("GetOrgClaim", fmt.Sprintf("user=%d org=%d", userId, orgId), claim, err)
+	return claim, err
+}
+
+func (r *recordingManager) GetOrgClaimByNodeId(ctx context.Context, userId int64, orgNodeId string) (*organization.Claim, error) {
+	claim, err := r.IdentityManager.GetOrgClaimByNodeId(ctx, userId, orgNodeId)
+	r.record("GetOrgClaimByNodeId", fmt.Sprintf("user=%d org=%s", userId, orgNodeId), claim, err)
+	return claim, err
+}
+
+func (r *recordingManager) GetTeamClaims(ctx context.Context, userId int64) ([]teamUser.Claim, error) {
+	claims, err := r.IdentityManager.GetTeamClaims(ctx, userId)
+	r.record("GetTeamClaims", fmt.Sprintf("user=%d", userId), claims, err)
+	return claims, err
+}
+
+func (r *recordingManager) GetTeamClaimsForOrg(ctx context.Context, userId int64, orgId int64) ([]teamUser.Claim, error) {
+	claims, err := r.IdentityManager.GetTeamClaimsForOrg(ctx, userId, orgId)
+	r.record("GetTeamClaimsForOrg", fmt.Sprintf("user=%d org=%d", userId, orgId), claims, err)
+	return claims, err
+}
+
+func (r *recordingManager) GetOrganizationIdForDataset(ctx context.Context, datasetId string) (int64, error) {
+	orgId, err := r.IdentityManager.GetOrganizationIdForDataset(ctx, datasetId)
+	r.record("GetOrganizationIdForDataset", fmt.Sprintf("dataset=%s", datasetId), orgId, err)
+	return orgId, err
+}
+
+func (r *recordingManager) GetManifest(ctx context.Context, manifestId string) (*dydb.ManifestTable, error) {
+	manifest, err := r.IdentityManager.GetManifest(ctx, manifestId)
+	r.record("GetManifest", fmt.Sprintf("manifest=%s", manifestId), manifest, err)
+	return manifest, err
+}
+
+func (r *recordingManager) GetTokenWorkspace() (manager.TokenWorkspace, bool) {
+	workspace, ok := r.IdentityManager.GetTokenWorkspace()
+	if ok {
+		r.record("GetTokenWorkspace", "", workspace, nil)
+	} else {
+		r.record("GetTokenWorkspace", "", "none", nil)
+	}
+	return workspace, ok
+}
+
+func (r *recordingManager) GetAuthentication() manager.Authentication {
+	authentication := r.IdentityManager.GetAuthentication()
+	r.record("GetAuthentication", "", authentication, nil)
+	return authentication
+}
+
+func (r *recordingManager) GetUserByNodeId(ctx context.Context, nodeId string) (*pgdbModels.User, error) {
+	found, err := r.IdentityManager.GetUserByNodeId(ctx, nodeId)
+	r.record("GetUserByNodeId", fmt.Sprintf("user=%s", nodeId), found, err)
+	return found, err
+}
+
+func (r *recordingManager) GetOrganizationIpAllowlist(ctx context.Context, orgId int64) ([]string, error) {
+	allowlist, err := r.IdentityManager.GetOrganizationIpAllowlist(ctx, orgId)
+	r.record("GetOrganizationIpAllowlist", fmt.Sprintf("org=%d", orgId), allowlist, err)
+	return allowlist, err
+}
+
+func (r *recordingManager) GetOrganizationAuthPolicy(ctx context.Context, orgId int64) (*manager.AuthPolicy, error) {
+	policy, err := r.IdentityManager.GetOrganizationAuthPolicy(ctx, orgId)
+	r.record("GetOrganizationAuthPolicy", fmt.Sprintf("org=%d", orgId), policy, err)
+	return policy, err
+}
+
+func (r *recordingManager) GetExternalDatasetGrant(ctx context.Context, userId int64, datasetId string, orgId int64) (*manager.ExternalGrant, error) {
+	grant, err := r.IdentityManager.GetExternalDatasetGrant(ctx, userId, datasetId, orgId)
+	r.record("GetExternalDatasetGrant", fmt.Sprintf("user=%d dataset=%s org=%d", userId, datasetId, orgId), grant, err)
+	return grant, err
+}
+
+func (r *recordingManager) GetDatasetIdentity(ctx context.Context, datasetId string, orgId int64) (*manager.DatasetIdentity, error) {
+	identity, err := r.IdentityManager.GetDatasetIdentity(ctx, datasetId, orgId)
+	r.record("GetDatasetIdentity", fmt.Sprintf("dataset=%s org=%d", datasetId, orgId), identity, err)
+	return identity, err
+}
+
+func (r *recordingManager) GetDatasetState(ctx context.Context, datasetId string, orgId int64) (*claims.DatasetState, error) {
+	datasetState, err := r.IdentityManager.GetDatasetState(ctx, datasetId, orgId)
+	r.record("GetDatasetState", fmt.Sprintf("dataset=%s org=%d", datasetId, orgId), datasetState, err)
+	return datasetState, err
+}
+
+func (r *recordingManager) GetDataUseAgreementStatus(ctx context.Context, userId int64, datasetId string, orgId int64) (*manager.DataUseAgreementStatus, error) {
+	status, err := r.IdentityManager.GetDataUseAgreementStatus(ctx, userId, datasetId, orgId)
+	r.record("GetDataUseAgreementStatus", fmt.Sprintf("user=%d dataset=%s org=%d", userId, datasetId, orgId), status, err)
+	return status, err
+}
+
+func (r *recordingManager) GetGrantExpiry(ctx context.Context, userId int64, datasetId string, orgId int64) (*claims.GrantExpiry, error) {
+	expiry, err := r.IdentityManager.GetGrantExpiry(ctx, userId, datasetId, orgId)
+	r.record("GetGrantExpiry", fmt.Sprintf("user=%d dataset=%s org=%d", userId, datasetId, orgId), expiry, err)
+	return expiry, err
+}
@@ -2,44 +2,156 @@ package factory
 
 import (
 	"errors"
+	"fmt"
+	"strings"
+
 	"github.com/pennsieve/pennsieve-go-api/authorizer/authorizers"
 	"github.com/pennsieve/pennsieve-go-api/authorizer/mappers"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/role"
 )
 
 type AuthorizerFactory interface {
-	Build([]string, map[string]string) (authorizers.Authorizer, error)
+	Build(Event) (authorizers.Authorizer, error)
+}
+
+// Location is where in a request a Binding's parameter is found.
+type Location string
+
+const (
+	LocationQuery  Location = "query"
+	LocationPath   Location = "path"
+	LocationHeader Location = "header"
+)
+
+// Binding declares that a non-token identity source selects an authorizer when it is the value of Parameter at
+// Location. New builds the authorizer for that value. MinRole, if set, is the least role the user must have on
+// the resource (see authorizers.CompositeResource).
+type Binding struct {
+	Location  Location
+	Parameter string
+	New       func(id string) authorizers.Authorizer
+	MinRole   role.Role
+}
+
+func (b Binding) String() string {
+	return fmt.Sprintf("%s parameter %s", b.Location, b.Parameter)
+}
+
+// Registry is the set of bindings a factory selects authorizers with.
+type Registry []Binding
+
+// DefaultRegistry binds the identity sources of the API Gateway routes. Routes that move or copy data between
+// datasets name the source_dataset_id, which the user must be able to read, and the target_dataset_id, which they
+// must be able to edit; routes that add to a workspace's collections name the collection_org_id, in which they must
+// be able to edit.
+var DefaultRegistry = Registry{
+	{Location: LocationQuery, Parameter: "dataset_id", New: authorizers.NewDatasetAuthorizer},
+	{Location: LocationQuery, Parameter: "organization_id", New: authorizers.NewWorkspaceAuthorizer},
+	{Location: LocationQuery, Parameter: "manifest_id", New: authorizers.NewManifestAuthorizer},
+	{Location: LocationQuery, Parameter: "source_dataset_id", New: authorizers.NewDatasetAuthorizer, MinRole: role.Viewer},
+	{Location: LocationQuery, Parameter: "target_dataset_id", New: authorizers.NewDatasetAuthorizer, MinRole: role.Editor},
+	{Location: LocationQuery, Parameter: "collection_org_id", New: authorizers.NewWorkspaceAuthorizer, MinRole: role.Editor},
 }
 
-type CustomAuthorizerFactory struct{}
+// Event is the metadata of the API Gateway authorizer event the factory selects an authorizer for. API Gateway
+// delivers HTTP API header names lower-cased.
+type Event struct {
+	IdentitySource        []string
+	QueryStringParameters map[string]string
+	PathParameters        map[string]string
+	Headers               map[string]string
+}
+
+// value returns the value of parameter at location in the event, or "" if there is none.
+func (e Event) value(location Location, parameter string) string {
+	switch location {
+	case LocationQuery:
+		return e.QueryStringParameters[parameter]
+	case LocationPath:
+		return e.PathParameters[parameter]
+	case LocationHeader:
+		return e.Headers[strings.ToLower(parameter)]
+	default:
+		return ""
+	}
+}
+
+// CustomAuthorizerFactory selects an authorizer by finding which binding in its Registry the identity source came
+// from.
+type CustomAuthorizerFactory struct {
+	Registry Registry
+}
 
+// NewCustomAuthorizerFactory returns a factory with the DefaultRegistry.
 func NewCustomAuthorizerFactory() AuthorizerFactory {
-	return &CustomAuthorizerFactory{}
+	return NewRegistryFactory(DefaultRegistry)
 }
 
-func (f *CustomAuthorizerFactory) Build(identitySource []string, queryStringParameters map[string]string) (authorizers.Authorizer, error) {
+// NewRegistryFactory returns a factory with the given registry.
+func NewRegistryFactory(registry Registry) AuthorizerFactory {
+	return &CustomAuthorizerFactory{Registry: registry}
+}
+
+// Build returns the UserAuthorizer if the identity source is the token alone. Otherwise each other identity source
+// is matched to a binding in the registry whose parameter holds it and that no earlier identity source matched.
+// A single resource with no minimum role gets its binding's authorizer; several resources, or one with a minimum
+// role, get a CompositeAuthorizer. It is an error if an identity source matches no binding, or bindings that build
+// different authorizer types, since the event then does not say which resource the route is for.
+func (f *CustomAuthorizerFactory) Build(event Event) (authorizers.Authorizer, error) {
 
-	mappedIdentitySource, err := mappers.NewIdentitySourceMapper(identitySource).Create()
+	mappedIdentitySource, err := mappers.NewIdentitySourceMapper(event.IdentitySource).Create()
 	if err != nil {
 		return nil, err
 	}
 
 	// immediately return the UserAuthorizer
-	if mappedIdentitySource.Other == nil {
+	if len(mappedIdentitySource.Others) == 0 {
 		return authorizers.NewUserAuthorizer(), nil
 	}
 
-	otherIdentitySource := *mappedIdentitySource.Other
-
-	if otherIdentitySource == queryStringParameters["dataset_id"] {
-		return authorizers.NewDatasetAuthorizer(otherIdentitySource), nil
+	used := make([]bool, len(f.Registry))
+	var resources []authorizers.CompositeResource
+	for _, otherIdentitySource := range mappedIdentitySource.Others {
+		binding, err := f.match(event, otherIdentitySource, used)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, authorizers.CompositeResource{
+			Name:       binding.Parameter,
+			Authorizer: binding.New(otherIdentitySource),
+			MinRole:    binding.MinRole,
+		})
 	}
 
-	if otherIdentitySource == queryStringParameters["organization_id"] {
-		return authorizers.NewWorkspaceAuthorizer(otherIdentitySource), nil
+	if len(resources) == 1 && resources[0].MinRole == role.None {
+		return resources[0].Authorizer, nil
 	}
+	return authorizers.NewCompositeAuthorizer(resources...), nil
+}
 
-	if otherIdentitySource == queryStringParameters["manifest_id"] {
-		return authorizers.NewManifestAuthorizer(otherIdentitySource), nil
+// match returns the binding not yet used whose parameter holds value, and marks it used. If several do, it returns
+// the one with the greatest minimum role, or the first of those, so that a client cannot escape a route's minimum
+// role by repeating the resource in a parameter that requires less.
+func (f *CustomAuthorizerFactory) match(event Event, value string, used []bool) (Binding, error) {
+	matched := -1
+	for i, binding := range f.Registry {
+		if used[i] || event.value(binding.Location, binding.Parameter) != value {
+			continue
+		}
+		if matched < 0 {
+			matched = i
+			continue
+		}
+		if authorizers.Scope(binding.New(value)) != authorizers.Scope(f.Registry[matched].New(value)) {
+			return Binding{}, fmt.Errorf("ambiguous identity source: both %s and %s hold its value", f.Registry[matched], binding)
+		}
+		if minRole := f.Registry[matched].MinRole; binding.MinRole != minRole && binding.MinRole.Implies(minRole) {
+			matched = i
+		}
+	}
+	if matched < 0 {
+		return Binding{}, errors.New("no suitable authorizer to process request")
 	}
-	return nil, errors.New("no suitable authorizer to process request")
+	used[matched] = true
+	return f.Registry[matched], nil
 }
@@ -2,6 +2,7 @@ package factory_test
 
 import (
 	"github.com/pennsieve/pennsieve-go-api/authorizer/authorizers"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/role"
 	"github.com/stretchr/testify/require"
 	"testing"
 
@@ -59,7 +60,7 @@ func TestFactory(t *testing.T) {
 		"user supplies both workspace and dataset id to dataset authorizer endpoint":   {objectIdentitySource, withDatasetAndWorkspaceIds, datasetAuthorizerType},
 	} {
 		t.Run(scenario, func(t *testing.T) {
-			authorizer, err := authFactory.Build(params.idSource, params.queryParams)
+			authorizer, err := authFactory.Build(factory.Event{IdentitySource: params.idSource, QueryStringParameters: params.queryParams})
 			require.NoError(t, err)
 			assert.IsType(t, params.expectedAuthorizerType, authorizer)
 		})
@@ -84,9 +85,194 @@ func TestFactory(t *testing.T) {
 		"empty object id":              {idSourceEmptyObjectId, queryParamsEmptyObjectId, "invalid non-token identity source found"},
 	} {
 		t.Run(scenario, func(t *testing.T) {
-			authorizer, err := authFactory.Build(params.idSource, params.queryParams)
+			authorizer, err := authFactory.Build(factory.Event{IdentitySource: params.idSource, QueryStringParameters: params.queryParams})
 			assert.Equal(t, nil, authorizer)
 			assert.ErrorContains(t, err, params.expectedErrorText)
 		})
 	}
 }
+
+func TestRegistryFactory(t *testing.T) {
+	authHeaderValue := "Bearer eyJra.some.random.string"
+	objectId := "someObjectId"
+
+	registry := factory.Registry{
+		{Location: factory.LocationPath, Parameter: "datasetId", New: authorizers.NewDatasetAuthorizer},
+		{Location: factory.LocationQuery, Parameter: "dataset_id", New: authorizers.NewDatasetAuthorizer},
+		{Location: factory.LocationHeader, Parameter: "X-Organization-Id", New: authorizers.NewWorkspaceAuthorizer},
+		{Location: factory.LocationQuery, Parameter: "manifest_id", New: authorizers.NewManifestAuthorizer},
+	}
+	authFactory := factory.NewRegistryFactory(registry)
+
+	var datasetAuthorizerType *authorizers.DatasetAuthorizer
+	var workspaceAuthorizerType *authorizers.WorkspaceAuthorizer
+
+	for scenario, params := range map[string]struct {
+		event                  factory.Event
+		expectedAuthorizerType authorizers.Authorizer
+	}{
+		"path parameter": {
+			factory.Event{IdentitySource: []string{authHeaderValue, objectId}, PathParameters: map[string]string{"datasetId": objectId}},
+			datasetAuthorizerType,
+		},
+		"header, matched case-insensitively": {
+			factory.Event{IdentitySource: []string{authHeaderValue, objectId}, Headers: map[string]string{"x-organization-id": objectId}},
+			workspaceAuthorizerType,
+		},
+		"same value in two bindings of the same authorizer type": {
+			factory.Event{
+				IdentitySource:        []string{authHeaderValue, objectId},
+				PathParameters:        map[string]string{"datasetId": objectId},
+				QueryStringParameters: map[string]string{"dataset_id": objectId},
+			},
+			datasetAuthorizerType,
+		},
+		"same value in a parameter with no binding": {
+			factory.Event{
+				IdentitySource:        []string{authHeaderValue, objectId},
+				QueryStringParameters: map[string]string{"organization_id": objectId},
+				Headers:               map[string]string{"x-organization-id": objectId},
+			},
+			workspaceAuthorizerType,
+		},
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			authorizer, err := authFactory.Build(params.event)
+			require.NoError(t, err)
+			assert.IsType(t, params.expectedAuthorizerType, authorizer)
+		})
+	}
+
+	t.Run("same value in bindings of different authorizer types", func(t *testing.T) {
+		authorizer, err := authFactory.Build(factory.Event{
+			IdentitySource:        []string{authHeaderValue, objectId},
+			QueryStringParameters: map[string]string{"dataset_id": objectId, "manifest_id": objectId},
+		})
+		assert.Nil(t, authorizer)
+		assert.ErrorContains(t, err, "ambiguous identity source")
+	})
+
+	t.Run("no binding holds the identity source", func(t *testing.T) {
+		authorizer, err := authFactory.Build(factory.Event{
+			IdentitySource:        []string{authHeaderValue, objectId},
+			QueryStringParameters: map[string]string{"organization_id": objectId},
+		})
+		assert.Nil(t, authorizer)
+		assert.ErrorContains(t, err, "no suitable authorizer")
+	})
+}
+
+func TestRegistryFactoryMultipleResources(t *testing.T) {
+	authHeaderValue := "Bearer eyJra.some.random.string"
+	sourceId := "N:dataset:source"
+	targetId := "N:dataset:target"
+
+	registry := factory.Registry{
+		{Location: factory.LocationPath, Parameter: "source_dataset_id", New: authorizers.NewDatasetAuthorizer, MinRole: role.Viewer},
+		{Location: factory.LocationQuery, Parameter: "target_dataset_id", New: authorizers.NewDatasetAuthorizer, MinRole: role.Editor},
+		{Location: factory.LocationQuery, Parameter: "dataset_id", New: authorizers.NewDatasetAuthorizer},
+	}
+	authFactory := factory.NewRegistryFactory(registry)
+
+	for scenario, params := range map[string]struct {
+		event             factory.Event
+		expectedResources []authorizers.CompositeResource
+	}{
+		"two resources": {
+			factory.Event{
+				IdentitySource:        []string{authHeaderValue, sourceId, targetId},
+				PathParameters:        map[string]string{"source_dataset_id": sourceId},
+				QueryStringParameters: map[string]string{"target_dataset_id": targetId},
+			},
+			[]authorizers.CompositeResource{
+				{Name: "source_dataset_id", Authorizer: authorizers.NewDatasetAuthorizer(sourceId), MinRole: role.Viewer},
+				{Name: "target_dataset_id", Authorizer: authorizers.NewDatasetAuthorizer(targetId), MinRole: role.Editor},
+			},
+		},
+		"the same dataset as both resources": {
+			factory.Event{
+				IdentitySource:        []string{authHeaderValue, sourceId, sourceId},
+				PathParameters:        map[string]string{"source_dataset_id": sourceId},
+				QueryStringParameters: map[string]string{"target_dataset_id": sourceId},
+			},
+			// Each identity source takes the strictest binding left that holds it.
+			[]authorizers.CompositeResource{
+				{Name: "target_dataset_id", Authorizer: authorizers.NewDatasetAuthorizer(sourceId), MinRole: role.Editor},
+				{Name: "source_dataset_id", Authorizer: authorizers.NewDatasetAuthorizer(sourceId), MinRole: role.Viewer},
+			},
+		},
+		"one resource with a minimum role": {
+			factory.Event{
+				IdentitySource:        []string{authHeaderValue, targetId},
+				QueryStringParameters: map[string]string{"target_dataset_id": targetId},
+			},
+			[]authorizers.CompositeResource{
+				{Name: "target_dataset_id", Authorizer: authorizers.NewDatasetAuthorizer(targetId), MinRole: role.Editor},
+			},
+		},
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			authorizer, err := authFactory.Build(params.event)
+			require.NoError(t, err)
+			assert.Equal(t, &authorizers.CompositeAuthorizer{Resources: params.expectedResources}, authorizer)
+		})
+	}
+
+	t.Run("one of several resources has no binding", func(t *testing.T) {
+		authorizer, err := authFactory.Build(factory.Event{
+			IdentitySource: []string{authHeaderValue, sourceId, "unbound"},
+			PathParameters: map[string]string{"source_dataset_id": sourceId},
+		})
+		assert.Nil(t, authorizer)
+		assert.ErrorContains(t, err, "no suitable authorizer")
+	})
+}
+
+func TestDefaultRegistryMultipleResources(t *testing.T) {
+	authFactory := factory.NewCustomAuthorizerFactory()
+	authHeaderValue := "Bearer eyJra.some.random.string"
+	sourceId := "N:dataset:source"
+	targetId := "N:dataset:target"
+	orgId := "N:organization:collections"
+
+	for scenario, params := range map[string]struct {
+		event             factory.Event
+		expectedResources []authorizers.CompositeResource
+	}{
+		"source and target datasets": {
+			factory.Event{
+				IdentitySource:        []string{authHeaderValue, sourceId, targetId},
+				QueryStringParameters: map[string]string{"source_dataset_id": sourceId, "target_dataset_id": targetId},
+			},
+			[]authorizers.CompositeResource{
+				{Name: "source_dataset_id", Authorizer: authorizers.NewDatasetAuthorizer(sourceId), MinRole: role.Viewer},
+				{Name: "target_dataset_id", Authorizer: authorizers.NewDatasetAuthorizer(targetId), MinRole: role.Editor},
+			},
+		},
+		"dataset added to a collection": {
+			factory.Event{
+				IdentitySource:        []string{authHeaderValue, orgId, sourceId},
+				QueryStringParameters: map[string]string{"collection_org_id": orgId, "source_dataset_id": sourceId},
+			},
+			[]authorizers.CompositeResource{
+				{Name: "collection_org_id", Authorizer: authorizers.NewWorkspaceAuthorizer(orgId), MinRole: role.Editor},
+				{Name: "source_dataset_id", Authorizer: authorizers.NewDatasetAuthorizer(sourceId), MinRole: role.Viewer},
+			},
+		},
+		"target repeated in a parameter with no minimum role": {
+			factory.Event{
+				IdentitySource:        []string{authHeaderValue, targetId},
+				QueryStringParameters: map[string]string{"dataset_id": targetId, "target_dataset_id": targetId},
+			},
+			[]authorizers.CompositeResource{
+				{Name: "target_dataset_id", Authorizer: authorizers.NewDatasetAuthorizer(targetId), MinRole: role.Editor},
+			},
+		},
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			authorizer, err := authFactory.Build(params.event)
+			require.NoError(t, err)
+			assert.Equal(t, &authorizers.CompositeAuthorizer{Resources: params.expectedResources}, authorizer)
+		})
+	}
+}
@@ -0,0 +1,174 @@
+// Package fault injects failures into the authorizer's dependencies: Postgres, DynamoDB and the Lambdas it invokes.
+// It lets tests and the local Docker environment check that every entry point fails closed, and classifies each
+// failure correctly, when a dependency is slow, failing or hung.
+package fault
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/metrics"
+)
+
+// Kinds of fault.
+const (
+	// KindLatency delays the call by LatencyMs, then makes it.
+	KindLatency = "latency"
+	// KindError fails the call without making it.
+	KindError = "error"
+	// KindTimeout fails the call once its context is done, as a hung dependency would.
+	KindTimeout = "timeout"
+)
+
+// ErrInjected is wrapped by every error an Injector returns.
+var ErrInjected = errors.New("injected fault")
+
+// Rule injects a fault into the calls it matches.
+type Rule struct {
+	// Dependency is the dependency whose calls match, as named in metrics: postgres, dynamodb or lambda. Empty
+	// matches every dependency.
+	Dependency string `json:"dependency,omitempty"`
+	// Operation is the call that matches, e.g. GetDatasetClaim or CallbackValidator. Empty matches every call.
+	Operation string `json:"operation,omitempty"`
+	// Kind is KindLatency, KindError or KindTimeout.
+	Kind string `json:"kind"`
+	// Probability is the chance, between 0 and 1, that a matching call gets the fault. Zero is taken as 1.
+	Probability float64 `json:"probability,omitempty"`
+	// LatencyMs is the delay added by a KindLatency fault.
+	LatencyMs int `json:"latencyMs,omitempty"`
+}
+
+func (r Rule) matches(dependency string, operation string) bool {
+	return (r.Dependency == "" || r.Dependency == dependency) && (r.Operation == "" || r.Operation == operation)
+}
+
+func (r Rule) validate() error {
+	switch {
+	case r.Kind != KindLatency && r.Kind != KindError && r.Kind != KindTimeout:
+		return fmt.Errorf("unknown kind %q", r.Kind)
+	case r.Probability < 0 || r.Probability > 1:
+		return fmt.Errorf("probability %v is not between 0 and 1", r.Probability)
+	case r.Kind == KindLatency && r.LatencyMs <= 0:
+		return errors.New("latency fault without a positive latencyMs")
+	}
+	return nil
+}
+
+// Config is the faults to inject.
+type Config struct {
+	// Rules are applied in order: every matching latency fault delays the call, and the first matching error or
+	// timeout fault fails it.
+	Rules []Rule `json:"rules"`
+	// Seed seeds the draws that decide whether a fault applies, so that a run can be repeated. Zero draws a
+	// random seed.
+	Seed uint64 `json:"seed,omitempty"`
+}
+
+// ConfigFromEnv parses the JSON Config in FAULT_INJECTION. The boolean is false, and no fault should be injected,
+// when the variable is unset.
+func ConfigFromEnv() (Config, bool, error) {
+	var config Config
+	raw := os.Getenv("FAULT_INJECTION")
+	if raw == "" {
+		return config, false, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &config); err != nil {
+		return Config{}, false, fmt.Errorf("invalid FAULT_INJECTION: %w", err)
+	}
+	for i, rule := range config.Rules {
+		if err := rule.validate(); err != nil {
+			return Config{}, false, fmt.Errorf("invalid FAULT_INJECTION rule %d: %w", i, err)
+		}
+	}
+	return config, true, nil
+}
+
+// Injector injects the faults of a Config. A nil *Injector injects none.
+type Injector struct {
+	rules []Rule
+
+	mu     sync.Mutex
+	random *rand.Rand
+}
+
+// NewInjector returns an Injector for config.
+func NewInjector(config Config) *Injector {
+	seed := config.Seed
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	return &Injector{rules: config.Rules, random: rand.New(rand.NewPCG(seed, seed))}
+}
+
+// Inject applies the faults that match a call to operation on dependency, made with ctx. A non-nil error, which
+// wraps ErrInjected, fails the call, which must then not be made.
+func (i *Injector) Inject(ctx context.Context, dependency string, operation string) error {
+	if i == nil {
+		return nil
+	}
+	for _, rule := range i.rules {
+		if !rule.matches(dependency, operation) || !i.draw(rule.Probability) {
+			continue
+		}
+		switch rule.Kind {
+		case KindLatency:
+			select {
+			case <-time.After(time.Duration(rule.LatencyMs) * time.Millisecond):
+			case <-ctx.Done():
+				return fmt.Errorf("%w: %s %s delayed past its deadline: %w", ErrInjected, dependency, operation, ctx.Err())
+			}
+		case KindError:
+			return fmt.Errorf("%w: %s %s failed", ErrInjected, dependency, operation)
+		case KindTimeout:
+			// A call without a deadline would hang for good; it times out at once instead.
+			if _, ok := ctx.Deadline(); ok {
+				<-ctx.Done()
+			}
+			return fmt.Errorf("%w: %s %s hung: %w", ErrInjected, dependency, operation, context.DeadlineExceeded)
+		}
+	}
+	return nil
+}
+
+func (i *Injector) draw(probability float64) bool {
+	if probability == 0 || probability == 1 {
+		return true
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.random.Float64() < probability
+}
+
+// LambdaInvoker is the part of the Lambda client the authorizer invokes other Lambdas with.
+type LambdaInvoker interface {
+	Invoke(ctx context.Context, params *lambda.InvokeInput, optFns ...func(*lambda.Options)) (*lambda.InvokeOutput, error)
+}
+
+// WrapLambda returns delegate with i's faults injected into its invokes, which are operation on the lambda
+// dependency. With a nil Injector, it returns delegate itself.
+func (i *Injector) WrapLambda(delegate LambdaInvoker, operation string) LambdaInvoker {
+	if i == nil {
+		return delegate
+	}
+	return &faultyInvoker{delegate: delegate, injector: i, operation: operation}
+}
+
+type faultyInvoker struct {
+	delegate  LambdaInvoker
+	injector  *Injector
+	operation string
+}
+
+func (f *faultyInvoker) Invoke(ctx context.Context, params *lambda.InvokeInput, optFns ...func(*lambda.Options)) (*lambda.InvokeOutput, error) {
+	if err := f.injector.Inject(ctx, metrics.DependencyLambda, f.operation); err != nil {
+		return nil, err
+	}
+	return f.delegate.Invoke(ctx, params, optFns...)
+}
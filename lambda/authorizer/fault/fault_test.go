@@ -0,0 +1,125 @@
+package fault_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/fault"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	_, enabled, err := fault.ConfigFromEnv()
+	require.NoError(t, err)
+	assert.False(t, enabled)
+
+	t.Setenv("FAULT_INJECTION", `{"seed":7,"rules":[{"dependency":"postgres","operation":"GetDatasetClaim","kind":"timeout","probability":0.5}]}`)
+	config, enabled, err := fault.ConfigFromEnv()
+	require.NoError(t, err)
+	assert.True(t, enabled)
+	assert.Equal(t, fault.Config{
+		Seed:  7,
+		Rules: []fault.Rule{{Dependency: metrics.DependencyPostgres, Operation: "GetDatasetClaim", Kind: fault.KindTimeout, Probability: 0.5}},
+	}, config)
+
+	for value, expectedErr := range map[string]string{
+		`rules`:                        "invalid FAULT_INJECTION",
+		`{"rules":[{"kind":"crash"}]}`: `unknown kind "crash"`,
+		`{"rules":[{"kind":"error","probability":2}]}`: "probability 2 is not between 0 and 1",
+		`{"rules":[{"kind":"latency"}]}`:               "latency fault without a positive latencyMs",
+	} {
+		t.Setenv("FAULT_INJECTION", value)
+		_, enabled, err = fault.ConfigFromEnv()
+		assert.ErrorContains(t, err, expectedErr, value)
+		assert.False(t, enabled, value)
+	}
+}
+
+func TestInject(t *testing.T) {
+	injector := fault.NewInjector(fault.Config{Rules: []fault.Rule{
+		{Dependency: metrics.DependencyPostgres, Operation: "GetDatasetClaim", Kind: fault.KindError},
+		{Dependency: metrics.DependencyDynamoDB, Kind: fault.KindTimeout},
+		{Operation: "GetTeamClaims", Kind: fault.KindLatency, LatencyMs: 20},
+	}})
+
+	err := injector.Inject(context.Background(), metrics.DependencyPostgres, "GetDatasetClaim")
+	assert.ErrorIs(t, err, fault.ErrInjected)
+	assert.NoError(t, injector.Inject(context.Background(), metrics.DependencyPostgres, "GetOrganizationClaim"))
+
+	// A timeout holds the call until its deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	err = injector.Inject(ctx, metrics.DependencyDynamoDB, "GetManifestById")
+	assert.ErrorIs(t, err, fault.ErrInjected)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.GreaterOrEqual(t, time.Since(started), 20*time.Millisecond)
+
+	started = time.Now()
+	assert.NoError(t, injector.Inject(context.Background(), metrics.DependencyPostgres, "GetTeamClaims"))
+	assert.GreaterOrEqual(t, time.Since(started), 20*time.Millisecond)
+
+	// Latency past the deadline fails the call.
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	err = injector.Inject(ctx, metrics.DependencyPostgres, "GetTeamClaims")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	var none *fault.Injector
+	assert.NoError(t, none.Inject(context.Background(), metrics.DependencyPostgres, "GetDatasetClaim"))
+}
+
+func TestInjectProbability(t *testing.T) {
+	config := fault.Config{Seed: 42, Rules: []fault.Rule{{Kind: fault.KindError, Probability: 0.25}}}
+	draw := func() []bool {
+		injector := fault.NewInjector(config)
+		failed := make([]bool, 1000)
+		for i := range failed {
+			failed[i] = injector.Inject(context.Background(), metrics.DependencyPostgres, "GetDatasetClaim") != nil
+		}
+		return failed
+	}
+
+	failed := draw()
+	count := 0
+	for _, f := range failed {
+		if f {
+			count++
+		}
+	}
+	assert.InDelta(t, 250, count, 60)
+	// The same seed injects the same faults.
+	assert.Equal(t, failed, draw())
+}
+
+type invoker struct {
+	calls int
+}
+
+func (i *invoker) Invoke(context.Context, *lambda.InvokeInput, ...func(*lambda.Options)) (*lambda.InvokeOutput, error) {
+	i.calls++
+	return &lambda.InvokeOutput{StatusCode: 200}, nil
+}
+
+func TestWrapLambda(t *testing.T) {
+	delegate := &invoker{}
+	injector := fault.NewInjector(fault.Config{Rules: []fault.Rule{
+		{Dependency: metrics.DependencyLambda, Operation: "CheckAccess", Kind: fault.KindError},
+	}})
+
+	_, err := injector.WrapLambda(delegate, "CheckAccess").Invoke(context.Background(), &lambda.InvokeInput{})
+	assert.ErrorIs(t, err, fault.ErrInjected)
+	assert.Zero(t, delegate.calls)
+
+	output, err := injector.WrapLambda(delegate, "CallbackValidator").Invoke(context.Background(), &lambda.InvokeInput{})
+	require.NoError(t, err)
+	assert.Equal(t, int32(200), output.StatusCode)
+	assert.Equal(t, 1, delegate.calls)
+
+	var none *fault.Injector
+	assert.Same(t, delegate, none.WrapLambda(delegate, "CheckAccess"))
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/authorizers"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/blocklist"
+	claimsClient "github.com/pennsieve/pennsieve-go-api/authorizer/claims"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/deadline"
+	coreAuthorizer "github.com/pennsieve/pennsieve-go-core/pkg/authorizer"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/organization"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/user"
+	log "github.com/sirupsen/logrus"
+)
+
+// blockList denies blocked users, API tokens and organizations. Nil disables the block list.
+var blockList *blocklist.List
+
+// newBlockList returns a DynamoDB-backed List of the entries in BLOCK_LIST_TABLE, cached for
+// BLOCK_LIST_CACHE_TTL, or nil if the block list is not configured.
+func newBlockList() *blocklist.List {
+	if appConfig.BlockListTable == "" {
+		return nil
+	}
+	cfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		log.WithError(err).Error("block list disabled: unable to load AWS config")
+		return nil
+	}
+	return blocklist.NewList(blocklist.NewDynamoStore(dynamodb.NewFromConfig(cfg), appConfig.BlockListTable), appConfig.BlockListTtl)
+}
+
+// checkBlocked returns a DenyError with reason blocked if any of candidates is on the block list. A failing
+// block list store is logged and the request checked against the entries last loaded: entries already known stay
+// blocked, but a DynamoDB problem must not deny every request. The reload is bounded by TIMEOUT_DYNAMODB_MS.
+func checkBlocked(ctx context.Context, logger *log.Entry, candidates ...blocklist.Entry) error {
+	if blockList == nil {
+		return nil
+	}
+	blockCtx, cancel := deadline.Bound(ctx, appConfig.Deadlines.DynamoDB)
+	defer cancel()
+	entry, blocked, err := blockList.Blocked(blockCtx, candidates...)
+	if err != nil {
+		logger.WithError(err).Warn("block list reload failed, checking against the last loaded entries")
+	}
+	if !blocked {
+		return nil
+	}
+	logger.WithFields(log.Fields{
+		"blocked":     entry.String(),
+		"blockReason": entry.Reason,
+	}).Warn("request blocked")
+	return authorizers.NewDenyError(authorizers.ReasonBlocked, fmt.Errorf("%s is blocked", entry))
+}
+
+// tokenEntries returns the block list entries for a Cognito token: the API token for token-pool tokens,
+// otherwise the user-pool username.
+func tokenEntries(token jwt.Token) []blocklist.Entry {
+	username, hasKey := token.Get("username")
+	if !hasKey {
+		return nil
+	}
+	if clientId, _ := token.Get("client_id"); clientId == appConfig.TokenClientId {
+		return []blocklist.Entry{{Kind: blocklist.KindToken, Id: fmt.Sprint(username)}}
+	}
+	return []blocklist.Entry{{Kind: blocklist.KindUsername, Id: fmt.Sprint(username)}}
+}
+
+// claimsEntries returns the block list entries for resolved claims: the user, the super-admin acting as them, if
+// any, and the organization of the request and of each of its resources.
+func claimsEntries(claims map[string]interface{}) []blocklist.Entry {
+	var entries []blocklist.Entry
+	for _, label := range []string{coreAuthorizer.LabelUserClaim, claimsClient.LabelImpersonatorClaim} {
+		if userClaim, ok := claims[label].(*user.Claim); ok && userClaim != nil {
+			entries = append(entries, blocklist.Entry{Kind: blocklist.KindUser, Id: userClaim.NodeId})
+		}
+	}
+	if orgClaim, ok := claims[coreAuthorizer.LabelOrganizationClaim].(*organization.Claim); ok && orgClaim != nil {
+		entries = append(entries, blocklist.Entry{Kind: blocklist.KindOrganization, Id: orgClaim.NodeId})
+	}
+	resourceClaims, _ := claims[claimsClient.LabelResourceClaims].(map[string]map[string]interface{})
+	for _, resource := range resourceClaims {
+		entries = append(entries, claimsEntries(resource)...)
+	}
+	return entries
+}
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/pennsieve/pennsieve-go-api/authorizer/authorizers"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/blocklist"
+	claimsClient "github.com/pennsieve/pennsieve-go-api/authorizer/claims"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/test"
+	coreAuthorizer "github.com/pennsieve/pennsieve-go-core/pkg/authorizer"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/organization"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/user"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func useBlockList(t *testing.T, store blocklist.Store) {
+	original := blockList
+	blockList = blocklist.NewList(store, 0)
+	t.Cleanup(func() { blockList = original })
+}
+
+// failingBlockListStore is a block list store that is unreachable.
+type failingBlockListStore struct{}
+
+func (failingBlockListStore) Load(context.Context) ([]blocklist.Entry, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestCheckBlocked(t *testing.T) {
+	store := blocklist.NewMemoryStore(blocklist.Entry{Kind: blocklist.KindOrganization, Id: "N:organization:1", Reason: "INC-42"})
+	useBlockList(t, store)
+	logger := log.WithField("test", t.Name())
+
+	assert.NoError(t, checkBlocked(context.Background(), logger, blocklist.Entry{Kind: blocklist.KindUser, Id: "N:user:1"}))
+
+	err := checkBlocked(context.Background(), logger,
+		blocklist.Entry{Kind: blocklist.KindUser, Id: "N:user:1"},
+		blocklist.Entry{Kind: blocklist.KindOrganization, Id: "N:organization:1"})
+	assert.Equal(t, authorizers.ReasonBlocked, authorizers.DenyReason(err))
+	assert.False(t, isIndeterminate(err))
+	assert.ErrorContains(t, err, "organization:N:organization:1 is blocked")
+
+	store.Remove(blocklist.KindOrganization, "N:organization:1")
+	assert.NoError(t, checkBlocked(context.Background(), logger, blocklist.Entry{Kind: blocklist.KindOrganization, Id: "N:organization:1"}))
+}
+
+func TestCheckBlockedStoreFailure(t *testing.T) {
+	useBlockList(t, failingBlockListStore{})
+
+	// With nothing loaded yet, an unreachable store blocks nothing.
+	assert.NoError(t, checkBlocked(context.Background(), log.WithField("test", t.Name()), blocklist.Entry{Kind: blocklist.KindUser, Id: "N:user:1"}))
+}
+
+func TestCheckBlockedDisabled(t *testing.T) {
+	original := blockList
+	blockList = nil
+	t.Cleanup(func() { blockList = original })
+
+	assert.NoError(t, checkBlocked(context.Background(), log.WithField("test", t.Name()), blocklist.Entry{Kind: blocklist.KindUser, Id: "N:user:1"}))
+}
+
+func TestTokenEntries(t *testing.T) {
+	userToken := test.NewJWTBuilder().Build(t)
+	apiToken := test.NewJWTBuilder().WithWorkspace(1001, "N:organization:1").Build(t)
+	originalClientID := appConfig.TokenClientId
+	appConfig.TokenClientId = apiToken.ClientId
+	t.Cleanup(func() { appConfig.TokenClientId = originalClientID })
+
+	assert.Equal(t, []blocklist.Entry{{Kind: blocklist.KindUsername, Id: userToken.Username}}, tokenEntries(userToken.Token))
+	assert.Equal(t, []blocklist.Entry{{Kind: blocklist.KindToken, Id: apiToken.Username}}, tokenEntries(apiToken.Token))
+}
+
+func TestClaimsEntries(t *testing.T) {
+	claims := map[string]interface{}{
+		coreAuthorizer.LabelUserClaim:         &user.Claim{Id: 1, NodeId: "N:user:target"},
+		claimsClient.LabelImpersonatorClaim:   &user.Claim{Id: 2, NodeId: "N:user:admin", IsSuperAdmin: true},
+		coreAuthorizer.LabelOrganizationClaim: &organization.Claim{IntId: 1, NodeId: "N:organization:1"},
+		claimsClient.LabelResourceClaims: map[string]map[string]interface{}{
+			"targetDatasetId": {
+				coreAuthorizer.LabelUserClaim:         &user.Claim{Id: 1, NodeId: "N:user:target"},
+				coreAuthorizer.LabelOrganizationClaim: &organization.Claim{IntId: 2, NodeId: "N:organization:2"},
+			},
+		},
+	}
+
+	assert.ElementsMatch(t, []blocklist.Entry{
+		{Kind: blocklist.KindUser, Id: "N:user:target"},
+		{Kind: blocklist.KindUser, Id: "N:user:admin"},
+		{Kind: blocklist.KindOrganization, Id: "N:organization:1"},
+		{Kind: blocklist.KindUser, Id: "N:user:target"},
+		{Kind: blocklist.KindOrganization, Id: "N:organization:2"},
+	}, claimsEntries(claims))
+	assert.Empty(t, claimsEntries(map[string]interface{}{}))
+}
@@ -0,0 +1,184 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/audit"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/authorizers"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/breakglass"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/deadline"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/helpers"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/pipeline"
+	log "github.com/sirupsen/logrus"
+)
+
+// breakGlassHeader is the header an operator sets to their justification for emergency read access to a dataset.
+// API Gateway delivers HTTP API header names lower-cased.
+const breakGlassHeader = "x-pennsieve-break-glass"
+
+// minBreakGlassJustification is the fewest characters a justification may have, so that it explains something.
+const minBreakGlassJustification = 20
+
+// breakGlassStore records when each break-glass grant started. Nil disables break-glass access.
+var breakGlassStore breakglass.Store
+
+// newBreakGlassStore returns a Store on BREAK_GLASS_TABLE, or nil if break-glass access is not configured.
+func newBreakGlassStore() breakglass.Store {
+	if appConfig.BreakGlassGroup == "" || appConfig.BreakGlassTable == "" {
+		return nil
+	}
+	cfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		log.WithError(err).Error("break-glass access disabled: unable to load AWS config")
+		return nil
+	}
+	return breakglass.NewDynamoStore(dynamodb.NewFromConfig(cfg), appConfig.BreakGlassTable)
+}
+
+// breakGlass tracks a single break-glass request so that every outcome gets exactly one audit record.
+type breakGlass struct {
+	justification string
+	datasetNodeId string
+	method        string
+	route         string
+	purposeOfUse  string
+	operator      string
+}
+
+// startBreakGlass returns a breakGlass for the request if the principal may break glass for it.
+func startBreakGlass(ctx context.Context, claimsManager manager.IdentityManager, token jwt.Token, resource pipeline.Resource, justification string, method string, route string) (*breakGlass, error) {
+	request, _ := manager.RequestFromContext(ctx)
+	b := &breakGlass{justification: justification, datasetNodeId: resource.DatasetNodeId, method: method, route: route, purposeOfUse: request.PurposeOfUse}
+
+	// A cached grant would serve the operator's later requests without the header.
+	if !request.Uncached {
+		if operator, err := claimsManager.GetCurrentUser(ctx); err == nil {
+			b.operator = operator.NodeId
+		}
+		b.audit(audit.OutcomeDenied, "route caches authorizer decisions")
+		return nil, authorizers.NewUncacheableDenyError(authorizers.ReasonUncachedAuthorizerRequired, errors.New("break-glass access requires a route whose authorizer decisions are not cached"))
+	}
+
+	if appConfig.BreakGlassGroup == "" || breakGlassStore == nil {
+		return nil, b.deny("break-glass access is not enabled")
+	}
+	if claimsManager.GetAuthentication().Method != manager.AuthenticationUser {
+		return nil, b.deny("break-glass access requires a user token, not an API token")
+	}
+	operator, err := claimsManager.GetCurrentUser(ctx)
+	if err != nil {
+		b.audit(audit.OutcomeDenied, "unable to resolve operator")
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, authorizers.NewDenyError(authorizers.ReasonUnknownUser, fmt.Errorf("no user found for break-glass operator: %w", err))
+		}
+		return nil, authorizers.NewIndeterminateError(fmt.Errorf("unable to get break-glass operator: %w", err))
+	}
+	b.operator = operator.NodeId
+
+	switch {
+	case !inCognitoGroup(token, appConfig.BreakGlassGroup):
+		return nil, b.deny(fmt.Sprintf("user %s is not in the break-glass group", operator.NodeId))
+	case !helpers.IsReadOnlyMethod(method):
+		return nil, b.deny(fmt.Sprintf("%s requests are not permitted with break-glass access", method))
+	case resource.DatasetNodeId == "" || resource.Composite != nil:
+		return nil, b.deny("break-glass access is only for routes on a single dataset")
+	case len(strings.TrimSpace(justification)) < minBreakGlassJustification:
+		return nil, b.deny(fmt.Sprintf("break-glass justification must be at least %d characters", minBreakGlassJustification))
+	}
+	return b, nil
+}
+
+// grant returns the claims for the request, given claims and claimsErr, the result of resolving the operator's own.
+// An operator who already has access keeps their own claims; one who was denied gets a viewer role on the dataset
+// until BREAK_GLASS_DURATION after their first request with the same justification, and is denied after that. An
+// indeterminate failure is returned as it is.
+func (b *breakGlass) grant(ctx context.Context, claimsManager manager.IdentityManager, claims map[string]interface{}, claimsErr error) (map[string]interface{}, error) {
+	if claimsErr == nil {
+		b.audit(audit.OutcomeAllowed, "existing access; break-glass access not used")
+		return claims, nil
+	}
+	if isIndeterminate(claimsErr) {
+		b.audit(audit.OutcomeDenied, claimsErr.Error())
+		return nil, claimsErr
+	}
+
+	expiresAt, err := b.expiry(ctx)
+	if err != nil {
+		b.audit(audit.OutcomeDenied, err.Error())
+		return nil, err
+	}
+	claims, err = authorizers.BreakGlassClaims(ctx, claimsManager, b.datasetNodeId, expiresAt, appConfig.AuthorizerMode)
+	if err != nil {
+		b.audit(audit.OutcomeDenied, err.Error())
+		return nil, err
+	}
+	b.audit(audit.OutcomeAllowed, fmt.Sprintf("break-glass viewer access until %s", expiresAt.Format(time.RFC3339)))
+	return claims, nil
+}
+
+// expiry returns when the grant expires, recording its start on the first request. A grant that has expired is
+// denied: further access needs a new justification, and so a new audit trail.
+func (b *breakGlass) expiry(ctx context.Context) (time.Time, error) {
+	now := time.Now()
+	storeCtx, cancel := deadline.Bound(ctx, appConfig.Deadlines.DynamoDB)
+	defer cancel()
+	grant := breakglass.Grant{OperatorNodeId: b.operator, DatasetNodeId: b.datasetNodeId, Justification: b.justification}
+	started, err := breakGlassStore.Start(storeCtx, grant, now, now.Add(appConfig.BreakGlassDuration+breakglass.Retention))
+	if err != nil {
+		return time.Time{}, authorizers.NewIndeterminateError(fmt.Errorf("unable to record break-glass grant: %w", err))
+	}
+	expiresAt := started.Add(appConfig.BreakGlassDuration).UTC()
+	if !now.Before(expiresAt) {
+		return time.Time{}, authorizers.NewDenyError(authorizers.ReasonBreakGlassNotAllowed,
+			fmt.Errorf("break-glass access for this justification expired at %s", expiresAt.Format(time.RFC3339)))
+	}
+	return expiresAt, nil
+}
+
+// deny audits a denied request and returns the deny.
+func (b *breakGlass) deny(reason string) error {
+	b.audit(audit.OutcomeDenied, reason)
+	return authorizers.NewDenyError(authorizers.ReasonBreakGlassNotAllowed, errors.New(reason))
+}
+
+func (b *breakGlass) audit(outcome audit.Outcome, reason string) {
+	auditLogger.Log(audit.Record{
+		Event:         "break_glass",
+		Outcome:       outcome,
+		Severity:      audit.SeverityHigh,
+		ActorNodeId:   b.operator,
+		Method:        b.method,
+		Route:         b.route,
+		Reason:        reason,
+		Resource:      b.datasetNodeId,
+		Justification: b.justification,
+		PurposeOfUse:  b.purposeOfUse,
+	})
+}
+
+// inCognitoGroup reports whether token's cognito:groups claim includes group.
+func inCognitoGroup(token jwt.Token, group string) bool {
+	groups, ok := token.Get("cognito:groups")
+	if !ok {
+		return false
+	}
+	list, ok := groups.([]interface{})
+	if !ok {
+		return false
+	}
+	for _, member := range list {
+		if member == group {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,331 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pennsieve/pennsieve-go-api/authorizer/audit"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/authorizers"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/breakglass"
+	claimsClient "github.com/pennsieve/pennsieve-go-api/authorizer/claims"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/pipeline"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/test"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/test/mocks"
+	coreAuthorizer "github.com/pennsieve/pennsieve-go-core/pkg/authorizer"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	breakGlassGroup         = "pennsieve-operators"
+	breakGlassJustification = "INC-1234: restoring files deleted in error"
+)
+
+// useBreakGlassGroup enables break-glass access for group, recording grants in the returned MemoryStore.
+func useBreakGlassGroup(t *testing.T, group string) *breakglass.MemoryStore {
+	originalGroup, originalDuration, originalStore := appConfig.BreakGlassGroup, appConfig.BreakGlassDuration, breakGlassStore
+	store := breakglass.NewMemoryStore()
+	appConfig.BreakGlassGroup, appConfig.BreakGlassDuration, breakGlassStore = group, time.Hour, store
+	t.Cleanup(func() {
+		appConfig.BreakGlassGroup, appConfig.BreakGlassDuration, breakGlassStore = originalGroup, originalDuration, originalStore
+	})
+	return store
+}
+
+// failingBreakGlassStore fails to record any grant.
+type failingBreakGlassStore struct{}
+
+func (failingBreakGlassStore) Start(context.Context, breakglass.Grant, time.Time, time.Time) (time.Time, error) {
+	return time.Time{}, errors.New("throttled")
+}
+
+// newOperatorParams returns ClaimsManagerParams for a user token whose Cognito groups are groups.
+func newOperatorParams(t *testing.T, operator *pgdb.User, groups ...interface{}) *mocks.ClaimsManagerParams {
+	managerParams := mocks.NewClaimsManagerParams(t).WithUserQueryMocked(t, operator)
+	require.NoError(t, managerParams.TestJWT.Token.Set("cognito:groups", groups))
+	return managerParams
+}
+
+func TestBreakGlass(t *testing.T) {
+	recorder := useAuditLogger(t)
+	useBreakGlassGroup(t, breakGlassGroup)
+	operator := test.NewUser(101, 1001)
+	managerParams := newOperatorParams(t, operator, "other-group", breakGlassGroup)
+	claimsManager := managerParams.BuildClaimsManager()
+
+	datasetNodeId := "N:dataset:abc"
+	datasetOrgId := int64(6001)
+	managerParams.MockPennsievePg.OnGetOrganizationIdForDataset(datasetNodeId).Return(datasetOrgId, nil)
+	managerParams.MockPennsievePg.OnGetDatasetIdentity(datasetNodeId, datasetOrgId).
+		Return(&manager.DatasetIdentity{OrganizationNodeId: "N:organization:xyz", DatasetId: 999}, nil)
+	managerParams.MockPennsievePg.OnGetDatasetState(datasetNodeId, datasetOrgId).Return(&claimsClient.DatasetState{}, nil)
+	managerParams.MockPennsievePg.OnGetDataUseAgreementStatus(operator.Id, datasetNodeId, datasetOrgId).Return(&manager.DataUseAgreementStatus{}, nil)
+
+	glass, err := startBreakGlass(uncachedContext(), claimsManager, managerParams.TestJWT.Token, pipeline.Resource{DatasetNodeId: datasetNodeId},
+		breakGlassJustification, "GET", "GET /datasets/{id}")
+	require.NoError(t, err)
+
+	claims, err := glass.grant(context.Background(), claimsManager, nil,
+		authorizers.NewDenyError(authorizers.ReasonNoDatasetRole, errors.New("user has no access to dataset")))
+	require.NoError(t, err)
+	assert.Equal(t, true, claims[claimsClient.LabelBreakGlass])
+	require.IsType(t, &claimsClient.GrantExpiry{}, claims[claimsClient.LabelGrantExpiry])
+	assert.WithinDuration(t, time.Now().Add(time.Hour), *claims[claimsClient.LabelGrantExpiry].(*claimsClient.GrantExpiry).Dataset, time.Minute)
+
+	require.Len(t, recorder.Records, 1)
+	record := recorder.Records[0]
+	assert.Equal(t, "break_glass", record.Event)
+	assert.Equal(t, audit.OutcomeAllowed, record.Outcome)
+	assert.Equal(t, audit.SeverityHigh, record.Severity)
+	assert.Equal(t, operator.NodeId, record.ActorNodeId)
+	assert.Equal(t, datasetNodeId, record.Resource)
+	assert.Equal(t, breakGlassJustification, record.Justification)
+	managerParams.AssertMockExpectations(t)
+}
+
+func TestBreakGlassGrantStart(t *testing.T) {
+	datasetNodeId := "N:dataset:abc"
+	datasetOrgId := int64(6001)
+
+	for scenario, params := range map[string]struct {
+		// startedAgo is how long before the request the grant started, if it had.
+		startedAgo     time.Duration
+		expectedExpiry time.Duration
+		expectedDeny   bool
+	}{
+		"first request":     {expectedExpiry: time.Hour},
+		"grant in progress": {startedAgo: 40 * time.Minute, expectedExpiry: 20 * time.Minute},
+		"grant expired":     {startedAgo: 2 * time.Hour, expectedDeny: true},
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			recorder := useAuditLogger(t)
+			store := useBreakGlassGroup(t, breakGlassGroup)
+			operator := test.NewUser(101, 1001)
+			managerParams := newOperatorParams(t, operator, breakGlassGroup)
+			claimsManager := managerParams.BuildClaimsManager()
+			if params.startedAgo > 0 {
+				grant := breakglass.Grant{OperatorNodeId: operator.NodeId, DatasetNodeId: datasetNodeId, Justification: breakGlassJustification}
+				_, err := store.Start(context.Background(), grant, time.Now().Add(-params.startedAgo), time.Now())
+				require.NoError(t, err)
+			}
+			if !params.expectedDeny {
+				managerParams.MockPennsievePg.OnGetOrganizationIdForDataset(datasetNodeId).Return(datasetOrgId, nil)
+				managerParams.MockPennsievePg.OnGetDatasetIdentity(datasetNodeId, datasetOrgId).
+					Return(&manager.DatasetIdentity{OrganizationNodeId: "N:organization:xyz", DatasetId: 999}, nil)
+				managerParams.MockPennsievePg.OnGetDatasetState(datasetNodeId, datasetOrgId).Return(&claimsClient.DatasetState{}, nil)
+				managerParams.MockPennsievePg.OnGetDataUseAgreementStatus(operator.Id, datasetNodeId, datasetOrgId).Return(&manager.DataUseAgreementStatus{}, nil)
+			}
+
+			glass, err := startBreakGlass(uncachedContext(), claimsManager, managerParams.TestJWT.Token, pipeline.Resource{DatasetNodeId: datasetNodeId},
+				breakGlassJustification, "GET", "GET /datasets/{id}")
+			require.NoError(t, err)
+			claims, err := glass.grant(context.Background(), claimsManager, nil,
+				authorizers.NewDenyError(authorizers.ReasonNoDatasetRole, errors.New("user has no access to dataset")))
+
+			require.Len(t, recorder.Records, 1)
+			if params.expectedDeny {
+				assert.Equal(t, authorizers.ReasonBreakGlassNotAllowed, authorizers.DenyReason(err))
+				assert.ErrorContains(t, err, "expired")
+				assert.Equal(t, audit.OutcomeDenied, recorder.Records[0].Outcome)
+			} else {
+				require.NoError(t, err)
+				expiry := claims[claimsClient.LabelGrantExpiry].(*claimsClient.GrantExpiry).Dataset
+				assert.WithinDuration(t, time.Now().Add(params.expectedExpiry), *expiry, time.Minute)
+				assert.Equal(t, audit.OutcomeAllowed, recorder.Records[0].Outcome)
+			}
+			managerParams.AssertMockExpectations(t)
+		})
+	}
+}
+
+func TestBreakGlassStoreFailure(t *testing.T) {
+	recorder := useAuditLogger(t)
+	useBreakGlassGroup(t, breakGlassGroup)
+	breakGlassStore = failingBreakGlassStore{}
+	managerParams := newOperatorParams(t, test.NewUser(101, 1001), breakGlassGroup)
+	claimsManager := managerParams.BuildClaimsManager()
+
+	glass, err := startBreakGlass(uncachedContext(), claimsManager, managerParams.TestJWT.Token, pipeline.Resource{DatasetNodeId: "N:dataset:abc"},
+		breakGlassJustification, "GET", "GET /datasets/{id}")
+	require.NoError(t, err)
+	_, err = glass.grant(context.Background(), claimsManager, nil,
+		authorizers.NewDenyError(authorizers.ReasonNoDatasetRole, errors.New("user has no access to dataset")))
+
+	assert.True(t, isIndeterminate(err))
+	require.Len(t, recorder.Records, 1)
+	assert.Equal(t, audit.OutcomeDenied, recorder.Records[0].Outcome)
+	managerParams.MockPennsievePg.AssertNotCalled(t, "GetDatasetIdentity")
+	managerParams.AssertMockExpectations(t)
+}
+
+func TestBreakGlassWithoutStore(t *testing.T) {
+	recorder := useAuditLogger(t)
+	useBreakGlassGroup(t, breakGlassGroup)
+	breakGlassStore = nil
+	managerParams := newOperatorParams(t, test.NewUser(101, 1001), breakGlassGroup)
+
+	_, err := startBreakGlass(uncachedContext(), managerParams.BuildClaimsManager(), managerParams.TestJWT.Token, pipeline.Resource{DatasetNodeId: "N:dataset:abc"},
+		breakGlassJustification, "GET", "GET /datasets/{id}")
+	assert.ErrorContains(t, err, "not enabled")
+	assert.Equal(t, authorizers.ReasonBreakGlassNotAllowed, authorizers.DenyReason(err))
+	require.Len(t, recorder.Records, 1)
+}
+
+func TestBreakGlassExistingAccess(t *testing.T) {
+	recorder := useAuditLogger(t)
+	useBreakGlassGroup(t, breakGlassGroup)
+	managerParams := newOperatorParams(t, test.NewUser(101, 1001), breakGlassGroup)
+	claimsManager := managerParams.BuildClaimsManager()
+
+	glass, err := startBreakGlass(uncachedContext(), claimsManager, managerParams.TestJWT.Token, pipeline.Resource{DatasetNodeId: "N:dataset:abc"},
+		breakGlassJustification, "GET", "GET /datasets/{id}")
+	require.NoError(t, err)
+
+	ownClaims := map[string]interface{}{coreAuthorizer.LabelUserClaim: "own claims"}
+	claims, err := glass.grant(context.Background(), claimsManager, ownClaims, nil)
+	require.NoError(t, err)
+	assert.Equal(t, ownClaims, claims)
+
+	require.Len(t, recorder.Records, 1)
+	assert.Equal(t, audit.OutcomeAllowed, recorder.Records[0].Outcome)
+	assert.Contains(t, recorder.Records[0].Reason, "existing access")
+	managerParams.AssertMockExpectations(t)
+}
+
+func TestBreakGlassIndeterminate(t *testing.T) {
+	recorder := useAuditLogger(t)
+	useBreakGlassGroup(t, breakGlassGroup)
+	managerParams := newOperatorParams(t, test.NewUser(101, 1001), breakGlassGroup)
+	claimsManager := managerParams.BuildClaimsManager()
+
+	glass, err := startBreakGlass(uncachedContext(), claimsManager, managerParams.TestJWT.Token, pipeline.Resource{DatasetNodeId: "N:dataset:abc"},
+		breakGlassJustification, "GET", "GET /datasets/{id}")
+	require.NoError(t, err)
+
+	_, err = glass.grant(context.Background(), claimsManager, nil, authorizers.NewIndeterminateError(errors.New("connection refused")))
+	assert.True(t, isIndeterminate(err))
+
+	require.Len(t, recorder.Records, 1)
+	assert.Equal(t, audit.OutcomeDenied, recorder.Records[0].Outcome)
+	managerParams.MockPennsievePg.AssertNotCalled(t, "GetDatasetIdentity")
+	managerParams.AssertMockExpectations(t)
+}
+
+func TestBreakGlassDenied(t *testing.T) {
+	datasetResource := pipeline.Resource{DatasetNodeId: "N:dataset:abc"}
+
+	for scenario, params := range map[string]struct {
+		group         string
+		groups        []interface{}
+		resource      pipeline.Resource
+		justification string
+		method        string
+		expectedError string
+	}{
+		"not enabled":         {group: "", groups: []interface{}{breakGlassGroup}, resource: datasetResource, justification: breakGlassJustification, method: "GET", expectedError: "not enabled"},
+		"not in group":        {group: breakGlassGroup, groups: []interface{}{"other-group"}, resource: datasetResource, justification: breakGlassJustification, method: "GET", expectedError: "not in the break-glass group"},
+		"no groups":           {group: breakGlassGroup, groups: nil, resource: datasetResource, justification: breakGlassJustification, method: "GET", expectedError: "not in the break-glass group"},
+		"write route":         {group: breakGlassGroup, groups: []interface{}{breakGlassGroup}, resource: datasetResource, justification: breakGlassJustification, method: "POST", expectedError: "POST requests are not permitted"},
+		"not a dataset":       {group: breakGlassGroup, groups: []interface{}{breakGlassGroup}, resource: pipeline.Resource{OrganizationNodeId: "N:organization:xyz"}, justification: breakGlassJustification, method: "GET", expectedError: "single dataset"},
+		"short justification": {group: breakGlassGroup, groups: []interface{}{breakGlassGroup}, resource: datasetResource, justification: "  because  ", method: "GET", expectedError: fmt.Sprintf("at least %d characters", minBreakGlassJustification)},
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			recorder := useAuditLogger(t)
+			useBreakGlassGroup(t, params.group)
+			managerParams := mocks.NewClaimsManagerParams(t)
+			if params.group != "" {
+				managerParams.WithUserQueryMocked(t, test.NewUser(101, 1001))
+			}
+			if params.groups != nil {
+				require.NoError(t, managerParams.TestJWT.Token.Set("cognito:groups", params.groups))
+			}
+
+			_, err := startBreakGlass(uncachedContext(), managerParams.BuildClaimsManager(), managerParams.TestJWT.Token, params.resource,
+				params.justification, params.method, fmt.Sprintf("%s /route", params.method))
+			assert.ErrorContains(t, err, params.expectedError)
+			assert.Equal(t, authorizers.ReasonBreakGlassNotAllowed, authorizers.DenyReason(err))
+			require.Len(t, recorder.Records, 1)
+			assert.Equal(t, audit.OutcomeDenied, recorder.Records[0].Outcome)
+			assert.Equal(t, audit.SeverityHigh, recorder.Records[0].Severity)
+			managerParams.AssertMockExpectations(t)
+		})
+	}
+}
+
+func TestBreakGlassCachedRoute(t *testing.T) {
+	for scenario, params := range map[string]struct {
+		group  string
+		groups []interface{}
+		method string
+	}{
+		"operator":      {group: breakGlassGroup, groups: []interface{}{breakGlassGroup}, method: "GET"},
+		"not enabled":   {group: "", groups: []interface{}{breakGlassGroup}, method: "GET"},
+		"not in group":  {group: breakGlassGroup, groups: []interface{}{"other-group"}, method: "GET"},
+		"write request": {group: breakGlassGroup, groups: []interface{}{breakGlassGroup}, method: "POST"},
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			recorder := useAuditLogger(t)
+			useBreakGlassGroup(t, params.group)
+			operator := test.NewUser(101, 1001)
+			managerParams := newOperatorParams(t, operator, params.groups...)
+			ctx := manager.WithRequest(context.Background(), manager.Request{Method: params.method})
+
+			_, err := startBreakGlass(ctx, managerParams.BuildClaimsManager(), managerParams.TestJWT.Token, pipeline.Resource{DatasetNodeId: "N:dataset:abc"},
+				breakGlassJustification, params.method, params.method+" /route")
+			assert.Equal(t, authorizers.ReasonUncachedAuthorizerRequired, authorizers.DenyReason(err))
+			assert.True(t, authorizers.Uncacheable(err))
+			require.Len(t, recorder.Records, 1)
+			assert.Equal(t, audit.OutcomeDenied, recorder.Records[0].Outcome)
+			assert.Equal(t, operator.NodeId, recorder.Records[0].ActorNodeId)
+			managerParams.AssertMockExpectations(t)
+
+			// A cached deny would also turn away the operator's later requests without the header.
+			response, err := deniedResponse(err)
+			assert.Error(t, err)
+			assert.False(t, response.IsAuthorized)
+		})
+	}
+}
+
+func TestBreakGlassApiToken(t *testing.T) {
+	recorder := useAuditLogger(t)
+	useBreakGlassGroup(t, breakGlassGroup)
+	managerParams := mocks.NewClaimsManagerParams(t).WithTokenWorkspace(t, manager.TokenWorkspace{Id: 1001, NodeId: "N:organization:xyz"})
+
+	_, err := startBreakGlass(uncachedContext(), managerParams.BuildClaimsManager(), managerParams.TestJWT.Token, pipeline.Resource{DatasetNodeId: "N:dataset:abc"},
+		breakGlassJustification, "GET", "GET /datasets/{id}")
+	assert.ErrorContains(t, err, "requires a user token")
+	require.Len(t, recorder.Records, 1)
+	managerParams.AssertMockExpectations(t)
+}
+
+func TestBreakGlassOperatorLookup(t *testing.T) {
+	for scenario, params := range map[string]struct {
+		lookupErr             error
+		expectedIndeterminate bool
+	}{
+		"operator not found":     {sql.ErrNoRows, false},
+		"operator lookup failed": {errors.New("connection refused"), true},
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			recorder := useAuditLogger(t)
+			useBreakGlassGroup(t, breakGlassGroup)
+			managerParams := mocks.NewClaimsManagerParams(t)
+			managerParams.MockPennsievePg.OnGetByCognitoId(managerParams.TestJWT.Username).Return((*pgdb.User)(nil), params.lookupErr)
+
+			_, err := startBreakGlass(uncachedContext(), managerParams.BuildClaimsManager(), managerParams.TestJWT.Token, pipeline.Resource{DatasetNodeId: "N:dataset:abc"},
+				breakGlassJustification, "GET", "GET /datasets/{id}")
+			require.Error(t, err)
+			assert.Equal(t, params.expectedIndeterminate, isIndeterminate(err))
+			require.Len(t, recorder.Records, 1)
+			assert.Equal(t, audit.OutcomeDenied, recorder.Records[0].Outcome)
+			managerParams.AssertMockExpectations(t)
+		})
+	}
+}
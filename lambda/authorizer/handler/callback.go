@@ -4,19 +4,25 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os"
-	"strings"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/authorizers"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/deadline"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/fault"
 	"github.com/pennsieve/pennsieve-go-api/authorizer/helpers"
-	coreAuthorizer "github.com/pennsieve/pennsieve-go-core/pkg/authorizer"
-	"github.com/pennsieve/pennsieve-go-core/pkg/models/role"
-	"github.com/pennsieve/pennsieve-go-core/pkg/models/user"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/metrics"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/pipeline"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/ratelimit"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/tracing"
 	"github.com/pennsieve/pennsieve-go-core/pkg/queries/pgdb"
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // CallbackValidateRequest is the payload sent to the service's validator Lambda.
@@ -35,27 +41,34 @@ type CallbackValidateResponse struct {
 	Error              string `json:"error,omitempty"`
 }
 
-// getValidatorArn looks up the Lambda ARN for the given service name from environment variables.
+// callbackScope is the authorizer type and rate limit scope of callback-token requests.
+const callbackScope = "callback"
+
+// getValidatorArn looks up the Lambda ARN for the given service name in the configured callback validators.
 // Environment variable format: CALLBACK_VALIDATOR_<SERVICE_NAME_UPPERCASED_WITH_UNDERSCORES>
 // e.g., CALLBACK_VALIDATOR_WORKFLOW_SERVICE for "workflow-service"
 func getValidatorArn(serviceName string) (string, error) {
-	envKey := "CALLBACK_VALIDATOR_" + strings.ToUpper(strings.ReplaceAll(serviceName, "-", "_"))
-	arn := os.Getenv(envKey)
-	if arn == "" {
-		return "", fmt.Errorf("no callback validator configured for service: %s", serviceName)
-	}
-	return arn, nil
+	return appConfig.ValidatorArn(serviceName)
 }
 
 // handleCallbackAuth handles requests with Callback authorization.
 // It parses the header, invokes the appropriate service's validator Lambda to verify the token
-// and get node IDs, then resolves those node IDs to full claims via Postgres.
+// and get node IDs, then resolves those node IDs to claims through the claims pipeline, which also
+// checks that the dataset belongs to the organization the validator returned.
 func handleCallbackAuth(ctx context.Context, event events.APIGatewayV2CustomAuthorizerV2Request) (events.APIGatewayV2CustomAuthorizerSimpleResponse, error) {
 	logger := log.WithFields(log.Fields{"authType": "callback"})
+	if request, ok := manager.RequestFromContext(ctx); ok {
+		request.Callback = true
+		ctx = manager.WithRequest(ctx, request)
+		if request.PurposeOfUse != "" {
+			logger = logger.WithField("purposeOfUse", request.PurposeOfUse)
+		}
+	}
 
 	callbackAuth, err := helpers.ParseCallbackAuth(event.Headers["authorization"])
 	if err != nil {
 		logger.Error(err)
+		recordDeny(callbackScope, reasonInvalidToken)
 		return events.APIGatewayV2CustomAuthorizerSimpleResponse{
 			IsAuthorized: false,
 		}, nil
@@ -69,81 +82,91 @@ func handleCallbackAuth(ctx context.Context, event events.APIGatewayV2CustomAuth
 	// Invoke the service's validator Lambda
 	validateResp, err := invokeValidator(ctx, logger, callbackAuth)
 	if err != nil {
+		// The validator could not be asked, or did not answer: no decision, so a 500 rather than a deny.
+		recordIndeterminate(callbackScope)
 		return events.APIGatewayV2CustomAuthorizerSimpleResponse{
 			IsAuthorized: false,
-		}, nil
+		}, err
 	}
 
 	if !validateResp.IsAuthorized {
 		logger.WithField("error", validateResp.Error).Warn("callback token validation failed")
+		recordDeny(callbackScope, reasonInvalidToken)
 		return events.APIGatewayV2CustomAuthorizerSimpleResponse{
 			IsAuthorized: false,
 		}, nil
 	}
 
-	// Resolve node IDs to full claims via Postgres (same pattern as DirectHandler)
-	db, err := pgdb.ConnectRDS()
-	if err != nil {
-		logger.WithError(err).Error("unable to connect to RDS instance")
-		return events.APIGatewayV2CustomAuthorizerSimpleResponse{
-			IsAuthorized: false,
-		}, err
-	}
-	defer db.Close()
-	postgresDB := pgdb.New(db)
-
-	currentUser, err := getUserByNodeId(ctx, db, validateResp.UserNodeID)
-	if err != nil {
-		logger.WithError(err).Error("unable to get user by node ID")
+	principal := ratelimit.Principal{Kind: ratelimit.KindCallback, Id: callbackAuth.Service}
+	if err := checkRateLimit(ctx, logger, principal, validateResp.OrganizationNodeID, callbackScope); err != nil {
+		logger.WithError(err).WithField("reason", authorizers.DenyReason(err)).Error("callback request throttled")
+		recordDecision(callbackScope, err)
 		return events.APIGatewayV2CustomAuthorizerSimpleResponse{
 			IsAuthorized: false,
 		}, nil
 	}
 
-	claims := map[string]interface{}{
-		coreAuthorizer.LabelUserClaim: &user.Claim{
-			Id:           currentUser.Id,
-			NodeId:       currentUser.NodeId,
-			IsSuperAdmin: currentUser.IsSuperAdmin,
-		},
+	// Resolve the validated node IDs to claims through the same pipeline as every other entry point
+	resource := pipeline.Resource{DatasetNodeId: validateResp.DatasetNodeID, OrganizationNodeId: validateResp.OrganizationNodeID}
+	claims, err := resolveCallbackClaims(ctx, validateResp.UserNodeID, resource)
+	claims, err = withLastKnownGood(ctx, logger, callbackScope, fmt.Sprintf("callback:%s:%s", callbackAuth.Service, validateResp.UserNodeID), resource, claims, err)
+	if err == nil {
+		err = checkBlocked(ctx, logger, claimsEntries(claims)...)
 	}
-
-	orgClaim, err := postgresDB.GetOrganizationClaimByNodeId(ctx, currentUser.Id, validateResp.OrganizationNodeID)
 	if err != nil {
-		logger.WithError(err).Error("unable to get organization claim")
+		withRemainingBudget(ctx, logger).WithError(err).WithField("reason", authorizers.DenyReason(err)).Error("unable to resolve callback claims")
+		recordDecision(callbackScope, err)
+		if isIndeterminate(err) {
+			return events.APIGatewayV2CustomAuthorizerSimpleResponse{
+				IsAuthorized: false,
+			}, err
+		}
 		return events.APIGatewayV2CustomAuthorizerSimpleResponse{
 			IsAuthorized: false,
 		}, nil
 	}
-	claims[coreAuthorizer.LabelOrganizationClaim] = orgClaim
-
-	datasetClaim, err := postgresDB.GetDatasetClaim(ctx, currentUser, validateResp.DatasetNodeID, orgClaim.IntId)
-	if err != nil {
-		logger.WithError(err).Error("unable to get dataset claim")
-		return events.APIGatewayV2CustomAuthorizerSimpleResponse{
-			IsAuthorized: false,
-		}, nil
-	}
-	if datasetClaim.Role == role.None {
-		logger.Warn("user has no access to dataset")
-		return events.APIGatewayV2CustomAuthorizerSimpleResponse{
-			IsAuthorized: false,
-		}, nil
-	}
-	claims[coreAuthorizer.LabelDatasetClaim] = datasetClaim
 
 	logger.Info("callback token authorization successful")
+	recordDecision(callbackScope, nil)
+	addTraceId(ctx, claims)
+	addPurposeOfUse(ctx, claims)
 	return events.APIGatewayV2CustomAuthorizerSimpleResponse{
 		IsAuthorized: true,
 		Context:      claims,
 	}, nil
 }
 
+// resolveCallbackClaims resolves the claims of the user with node id userNodeId for resource.
+func resolveCallbackClaims(ctx context.Context, userNodeId string, resource pipeline.Resource) (map[string]interface{}, error) {
+	db, err := pgdb.ConnectRDS()
+	if err != nil {
+		return nil, authorizers.NewIndeterminateError(fmt.Errorf("unable to connect to RDS instance: %w", err))
+	}
+	defer db.Close()
+	sources := pipeline.Sources{
+		PostgresDB:    newPostgresDB(db, appConfig.ResultTtl),
+		TokenClientId: appConfig.TokenClientId,
+		ManifestTable: appConfig.ManifestTable,
+	}
+	return pipeline.Resolve(ctx, sources.NodeIdPrincipal(userNodeId), resource, appConfig.AuthorizerMode)
+}
+
+// validatorInvoker invokes the callback validators. Nil, as it is outside tests, invokes them with a client built
+// from the default AWS config.
+var validatorInvoker fault.LambdaInvoker
+
+// invokeValidator invokes the service's validator Lambda, bounded by TIMEOUT_CALLBACK_VALIDATOR_MS, and returns its
+// response, which says whether it accepted the token. Every failure to get that answer — no valid validator ARN for
+// the service, no AWS config, a failed or timed-out invoke, an error raised by the validator or a response that
+// cannot be read — is an *authorizers.IndeterminateError, so that only the validator's rejection denies the token.
 func invokeValidator(ctx context.Context, logger *log.Entry, callbackAuth *helpers.CallbackAuth) (*CallbackValidateResponse, error) {
 	validatorArn, err := getValidatorArn(callbackAuth.Service)
+	if err == nil {
+		_, err = arn.Parse(validatorArn)
+	}
 	if err != nil {
 		logger.Error(err)
-		return nil, err
+		return nil, authorizers.NewIndeterminateError(fmt.Errorf("no valid callback validator for %s: %w", callbackAuth.Service, err))
 	}
 
 	payload, err := json.Marshal(CallbackValidateRequest{
@@ -152,30 +175,48 @@ func invokeValidator(ctx context.Context, logger *log.Entry, callbackAuth *helpe
 	})
 	if err != nil {
 		logger.WithError(err).Error("failed to marshal validate request")
-		return nil, err
+		return nil, authorizers.NewIndeterminateError(err)
 	}
 
-	cfg, err := awsconfig.LoadDefaultConfig(ctx)
-	if err != nil {
-		logger.WithError(err).Error("unable to load AWS config")
-		return nil, err
+	client := validatorInvoker
+	if client == nil {
+		cfg, err := awsconfig.LoadDefaultConfig(ctx)
+		if err != nil {
+			logger.WithError(err).Error("unable to load AWS config")
+			return nil, authorizers.NewIndeterminateError(fmt.Errorf("unable to load AWS config: %w", err))
+		}
+		client = lambda.NewFromConfig(cfg)
 	}
 
-	lambdaClient := lambda.NewFromConfig(cfg)
-	result, err := lambdaClient.Invoke(ctx, &lambda.InvokeInput{
+	lambdaClient := faults.WrapLambda(client, "CallbackValidator")
+	stopTracking := metrics.Track(metricsRecorder, metrics.DependencyLambda, "CallbackValidator")
+	invokeCtx, cancel := deadline.Bound(ctx, appConfig.Deadlines.CallbackValidator)
+	defer cancel()
+	invokeCtx, span := tracing.Tracer().Start(invokeCtx, "InvokeCallbackValidator", trace.WithAttributes(attribute.String("pennsieve.callback_service", callbackAuth.Service)))
+	result, err := lambdaClient.Invoke(invokeCtx, &lambda.InvokeInput{
 		FunctionName: aws.String(validatorArn),
 		Payload:      payload,
 	})
-	if err != nil {
-		logger.WithError(err).Error("failed to invoke validator Lambda")
+	tracing.End(span, err)
+	stopTracking()
+	if err = indeterminateOnTimeout(invokeCtx, "CallbackValidator", err); err != nil {
+		withRemainingBudget(ctx, logger).WithError(err).Error("failed to invoke validator Lambda")
+		if !isIndeterminate(err) {
+			err = authorizers.NewIndeterminateError(fmt.Errorf("unable to invoke callback validator: %w", err))
+		}
 		return nil, err
 	}
+	if result.FunctionError != nil {
+		err := fmt.Errorf("callback validator function error: %s — payload=%s", aws.ToString(result.FunctionError), string(result.Payload))
+		logger.Error(err)
+		return nil, authorizers.NewIndeterminateError(err)
+	}
 
 	var validateResp CallbackValidateResponse
 	if err := json.Unmarshal(result.Payload, &validateResp); err != nil {
 		logger.WithError(err).Error("failed to unmarshal validator response")
-		return nil, err
+		return nil, authorizers.NewIndeterminateError(fmt.Errorf("unable to read callback validator response: %w", err))
 	}
 
 	return &validateResp, nil
-}
\ No newline at end of file
+}
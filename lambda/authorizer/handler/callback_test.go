@@ -1,13 +1,23 @@
 package handler
 
 import (
+	"context"
+	"errors"
 	"testing"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/config"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/helpers"
+	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGetValidatorArn(t *testing.T) {
-	t.Setenv("CALLBACK_VALIDATOR_WORKFLOW_SERVICE", "arn:aws:lambda:us-east-1:123:function:workflow-validator")
+	useConfig(t, &config.Config{CallbackValidators: map[string]string{
+		"WORKFLOW_SERVICE": "arn:aws:lambda:us-east-1:123:function:workflow-validator",
+	}})
 
 	arn, err := getValidatorArn("workflow-service")
 	assert.NoError(t, err)
@@ -18,4 +28,51 @@ func TestGetValidatorArnUnknownService(t *testing.T) {
 	_, err := getValidatorArn("unknown-service")
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "no callback validator configured for service: unknown-service")
-}
\ No newline at end of file
+}
+
+// validator answers invokes with its output and error.
+type validator struct {
+	output *lambda.InvokeOutput
+	err    error
+}
+
+func (v *validator) Invoke(context.Context, *lambda.InvokeInput, ...func(*lambda.Options)) (*lambda.InvokeOutput, error) {
+	return v.output, v.err
+}
+
+func TestInvokeValidator(t *testing.T) {
+	validatorArn := "arn:aws:lambda:us-east-1:123:function:workflow-validator"
+	for scenario, params := range map[string]struct {
+		arn                   string
+		output                *lambda.InvokeOutput
+		invokeErr             error
+		expectedAuthorized    bool
+		expectedIndeterminate bool
+	}{
+		"accepted":       {validatorArn, &lambda.InvokeOutput{Payload: []byte(`{"isAuthorized":true,"userNodeId":"N:user:1"}`)}, nil, true, false},
+		"rejected":       {validatorArn, &lambda.InvokeOutput{Payload: []byte(`{"isAuthorized":false,"error":"expired"}`)}, nil, false, false},
+		"no validator":   {"", nil, nil, false, true},
+		"invalid arn":    {"workflow-validator", nil, nil, false, true},
+		"invoke failed":  {validatorArn, nil, errors.New("AccessDeniedException"), false, true},
+		"function error": {validatorArn, &lambda.InvokeOutput{FunctionError: aws.String("Unhandled"), Payload: []byte(`{"errorMessage":"boom"}`)}, nil, false, true},
+		"bad payload":    {validatorArn, &lambda.InvokeOutput{Payload: []byte(`not json`)}, nil, false, true},
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			c := validConfig()
+			c.CallbackValidators = map[string]string{"WORKFLOW_SERVICE": params.arn}
+			useConfig(t, c)
+			original := validatorInvoker
+			validatorInvoker = &validator{output: params.output, err: params.invokeErr}
+			t.Cleanup(func() { validatorInvoker = original })
+
+			callbackAuth := &helpers.CallbackAuth{Service: "workflow-service", Token: "t", ExecutionRunID: "run"}
+			resp, err := invokeValidator(context.Background(), log.WithField("test", t.Name()), callbackAuth)
+			if params.expectedIndeterminate {
+				assert.True(t, isIndeterminate(err), "expected indeterminate, got %v", err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, params.expectedAuthorized, resp.IsAuthorized)
+		})
+	}
+}
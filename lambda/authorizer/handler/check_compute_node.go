@@ -25,11 +25,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/lambda"
 	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/deadline"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/metrics"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/tracing"
 )
 
 // checkUserNodeAccessRequest mirrors account-service's
@@ -56,14 +58,15 @@ type checkUserNodeAccessResponse struct {
 // verify that `userNodeID` has access to compute node `nodeUUID` within
 // `orgNodeID`. Returns the access type ("owner", "shared", "workspace",
 // "team") on success, empty string on a clean denial, and an error on any
-// transport / configuration / unmarshaling failure.
+// transport / configuration / unmarshaling failure. The invoke is bounded by
+// TIMEOUT_CHECK_ACCESS_MS; a timeout is an *authorizers.IndeterminateError.
 //
 // Fails closed: any non-nil error from this function MUST cause the caller
 // to refuse the connection. We never want a misconfigured
 // CHECK_ACCESS_LAMBDA_NAME or a transient Lambda failure to silently let
 // unauthorized users through.
 func checkComputeNodeAccess(ctx context.Context, cfg aws.Config, userNodeID, nodeUUID, orgNodeID string) (string, error) {
-	name := os.Getenv("CHECK_ACCESS_LAMBDA_NAME")
+	name := appConfig.CheckAccessLambdaName
 	if name == "" {
 		return "", errors.New("CHECK_ACCESS_LAMBDA_NAME env var not set")
 	}
@@ -80,13 +83,19 @@ func checkComputeNodeAccess(ctx context.Context, cfg aws.Config, userNodeID, nod
 		return "", fmt.Errorf("marshaling check-access request: %w", err)
 	}
 
-	client := lambda.NewFromConfig(cfg)
-	out, err := client.Invoke(ctx, &lambda.InvokeInput{
+	client := faults.WrapLambda(lambda.NewFromConfig(cfg), "CheckAccess")
+	stopTracking := metrics.Track(metricsRecorder, metrics.DependencyLambda, "CheckAccess")
+	invokeCtx, cancel := deadline.Bound(ctx, appConfig.Deadlines.CheckAccess)
+	defer cancel()
+	invokeCtx, span := tracing.Tracer().Start(invokeCtx, "InvokeCheckAccess")
+	out, err := client.Invoke(invokeCtx, &lambda.InvokeInput{
 		FunctionName:   aws.String(name),
 		InvocationType: lambdatypes.InvocationTypeRequestResponse,
 		Payload:        payload,
 	})
-	if err != nil {
+	tracing.End(span, err)
+	stopTracking()
+	if err := indeterminateOnTimeout(invokeCtx, "CheckAccess", err); err != nil {
 		return "", fmt.Errorf("invoking check-access: %w", err)
 	}
 	if out.FunctionError != nil {
@@ -0,0 +1,22 @@
+package handler
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
+)
+
+// claimsCache keeps user, dataset organization and claim lookups across the invocations a warm container handles.
+// Nil, when CLAIMS_CACHE_ENABLED=false, disables it.
+var claimsCache *manager.ClaimsCache
+
+// newPostgresDB returns the PennsievePgAPI for one invocation: queries on db, with any injected faults, timed and
+// bounded by TIMEOUT_POSTGRES_MS, behind the claims cache.
+// resultTtl is how long the invocation's result may be cached by API Gateway; grants that expire within it, or
+// within the time a cached claim may be stale, no longer count.
+func newPostgresDB(db *sql.DB, resultTtl time.Duration) manager.PennsievePgAPI {
+	queries := manager.NewQueries(db, resultTtl+claimsCache.Staleness()).WithOptionalAuthPolicies(appConfig.AuthPoliciesOptional).
+		WithOptionalIpAllowlists(appConfig.IpAllowlistsOptional)
+	return claimsCache.Wrap(manager.NewTimedPgAPI(manager.NewFaultyPgAPI(queries, faults), metricsRecorder, appConfig.Deadlines.Postgres))
+}
@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/authorizers"
+	claimsClient "github.com/pennsieve/pennsieve-go-api/authorizer/claims"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/test"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/test/mocks"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/tracing"
+	coreAuthorizer "github.com/pennsieve/pennsieve-go-core/pkg/authorizer"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dataset"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/organization"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/role"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/teamUser"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// roundTripFixture holds the claims a DatasetAuthorizer generated, plus the individual claims
+// the client package is expected to recover from either event shape.
+type roundTripFixture struct {
+	generated    map[string]interface{}
+	userClaim    *user.Claim
+	orgClaim     *organization.Claim
+	datasetClaim *dataset.Claim
+	teamClaims   []teamUser.Claim
+}
+
+func newRoundTripFixture(t *testing.T) roundTripFixture {
+	managerParams := mocks.NewClaimsManagerParams(t)
+	currentUser := test.NewUser(101, 1001)
+	claimsManager := managerParams.WithUserQueryMocked(t, currentUser).BuildClaimsManager()
+
+	orgId := int64(1001)
+	datasetNodeId := fmt.Sprintf("N:dataset:%s", uuid.NewString())
+	orgClaim := &organization.Claim{
+		Role:   pgdb.Delete,
+		IntId:  orgId,
+		NodeId: fmt.Sprintf("N:organization:%s", uuid.NewString()),
+	}
+	datasetClaim := &dataset.Claim{Role: role.Manager, NodeId: datasetNodeId, IntId: 999}
+	teamClaims := []teamUser.Claim{
+		{IntId: 10, Name: "publishers", NodeId: fmt.Sprintf("N:team:%s", uuid.NewString()), Permission: pgdb.Administer, TeamType: "publishers"},
+	}
+	managerParams.MockPennsievePg.OnGetOrganizationIdForDataset(datasetNodeId).Return(orgId, nil)
+	managerParams.MockPennsievePg.OnGetOrganizationClaim(currentUser.Id, orgId).Return(orgClaim, nil)
+	managerParams.MockPennsievePg.OnGetDatasetClaim(currentUser, datasetNodeId, orgId).Return(datasetClaim, nil)
+	managerParams.MockPennsievePg.OnGetDatasetState(datasetNodeId, orgId).Return(&claimsClient.DatasetState{}, nil)
+	managerParams.MockPennsievePg.OnGetDataUseAgreementStatus(currentUser.Id, datasetNodeId, orgId).Return(&manager.DataUseAgreementStatus{}, nil)
+	managerParams.MockPennsievePg.OnGetTeamClaimsForOrg(currentUser.Id, orgId).Return(teamClaims, nil)
+
+	generated, err := authorizers.NewDatasetAuthorizer(datasetNodeId).GenerateClaims(context.Background(), claimsManager, "LEGACY")
+	require.NoError(t, err)
+	managerParams.AssertMockExpectations(t)
+
+	return roundTripFixture{
+		generated: generated,
+		userClaim: &user.Claim{
+			Id:           currentUser.Id,
+			NodeId:       currentUser.NodeId,
+			IsSuperAdmin: currentUser.IsSuperAdmin,
+		},
+		orgClaim:     orgClaim,
+		datasetClaim: datasetClaim,
+		teamClaims:   teamClaims,
+	}
+}
+
+// viaAPIGateway serializes v as API Gateway does when passing authorizer context to the
+// integration, and decodes it the way the downstream Lambda runtime would.
+func viaAPIGateway(t *testing.T, v interface{}) map[string]interface{} {
+	encoded, err := json.Marshal(v)
+	require.NoError(t, err)
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(encoded, &decoded))
+	return decoded
+}
+
+func TestClaimsRoundTripHTTP(t *testing.T) {
+	fixture := newRoundTripFixture(t)
+
+	response := events.APIGatewayV2CustomAuthorizerSimpleResponse{IsAuthorized: true, Context: fixture.generated}
+	requestContext := events.APIGatewayV2HTTPRequestContext{
+		Authorizer: &events.APIGatewayV2HTTPRequestContextAuthorizerDescription{
+			Lambda: viaAPIGateway(t, response.Context),
+		},
+	}
+
+	resolved, err := claimsClient.FromHTTPRequestContext(requestContext)
+	require.NoError(t, err)
+	assert.Equal(t, fixture.userClaim, resolved.User)
+	assert.Equal(t, fixture.orgClaim, resolved.Organization)
+	assert.Equal(t, fixture.datasetClaim, resolved.Dataset)
+	assert.Equal(t, fixture.teamClaims, resolved.Teams)
+	assert.True(t, resolved.HasDatasetRole(role.Manager))
+	assert.False(t, resolved.HasDatasetRole(role.Owner))
+}
+
+func TestClaimsRoundTripWebSocket(t *testing.T) {
+	fixture := newRoundTripFixture(t)
+
+	response := allowResponseWithComputeNode("arn:aws:execute-api:us-east-1:123:abc/dev/$connect", fixture.generated, "team")
+	request := events.APIGatewayWebsocketProxyRequest{
+		RequestContext: events.APIGatewayWebsocketProxyRequestContext{
+			Authorizer: viaAPIGateway(t, response.Context),
+		},
+	}
+
+	resolved, err := claimsClient.FromWebSocketRequest(request)
+	require.NoError(t, err)
+	assert.Equal(t, fixture.userClaim, resolved.User)
+	assert.Equal(t, fixture.orgClaim, resolved.Organization)
+	assert.Equal(t, fixture.datasetClaim, resolved.Dataset)
+	assert.Equal(t, fixture.teamClaims, resolved.Teams)
+	assert.Equal(t, "team", resolved.ComputeNodeAccess)
+	assert.Equal(t, fixture.userClaim.NodeId, resolved.UserNodeId())
+}
+
+func TestClaimsRoundTripWebSocketDeny(t *testing.T) {
+	response := denyResponse("arn:aws:execute-api:us-east-1:123:abc/dev/$connect", "invalid_token")
+
+	_, err := claimsClient.FromWebSocketAuthorizer(viaAPIGateway(t, response.Context))
+	assert.ErrorContains(t, err, "invalid_token")
+}
+
+func TestClaimsRoundTripTraceId(t *testing.T) {
+	fixture := newRoundTripFixture(t)
+	ctx := tracing.Extract(context.Background(), map[string]string{
+		"x-amzn-trace-id": "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1",
+	})
+	addTraceId(ctx, fixture.generated)
+
+	httpResolved, err := claimsClient.FromLambdaContext(viaAPIGateway(t, fixture.generated))
+	require.NoError(t, err)
+	assert.Equal(t, "5759e988bd862e3fe1be46a994272793", httpResolved.TraceId)
+
+	response := allowResponseWithComputeNode("arn:aws:execute-api:us-east-1:123:abc/dev/$connect", fixture.generated, "")
+	wsResolved, err := claimsClient.FromWebSocketAuthorizer(viaAPIGateway(t, response.Context))
+	require.NoError(t, err)
+	assert.Equal(t, "5759e988bd862e3fe1be46a994272793", wsResolved.TraceId)
+}
+
+func TestClaimsRoundTripPurposeOfUse(t *testing.T) {
+	fixture := newRoundTripFixture(t)
+	ctx := manager.WithRequest(context.Background(), manager.Request{PurposeOfUse: claimsClient.PurposeOfUseResearch})
+	addPurposeOfUse(ctx, fixture.generated)
+
+	httpResolved, err := claimsClient.FromLambdaContext(viaAPIGateway(t, fixture.generated))
+	require.NoError(t, err)
+	assert.Equal(t, claimsClient.PurposeOfUseResearch, httpResolved.PurposeOfUse)
+
+	response := allowResponseWithComputeNode("arn:aws:execute-api:us-east-1:123:abc/dev/$connect", fixture.generated, "")
+	wsResolved, err := claimsClient.FromWebSocketAuthorizer(viaAPIGateway(t, response.Context))
+	require.NoError(t, err)
+	assert.Equal(t, claimsClient.PurposeOfUseResearch, wsResolved.PurposeOfUse)
+}
+
+// TestClaimsRoundTripWebSocketEveryField checks that every field of ResolvedClaims survives the flattened WebSocket
+// context, by building claims with each of them set and parsing the flattened context back.
+func TestClaimsRoundTripWebSocketEveryField(t *testing.T) {
+	expiresAt := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	datasetState := &claimsClient.DatasetState{Status: "NO_STATUS", Protected: true}
+	expected := &claimsClient.ResolvedClaims{
+		User:                 &user.Claim{Id: 101, NodeId: "N:user:101"},
+		Organization:         &organization.Claim{Role: pgdb.Read, IntId: 1001, NodeId: "N:organization:1001"},
+		Dataset:              &dataset.Claim{Role: role.Viewer, NodeId: "N:dataset:1", IntId: 1},
+		Teams:                []teamUser.Claim{{IntId: 10, Name: "publishers", NodeId: "N:team:10", Permission: pgdb.Administer, TeamType: "publishers"}},
+		Impersonator:         &user.Claim{Id: 1, NodeId: "N:user:1", IsSuperAdmin: true},
+		ComputeNodeAccess:    "team",
+		TraceId:              "5759e988bd862e3fe1be46a994272793",
+		ExternalCollaborator: true,
+		DatasetState:         datasetState,
+		GrantExpiry:          &claimsClient.GrantExpiry{Dataset: &expiresAt},
+		Stale:                true,
+		BreakGlass:           true,
+		PurposeOfUse:         claimsClient.PurposeOfUseTreatment,
+		Resources: map[string]*claimsClient.ResolvedClaims{
+			"source_dataset_id": {
+				Dataset:      &dataset.Claim{Role: role.Viewer, NodeId: "N:dataset:1", IntId: 1},
+				DatasetState: datasetState,
+			},
+		},
+	}
+	value := reflect.ValueOf(*expected)
+	for i := 0; i < value.NumField(); i++ {
+		require.False(t, value.Field(i).IsZero(), "the fixture leaves %s unset", value.Type().Field(i).Name)
+	}
+	generated := map[string]interface{}{
+		coreAuthorizer.LabelUserClaim:          expected.User,
+		coreAuthorizer.LabelOrganizationClaim:  expected.Organization,
+		coreAuthorizer.LabelDatasetClaim:       expected.Dataset,
+		coreAuthorizer.LabelTeamClaims:         expected.Teams,
+		claimsClient.LabelImpersonatorClaim:    expected.Impersonator,
+		claimsClient.LabelTraceId:              expected.TraceId,
+		claimsClient.LabelExternalCollaborator: true,
+		claimsClient.LabelDatasetState:         expected.DatasetState,
+		claimsClient.LabelGrantExpiry:          expected.GrantExpiry,
+		claimsClient.LabelStale:                true,
+		claimsClient.LabelBreakGlass:           true,
+		claimsClient.LabelPurposeOfUse:         expected.PurposeOfUse,
+		claimsClient.LabelResourceClaims: map[string]map[string]interface{}{
+			"source_dataset_id": {
+				coreAuthorizer.LabelDatasetClaim: expected.Dataset,
+				claimsClient.LabelDatasetState:   expected.DatasetState,
+			},
+		},
+	}
+
+	response := allowResponseWithComputeNode("arn:aws:execute-api:us-east-1:123:abc/dev/$connect", generated, expected.ComputeNodeAccess)
+	resolved, err := claimsClient.FromWebSocketAuthorizer(viaAPIGateway(t, response.Context))
+	require.NoError(t, err)
+	assert.Equal(t, expected, resolved)
+}
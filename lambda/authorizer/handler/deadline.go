@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/pennsieve/pennsieve-go-api/authorizer/authorizers"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/deadline"
+	log "github.com/sirupsen/logrus"
+)
+
+// withBudget returns ctx bounded by the invocation's budget: the Lambda's deadline less DEADLINE_RESERVE_MS, which
+// is kept back so that a failure is still reported, and not lost to the Lambda timing out.
+func withBudget(ctx context.Context) (context.Context, context.CancelFunc) {
+	return deadline.WithBudget(ctx, appConfig.Deadlines.Reserve)
+}
+
+// withRemainingBudget returns logger with how much of the invocation's budget is left, to log with a failure.
+func withRemainingBudget(ctx context.Context, logger *log.Entry) *log.Entry {
+	remaining, ok := deadline.Remaining(ctx)
+	if !ok {
+		return logger
+	}
+	return logger.WithField("remainingBudgetMs", remaining.Milliseconds())
+}
+
+// indeterminateOnTimeout returns err, the result of invoking operation with ctx, as an
+// *authorizers.IndeterminateError if the invoke timed out: a Lambda that did not answer in time has not denied the
+// request.
+func indeterminateOnTimeout(ctx context.Context, operation string, err error) error {
+	if err = deadline.Check(ctx, operation, err); deadline.IsTimeout(err) {
+		return authorizers.NewIndeterminateError(err)
+	}
+	return err
+}
@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pennsieve/pennsieve-go-api/authorizer/deadline"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndeterminateOnTimeout(t *testing.T) {
+	invokeErr := errors.New("operation error Lambda: Invoke, canceled")
+	expired, cancel := deadline.Bound(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-expired.Done()
+
+	err := indeterminateOnTimeout(expired, "CallbackValidator", invokeErr)
+	assert.True(t, isIndeterminate(err))
+	assert.ErrorIs(t, err, invokeErr)
+
+	assert.Same(t, invokeErr, indeterminateOnTimeout(context.Background(), "CallbackValidator", invokeErr))
+	assert.NoError(t, indeterminateOnTimeout(expired, "CallbackValidator", nil))
+}
+
+func TestWithRemainingBudget(t *testing.T) {
+	invocation, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	budget, cancelBudget := withBudget(invocation)
+	defer cancelBudget()
+
+	logger := withRemainingBudget(budget, log.NewEntry(log.StandardLogger()))
+	assert.InDelta(t, (time.Minute - appConfig.Deadlines.Reserve).Milliseconds(), logger.Data["remainingBudgetMs"], float64(time.Second.Milliseconds()))
+
+	assert.NotContains(t, withRemainingBudget(context.Background(), log.NewEntry(log.StandardLogger())).Data, "remainingBudgetMs")
+}
@@ -2,18 +2,17 @@ package handler
 
 import (
 	"context"
-	"database/sql"
-	"fmt"
 
-	coreAuthorizer "github.com/pennsieve/pennsieve-go-core/pkg/authorizer"
-	"github.com/pennsieve/pennsieve-go-core/pkg/models/organization"
-	pgdbModels "github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
-	"github.com/pennsieve/pennsieve-go-core/pkg/models/role"
-	"github.com/pennsieve/pennsieve-go-core/pkg/models/user"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/authorizers"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/pipeline"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/tracing"
 	"github.com/pennsieve/pennsieve-go-core/pkg/queries/pgdb"
 	log "github.com/sirupsen/logrus"
 )
 
+// directAuthorizerType is the authorizer type recorded in metrics for direct invocations.
+const directAuthorizerType = "direct"
+
 // DirectAuthorizeRequest is the request payload for direct Lambda-to-Lambda invocation.
 // Callers provide node IDs for the user and optionally an organization and/or dataset.
 type DirectAuthorizeRequest struct {
@@ -22,42 +21,44 @@ type DirectAuthorizeRequest struct {
 	DatasetNodeID      string `json:"dataset_node_id,omitempty"`
 }
 
-// getUserByNodeId looks up a Pennsieve user by their node ID (e.g. "N:user:...").
-func getUserByNodeId(ctx context.Context, db *sql.DB, nodeId string) (*pgdbModels.User, error) {
-	queryStr := "SELECT id, node_id, email, first_name, last_name, is_super_admin, COALESCE(preferred_org_id, -1) as preferred_org_id " +
-		"FROM pennsieve.users WHERE node_id=$1;"
-
-	var u pgdbModels.User
-	row := db.QueryRowContext(ctx, queryStr, nodeId)
-	err := row.Scan(
-		&u.Id,
-		&u.NodeId,
-		&u.Email,
-		&u.FirstName,
-		&u.LastName,
-		&u.IsSuperAdmin,
-		&u.PreferredOrg)
-
-	if err != nil {
-		return nil, err
-	}
-	return &u, nil
-}
-
 // DirectAuthorizeResponse is the response payload for direct Lambda-to-Lambda invocation.
 type DirectAuthorizeResponse struct {
 	IsAuthorized bool                   `json:"is_authorized"`
 	Claims       map[string]interface{} `json:"claims,omitempty"`
 	Error        string                 `json:"error,omitempty"`
+	Reason       string                 `json:"reason,omitempty"`
 }
 
 // DirectHandler handles direct Lambda-to-Lambda invocation for authorization.
-// It looks up the user by node ID (no JWT required) and builds claims based
-// on which node IDs are provided:
-//   - user_node_id only: returns user claim
-//   - user_node_id + organization_node_id: returns user, organization, and team claims
-//   - user_node_id + organization_node_id + dataset_node_id: returns user, organization, dataset, and team claims
+// It identifies the user by node ID (no JWT required) and resolves claims through the same pipeline as the
+// API Gateway authorizers, selecting the authorizer by which node IDs are provided:
+//   - user_node_id only: user authorizer
+//   - user_node_id + organization_node_id: workspace authorizer
+//   - user_node_id + organization_node_id + dataset_node_id: dataset authorizer; the dataset must belong to the organization
+//
+// Denies are returned in the response's Error, with their reason code in Reason; an indeterminate failure is
+// returned as an error.
 func DirectHandler(ctx context.Context, request DirectAuthorizeRequest) (DirectAuthorizeResponse, error) {
+	ctx = tracing.Extract(ctx, nil)
+	defer tracing.Flush(ctx)
+	ctx, span := tracing.Tracer().Start(ctx, "AuthorizeDirect")
+	defer span.End()
+	ctx, cancel := withBudget(ctx)
+	defer cancel()
+
+	response, err := directAuthorize(ctx, request)
+	switch {
+	case err != nil:
+		recordIndeterminate(directAuthorizerType)
+	case response.IsAuthorized:
+		recordDecision(directAuthorizerType, nil)
+	default:
+		recordDeny(directAuthorizerType, reasonDenied)
+	}
+	return response, err
+}
+
+func directAuthorize(ctx context.Context, request DirectAuthorizeRequest) (DirectAuthorizeResponse, error) {
 	logger := log.WithFields(log.Fields{
 		"user_node_id":         request.UserNodeID,
 		"organization_node_id": request.OrganizationNodeID,
@@ -86,72 +87,30 @@ func DirectHandler(ctx context.Context, request DirectAuthorizeRequest) (DirectA
 		return DirectAuthorizeResponse{IsAuthorized: false}, err
 	}
 	defer db.Close()
-	postgresDB := pgdb.New(db)
+	// API Gateway does not cache direct invocation results, so grants count until they expire, less the claims
+	// cache's staleness.
+	sources := pipeline.Sources{
+		PostgresDB:    newPostgresDB(db, 0),
+		TokenClientId: appConfig.TokenClientId,
+	}
 
-	// Look up the user by node ID
-	currentUser, err := getUserByNodeId(ctx, db, request.UserNodeID)
+	resource := pipeline.Resource{DatasetNodeId: request.DatasetNodeID, OrganizationNodeId: request.OrganizationNodeID}
+	claims, err := pipeline.Resolve(ctx, sources.NodeIdPrincipal(request.UserNodeID), resource, appConfig.AuthorizerMode)
+	if err == nil {
+		err = checkBlocked(ctx, logger, claimsEntries(claims)...)
+	}
 	if err != nil {
-		logger.WithError(err).Error("unable to get user by node ID")
+		withRemainingBudget(ctx, logger).WithError(err).WithField("reason", authorizers.DenyReason(err)).Error("unable to resolve claims")
+		if isIndeterminate(err) {
+			return DirectAuthorizeResponse{IsAuthorized: false}, err
+		}
 		return DirectAuthorizeResponse{
 			IsAuthorized: false,
-			Error:        fmt.Sprintf("unable to get user: %s", err.Error()),
+			Error:        err.Error(),
+			Reason:       authorizers.DenyReason(err),
 		}, nil
 	}
 
-	claims := map[string]interface{}{
-		coreAuthorizer.LabelUserClaim: &user.Claim{
-			Id:           currentUser.Id,
-			NodeId:       currentUser.NodeId,
-			IsSuperAdmin: currentUser.IsSuperAdmin,
-		},
-	}
-
-	// If organization_node_id is provided, add organization and team claims
-	if request.OrganizationNodeID != "" {
-		orgClaim, err := postgresDB.GetOrganizationClaimByNodeId(ctx, currentUser.Id, request.OrganizationNodeID)
-		if err != nil {
-			logger.WithError(err).Error("unable to get organization claim")
-			return DirectAuthorizeResponse{
-				IsAuthorized: false,
-				Error:        fmt.Sprintf("unable to get organization claim: %s", err.Error()),
-			}, nil
-		}
-		claims[coreAuthorizer.LabelOrganizationClaim] = orgClaim
-
-		teamClaims, err := postgresDB.GetTeamClaimsForOrg(ctx, currentUser.Id, orgClaim.IntId)
-		if err != nil {
-			logger.WithError(err).Error("unable to get team claims")
-			return DirectAuthorizeResponse{
-				IsAuthorized: false,
-				Error:        fmt.Sprintf("unable to get team claims: %s", err.Error()),
-			}, nil
-		}
-		claims[coreAuthorizer.LabelTeamClaims] = teamClaims
-	}
-
-	// If dataset_node_id is provided, add dataset claim (organization_node_id is guaranteed present)
-	if request.DatasetNodeID != "" {
-		// Use the org's integer ID from the org claim for the dataset schema lookup
-		orgClaim := claims[coreAuthorizer.LabelOrganizationClaim]
-		orgIntId := orgClaim.(*organization.Claim).IntId
-
-		datasetClaim, err := postgresDB.GetDatasetClaim(ctx, currentUser, request.DatasetNodeID, orgIntId)
-		if err != nil {
-			logger.WithError(err).Error("unable to get dataset claim")
-			return DirectAuthorizeResponse{
-				IsAuthorized: false,
-				Error:        fmt.Sprintf("unable to get dataset claim: %s", err.Error()),
-			}, nil
-		}
-		if datasetClaim.Role == role.None {
-			return DirectAuthorizeResponse{
-				IsAuthorized: false,
-				Error:        "user has no access to dataset",
-			}, nil
-		}
-		claims[coreAuthorizer.LabelDatasetClaim] = datasetClaim
-	}
-
 	return DirectAuthorizeResponse{
 		IsAuthorized: true,
 		Claims:       claims,
@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/fault"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
+	"github.com/pennsieve/pennsieve-go-core/pkg/queries/dydb"
+	log "github.com/sirupsen/logrus"
+)
+
+// faults injects the failures configured by FAULT_INJECTION into Postgres, DynamoDB and Lambda calls. Nil, as it is
+// unless FAULT_INJECTION is set, injects none.
+var faults *fault.Injector
+
+// newFaultInjector returns the Injector configured by FAULT_INJECTION, or nil if it is not set. An invalid or
+// disallowed configuration is reported by the self-test, and fails every authorization.
+func newFaultInjector() *fault.Injector {
+	if !appConfig.FaultsEnabled {
+		return nil
+	}
+	log.WithField("rules", len(appConfig.Faults.Rules)).Warn("fault injection enabled")
+	return fault.NewInjector(appConfig.Faults)
+}
+
+// newDynamoDB returns the PennsieveDyAPI for one invocation: DynamoDB, with any injected faults, timed and bounded
+// by TIMEOUT_DYNAMODB_MS.
+func newDynamoDB(cfg aws.Config) manager.PennsieveDyAPI {
+	return manager.NewTimedDyAPI(manager.NewFaultyDyAPI(dydb.New(dynamodb.NewFromConfig(cfg)), faults), metricsRecorder, appConfig.Deadlines.DynamoDB)
+}
@@ -8,39 +8,36 @@ import (
 	"time"
 
 	"github.com/aws/aws-lambda-go/events"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/lestrrat-go/jwx/v2/jwk"
 	"github.com/lestrrat-go/jwx/v2/jwt"
 	"github.com/pennsieve/pennsieve-go-api/authorizer/authorizers"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/config"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/deadline"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/factory"
 	"github.com/pennsieve/pennsieve-go-api/authorizer/helpers"
 	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/metrics"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/pipeline"
 	"github.com/pennsieve/pennsieve-go-api/authorizer/service"
-	"github.com/pennsieve/pennsieve-go-core/pkg/queries/dydb"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/tracing"
 	"github.com/pennsieve/pennsieve-go-core/pkg/queries/pgdb"
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var keySet jwk.Set
-var regionID string
-var userPoolID string
-var userClientID string
-var tokenPoolID string
-var tokenClientID string
-var issuer string
-var tokenIssuer string
-var manifestTableName string
+
+// appConfig is the Lambda's environment, loaded once on cold start. configErr is set, and every authorization
+// fails, when it does not satisfy the running binary (see WithSelfTest).
+var appConfig *config.Config
+var configErr error
 
 // init runs on cold start of lambda and gets jwt keysets from Cognito user pools.
 func init() {
-	regionID = os.Getenv("REGION")
-	userPoolID = os.Getenv("USER_POOL")
-	userClientID = os.Getenv("USER_CLIENT")
-	tokenPoolID = os.Getenv("TOKEN_POOL")
-	tokenClientID = os.Getenv("TOKEN_CLIENT")
-	manifestTableName = os.Getenv("MANIFEST_TABLE")
-	issuer = fmt.Sprintf("https://cognito-idp.%s.amazonaws.com/%s", regionID, userPoolID)
-	tokenIssuer = fmt.Sprintf("https://cognito-idp.%s.amazonaws.com/%s", regionID, tokenPoolID)
+	appConfig = config.FromEnv()
+	authorizers.Configure(appConfig.AuthorizerSettings())
 
 	log.SetFormatter(&log.JSONFormatter{})
 	ll, err := log.ParseLevel(os.Getenv("LOG_LEVEL"))
@@ -50,17 +47,25 @@ func init() {
 		log.SetLevel(ll)
 	}
 
+	if err := tracing.Init(context.Background()); err != nil {
+		log.Error("Unable to initialize tracing", err)
+	}
+
 	// Get UserPool keyset
 	// https://docs.aws.amazon.com/cognito/latest/developerguide/amazon-cognito-user-pools-using-tokens-verifying-a-jwt.html
-	userJwksURL := fmt.Sprintf("https://cognito-idp.%s.amazonaws.com/%s/.well-known/jwks.json", regionID, userPoolID)
-	keySet, err = jwk.Fetch(context.Background(), userJwksURL)
+	userJwksURL := appConfig.JWKSURL(appConfig.UserPoolId)
+	stopTracking := metrics.Track(metricsRecorder, metrics.DependencyJWKS, "FetchUserPoolKeySet")
+	keySet, err = fetchKeySet(userJwksURL)
+	stopTracking()
 	if err != nil {
 		log.Error("Unable to fetch user pool Key Set", err)
 	}
 
 	// Get TokenPool keyset
-	tokenJwksURL := fmt.Sprintf("https://cognito-idp.%s.amazonaws.com/%s/.well-known/jwks.json", regionID, tokenPoolID)
-	tokenKeySet, err := jwk.Fetch(context.Background(), tokenJwksURL)
+	tokenJwksURL := appConfig.JWKSURL(appConfig.TokenPoolId)
+	stopTracking = metrics.Track(metricsRecorder, metrics.DependencyJWKS, "FetchTokenPoolKeySet")
+	tokenKeySet, err := fetchKeySet(tokenJwksURL)
+	stopTracking()
 	if err != nil {
 		log.Error("Unable to fetch token pool Key Set", err)
 	}
@@ -75,6 +80,21 @@ func init() {
 		}
 	}
 
+	faults = newFaultInjector()
+	rateLimiter = newRateLimiter()
+	blockList = newBlockList()
+	breakGlassStore = newBreakGlassStore()
+	claimsCache = manager.NewClaimsCache(appConfig.ClaimsCache, metricsRecorder)
+	lastKnownGood = pipeline.NewLastKnownGood(appConfig.StaleClaimsWindow)
+
+}
+
+// fetchKeySet fetches the JWKS at url, bounded by TIMEOUT_JWKS_MS: a cold start has no invocation budget yet, but a
+// hung Cognito endpoint must not consume the Lambda's init phase.
+func fetchKeySet(url string) (jwk.Set, error) {
+	ctx, cancel := deadline.Bound(context.Background(), appConfig.Deadlines.JWKS)
+	defer cancel()
+	return jwk.Fetch(ctx, url)
 }
 
 // Handler runs in response to authorization event from the AWS API Gateway.
@@ -90,6 +110,31 @@ func Handler(ctx context.Context, event events.APIGatewayV2CustomAuthorizerV2Req
 		"IdentitySource": event.IdentitySource,
 		"Headers":        event.Headers}).Info("request parameters")
 
+	ctx = tracing.Extract(ctx, event.Headers)
+	defer tracing.Flush(ctx)
+	ctx, span := tracing.Tracer().Start(ctx, "Authorize", trace.WithAttributes(attribute.String("http.route", event.RequestContext.RouteKey)))
+	defer span.End()
+	ctx, cancel := withBudget(ctx)
+	defer cancel()
+
+	purposeOfUse, err := authorizers.ParsePurposeOfUse(event.Headers[purposeOfUseHeader])
+	if err != nil {
+		logger.WithField("reason", authorizers.DenyReason(err)).Error(err)
+		recordDeny(authorizerTypeNone, authorizers.ReasonInvalidPurposeOfUse)
+		return deniedResponse(err)
+	}
+	if purposeOfUse != "" {
+		logger = logger.WithField("purposeOfUse", purposeOfUse)
+	}
+	identitySources, uncached := resourceIdentitySources(event)
+	ctx = manager.WithRequest(ctx, manager.Request{
+		SourceIp:     event.RequestContext.HTTP.SourceIP,
+		Method:       event.RequestContext.HTTP.Method,
+		Route:        event.RequestContext.RouteKey,
+		PurposeOfUse: purposeOfUse,
+		Uncached:     uncached,
+	})
+
 	// Check for Callback authorization scheme before JWT processing
 	if helpers.IsCallbackAuth(event.Headers["authorization"]) {
 		return handleCallbackAuth(ctx, event)
@@ -98,6 +143,7 @@ func Handler(ctx context.Context, event events.APIGatewayV2CustomAuthorizerV2Req
 	jwtB64, err := helpers.GetJWT(event.Headers["authorization"])
 	if err != nil {
 		logger.Error(err)
+		recordDeny(authorizerTypeNone, reasonMissingToken)
 		return events.APIGatewayV2CustomAuthorizerSimpleResponse{
 			IsAuthorized: false,
 			Context:      nil,
@@ -105,75 +151,152 @@ func Handler(ctx context.Context, event events.APIGatewayV2CustomAuthorizerV2Req
 	}
 
 	// Validate and parse token, and return unauthorized if not valid
+	_, jwtSpan := tracing.Tracer().Start(ctx, "ValidateJWT")
 	token, err := validateCognitoJWT(jwtB64)
+	tracing.End(jwtSpan, err)
 	if err != nil {
 		logger.Error(err)
+		recordDeny(authorizerTypeNone, reasonInvalidToken)
 		return events.APIGatewayV2CustomAuthorizerSimpleResponse{
 			IsAuthorized: false,
 			Context:      nil,
 		}, nil
 	}
 
-	// Open Pennsieve DB Connection
-	db, err := pgdb.ConnectRDS()
-	postgresDB := pgdb.New(db)
-	if err != nil {
-		logger.Error("unable to connect to RDS instance: ", err)
-		// deliberately returning non-nil error so caller gets a 500 rather
-		// than 40x response
-		return events.APIGatewayV2CustomAuthorizerSimpleResponse{
-			IsAuthorized: false,
-		}, err
-	}
-	defer db.Close()
-
-	// Create a DynamoDB connection
-	cfg, err := config.LoadDefaultConfig(ctx)
-	if err != nil {
-		logger.Error("unable to load AWS config: ", err)
-		// deliberately returning non-nil error so caller gets a 500 rather
-		// than 40x response
-		return events.APIGatewayV2CustomAuthorizerSimpleResponse{
-			IsAuthorized: false,
-		}, err
-	}
-	client := dynamodb.NewFromConfig(cfg)
-	dynamoDB := dydb.New(client)
-
 	// Get claims
-	identityService := service.NewIdentitySourceService(event.IdentitySource, event.QueryStringParameters)
+	identityService := service.NewIdentitySourceService(factory.Event{
+		IdentitySource:        identitySources,
+		QueryStringParameters: event.QueryStringParameters,
+		PathParameters:        event.PathParameters,
+		Headers:               event.Headers,
+	})
 	authorizer, err := identityService.GetAuthorizer(ctx)
 	if err != nil {
 		logger.Error(err)
+		recordDeny(authorizerTypeNone, reasonNoAuthorizer)
 		return events.APIGatewayV2CustomAuthorizerSimpleResponse{
 			IsAuthorized: false,
 			Context:      nil,
 		}, nil
 	}
-	claimsManager := manager.NewClaimsManager(postgresDB, dynamoDB, token, tokenClientID, manifestTableName)
-	authorizerMode := os.Getenv("AUTHORIZER_MODE")
-	claims, err := authorizer.GenerateClaims(ctx, claimsManager, authorizerMode)
-	if err != nil {
-		logger.Error(err)
-		if isIndeterminate(err) {
-			// DB failure, timeout, or other unexpected lookup error: not an authoritative
-			// decision, so return the error (uncached HTTP 500) instead of a cacheable deny.
+	resource := pipeline.ResourceOf(authorizer)
+
+	orgNodeId, orgKnown := jwtOrgNodeId(token, resource)
+	if orgKnown {
+		if err := checkRateLimit(ctx, logger, jwtPrincipal(token), orgNodeId, authorizers.Scope(authorizer)); err != nil {
+			logger.WithField("reason", authorizers.DenyReason(err)).Error(err)
+			recordDecision(authorizers.Scope(authorizer), err)
 			return events.APIGatewayV2CustomAuthorizerSimpleResponse{
 				IsAuthorized: false,
-			}, err
+			}, nil
 		}
-		return events.APIGatewayV2CustomAuthorizerSimpleResponse{
-			IsAuthorized: false,
-			Context:      nil,
-		}, nil
 	}
 
+	claims, actingAs, err := resolveClaims(ctx, event, token, resource)
+	if targetNodeId := event.Headers[actAsHeader]; targetNodeId != "" {
+		logger = logger.WithField("actAs", targetNodeId)
+	} else if event.Headers[breakGlassHeader] != "" {
+		logger = logger.WithField("breakGlass", true)
+	} else {
+		// Impersonated and break-glass requests are never served stale claims: each is checked, and audited, afresh.
+		claims, err = withLastKnownGood(ctx, logger, authorizers.Scope(authorizer), tokenPrincipal(token), resource, claims, err)
+	}
+	if err == nil && !orgKnown {
+		// Throttled once the claims name the resource's organization, so that its bucket applies.
+		err = checkRateLimit(ctx, logger, jwtPrincipal(token), extractOrgNodeID(claims), authorizers.Scope(authorizer))
+	}
+	if err == nil {
+		err = checkBlocked(ctx, logger, append(tokenEntries(token), claimsEntries(claims)...)...)
+	}
+	if actingAs != nil {
+		actingAs.finish(claims, err)
+	}
+	recordDecision(authorizers.Scope(authorizer), err)
+	if err != nil {
+		if reason := authorizers.DenyReason(err); reason != "" {
+			logger = logger.WithField("reason", reason)
+		}
+		withRemainingBudget(ctx, logger).Error(err)
+		return deniedResponse(err)
+	}
+
+	addTraceId(ctx, claims)
+	addPurposeOfUse(ctx, claims)
 	return events.APIGatewayV2CustomAuthorizerSimpleResponse{
 		IsAuthorized: true,
 		Context:      claims,
 	}, nil
 }
 
+// resolveClaims resolves the claims of the principal authenticated by token for resource, or, if the act-as header
+// is set, of the user it names. If the break-glass header is set and the principal is denied, it grants them
+// break-glass claims if it can. The returned impersonation is nil unless the request is impersonated.
+func resolveClaims(ctx context.Context, event events.APIGatewayV2CustomAuthorizerV2Request, token jwt.Token, resource pipeline.Resource) (map[string]interface{}, *impersonation, error) {
+	// Open Pennsieve DB Connection
+	db, err := pgdb.ConnectRDS()
+	if err != nil {
+		return nil, nil, authorizers.NewIndeterminateError(fmt.Errorf("unable to connect to RDS instance: %w", err))
+	}
+	defer db.Close()
+
+	// Create a DynamoDB connection
+	cfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, nil, authorizers.NewIndeterminateError(fmt.Errorf("unable to load AWS config: %w", err))
+	}
+	sources := pipeline.Sources{
+		PostgresDB:    newPostgresDB(db, appConfig.ResultTtl),
+		DynamoDB:      newDynamoDB(cfg),
+		TokenClientId: appConfig.TokenClientId,
+		ManifestTable: appConfig.ManifestTable,
+	}
+	claimsManager := sources.CognitoPrincipal(token)
+
+	// An operator may break glass for emergency read access to a dataset they have no role on.
+	if justification := event.Headers[breakGlassHeader]; justification != "" {
+		if event.Headers[actAsHeader] != "" {
+			return nil, nil, authorizers.NewUncacheableDenyError(authorizers.ReasonBreakGlassNotAllowed, errors.New("break-glass access cannot be combined with acting as another user"))
+		}
+		glass, err := startBreakGlass(ctx, claimsManager, token, resource, justification, event.RequestContext.HTTP.Method, event.RequestContext.RouteKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		claims, err := pipeline.Resolve(ctx, claimsManager, resource, appConfig.AuthorizerMode)
+		claims, err = glass.grant(ctx, claimsManager, claims, err)
+		return claims, nil, err
+	}
+
+	// A super-admin may act as another user; claims are then resolved for that user instead.
+	var actingAs *impersonation
+	if targetNodeId := event.Headers[actAsHeader]; targetNodeId != "" {
+		actingAs, err = startImpersonation(ctx, claimsManager, targetNodeId, event.RequestContext.HTTP.Method, event.RequestContext.RouteKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		claimsManager = actingAs.manager
+	}
+
+	claims, err := pipeline.Resolve(ctx, claimsManager, resource, appConfig.AuthorizerMode)
+	return claims, actingAs, err
+}
+
+// deniedResponse returns the response to a request that err denied or left undecided.
+func deniedResponse(err error) (events.APIGatewayV2CustomAuthorizerSimpleResponse, error) {
+	if isIndeterminate(err) || authorizers.Uncacheable(err) {
+		// DB failure, timeout, or other unexpected lookup error: not an authoritative
+		// decision, so return the error (uncached HTTP 500) instead of a cacheable deny.
+		// A deny that must not be cached is returned the same way, since simple responses
+		// cannot opt out of API Gateway's cache.
+		return events.APIGatewayV2CustomAuthorizerSimpleResponse{
+			IsAuthorized: false,
+		}, err
+	}
+	return events.APIGatewayV2CustomAuthorizerSimpleResponse{
+		IsAuthorized: false,
+		Context:      nil,
+	}, nil
+}
+
 // isIndeterminate reports whether err represents a DB failure, timeout, or other unexpected
 // lookup error (as opposed to an authoritative access decision) and so must not be cached as a deny.
 func isIndeterminate(err error) bool {
@@ -183,6 +306,7 @@ func isIndeterminate(err error) bool {
 
 // validateCognitoJWT parses and validates the provided JWT from Cognito.
 func validateCognitoJWT(jwtB64 []byte) (jwt.Token, error) {
+	defer metrics.Track(metricsRecorder, metrics.DependencyJWKS, "ValidateJWT")()
 
 	// Parse the JWT.
 	token, err := jwt.Parse(jwtB64, jwt.WithKeySet(keySet))
@@ -190,12 +314,12 @@ func validateCognitoJWT(jwtB64 []byte) (jwt.Token, error) {
 		return nil, fmt.Errorf("error parsing JWT: %w", err)
 	}
 
-	if token.Issuer() != issuer && token.Issuer() != tokenIssuer {
+	if token.Issuer() != appConfig.Issuer(appConfig.UserPoolId) && token.Issuer() != appConfig.Issuer(appConfig.TokenPoolId) {
 		return nil, fmt.Errorf("AUTHORIZER_FAILURE: Issuer in token does not match Pennsieve token issuers: %s", token.Issuer())
 	}
 
 	clientIdClaim, hasKey := token.Get("client_id")
-	if !hasKey || (clientIdClaim != userClientID && clientIdClaim != tokenClientID) {
+	if !hasKey || (clientIdClaim != appConfig.UserClientId && clientIdClaim != appConfig.TokenClientId) {
 		detail := clientIdClaim
 		if !hasKey {
 			detail = "client_id missing"
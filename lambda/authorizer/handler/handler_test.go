@@ -25,4 +25,25 @@ func TestIsIndeterminate_IndeterminateErrorWrappedFurther(t *testing.T) {
 
 func TestIsIndeterminate_PlainError(t *testing.T) {
 	assert.False(t, isIndeterminate(errors.New("user has no access to dataset")))
-}
\ No newline at end of file
+}
+
+func TestDeniedResponse(t *testing.T) {
+	for scenario, params := range map[string]struct {
+		err           error
+		expectedError bool
+	}{
+		"deny":             {authorizers.NewDenyError(authorizers.ReasonNoDatasetRole, errors.New("no role")), false},
+		"uncacheable deny": {authorizers.NewUncacheableDenyError(authorizers.ReasonBreakGlassNotAllowed, errors.New("not allowed")), true},
+		"indeterminate":    {authorizers.NewIndeterminateError(errors.New("timeout")), true},
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			response, err := deniedResponse(params.err)
+			assert.False(t, response.IsAuthorized)
+			if params.expectedError {
+				assert.Equal(t, params.err, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package handler
+
+import "github.com/aws/aws-lambda-go/events"
+
+// resourceIdentitySources returns the identity sources of event that name the token and the resources, and
+// whether API Gateway caches the decision. Routes list identity sources from the request context after those:
+// either the method and source IP, which a cached decision must be keyed on, or the request id, which no two
+// requests share, so that an authorizer listing it never reuses a decision. They are stripped by position, so a
+// resource whose value happens to equal the method or the source IP is kept.
+func resourceIdentitySources(event events.APIGatewayV2CustomAuthorizerV2Request) ([]string, bool) {
+	sources := event.IdentitySource
+	n := len(sources)
+	requestId := event.RequestContext.RequestID
+	switch {
+	case n >= 2 && requestId != "" && sources[n-1] == requestId:
+		return sources[:n-1], true
+	case n >= 3 && sources[n-2] == event.RequestContext.HTTP.Method && sources[n-1] == event.RequestContext.HTTP.SourceIP:
+		return sources[:n-2], false
+	}
+	return sources, false
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResourceIdentitySources(t *testing.T) {
+	event := func(identitySource ...string) events.APIGatewayV2CustomAuthorizerV2Request {
+		return events.APIGatewayV2CustomAuthorizerV2Request{
+			IdentitySource: identitySource,
+			RequestContext: events.APIGatewayV2HTTPRequestContext{
+				RequestID: "JKJaXmPLvHcESHA=",
+				HTTP:      events.APIGatewayV2HTTPRequestContextHTTPDescription{Method: "GET", SourceIP: "192.0.2.10"},
+			},
+		}
+	}
+
+	for scenario, params := range map[string]struct {
+		identitySource   []string
+		expectedSources  []string
+		expectedUncached bool
+	}{
+		"token only":                  {[]string{"Bearer t"}, []string{"Bearer t"}, false},
+		"cached with method and ip":   {[]string{"Bearer t", "N:dataset:1", "GET", "192.0.2.10"}, []string{"Bearer t", "N:dataset:1"}, false},
+		"uncached":                    {[]string{"Bearer t", "N:dataset:1", "JKJaXmPLvHcESHA="}, []string{"Bearer t", "N:dataset:1"}, true},
+		"context sources only at end": {[]string{"Bearer t", "GET", "N:dataset:1"}, []string{"Bearer t", "GET", "N:dataset:1"}, false},
+		"token with method and ip":    {[]string{"Bearer t", "GET", "192.0.2.10"}, []string{"Bearer t"}, false},
+		"resource equal to method":    {[]string{"Bearer t", "GET", "GET", "192.0.2.10"}, []string{"Bearer t", "GET"}, false},
+		"resource equal to ip":        {[]string{"Bearer t", "192.0.2.10", "GET", "192.0.2.10"}, []string{"Bearer t", "192.0.2.10"}, false},
+		"uncached resource like ip":   {[]string{"Bearer t", "192.0.2.10", "JKJaXmPLvHcESHA="}, []string{"Bearer t", "192.0.2.10"}, true},
+		"method without ip":           {[]string{"Bearer t", "N:dataset:1", "GET"}, []string{"Bearer t", "N:dataset:1", "GET"}, false},
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			sources, uncached := resourceIdentitySources(event(params.identitySource...))
+			assert.Equal(t, params.expectedSources, sources)
+			assert.Equal(t, params.expectedUncached, uncached)
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/pennsieve/pennsieve-go-api/authorizer/audit"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/authorizers"
+	claimsClient "github.com/pennsieve/pennsieve-go-api/authorizer/claims"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/helpers"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/user"
+)
+
+// actAsHeader is the header a super-admin sets to the node id of the user they want to act as.
+// API Gateway delivers HTTP API header names lower-cased.
+const actAsHeader = "x-pennsieve-act-as"
+
+// auditLogger receives an audit record for every impersonation attempt, allowed or not.
+var auditLogger audit.Logger = audit.NewLogger()
+
+// impersonation tracks a single act-as request so that every outcome gets exactly one audit record.
+type impersonation struct {
+	targetNodeId string
+	method       string
+	route        string
+	purposeOfUse string
+	manager      *manager.ImpersonatingManager
+}
+
+// startImpersonation returns an impersonation of targetNodeId if the principal may make this request as them.
+func startImpersonation(ctx context.Context, claimsManager manager.IdentityManager, targetNodeId string, method string, route string) (*impersonation, error) {
+	request, _ := manager.RequestFromContext(ctx)
+	i := &impersonation{targetNodeId: targetNodeId, method: method, route: route, purposeOfUse: request.PurposeOfUse}
+
+	// A cached grant would serve the target's claims to the super-admin's later requests without the header.
+	if !request.Uncached {
+		impersonatorNodeId := ""
+		if impersonator, err := claimsManager.GetCurrentUser(ctx); err == nil {
+			impersonatorNodeId = impersonator.NodeId
+		}
+		i.audit(impersonatorNodeId, audit.OutcomeDenied, "route caches authorizer decisions")
+		return nil, authorizers.NewUncacheableDenyError(authorizers.ReasonUncachedAuthorizerRequired, errors.New("acting as another user requires a route whose authorizer decisions are not cached"))
+	}
+
+	impersonator, err := claimsManager.GetCurrentUser(ctx)
+	if err != nil {
+		i.audit("", audit.OutcomeDenied, "unable to resolve impersonator")
+		return nil, fmt.Errorf("unable to get current user: %w", err)
+	}
+	if !impersonator.IsSuperAdmin {
+		i.audit(impersonator.NodeId, audit.OutcomeDenied, "impersonator is not a super-admin")
+		return nil, authorizers.NewDenyError(authorizers.ReasonImpersonationNotAllowed, fmt.Errorf("user %s is not permitted to act as another user", impersonator.NodeId))
+	}
+	if !helpers.IsReadOnlyMethod(method) && !appConfig.ImpersonationAllowWrites {
+		i.audit(impersonator.NodeId, audit.OutcomeDenied, "write request while impersonating")
+		return nil, authorizers.NewDenyError(authorizers.ReasonImpersonationNotAllowed, fmt.Errorf("%s requests are not permitted while acting as another user", method))
+	}
+
+	target, err := claimsManager.GetUserByNodeId(ctx, targetNodeId)
+	if err != nil {
+		i.audit(impersonator.NodeId, audit.OutcomeDenied, "unable to resolve target user")
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, authorizers.NewDenyError(authorizers.ReasonUnknownUser, fmt.Errorf("no user found to act as: %s", targetNodeId))
+		}
+		return nil, authorizers.NewIndeterminateError(fmt.Errorf("unable to get user to act as %s: %w", targetNodeId, err))
+	}
+
+	i.manager = manager.NewImpersonatingManager(claimsManager, impersonator, target)
+	return i, nil
+}
+
+// finish adds the impersonator to claims and audits the outcome of resolving them.
+func (i *impersonation) finish(claims map[string]interface{}, claimsErr error) {
+	impersonator := i.manager.Impersonator
+	if claimsErr != nil {
+		i.audit(impersonator.NodeId, audit.OutcomeDenied, claimsErr.Error())
+		return
+	}
+	claims[claimsClient.LabelImpersonatorClaim] = &user.Claim{
+		Id:           impersonator.Id,
+		NodeId:       impersonator.NodeId,
+		IsSuperAdmin: impersonator.IsSuperAdmin,
+	}
+	i.audit(impersonator.NodeId, audit.OutcomeAllowed, "")
+}
+
+func (i *impersonation) audit(impersonatorNodeId string, outcome audit.Outcome, reason string) {
+	auditLogger.Log(audit.Record{
+		Event:         "impersonation",
+		Outcome:       outcome,
+		Severity:      audit.SeverityHigh,
+		ActorNodeId:   impersonatorNodeId,
+		SubjectNodeId: i.targetNodeId,
+		Method:        i.method,
+		Route:         i.route,
+		Reason:        reason,
+		PurposeOfUse:  i.purposeOfUse,
+	})
+}
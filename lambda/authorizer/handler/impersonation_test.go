@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/pennsieve/pennsieve-go-api/authorizer/audit"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/authorizers"
+	claimsClient "github.com/pennsieve/pennsieve-go-api/authorizer/claims"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/test"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/test/mocks"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func useAuditLogger(t *testing.T) *mocks.AuditLogger {
+	recorder := mocks.NewAuditLogger()
+	original := auditLogger
+	auditLogger = recorder
+	t.Cleanup(func() { auditLogger = original })
+	return recorder
+}
+
+func newSuperAdmin() *pgdb.User {
+	admin := test.NewUser(101, 1001)
+	admin.IsSuperAdmin = true
+	return admin
+}
+
+// uncachedContext returns a context for a request whose authorizer decision API Gateway does not cache.
+func uncachedContext() context.Context {
+	return manager.WithRequest(context.Background(), manager.Request{Uncached: true})
+}
+
+func TestImpersonation(t *testing.T) {
+	recorder := useAuditLogger(t)
+	admin := newSuperAdmin()
+	target := test.NewUser(202, 2002)
+	managerParams := mocks.NewClaimsManagerParams(t).WithUserQueryMocked(t, admin)
+	managerParams.MockPennsievePg.OnGetUserByNodeId(target.NodeId).Return(target, nil)
+
+	actingAs, err := startImpersonation(uncachedContext(), managerParams.BuildClaimsManager(), target.NodeId, "GET", "GET /datasets")
+	require.NoError(t, err)
+
+	currentUser, err := actingAs.manager.GetCurrentUser(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, target, currentUser)
+
+	claims := map[string]interface{}{}
+	actingAs.finish(claims, nil)
+	assert.Equal(t, &user.Claim{Id: admin.Id, NodeId: admin.NodeId, IsSuperAdmin: true}, claims[claimsClient.LabelImpersonatorClaim])
+
+	require.Len(t, recorder.Records, 1)
+	assert.Equal(t, audit.OutcomeAllowed, recorder.Records[0].Outcome)
+	assert.Equal(t, admin.NodeId, recorder.Records[0].ActorNodeId)
+	assert.Equal(t, target.NodeId, recorder.Records[0].SubjectNodeId)
+	managerParams.AssertMockExpectations(t)
+}
+
+func TestImpersonationClaimsDenied(t *testing.T) {
+	recorder := useAuditLogger(t)
+	admin := newSuperAdmin()
+	target := test.NewUser(202, 2002)
+	managerParams := mocks.NewClaimsManagerParams(t).WithUserQueryMocked(t, admin)
+	managerParams.MockPennsievePg.OnGetUserByNodeId(target.NodeId).Return(target, nil)
+
+	actingAs, err := startImpersonation(uncachedContext(), managerParams.BuildClaimsManager(), target.NodeId, "GET", "GET /datasets")
+	require.NoError(t, err)
+
+	actingAs.finish(nil, errors.New("user has no access to dataset"))
+
+	require.Len(t, recorder.Records, 1)
+	assert.Equal(t, audit.OutcomeDenied, recorder.Records[0].Outcome)
+	assert.Equal(t, "user has no access to dataset", recorder.Records[0].Reason)
+}
+
+func TestImpersonationNotSuperAdmin(t *testing.T) {
+	recorder := useAuditLogger(t)
+	notAdmin := test.NewUser(101, 1001)
+	managerParams := mocks.NewClaimsManagerParams(t).WithUserQueryMocked(t, notAdmin)
+
+	_, err := startImpersonation(uncachedContext(), managerParams.BuildClaimsManager(), "N:user:target", "GET", "GET /datasets")
+	assert.ErrorContains(t, err, "not permitted to act as another user")
+	assert.Equal(t, authorizers.ReasonImpersonationNotAllowed, authorizers.DenyReason(err))
+	assert.False(t, isIndeterminate(err))
+
+	require.Len(t, recorder.Records, 1)
+	assert.Equal(t, audit.OutcomeDenied, recorder.Records[0].Outcome)
+	managerParams.AssertMockExpectations(t)
+}
+
+func TestImpersonationCachedRoute(t *testing.T) {
+	recorder := useAuditLogger(t)
+	admin := newSuperAdmin()
+	managerParams := mocks.NewClaimsManagerParams(t).WithUserQueryMocked(t, admin)
+	ctx := manager.WithRequest(context.Background(), manager.Request{Method: "GET"})
+
+	_, err := startImpersonation(ctx, managerParams.BuildClaimsManager(), "N:user:target", "GET", "GET /datasets")
+	assert.Equal(t, authorizers.ReasonUncachedAuthorizerRequired, authorizers.DenyReason(err))
+	assert.True(t, authorizers.Uncacheable(err))
+
+	require.Len(t, recorder.Records, 1)
+	assert.Equal(t, audit.OutcomeDenied, recorder.Records[0].Outcome)
+	assert.Equal(t, admin.NodeId, recorder.Records[0].ActorNodeId)
+	managerParams.AssertMockExpectations(t)
+}
+
+func TestImpersonationCachedRouteNotSuperAdmin(t *testing.T) {
+	useAuditLogger(t)
+	managerParams := mocks.NewClaimsManagerParams(t).WithUserQueryMocked(t, test.NewUser(101, 1001))
+	ctx := manager.WithRequest(context.Background(), manager.Request{Method: "POST"})
+
+	// Checked before anything else, so that no deny an act-as request gets on a cached route is ever cached.
+	_, err := startImpersonation(ctx, managerParams.BuildClaimsManager(), "N:user:target", "POST", "POST /datasets")
+	assert.Equal(t, authorizers.ReasonUncachedAuthorizerRequired, authorizers.DenyReason(err))
+	assert.True(t, authorizers.Uncacheable(err))
+}
+
+func TestImpersonationWrites(t *testing.T) {
+	target := test.NewUser(202, 2002)
+
+	t.Run("denied by default", func(t *testing.T) {
+		recorder := useAuditLogger(t)
+		managerParams := mocks.NewClaimsManagerParams(t).WithUserQueryMocked(t, newSuperAdmin())
+
+		_, err := startImpersonation(uncachedContext(), managerParams.BuildClaimsManager(), target.NodeId, "POST", "POST /datasets")
+		assert.ErrorContains(t, err, "POST requests are not permitted")
+		assert.Equal(t, authorizers.ReasonImpersonationNotAllowed, authorizers.DenyReason(err))
+		require.Len(t, recorder.Records, 1)
+		assert.Equal(t, audit.OutcomeDenied, recorder.Records[0].Outcome)
+		managerParams.AssertMockExpectations(t)
+	})
+
+	t.Run("allowed when enabled", func(t *testing.T) {
+		appConfig.ImpersonationAllowWrites = true
+		t.Cleanup(func() { appConfig.ImpersonationAllowWrites = false })
+		useAuditLogger(t)
+		managerParams := mocks.NewClaimsManagerParams(t).WithUserQueryMocked(t, newSuperAdmin())
+		managerParams.MockPennsievePg.OnGetUserByNodeId(target.NodeId).Return(target, nil)
+
+		_, err := startImpersonation(uncachedContext(), managerParams.BuildClaimsManager(), target.NodeId, "POST", "POST /datasets")
+		assert.NoError(t, err)
+		managerParams.AssertMockExpectations(t)
+	})
+}
+
+func TestImpersonationTargetLookup(t *testing.T) {
+	for scenario, params := range map[string]struct {
+		lookupErr             error
+		expectedIndeterminate bool
+		expectedReason        string
+	}{
+		"target not found":     {sql.ErrNoRows, false, authorizers.ReasonUnknownUser},
+		"target lookup failed": {errors.New("connection refused"), true, ""},
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			recorder := useAuditLogger(t)
+			managerParams := mocks.NewClaimsManagerParams(t).WithUserQueryMocked(t, newSuperAdmin())
+			managerParams.MockPennsievePg.OnGetUserByNodeId("N:user:target").Return((*pgdb.User)(nil), params.lookupErr)
+
+			_, err := startImpersonation(uncachedContext(), managerParams.BuildClaimsManager(), "N:user:target", "GET", "GET /datasets")
+			require.Error(t, err)
+			assert.Equal(t, params.expectedIndeterminate, isIndeterminate(err))
+			assert.Equal(t, params.expectedReason, authorizers.DenyReason(err))
+			require.Len(t, recorder.Records, 1)
+			assert.Equal(t, audit.OutcomeDenied, recorder.Records[0].Outcome)
+			managerParams.AssertMockExpectations(t)
+		})
+	}
+}
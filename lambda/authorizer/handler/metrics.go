@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"github.com/pennsieve/pennsieve-go-api/authorizer/authorizers"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/metrics"
+)
+
+// Reason codes recorded for denies that happen before any authorizer runs, or that carry no DenyError.
+const (
+	reasonMissingToken = "missing_token"
+	reasonInvalidToken = "invalid_token"
+	reasonNoAuthorizer = "no_authorizer"
+	reasonDenied       = "denied"
+)
+
+// authorizerTypeNone is recorded for decisions made before an authorizer was chosen.
+const authorizerTypeNone = "none"
+
+// metricsRecorder receives decision and dependency latency metrics.
+var metricsRecorder metrics.Metrics = metrics.NewEMFFromEnv()
+
+// recordDecision counts the decision for a request handled by authorizerType: an allow if err is
+// nil, otherwise an indeterminate result or a deny with err's reason code.
+func recordDecision(authorizerType string, err error) {
+	switch {
+	case err == nil:
+		metrics.Decision(metricsRecorder, authorizerType, metrics.OutcomeAllow, "")
+	case isIndeterminate(err):
+		recordIndeterminate(authorizerType)
+	default:
+		reason := authorizers.DenyReason(err)
+		if reason == "" {
+			reason = reasonDenied
+		}
+		recordDeny(authorizerType, reason)
+	}
+}
+
+func recordDeny(authorizerType string, reason string) {
+	metrics.Decision(metricsRecorder, authorizerType, metrics.OutcomeDeny, reason)
+}
+
+func recordIndeterminate(authorizerType string) {
+	metrics.Decision(metricsRecorder, authorizerType, metrics.OutcomeIndeterminate, "")
+}
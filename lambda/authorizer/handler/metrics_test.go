@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/pennsieve/pennsieve-go-api/authorizer/authorizers"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/metrics"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/test/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func useMetrics(t *testing.T) *mocks.Metrics {
+	recorder := mocks.NewMetrics()
+	original := metricsRecorder
+	metricsRecorder = recorder
+	t.Cleanup(func() { metricsRecorder = original })
+	return recorder
+}
+
+func TestRecordDecision(t *testing.T) {
+	for scenario, params := range map[string]struct {
+		err             error
+		expectedOutcome string
+		expectedReason  string
+	}{
+		"allow":         {nil, metrics.OutcomeAllow, ""},
+		"plain deny":    {errors.New("user has no access to dataset"), metrics.OutcomeDeny, reasonDenied},
+		"reasoned deny": {authorizers.NewDenyError(authorizers.ReasonThrottled, errors.New("slow down")), metrics.OutcomeDeny, authorizers.ReasonThrottled},
+		"indeterminate": {authorizers.NewIndeterminateError(errors.New("connection refused")), metrics.OutcomeIndeterminate, ""},
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			recorder := useMetrics(t)
+			recordDecision("dataset", params.err)
+			assert.Equal(t, 1, recorder.CountOf("Decisions", metrics.Dimensions{
+				"AuthorizerType": "dataset",
+				"Outcome":        params.expectedOutcome,
+				"Reason":         params.expectedReason,
+			}))
+		})
+	}
+}
+
+func TestDirectHandlerRecordsDecision(t *testing.T) {
+	recorder := useMetrics(t)
+
+	_, err := DirectHandler(context.Background(), DirectAuthorizeRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, recorder.CountOf("Decisions", metrics.Dimensions{
+		"AuthorizerType": directAuthorizerType,
+		"Outcome":        metrics.OutcomeDeny,
+		"Reason":         reasonDenied,
+	}))
+}
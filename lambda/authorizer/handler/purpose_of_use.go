@@ -0,0 +1,20 @@
+package handler
+
+import (
+	"context"
+
+	claimsClient "github.com/pennsieve/pennsieve-go-api/authorizer/claims"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
+)
+
+// purposeOfUseHeader is the header a client sets to the purpose of use of a request, e.g. "research". API Gateway
+// delivers header names lower-cased.
+const purposeOfUseHeader = "x-purpose-of-use"
+
+// addPurposeOfUse passes the purpose of use the request in ctx declared, if any, to downstream services in the
+// claims context.
+func addPurposeOfUse(ctx context.Context, claims map[string]interface{}) {
+	if request, ok := manager.RequestFromContext(ctx); ok && request.PurposeOfUse != "" && claims != nil {
+		claims[claimsClient.LabelPurposeOfUse] = request.PurposeOfUse
+	}
+}
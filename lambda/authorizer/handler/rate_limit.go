@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/authorizers"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/deadline"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/pipeline"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/ratelimit"
+	log "github.com/sirupsen/logrus"
+)
+
+// rateLimiter throttles uncached authorizations per principal. Nil disables rate limiting.
+var rateLimiter *ratelimit.Limiter
+
+// newRateLimiter returns a Limiter on RATE_LIMIT_TABLE, or nil if rate limiting is not configured.
+func newRateLimiter() *ratelimit.Limiter {
+	if !appConfig.RateLimitEnabled || appConfig.RateLimitTable == "" {
+		return nil
+	}
+	cfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		log.WithError(err).Error("rate limiting disabled: unable to load AWS config")
+		return nil
+	}
+	return ratelimit.NewLimiter(ratelimit.NewDynamoStore(dynamodb.NewFromConfig(cfg), appConfig.RateLimitTable), appConfig.RateLimit)
+}
+
+// checkRateLimit denies an uncached request once principal has used up its bucket, and allows it if the store fails.
+func checkRateLimit(ctx context.Context, logger *log.Entry, principal ratelimit.Principal, orgNodeId string, scope string) error {
+	if rateLimiter == nil {
+		return nil
+	}
+	if request, _ := manager.RequestFromContext(ctx); !request.Uncached {
+		return nil
+	}
+	limitCtx, cancel := deadline.Bound(ctx, appConfig.Deadlines.DynamoDB)
+	defer cancel()
+	allowed, err := rateLimiter.Allow(limitCtx, principal, orgNodeId, scope)
+	if err != nil {
+		logger.WithError(err).Warn("rate limit check failed, allowing request")
+		return nil
+	}
+	if !allowed {
+		logger.WithFields(log.Fields{
+			"principal":      principal.String(),
+			"scope":          scope,
+			"throttledTotal": rateLimiter.Throttled(),
+		}).Warn("request throttled")
+		return authorizers.NewDenyError(authorizers.ReasonThrottled, fmt.Errorf("rate limit exceeded for %s", principal))
+	}
+	return nil
+}
+
+// jwtPrincipal returns the principal rate limited for token: the API token, or the user it was issued to.
+func jwtPrincipal(token jwt.Token) ratelimit.Principal {
+	username, _ := token.Get("username")
+	if clientId, _ := token.Get("client_id"); clientId == appConfig.TokenClientId {
+		return ratelimit.Principal{Kind: ratelimit.KindToken, Id: fmt.Sprint(username)}
+	}
+	return ratelimit.Principal{Kind: ratelimit.KindUser, Id: fmt.Sprint(username)}
+}
+
+// jwtOrgNodeId returns the organization whose bucket applies, and whether it is known before claims are resolved.
+func jwtOrgNodeId(token jwt.Token, resource pipeline.Resource) (string, bool) {
+	if resource.OrganizationNodeId != "" {
+		return resource.OrganizationNodeId, true
+	}
+	if orgNodeId, hasKey := token.Get("custom:organization_node_id"); hasKey {
+		return fmt.Sprint(orgNodeId), true
+	}
+	return "", resource == pipeline.Resource{}
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/authorizers"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/pipeline"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/ratelimit"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/test"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func useRateLimiter(t *testing.T, config ratelimit.Config) *ratelimit.Limiter {
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), config)
+	original := rateLimiter
+	rateLimiter = limiter
+	t.Cleanup(func() { rateLimiter = original })
+	return limiter
+}
+
+func TestCheckRateLimit(t *testing.T) {
+	limiter := useRateLimiter(t, ratelimit.Config{Default: ratelimit.Limit{Burst: 1, PerSecond: 0.001}})
+	principal := ratelimit.Principal{Kind: ratelimit.KindCallback, Id: "workflow-service"}
+	logger := log.WithField("test", t.Name())
+
+	assert.NoError(t, checkRateLimit(uncachedContext(), logger, principal, "N:organization:1", "callback"))
+
+	err := checkRateLimit(uncachedContext(), logger, principal, "N:organization:1", "callback")
+	assert.Equal(t, authorizers.ReasonThrottled, authorizers.DenyReason(err))
+	assert.False(t, isIndeterminate(err))
+	assert.False(t, authorizers.Uncacheable(err))
+	assert.Equal(t, int64(1), limiter.Throttled())
+
+	// API Gateway's cache absorbs repeats on cached routes, which are not limited.
+	assert.NoError(t, checkRateLimit(context.Background(), logger, principal, "N:organization:1", "callback"))
+	assert.Equal(t, int64(1), limiter.Throttled())
+}
+
+func TestCheckRateLimitDisabled(t *testing.T) {
+	original := rateLimiter
+	rateLimiter = nil
+	t.Cleanup(func() { rateLimiter = original })
+
+	principal := ratelimit.Principal{Kind: ratelimit.KindUser, Id: "user-1"}
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, checkRateLimit(uncachedContext(), log.WithField("test", t.Name()), principal, "", "user"))
+	}
+}
+
+func TestJwtPrincipal(t *testing.T) {
+	userToken := test.NewJWTBuilder().Build(t)
+	apiToken := test.NewJWTBuilder().WithWorkspace(1001, "N:organization:1").Build(t)
+	originalClientID := appConfig.TokenClientId
+	appConfig.TokenClientId = apiToken.ClientId
+	t.Cleanup(func() { appConfig.TokenClientId = originalClientID })
+
+	assert.Equal(t, ratelimit.Principal{Kind: ratelimit.KindUser, Id: userToken.Username}, jwtPrincipal(userToken.Token))
+	assert.Equal(t, ratelimit.Principal{Kind: ratelimit.KindToken, Id: apiToken.Username}, jwtPrincipal(apiToken.Token))
+}
+
+func TestJwtOrgNodeId(t *testing.T) {
+	userToken := test.NewJWTBuilder().Build(t)
+	apiToken := test.NewJWTBuilder().WithWorkspace(1001, "N:organization:1").Build(t)
+
+	orgNodeId := func(token jwt.Token, resource pipeline.Resource) []any {
+		orgNodeId, known := jwtOrgNodeId(token, resource)
+		return []any{orgNodeId, known}
+	}
+	assert.Equal(t, []any{"", true}, orgNodeId(userToken.Token, pipeline.Resource{}))
+	assert.Equal(t, []any{"", false}, orgNodeId(userToken.Token, pipeline.Resource{DatasetNodeId: "N:dataset:1"}))
+	assert.Equal(t, []any{"", false}, orgNodeId(userToken.Token, pipeline.Resource{ManifestId: "manifest-1"}))
+	assert.Equal(t, []any{"N:organization:2", true}, orgNodeId(userToken.Token, pipeline.Resource{OrganizationNodeId: "N:organization:2"}))
+	assert.Equal(t, []any{"N:organization:1", true}, orgNodeId(apiToken.Token, pipeline.Resource{DatasetNodeId: "N:dataset:1"}))
+	assert.Equal(t, []any{"N:organization:2", true}, orgNodeId(apiToken.Token, pipeline.Resource{OrganizationNodeId: "N:organization:2"}))
+}
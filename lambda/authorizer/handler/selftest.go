@@ -0,0 +1,237 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/config"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
+	"github.com/pennsieve/pennsieve-go-core/pkg/queries/pgdb"
+	log "github.com/sirupsen/logrus"
+)
+
+// selfTestTimeout bounds each self-test check, so that one unreachable dependency cannot use up the invocation.
+const selfTestTimeout = 5 * time.Second
+
+// SelfTestRequest is the payload of a self-test invocation, e.g.
+//
+//	aws lambda invoke --function-name <authorizer> --payload '{"selfTest": true}' out.json
+type SelfTestRequest struct {
+	SelfTest bool `json:"selfTest"`
+}
+
+// SelfTestReport is the response to a self-test invocation. Ok is true only if the configuration, optional settings
+// included, is valid and every check passed.
+type SelfTestReport struct {
+	Binary       config.Binary   `json:"binary"`
+	Ok           bool            `json:"ok"`
+	ConfigErrors []string        `json:"configErrors,omitempty"`
+	Checks       []SelfTestCheck `json:"checks"`
+}
+
+// SelfTestCheck is the result of checking that a single dependency is reachable.
+type SelfTestCheck struct {
+	Name      string `json:"name"`
+	Ok        bool   `json:"ok"`
+	Error     string `json:"error,omitempty"`
+	ElapsedMs int64  `json:"elapsedMs"`
+}
+
+// selfTestProbe checks a single dependency.
+type selfTestProbe struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// WithSelfTest validates the configuration for binary and wraps handle, the binary's Lambda handler, so that a
+// {"selfTest": true} invocation runs a self-test instead. Configuration errors are logged once here; while they
+// persist every other invocation fails with an error (an uncached 500) instead of being denied. Malformed optional
+// settings are only logged, and their defaults used.
+func WithSelfTest[Request any, Response any](binary config.Binary, handle func(context.Context, Request) (Response, error)) func(context.Context, json.RawMessage) (interface{}, error) {
+	configErr = appConfig.Validate(binary)
+	if configErr != nil {
+		log.WithError(configErr).WithField("binary", binary).Error("invalid configuration")
+	}
+	if err := appConfig.SettingErrors(); err != nil {
+		log.WithError(err).WithField("binary", binary).Warn("invalid optional settings, using their defaults")
+	}
+
+	return func(ctx context.Context, payload json.RawMessage) (interface{}, error) {
+		var selfTest SelfTestRequest
+		if err := json.Unmarshal(payload, &selfTest); err == nil && selfTest.SelfTest {
+			return runSelfTest(ctx, binary, selfTestProbes(binary)), nil
+		}
+
+		var request Request
+		if err := json.Unmarshal(payload, &request); err != nil {
+			return nil, fmt.Errorf("unable to parse request: %w", err)
+		}
+		if configErr != nil {
+			recordIndeterminate(authorizerTypeNone)
+			var response Response
+			return response, fmt.Errorf("invalid configuration: %w", configErr)
+		}
+		return handle(ctx, request)
+	}
+}
+
+// runSelfTest reports the configuration errors for binary and runs each probe.
+func runSelfTest(ctx context.Context, binary config.Binary, probes []selfTestProbe) SelfTestReport {
+	report := SelfTestReport{Binary: binary, Ok: true, Checks: []SelfTestCheck{}}
+	for _, err := range []error{appConfig.Validate(binary), appConfig.SettingErrors()} {
+		if err != nil {
+			report.Ok = false
+			report.ConfigErrors = append(report.ConfigErrors, configErrors(err)...)
+		}
+	}
+
+	for _, probe := range probes {
+		checkCtx, cancel := context.WithTimeout(ctx, selfTestTimeout)
+		start := time.Now()
+		err := probe.check(checkCtx)
+		cancel()
+
+		check := SelfTestCheck{Name: probe.name, Ok: err == nil, ElapsedMs: time.Since(start).Milliseconds()}
+		if err != nil {
+			check.Error = err.Error()
+			report.Ok = false
+		}
+		report.Checks = append(report.Checks, check)
+	}
+
+	log.WithField("selfTest", report).Info("self-test complete")
+	return report
+}
+
+// configErrors splits an error returned by config.Validate or SettingErrors into one message per problem.
+func configErrors(err error) []string {
+	var joined interface{ Unwrap() []error }
+	if !errors.As(err, &joined) {
+		return []string{err.Error()}
+	}
+	var messages []string
+	for _, problem := range joined.Unwrap() {
+		messages = append(messages, problem.Error())
+	}
+	return messages
+}
+
+// selfTestProbes returns the dependency checks for binary: the Cognito key sets, Postgres and the tables it must have,
+// DynamoDB tables, and each Lambda the binary invokes.
+func selfTestProbes(binary config.Binary) []selfTestProbe {
+	probes := []selfTestProbe{{name: "postgres", check: checkPostgres}, {name: "postgres:schema", check: checkSchema}}
+	if appConfig.BlockListTable != "" {
+		probes = append(probes, selfTestProbe{name: "dynamodb:BLOCK_LIST_TABLE", check: checkTable(appConfig.BlockListTable)})
+	}
+	if binary == config.BinaryDirect {
+		return probes
+	}
+
+	probes = append(probes,
+		selfTestProbe{name: "jwks:USER_POOL", check: checkJWKS(appConfig.JWKSURL(appConfig.UserPoolId))},
+		selfTestProbe{name: "jwks:TOKEN_POOL", check: checkJWKS(appConfig.JWKSURL(appConfig.TokenPoolId))},
+		selfTestProbe{name: "dynamodb:MANIFEST_TABLE", check: checkTable(appConfig.ManifestTable)},
+	)
+	if appConfig.RateLimitEnabled {
+		probes = append(probes, selfTestProbe{name: "dynamodb:RATE_LIMIT_TABLE", check: checkTable(appConfig.RateLimitTable)})
+	}
+	if binary == config.BinaryHTTP && appConfig.BreakGlassGroup != "" {
+		probes = append(probes, selfTestProbe{name: "dynamodb:BREAK_GLASS_TABLE", check: checkTable(appConfig.BreakGlassTable)})
+	}
+
+	switch binary {
+	case config.BinaryHTTP:
+		validators := appConfig.Validators()
+		names := make([]string, 0, len(validators))
+		for name := range validators {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			probes = append(probes, selfTestProbe{name: "lambda:" + name, check: checkLambda(validators[name])})
+		}
+	case config.BinaryWebSocket:
+		if appConfig.CheckAccessLambdaName != "" {
+			probes = append(probes, selfTestProbe{name: "lambda:CHECK_ACCESS_LAMBDA_NAME", check: checkLambda(appConfig.CheckAccessLambdaName)})
+		}
+	}
+	return probes
+}
+
+func checkPostgres(ctx context.Context) error {
+	db, err := pgdb.ConnectRDS()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.PingContext(ctx)
+}
+
+// checkSchema runs the lookups of the tables the platform's migrations add, as configured, so that a table they
+// need and lack fails the self-test instead of every request for an organization's resources.
+func checkSchema(ctx context.Context) error {
+	db, err := pgdb.ConnectRDS()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	queries := manager.NewQueries(db, 0).WithOptionalAuthPolicies(appConfig.AuthPoliciesOptional).
+		WithOptionalIpAllowlists(appConfig.IpAllowlistsOptional)
+	if _, err := queries.GetOrganizationIpAllowlist(ctx, 0); err != nil {
+		return fmt.Errorf("organization IP allowlists: %w", err)
+	}
+	if _, err := queries.GetOrganizationAuthPolicy(ctx, 0); err != nil {
+		return fmt.Errorf("organization authentication policies: %w", err)
+	}
+	return nil
+}
+
+func checkJWKS(url string) func(context.Context) error {
+	return func(ctx context.Context) error {
+		_, err := jwk.Fetch(ctx, url)
+		return err
+	}
+}
+
+func checkTable(table string) func(context.Context) error {
+	return func(ctx context.Context) error {
+		if table == "" {
+			return errors.New("not configured")
+		}
+		cfg, err := awsconfig.LoadDefaultConfig(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = dynamodb.NewFromConfig(cfg).DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)})
+		return err
+	}
+}
+
+// checkLambda makes a DryRun invocation, which verifies that the function exists and that this Lambda may invoke
+// it, without running it.
+func checkLambda(function string) func(context.Context) error {
+	return func(ctx context.Context) error {
+		if function == "" {
+			return errors.New("not configured")
+		}
+		cfg, err := awsconfig.LoadDefaultConfig(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = lambda.NewFromConfig(cfg).Invoke(ctx, &lambda.InvokeInput{
+			FunctionName:   aws.String(function),
+			InvocationType: lambdatypes.InvocationTypeDryRun,
+		})
+		return err
+	}
+}
@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pennsieve/pennsieve-go-api/authorizer/config"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useConfig replaces appConfig for the duration of the test.
+func useConfig(t *testing.T, c *config.Config) *config.Config {
+	originalConfig, originalErr := appConfig, configErr
+	appConfig = c
+	t.Cleanup(func() { appConfig, configErr = originalConfig, originalErr })
+	return c
+}
+
+func validConfig() *config.Config {
+	return &config.Config{
+		Env:                   "DOCKER",
+		Region:                "us-east-1",
+		UserPoolId:            "us-east-1_user",
+		UserClientId:          "user-client",
+		TokenPoolId:           "us-east-1_token",
+		TokenClientId:         "token-client",
+		ManifestTable:         "manifests",
+		CheckAccessLambdaName: "check-access",
+		CallbackValidators: map[string]string{
+			"WORKFLOW_SERVICE": "arn:aws:lambda:us-east-1:123:function:workflow-validator",
+		},
+	}
+}
+
+type echoRequest struct {
+	Value string `json:"value"`
+}
+
+func echo(_ context.Context, request echoRequest) (string, error) {
+	return request.Value, nil
+}
+
+func TestWithSelfTestPassesRequestsThrough(t *testing.T) {
+	useConfig(t, validConfig())
+
+	handle := WithSelfTest(config.BinaryHTTP, echo)
+	response, err := handle(context.Background(), json.RawMessage(`{"value":"hello"}`))
+
+	require.NoError(t, err)
+	assert.Equal(t, "hello", response)
+}
+
+func TestWithSelfTestFailsRequestsWhenMisconfigured(t *testing.T) {
+	recorder := useMetrics(t)
+	c := useConfig(t, validConfig())
+	c.UserPoolId = ""
+
+	handle := WithSelfTest(config.BinaryHTTP, echo)
+	_, err := handle(context.Background(), json.RawMessage(`{"value":"hello"}`))
+
+	assert.ErrorContains(t, err, "invalid configuration: USER_POOL is not set")
+	assert.Equal(t, 1, recorder.CountOf("Decisions", metrics.Dimensions{
+		"AuthorizerType": authorizerTypeNone,
+		"Outcome":        metrics.OutcomeIndeterminate,
+		"Reason":         "",
+	}))
+}
+
+func TestWithSelfTestFallsBackOnInvalidOptionalSettings(t *testing.T) {
+	t.Setenv("ENV", "DOCKER")
+	t.Setenv("AUTHORIZER_RESULT_TTL", "5m")
+	c := useConfig(t, config.FromEnv())
+
+	handle := WithSelfTest(config.BinaryDirect, echo)
+	response, err := handle(context.Background(), json.RawMessage(`{"value":"hello"}`))
+
+	require.NoError(t, err)
+	assert.Equal(t, "hello", response)
+	assert.Equal(t, 300*time.Second, c.ResultTtl)
+
+	report := runSelfTest(context.Background(), config.BinaryDirect, nil)
+	assert.False(t, report.Ok)
+	assert.Equal(t, []string{`invalid AUTHORIZER_RESULT_TTL: "5m" is not a number of seconds`}, report.ConfigErrors)
+}
+
+func TestRunSelfTest(t *testing.T) {
+	c := useConfig(t, validConfig())
+	c.ManifestTable = ""
+	c.TokenClientId = ""
+
+	report := runSelfTest(context.Background(), config.BinaryHTTP, []selfTestProbe{
+		{name: "reachable", check: func(context.Context) error { return nil }},
+		{name: "unreachable", check: func(context.Context) error { return errors.New("connection refused") }},
+	})
+
+	assert.False(t, report.Ok)
+	assert.Equal(t, config.BinaryHTTP, report.Binary)
+	assert.Equal(t, []string{"TOKEN_CLIENT is not set", "MANIFEST_TABLE is not set"}, report.ConfigErrors)
+	require.Len(t, report.Checks, 2)
+	assert.Equal(t, SelfTestCheck{Name: "reachable", Ok: true, ElapsedMs: report.Checks[0].ElapsedMs}, report.Checks[0])
+	assert.Equal(t, "unreachable", report.Checks[1].Name)
+	assert.False(t, report.Checks[1].Ok)
+	assert.Equal(t, "connection refused", report.Checks[1].Error)
+}
+
+func TestRunSelfTestOk(t *testing.T) {
+	useConfig(t, validConfig())
+
+	report := runSelfTest(context.Background(), config.BinaryDirect, []selfTestProbe{
+		{name: "reachable", check: func(context.Context) error { return nil }},
+	})
+
+	assert.True(t, report.Ok)
+	assert.Empty(t, report.ConfigErrors)
+}
+
+func TestSelfTestProbes(t *testing.T) {
+	names := func(probes []selfTestProbe) []string {
+		var names []string
+		for _, probe := range probes {
+			names = append(names, probe.name)
+		}
+		return names
+	}
+
+	c := useConfig(t, validConfig())
+	assert.Equal(t, []string{"postgres", "postgres:schema"}, names(selfTestProbes(config.BinaryDirect)))
+	assert.Equal(t, []string{
+		"postgres", "postgres:schema", "jwks:USER_POOL", "jwks:TOKEN_POOL", "dynamodb:MANIFEST_TABLE", "lambda:CALLBACK_VALIDATOR_WORKFLOW_SERVICE",
+	}, names(selfTestProbes(config.BinaryHTTP)))
+	assert.Equal(t, []string{
+		"postgres", "postgres:schema", "jwks:USER_POOL", "jwks:TOKEN_POOL", "dynamodb:MANIFEST_TABLE", "lambda:CHECK_ACCESS_LAMBDA_NAME",
+	}, names(selfTestProbes(config.BinaryWebSocket)))
+
+	c.CheckAccessLambdaName = ""
+	assert.NotContains(t, names(selfTestProbes(config.BinaryWebSocket)), "lambda:CHECK_ACCESS_LAMBDA_NAME")
+
+	c.RateLimitEnabled = true
+	assert.Contains(t, names(selfTestProbes(config.BinaryWebSocket)), "dynamodb:RATE_LIMIT_TABLE")
+
+	c.BreakGlassGroup, c.BreakGlassTable = "pennsieve-operators", "break-glass"
+	assert.Contains(t, names(selfTestProbes(config.BinaryHTTP)), "dynamodb:BREAK_GLASS_TABLE")
+	assert.NotContains(t, names(selfTestProbes(config.BinaryWebSocket)), "dynamodb:BREAK_GLASS_TABLE")
+
+	c.BlockListTable = "block-list"
+	assert.Equal(t, []string{"postgres", "postgres:schema", "dynamodb:BLOCK_LIST_TABLE"}, names(selfTestProbes(config.BinaryDirect)))
+}
+
+func TestWithSelfTestInvocation(t *testing.T) {
+	c := useConfig(t, validConfig())
+	// Nothing is reachable from the test; only the shape of the response matters here.
+	c.CallbackValidators = map[string]string{}
+
+	handle := WithSelfTest(config.BinaryDirect, echo)
+	response, err := handle(context.Background(), json.RawMessage(`{"selfTest":true}`))
+
+	require.NoError(t, err)
+	report, ok := response.(SelfTestReport)
+	require.True(t, ok)
+	assert.Equal(t, config.BinaryDirect, report.Binary)
+	require.Len(t, report.Checks, 2)
+	assert.Equal(t, "postgres", report.Checks[0].Name)
+	assert.Equal(t, "postgres:schema", report.Checks[1].Name)
+}
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/metrics"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/pipeline"
+	log "github.com/sirupsen/logrus"
+)
+
+// lastKnownGood keeps the claims last resolved for each principal and resource, to be served to read-only HTTP
+// requests while Postgres is unavailable. Nil unless STALE_CLAIMS_WINDOW is set.
+var lastKnownGood *pipeline.LastKnownGood
+
+// withLastKnownGood applies lastKnownGood to the outcome of resolving the claims of principal for resource (see
+// pipeline.LastKnownGood.Apply), and logs and counts claims served stale for requests of the given scope.
+func withLastKnownGood(ctx context.Context, logger *log.Entry, scope string, principal string, resource pipeline.Resource, claims map[string]interface{}, err error) (map[string]interface{}, error) {
+	served, stale, servedErr := lastKnownGood.Apply(ctx, principal, resource, claims, err)
+	if stale {
+		logger.WithError(err).Warn("claims unavailable, serving last known good claims")
+		metrics.StaleClaims(metricsRecorder, scope)
+	}
+	return served, servedErr
+}
+
+// tokenPrincipal identifies the principal authenticated by a Cognito token without looking it up: the user or the
+// API token by its Cognito username. It is empty if the token has no username.
+func tokenPrincipal(token jwt.Token) string {
+	username, hasKey := token.Get("username")
+	if !hasKey {
+		return ""
+	}
+	if clientId, _ := token.Get("client_id"); clientId == appConfig.TokenClientId {
+		return fmt.Sprintf("token:%v", username)
+	}
+	return fmt.Sprintf("user:%v", username)
+}
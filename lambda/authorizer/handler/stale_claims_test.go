@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/authorizers"
+	claimsClient "github.com/pennsieve/pennsieve-go-api/authorizer/claims"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/metrics"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/pipeline"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithLastKnownGood(t *testing.T) {
+	recorder := useMetrics(t)
+	original := lastKnownGood
+	lastKnownGood = pipeline.NewLastKnownGood(time.Minute)
+	t.Cleanup(func() { lastKnownGood = original })
+	ctx := manager.WithRequest(context.Background(), manager.Request{Method: "GET", Uncached: true})
+	logger := log.WithField("test", t.Name())
+	resource := pipeline.Resource{DatasetNodeId: "N:dataset:1"}
+
+	_, err := withLastKnownGood(ctx, logger, "dataset", "user:abc", resource, map[string]interface{}{}, nil)
+	require.NoError(t, err)
+	claims, err := withLastKnownGood(ctx, logger, "dataset", "user:abc", resource, nil, authorizers.NewIndeterminateError(errors.New("connection refused")))
+	require.NoError(t, err)
+
+	assert.Equal(t, true, claims[claimsClient.LabelStale])
+	assert.Equal(t, 1, recorder.CountOf("StaleClaimsServed", metrics.Dimensions{"AuthorizerType": "dataset"}))
+}
+
+func TestTokenPrincipal(t *testing.T) {
+	userToken, err := jwt.NewBuilder().Claim("username", "abc").Claim("client_id", "user-client").Build()
+	require.NoError(t, err)
+	apiToken, err := jwt.NewBuilder().Claim("username", "abc").Claim("client_id", appConfig.TokenClientId).Build()
+	require.NoError(t, err)
+
+	assert.Equal(t, "user:abc", tokenPrincipal(userToken))
+	assert.Equal(t, "token:abc", tokenPrincipal(apiToken))
+	assert.Empty(t, tokenPrincipal(jwt.New()))
+}
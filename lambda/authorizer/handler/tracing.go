@@ -0,0 +1,15 @@
+package handler
+
+import (
+	"context"
+
+	claimsClient "github.com/pennsieve/pennsieve-go-api/authorizer/claims"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/tracing"
+)
+
+// addTraceId exposes the trace of ctx to downstream services in the claims context.
+func addTraceId(ctx context.Context, claims map[string]interface{}) {
+	if traceId := tracing.TraceId(ctx); traceId != "" && claims != nil {
+		claims[claimsClient.LabelTraceId] = traceId
+	}
+}
@@ -4,18 +4,18 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/pennsieve/pennsieve-go-api/authorizer/authorizers"
+	claimsClient "github.com/pennsieve/pennsieve-go-api/authorizer/claims"
 	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/pipeline"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/tracing"
 	coreAuthorizer "github.com/pennsieve/pennsieve-go-core/pkg/authorizer"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/dataset"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/organization"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/user"
-	"github.com/pennsieve/pennsieve-go-core/pkg/queries/dydb"
 	"github.com/pennsieve/pennsieve-go-core/pkg/queries/pgdb"
 	log "github.com/sirupsen/logrus"
 )
@@ -45,13 +45,14 @@ import (
 //     uses — single source of truth for "what is a valid Pennsieve JWT."
 //
 //  3. Resolves the Cognito sub to a Pennsieve user node ID via the SAME
-//     `ClaimsManager.GetCurrentUser` the HTTP authorizer uses. This is the
+//     claims pipeline (`pipeline.Resolve`) the HTTP authorizer uses. This is the
 //     non-trivial bit that consumers cannot reproduce without postgres access:
 //     the JWT's `sub`/`username` is the Cognito ID, and the Pennsieve user
 //     `node_id` is a separate UUID linked via `users.cognito_id`.
 //
 //  4. If `datasetId` is present in the query string, runs the same dataset role
-//     check the HTTP `DatasetAuthorizer` runs. Refuses with role.None.
+//     check the HTTP `DatasetAuthorizer` runs. Refuses with role.None, and
+//     refuses if `orgId` is also present and does not own the dataset.
 //
 //  5. If `computeNodeId` is present in the query string (and we have a resolved
 //     org node ID from the claims), invokes account-service's check-access
@@ -88,7 +89,8 @@ import (
 //
 // Missing/invalid parameters produce a Deny policy with a `errorReason` context
 // field so callers can log the cause without exposing it to the WebSocket
-// client (the client only sees a 401 / 403 from API Gateway).
+// client (the client only sees a 401 / 403 from API Gateway). Indeterminate
+// failures (DB errors, timeouts) return an error, i.e. a 500, instead.
 func WebSocketHandler(ctx context.Context, event events.APIGatewayCustomAuthorizerRequestTypeRequest) (events.APIGatewayCustomAuthorizerResponse, error) {
 	logger := log.WithFields(log.Fields{
 		"methodArn":             event.MethodArn,
@@ -96,56 +98,112 @@ func WebSocketHandler(ctx context.Context, event events.APIGatewayCustomAuthoriz
 	})
 	logger.Info("WebSocket REQUEST authorizer invoked")
 
+	ctx = tracing.Extract(ctx, event.Headers)
+	defer tracing.Flush(ctx)
+	ctx, span := tracing.Tracer().Start(ctx, "AuthorizeWebSocket")
+	defer span.End()
+	ctx, cancel := withBudget(ctx)
+	defer cancel()
+
 	token := event.QueryStringParameters["token"]
 	if token == "" {
 		logger.Warn("rejecting — missing token query parameter")
+		recordDeny(authorizerTypeNone, reasonMissingToken)
 		return denyResponse(event.MethodArn, "missing_token"), nil
 	}
 
+	_, jwtSpan := tracing.Tracer().Start(ctx, "ValidateJWT")
 	jwtToken, err := validateCognitoJWT([]byte(token))
+	tracing.End(jwtSpan, err)
 	if err != nil {
 		logger.WithError(err).Warn("rejecting — JWT invalid")
+		recordDeny(authorizerTypeNone, reasonInvalidToken)
 		return denyResponse(event.MethodArn, "invalid_token"), nil
 	}
 
+	// The resource is named by the identity sources the client supplied.
+	// chat-service always sends datasetId → DatasetAuthorizer (most restrictive,
+	// also resolves user + org claims as a side effect). Other future WS
+	// services might send only orgId or just the token. When both datasetId and
+	// orgId are sent, the dataset must belong to that organization.
+	resource := pipeline.Resource{
+		DatasetNodeId:      event.QueryStringParameters["datasetId"],
+		OrganizationNodeId: event.QueryStringParameters["orgId"],
+	}
+	scope := resource.Scope()
+
+	purposeOfUse, err := authorizers.ParsePurposeOfUse(event.Headers[purposeOfUseHeader])
+	if err != nil {
+		logger.WithError(err).Warn("rejecting — invalid purpose of use")
+		recordDeny(authorizerTypeNone, authorizers.ReasonInvalidPurposeOfUse)
+		return denyResponse(event.MethodArn, authorizers.ReasonInvalidPurposeOfUse), nil
+	}
+	if purposeOfUse != "" {
+		logger = logger.WithField("purposeOfUse", purposeOfUse)
+	}
+	// API Gateway does not cache WebSocket authorizer decisions.
+	ctx = manager.WithRequest(ctx, manager.Request{
+		SourceIp:     event.RequestContext.Identity.SourceIP,
+		Method:       event.HTTPMethod,
+		PurposeOfUse: purposeOfUse,
+		Uncached:     true,
+	})
+
+	// Throttled before any lookup where the organization is known, so that a throttled client costs no Postgres queries.
+	orgNodeId, orgKnown := jwtOrgNodeId(jwtToken, resource)
+	if orgKnown {
+		if err := checkRateLimit(ctx, logger, jwtPrincipal(jwtToken), orgNodeId, scope); err != nil {
+			recordDecision(scope, err)
+			return denyResponse(event.MethodArn, authorizers.ReasonThrottled), nil
+		}
+	}
+
 	db, err := pgdb.ConnectRDS()
 	if err != nil {
 		logger.WithError(err).Error("postgres connect failed")
+		recordIndeterminate(authorizerTypeNone)
 		// Non-nil error → API Gateway returns 500 to the client. JWT was valid
 		// but we can't enforce platform-level access without the DB.
 		return events.APIGatewayCustomAuthorizerResponse{}, err
 	}
 	defer db.Close()
-	postgresDB := pgdb.New(db)
+	postgresDB := newPostgresDB(db, appConfig.ResultTtl)
 
 	cfg, err := config.LoadDefaultConfig(ctx)
 	if err != nil {
 		logger.WithError(err).Error("aws config load failed")
+		recordIndeterminate(authorizerTypeNone)
 		return events.APIGatewayCustomAuthorizerResponse{}, err
 	}
-	dynamoDB := dydb.New(dynamodb.NewFromConfig(cfg))
+	dynamoDB := newDynamoDB(cfg)
 
-	claimsManager := manager.NewClaimsManager(postgresDB, dynamoDB, jwtToken, tokenClientID, manifestTableName)
-	authorizerMode := os.Getenv("AUTHORIZER_MODE")
-
-	// Pick the authorizer based on what identity sources the client supplied.
-	// chat-service always sends datasetId → DatasetAuthorizer (most restrictive,
-	// also resolves user + org claims as a side effect). Other future WS
-	// services might send only orgId or just the token.
-	var auth authorizers.Authorizer
-	switch {
-	case event.QueryStringParameters["datasetId"] != "":
-		auth = authorizers.NewDatasetAuthorizer(event.QueryStringParameters["datasetId"])
-	case event.QueryStringParameters["orgId"] != "":
-		auth = authorizers.NewWorkspaceAuthorizer(event.QueryStringParameters["orgId"])
-	default:
-		auth = authorizers.NewUserAuthorizer()
+	sources := pipeline.Sources{
+		PostgresDB:    postgresDB,
+		DynamoDB:      dynamoDB,
+		TokenClientId: appConfig.TokenClientId,
+		ManifestTable: appConfig.ManifestTable,
 	}
 
-	claims, err := auth.GenerateClaims(ctx, claimsManager, authorizerMode)
+	claims, err := pipeline.Resolve(ctx, sources.CognitoPrincipal(jwtToken), resource, appConfig.AuthorizerMode)
+	if err == nil && !orgKnown {
+		err = checkRateLimit(ctx, logger, jwtPrincipal(jwtToken), extractOrgNodeID(claims), scope)
+	}
+	if err == nil {
+		err = checkBlocked(ctx, logger, append(tokenEntries(jwtToken), claimsEntries(claims)...)...)
+	}
 	if err != nil {
+		recordDecision(scope, err)
+		if isIndeterminate(err) {
+			// As for the HTTP authorizer: no decision could be reached, so the
+			// client gets a 500 rather than a deny.
+			withRemainingBudget(ctx, logger).WithError(err).Error("claims generation indeterminate")
+			return events.APIGatewayCustomAuthorizerResponse{}, err
+		}
 		// Includes "user has no access to dataset" from DatasetAuthorizer.
 		logger.WithError(err).Warn("rejecting — claims generation failed")
+		if reason := authorizers.DenyReason(err); reason != "" {
+			return denyResponse(event.MethodArn, reason), nil
+		}
 		return denyResponse(event.MethodArn, fmt.Sprintf("claims_failed: %s", err.Error())), nil
 	}
 
@@ -160,22 +218,33 @@ func WebSocketHandler(ctx context.Context, event events.APIGatewayCustomAuthoriz
 		orgNodeID := extractOrgNodeID(claims)
 		if orgNodeID == "" {
 			logger.Warn("rejecting — computeNodeId provided without an org claim")
+			recordDeny(scope, "compute_node_check_missing_org")
 			return denyResponse(event.MethodArn, "compute_node_check_missing_org"), nil
 		}
 		accessType, err := checkComputeNodeAccess(ctx, cfg, userNodeID, nodeUUID, orgNodeID)
 		if err != nil {
-			logger.WithError(err).Error("compute-node access check failed")
+			withRemainingBudget(ctx, logger).WithError(err).Error("compute-node access check failed")
+			if isIndeterminate(err) {
+				// check-access did not answer in time: no decision, so a 500 rather than a deny.
+				recordIndeterminate(scope)
+				return events.APIGatewayCustomAuthorizerResponse{}, err
+			}
+			recordDeny(scope, "compute_node_check_failed")
 			// Fail closed on transport errors — if we can't confirm access, refuse.
 			return denyResponse(event.MethodArn, fmt.Sprintf("compute_node_check_failed: %s", err.Error())), nil
 		}
 		if accessType == "" {
 			logger.WithFields(log.Fields{"user": userNodeID, "node": nodeUUID, "org": orgNodeID}).
 				Warn("rejecting — compute-node access denied")
+			recordDeny(scope, "compute_node_access_denied")
 			return denyResponse(event.MethodArn, "compute_node_access_denied"), nil
 		}
 		computeNodeAccessType = accessType
 	}
 
+	recordDecision(scope, nil)
+	addTraceId(ctx, claims)
+	addPurposeOfUse(ctx, claims)
 	return allowResponseWithComputeNode(event.MethodArn, claims, computeNodeAccessType), nil
 }
 
@@ -190,7 +259,7 @@ func WebSocketHandler(ctx context.Context, event events.APIGatewayCustomAuthoriz
 func allowResponseWithComputeNode(methodArn string, claims map[string]interface{}, computeNodeAccessType string) events.APIGatewayCustomAuthorizerResponse {
 	ctx := flattenContext(claims)
 	if computeNodeAccessType != "" {
-		ctx["computeNodeAccess"] = computeNodeAccessType
+		ctx[claimsClient.KeyComputeNodeAccess] = computeNodeAccessType
 	}
 	return events.APIGatewayCustomAuthorizerResponse{
 		PrincipalID: extractPrincipalID(claims),
@@ -221,7 +290,7 @@ func denyResponse(methodArn, reason string) events.APIGatewayCustomAuthorizerRes
 				Resource: []string{methodArn},
 			}},
 		},
-		Context: map[string]interface{}{"errorReason": reason},
+		Context: map[string]interface{}{claimsClient.KeyErrorReason: reason},
 	}
 }
 
@@ -247,32 +316,67 @@ func flattenContext(claims map[string]interface{}) map[string]interface{} {
 
 	if v, ok := claims[coreAuthorizer.LabelUserClaim]; ok {
 		if uc, ok := v.(*user.Claim); ok && uc != nil {
-			out["userNodeId"] = uc.NodeId
+			out[claimsClient.KeyUserNodeId] = uc.NodeId
 			if b, err := json.Marshal(uc); err == nil {
-				out["userClaim"] = string(b)
+				out[claimsClient.KeyUserClaim] = string(b)
 			}
 		}
 	}
 	if v, ok := claims[coreAuthorizer.LabelOrganizationClaim]; ok {
 		if oc, ok := v.(*organization.Claim); ok && oc != nil {
-			out["orgNodeId"] = oc.NodeId
+			out[claimsClient.KeyOrgNodeId] = oc.NodeId
 			if b, err := json.Marshal(oc); err == nil {
-				out["orgClaim"] = string(b)
+				out[claimsClient.KeyOrgClaim] = string(b)
 			}
 		}
 	}
 	if v, ok := claims[coreAuthorizer.LabelDatasetClaim]; ok {
 		if dc, ok := v.(*dataset.Claim); ok && dc != nil {
-			out["datasetNodeId"] = dc.NodeId
-			out["datasetRole"] = dc.Role.String()
+			out[claimsClient.KeyDatasetNodeId] = dc.NodeId
+			out[claimsClient.KeyDatasetRole] = dc.Role.String()
 			if b, err := json.Marshal(dc); err == nil {
-				out["datasetClaim"] = string(b)
+				out[claimsClient.KeyDatasetClaim] = string(b)
 			}
 		}
 	}
+	if v, ok := claims[claimsClient.LabelDatasetState]; ok {
+		if b, err := json.Marshal(v); err == nil {
+			out[claimsClient.KeyDatasetState] = string(b)
+		}
+	}
+	if v, ok := claims[claimsClient.LabelGrantExpiry]; ok {
+		if b, err := json.Marshal(v); err == nil {
+			out[claimsClient.KeyGrantExpiry] = string(b)
+		}
+	}
 	if v, ok := claims[coreAuthorizer.LabelTeamClaims]; ok {
 		if b, err := json.Marshal(v); err == nil {
-			out["teamClaims"] = string(b)
+			out[claimsClient.KeyTeamClaims] = string(b)
+		}
+	}
+	if traceId, ok := claims[claimsClient.LabelTraceId].(string); ok {
+		out[claimsClient.KeyTraceId] = traceId
+	}
+	if external, ok := claims[claimsClient.LabelExternalCollaborator].(bool); ok && external {
+		out[claimsClient.KeyExternalCollaborator] = "true"
+	}
+	if purposeOfUse, ok := claims[claimsClient.LabelPurposeOfUse].(string); ok {
+		out[claimsClient.KeyPurposeOfUse] = purposeOfUse
+	}
+	if v, ok := claims[claimsClient.LabelImpersonatorClaim]; ok {
+		if b, err := json.Marshal(v); err == nil {
+			out[claimsClient.KeyImpersonatorClaim] = string(b)
+		}
+	}
+	if stale, ok := claims[claimsClient.LabelStale].(bool); ok && stale {
+		out[claimsClient.KeyStale] = "true"
+	}
+	if breakGlass, ok := claims[claimsClient.LabelBreakGlass].(bool); ok && breakGlass {
+		out[claimsClient.KeyBreakGlass] = "true"
+	}
+	if v, ok := claims[claimsClient.LabelResourceClaims]; ok {
+		if b, err := json.Marshal(v); err == nil {
+			out[claimsClient.KeyResourceClaims] = string(b)
 		}
 	}
 	return out
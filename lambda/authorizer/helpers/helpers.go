@@ -54,3 +54,12 @@ func ParseCallbackAuth(authorization string) (*CallbackAuth, error) {
 func IsCallbackAuth(authorization string) bool {
 	return strings.HasPrefix(authorization, "Callback ")
 }
+
+// IsReadOnlyMethod returns true if the HTTP method cannot modify server state (GET, HEAD, OPTIONS).
+func IsReadOnlyMethod(method string) bool {
+	switch strings.ToUpper(method) {
+	case "GET", "HEAD", "OPTIONS":
+		return true
+	}
+	return false
+}
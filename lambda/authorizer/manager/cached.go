@@ -0,0 +1,238 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pennsieve/pennsieve-go-api/authorizer/cache"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/metrics"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/teamUser"
+)
+
+// Names of the caches kept by a ClaimsCache, as recorded in metrics.
+const (
+	cacheUsers                = "users"
+	cacheDatasetOrganizations = "dataset_organizations"
+	cacheOrganizationClaims   = "organization_claims"
+	cacheTeamClaims           = "team_claims"
+)
+
+// ClaimsCache keeps lookups that change rarely or never for the lifetime of a warm Lambda container, shared by
+// every request it handles: users by Cognito or node id, the organization of each dataset, and, for a short
+// time, organization and team claims. Failed lookups are never kept. A nil *ClaimsCache caches nothing.
+type ClaimsCache struct {
+	users                *cache.LRU[string, pgdb.User]
+	datasetOrganizations *cache.LRU[string, int64]
+	organizationClaims   *cache.LRU[string, OrganizationClaim]
+	teamClaims           *cache.LRU[string, []teamUser.Claim]
+	userTtl              time.Duration
+	claimTtl             time.Duration
+	metrics              metrics.Metrics
+}
+
+// NewClaimsCache returns a ClaimsCache bounded by config that records hits, misses and evictions in m, or nil if
+// config disables it.
+func NewClaimsCache(config cache.Config, m metrics.Metrics) *ClaimsCache {
+	if !config.Enabled {
+		return nil
+	}
+	return &ClaimsCache{
+		users:                cache.NewLRU[string, pgdb.User](config.MaxEntries, config.UserTtl),
+		datasetOrganizations: cache.NewLRU[string, int64](config.MaxEntries, cache.DatasetOrganizationTtl),
+		organizationClaims:   cache.NewLRU[string, OrganizationClaim](config.MaxEntries, config.ClaimTtl),
+		teamClaims:           cache.NewLRU[string, []teamUser.Claim](config.MaxEntries, config.ClaimTtl),
+		userTtl:              config.UserTtl,
+		claimTtl:             config.ClaimTtl,
+		metrics:              m,
+	}
+}
+
+// Staleness is how long a change to a user, such as to is_super_admin, or to their organization membership can go
+// unseen because of the cache. Grants must stop counting at least this long before they expire.
+func (c *ClaimsCache) Staleness() time.Duration {
+	if c == nil {
+		return 0
+	}
+	return max(c.userTtl, c.claimTtl)
+}
+
+// Wrap returns a PennsievePgAPI that answers the lookups c keeps from c, and the rest from delegate.
+func (c *ClaimsCache) Wrap(delegate PennsievePgAPI) PennsievePgAPI {
+	if c == nil {
+		return delegate
+	}
+	return &cachedPgAPI{PennsievePgAPI: delegate, cache: c}
+}
+
+// cachedPgAPI is a PennsievePgAPI that answers some lookups from a ClaimsCache.
+type cachedPgAPI struct {
+	PennsievePgAPI
+	cache *ClaimsCache
+}
+
+// lookup returns the value under key in lru if there is one, and otherwise loads it and, if that succeeds, keeps
+// it under key.
+func lookup[V any](c *ClaimsCache, name string, lru *cache.LRU[string, V], key string, load func() (V, error)) (V, error) {
+	if value, ok := lru.Get(key); ok {
+		metrics.CacheLookup(c.metrics, name, true)
+		return value, nil
+	}
+	metrics.CacheLookup(c.metrics, name, false)
+	value, err := load()
+	if err != nil {
+		return value, err
+	}
+	if lru.Add(key, value) {
+		metrics.CacheEviction(c.metrics, name)
+	}
+	return value, nil
+}
+
+// lookupPointer is lookup for loaders that return pointers. Values are kept, and returned, as copies so that no
+// caller can change what another request sees.
+func lookupPointer[V any](c *ClaimsCache, name string, lru *cache.LRU[string, V], key string, load func() (*V, error)) (*V, error) {
+	if value, ok := lru.Get(key); ok {
+		metrics.CacheLookup(c.metrics, name, true)
+		return &value, nil
+	}
+	metrics.CacheLookup(c.metrics, name, false)
+	loaded, err := load()
+	if err != nil || loaded == nil {
+		return loaded, err
+	}
+	value := *loaded
+	if lru.Add(key, value) {
+		metrics.CacheEviction(c.metrics, name)
+	}
+	return &value, nil
+}
+
+// userKey is the key of the user with the given Cognito id in the users cache.
+func userKey(cognitoId string, isFromTokenPool bool) string {
+	if isFromTokenPool {
+		return "token:" + cognitoId
+	}
+	return "user:" + cognitoId
+}
+
+func (c *cachedPgAPI) GetUserByCognitoId(ctx context.Context, cognitoId string) (*pgdb.User, error) {
+	return lookupPointer(c.cache, cacheUsers, c.cache.users, userKey(cognitoId, true), func() (*pgdb.User, error) {
+		return c.PennsievePgAPI.GetUserByCognitoId(ctx, cognitoId)
+	})
+}
+
+func (c *cachedPgAPI) GetByCognitoId(ctx context.Context, cognitoId string) (*pgdb.User, error) {
+	return lookupPointer(c.cache, cacheUsers, c.cache.users, userKey(cognitoId, false), func() (*pgdb.User, error) {
+		return c.PennsievePgAPI.GetByCognitoId(ctx, cognitoId)
+	})
+}
+
+func (c *cachedPgAPI) GetUserByNodeId(ctx context.Context, nodeId string) (*pgdb.User, error) {
+	return lookupPointer(c.cache, cacheUsers, c.cache.users, "node:"+nodeId, func() (*pgdb.User, error) {
+		return c.PennsievePgAPI.GetUserByNodeId(ctx, nodeId)
+	})
+}
+
+func (c *cachedPgAPI) GetOrganizationIdForDataset(ctx context.Context, datasetNodeId string) (int64, error) {
+	return lookup(c.cache, cacheDatasetOrganizations, c.cache.datasetOrganizations, datasetNodeId, func() (int64, error) {
+		return c.PennsievePgAPI.GetOrganizationIdForDataset(ctx, datasetNodeId)
+	})
+}
+
+func (c *cachedPgAPI) GetOrganizationClaim(ctx context.Context, userId int64, organizationId int64) (*OrganizationClaim, error) {
+	return lookupPointer(c.cache, cacheOrganizationClaims, c.cache.organizationClaims, fmt.Sprintf("%d:%d", userId, organizationId), func() (*OrganizationClaim, error) {
+		return c.PennsievePgAPI.GetOrganizationClaim(ctx, userId, organizationId)
+	})
+}
+
+func (c *cachedPgAPI) GetOrganizationClaimByNodeId(ctx context.Context, userId int64, organizationNodeId string) (*OrganizationClaim, error) {
+	return lookupPointer(c.cache, cacheOrganizationClaims, c.cache.organizationClaims, fmt.Sprintf("%d:%s", userId, organizationNodeId), func() (*OrganizationClaim, error) {
+		return c.PennsievePgAPI.GetOrganizationClaimByNodeId(ctx, userId, organizationNodeId)
+	})
+}
+
+func (c *cachedPgAPI) GetTeamClaimsForOrg(ctx context.Context, userId int64, organizationId int64) ([]teamUser.Claim, error) {
+	teamClaims, err := lookup(c.cache, cacheTeamClaims, c.cache.teamClaims, fmt.Sprintf("%d:%d", userId, organizationId), func() ([]teamUser.Claim, error) {
+		return c.PennsievePgAPI.GetTeamClaimsForOrg(ctx, userId, organizationId)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if teamClaims == nil {
+		return nil, nil
+	}
+	// The claims are shared with other requests, so hand out a copy of the slice.
+	return append(make([]teamUser.Claim, 0, len(teamClaims)), teamClaims...), nil
+}
+
+// GetDatasetAuthorization answers from the cache if it keeps the user, the dataset's organization and their
+// organization and team claims, leaving the dataset claim, which it does not keep, to be looked up on its own.
+// Otherwise it looks everything up in a single statement and keeps what it can.
+func (c *cachedPgAPI) GetDatasetAuthorization(ctx context.Context, cognitoId string, isFromTokenPool bool, datasetNodeId string) (*DatasetAuthorization, error) {
+	if cached, ok := c.cachedDatasetAuthorization(userKey(cognitoId, isFromTokenPool), datasetNodeId); ok {
+		return cached, nil
+	}
+	delegate, ok := c.PennsievePgAPI.(DatasetAuthorizationAPI)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	authorization, err := delegate.GetDatasetAuthorization(ctx, cognitoId, isFromTokenPool, datasetNodeId)
+	if err != nil {
+		return nil, err
+	}
+	c.keepDatasetAuthorization(userKey(cognitoId, isFromTokenPool), datasetNodeId, authorization)
+	return authorization, nil
+}
+
+// cachedDatasetAuthorization returns the DatasetAuthorization, without its dataset claim, of the user under key for
+// the dataset, if the cache keeps all of it.
+func (c *cachedPgAPI) cachedDatasetAuthorization(key string, datasetNodeId string) (*DatasetAuthorization, bool) {
+	currentUser, ok := c.cache.users.Get(key)
+	metrics.CacheLookup(c.cache.metrics, cacheUsers, ok)
+	if !ok {
+		return nil, false
+	}
+	organizationId, ok := c.cache.datasetOrganizations.Get(datasetNodeId)
+	metrics.CacheLookup(c.cache.metrics, cacheDatasetOrganizations, ok)
+	if !ok {
+		return nil, false
+	}
+	claimKey := fmt.Sprintf("%d:%d", currentUser.Id, organizationId)
+	orgClaim, ok := c.cache.organizationClaims.Get(claimKey)
+	metrics.CacheLookup(c.cache.metrics, cacheOrganizationClaims, ok)
+	if !ok {
+		return nil, false
+	}
+	teamClaims, ok := c.cache.teamClaims.Get(claimKey)
+	metrics.CacheLookup(c.cache.metrics, cacheTeamClaims, ok)
+	if !ok {
+		return nil, false
+	}
+	if teamClaims != nil {
+		teamClaims = append(make([]teamUser.Claim, 0, len(teamClaims)), teamClaims...)
+	}
+	return &DatasetAuthorization{User: &currentUser, OrganizationId: organizationId, Organization: &orgClaim, Teams: teamClaims}, true
+}
+
+// keepDatasetAuthorization keeps what authorization found for the user under key and the dataset.
+func (c *cachedPgAPI) keepDatasetAuthorization(key string, datasetNodeId string, authorization *DatasetAuthorization) {
+	keep := func(name string, evicted bool) {
+		if evicted {
+			metrics.CacheEviction(c.cache.metrics, name)
+		}
+	}
+	keep(cacheUsers, c.cache.users.Add(key, *authorization.User))
+	if authorization.OrganizationId == 0 {
+		return
+	}
+	keep(cacheDatasetOrganizations, c.cache.datasetOrganizations.Add(datasetNodeId, authorization.OrganizationId))
+	if authorization.Organization == nil {
+		return
+	}
+	claimKey := fmt.Sprintf("%d:%d", authorization.User.Id, authorization.OrganizationId)
+	keep(cacheOrganizationClaims, c.cache.organizationClaims.Add(claimKey, *authorization.Organization))
+	keep(cacheTeamClaims, c.cache.teamClaims.Add(claimKey, append([]teamUser.Claim(nil), authorization.Teams...)))
+}
@@ -0,0 +1,151 @@
+package manager_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pennsieve/pennsieve-go-api/authorizer/cache"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/metrics"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/test"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/test/mocks"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dataset"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/organization"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/role"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/teamUser"
+	corePgdb "github.com/pennsieve/pennsieve-go-core/pkg/queries/pgdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var cacheConfig = cache.Config{Enabled: true, MaxEntries: 10, UserTtl: time.Minute, ClaimTtl: time.Minute}
+
+func TestClaimsCacheHits(t *testing.T) {
+	recorder := mocks.NewMetrics()
+	mockPg := mocks.NewMockPennsievePgAPI()
+	currentUser := test.NewUser(101, 1001)
+	// The membership's expiry is cached with the claim.
+	expiresAt := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	orgClaim := &manager.OrganizationClaim{Claim: organization.Claim{Role: pgdb.Write, IntId: 1001, NodeId: "N:organization:1"}, ExpiresAt: &expiresAt}
+	teamClaims := []teamUser.Claim{{IntId: 1, Name: "publishers"}}
+	mockPg.OnGetByCognitoId("cognito-1").Return(currentUser, nil).Once()
+	mockPg.OnGetOrganizationIdForDataset("N:dataset:1").Return(int64(1001), nil).Once()
+	mockPg.OnGetOrganizationClaim(currentUser.Id, int64(1001)).Return(orgClaim, nil).Once()
+	mockPg.OnGetTeamClaimsForOrg(currentUser.Id, int64(1001)).Return(teamClaims, nil).Once()
+	ctx := context.Background()
+
+	// Each request wraps its own PennsievePgAPI with the same cache.
+	claimsCache := manager.NewClaimsCache(cacheConfig, recorder)
+	for i := 0; i < 2; i++ {
+		postgresDB := claimsCache.Wrap(mockPg)
+
+		user, err := postgresDB.GetByCognitoId(ctx, "cognito-1")
+		require.NoError(t, err)
+		assert.Equal(t, currentUser, user)
+		orgId, err := postgresDB.GetOrganizationIdForDataset(ctx, "N:dataset:1")
+		require.NoError(t, err)
+		assert.Equal(t, int64(1001), orgId)
+		claim, err := postgresDB.GetOrganizationClaim(ctx, currentUser.Id, 1001)
+		require.NoError(t, err)
+		assert.Equal(t, orgClaim, claim)
+		teams, err := postgresDB.GetTeamClaimsForOrg(ctx, currentUser.Id, 1001)
+		require.NoError(t, err)
+		assert.Equal(t, teamClaims, teams)
+
+		// Changing what a request was given does not change what later requests get.
+		user.IsSuperAdmin = true
+		claim.Role = pgdb.Owner
+		teams[0].Name = "changed"
+	}
+
+	for _, name := range []string{"users", "dataset_organizations", "organization_claims", "team_claims"} {
+		assert.Equal(t, 1, recorder.CountOf("CacheLookups", metrics.Dimensions{"Cache": name, "Result": "miss"}), name)
+		assert.Equal(t, 1, recorder.CountOf("CacheLookups", metrics.Dimensions{"Cache": name, "Result": "hit"}), name)
+	}
+	mockPg.AssertExpectations(t)
+}
+
+func TestClaimsCacheDoesNotKeepFailures(t *testing.T) {
+	mockPg := mocks.NewMockPennsievePgAPI()
+	currentUser := test.NewUser(101, 1001)
+	notFound := corePgdb.DatasetOrganizationNotFoundError{DatasetNodeId: "N:dataset:new"}
+	mockPg.OnGetOrganizationIdForDataset("N:dataset:new").Return(int64(0), notFound).Once()
+	mockPg.OnGetOrganizationIdForDataset("N:dataset:new").Return(int64(1001), nil).Once()
+	mockPg.OnGetOrganizationClaim(currentUser.Id, int64(1001)).Return((*organization.Claim)(nil), errors.New("connection refused")).Twice()
+	postgresDB := manager.NewClaimsCache(cacheConfig, mocks.NewMetrics()).Wrap(mockPg)
+	ctx := context.Background()
+
+	_, err := postgresDB.GetOrganizationIdForDataset(ctx, "N:dataset:new")
+	assert.ErrorAs(t, err, &corePgdb.DatasetOrganizationNotFoundError{})
+	orgId, err := postgresDB.GetOrganizationIdForDataset(ctx, "N:dataset:new")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1001), orgId)
+
+	for i := 0; i < 2; i++ {
+		_, err = postgresDB.GetOrganizationClaim(ctx, currentUser.Id, 1001)
+		assert.ErrorContains(t, err, "connection refused")
+	}
+	mockPg.AssertExpectations(t)
+}
+
+func TestClaimsCacheBounds(t *testing.T) {
+	recorder := mocks.NewMetrics()
+	mockPg := mocks.NewMockPennsievePgAPI()
+	mockPg.OnGetOrganizationIdForDataset("N:dataset:1").Return(int64(1001), nil).Twice()
+	mockPg.OnGetOrganizationIdForDataset("N:dataset:2").Return(int64(1002), nil).Once()
+	postgresDB := manager.NewClaimsCache(cache.Config{Enabled: true, MaxEntries: 1}, recorder).Wrap(mockPg)
+	ctx := context.Background()
+
+	for _, datasetNodeId := range []string{"N:dataset:1", "N:dataset:2", "N:dataset:1"} {
+		_, err := postgresDB.GetOrganizationIdForDataset(ctx, datasetNodeId)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 2, recorder.CountOf("CacheEvictions", metrics.Dimensions{"Cache": "dataset_organizations"}))
+	mockPg.AssertExpectations(t)
+}
+
+func TestClaimsCacheDatasetAuthorization(t *testing.T) {
+	recorder := mocks.NewMetrics()
+	mockPg := mocks.NewMockDatasetAuthorizationPgAPI()
+	currentUser := test.NewUser(101, 1001)
+	orgClaim := &manager.OrganizationClaim{Claim: organization.Claim{Role: pgdb.Write, IntId: 1001, NodeId: "N:organization:1"}}
+	teamClaims := []teamUser.Claim{{IntId: 1, Name: "publishers"}}
+	datasetClaim := &manager.DatasetClaim{Claim: dataset.Claim{Role: role.Editor, NodeId: "N:dataset:1", IntId: 1}}
+	mockPg.OnGetDatasetAuthorization("cognito-1", true, "N:dataset:1").Return(&manager.DatasetAuthorization{
+		User: currentUser, OrganizationId: 1001, Organization: orgClaim, Dataset: datasetClaim, Teams: teamClaims,
+	}, nil).Once()
+	claimsCache := manager.NewClaimsCache(cacheConfig, recorder)
+	ctx := context.Background()
+
+	first, err := claimsCache.Wrap(mockPg).(manager.DatasetAuthorizationAPI).GetDatasetAuthorization(ctx, "cognito-1", true, "N:dataset:1")
+	require.NoError(t, err)
+	assert.Equal(t, datasetClaim, first.Dataset)
+
+	// What the cache keeps answers the next request, but for the dataset claim, which is left to be looked up.
+	second, err := claimsCache.Wrap(mockPg).(manager.DatasetAuthorizationAPI).GetDatasetAuthorization(ctx, "cognito-1", true, "N:dataset:1")
+	require.NoError(t, err)
+	assert.Equal(t, &manager.DatasetAuthorization{User: currentUser, OrganizationId: 1001, Organization: orgClaim, Teams: teamClaims}, second)
+	// So do the lookups made one by one.
+	user, err := claimsCache.Wrap(mockPg).GetUserByCognitoId(ctx, "cognito-1")
+	require.NoError(t, err)
+	assert.Equal(t, currentUser, user)
+	assert.Equal(t, 2, recorder.CountOf("CacheLookups", metrics.Dimensions{"Cache": "users", "Result": "hit"}))
+	mockPg.AssertExpectations(t)
+
+	_, err = claimsCache.Wrap(mocks.NewMockPennsievePgAPI()).(manager.DatasetAuthorizationAPI).GetDatasetAuthorization(ctx, "cognito-2", false, "N:dataset:1")
+	assert.ErrorIs(t, err, errors.ErrUnsupported)
+}
+
+func TestClaimsCacheDisabled(t *testing.T) {
+	mockPg := mocks.NewMockPennsievePgAPI()
+	claimsCache := manager.NewClaimsCache(cache.Config{Enabled: false}, mocks.NewMetrics())
+
+	assert.Nil(t, claimsCache)
+	assert.Same(t, mockPg, claimsCache.Wrap(mockPg))
+	assert.Zero(t, claimsCache.Staleness())
+	assert.Equal(t, time.Minute, manager.NewClaimsCache(cacheConfig, mocks.NewMetrics()).Staleness())
+	assert.Equal(t, time.Minute, manager.NewClaimsCache(cache.Config{Enabled: true, MaxEntries: 10, UserTtl: time.Minute}, mocks.NewMetrics()).Staleness())
+}
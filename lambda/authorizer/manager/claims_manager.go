@@ -2,9 +2,14 @@ package manager
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
+
 	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/claims"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/dataset"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/dydb"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/organization"
@@ -28,6 +33,34 @@ type IdentityManager interface {
 	GetOrganizationIdForDataset(ctx context.Context, datasetId string) (int64, error)
 	GetManifest(ctx context.Context, manifestId string) (*dydb.ManifestTable, error)
 	GetTokenWorkspace() (TokenWorkspace, bool)
+	// GetAuthentication returns how the principal authenticated.
+	GetAuthentication() Authentication
+	// GetUserByNodeId returns the Pennsieve user with the given node id.
+	GetUserByNodeId(ctx context.Context, nodeId string) (*pgdbModels.User, error)
+	// GetOrganizationIpAllowlist returns the CIDR ranges the organization accepts requests from. Empty means no restriction.
+	GetOrganizationIpAllowlist(ctx context.Context, orgId int64) ([]string, error)
+	// GetOrganizationAuthPolicy returns the organization's restrictions on how requests are authenticated.
+	GetOrganizationAuthPolicy(ctx context.Context, orgId int64) (*AuthPolicy, error)
+	// GetExternalDatasetGrant returns the role granted directly to the user on the dataset, for a user who is not a
+	// member of the dataset's organization.
+	GetExternalDatasetGrant(ctx context.Context, userId int64, datasetId string, orgId int64) (*ExternalGrant, error)
+	// GetDatasetIdentity returns the dataset's id and its organization's node id, whatever the user's role on it.
+	GetDatasetIdentity(ctx context.Context, datasetId string, orgId int64) (*DatasetIdentity, error)
+	// GetDatasetState returns the lifecycle state of the dataset in the given organization.
+	GetDatasetState(ctx context.Context, datasetId string, orgId int64) (*claims.DatasetState, error)
+	// GetDataUseAgreementStatus returns whether the user must, and has, accepted the dataset's data-use agreement.
+	GetDataUseAgreementStatus(ctx context.Context, userId int64, datasetId string, orgId int64) (*DataUseAgreementStatus, error)
+	// GetGrantExpiry returns when the user's organization membership and, if datasetId is not empty, dataset role expire.
+	GetGrantExpiry(ctx context.Context, userId int64, datasetId string, orgId int64) (*claims.GrantExpiry, error)
+}
+
+// DatasetPreloader is implemented by IdentityManagers that can look up, ahead of the dataset authorizer, the user,
+// the dataset's organization and the user's organization claim, dataset claim and teams in a single statement.
+type DatasetPreloader interface {
+	// PreloadDataset looks up the current user and their claims on the dataset. If it cannot look them up together,
+	// or finds nothing, the IdentityManager looks each of them up when asked, as it otherwise would. Any other
+	// failure is returned, since the lookups one by one would most likely fail the same way.
+	PreloadDataset(ctx context.Context, datasetId string) error
 }
 
 type ClaimsManager struct {
@@ -36,24 +69,110 @@ type ClaimsManager struct {
 	Token             jwt.Token
 	TokenClientID     string
 	ManifestTableName string
+	// preloaded is what PreloadDataset looked up for preloadedDataset.
+	preloaded        *DatasetAuthorization
+	preloadedDataset string
+	// grantExpiries are when the grants behind the claims resolved so far expire, for GetGrantExpiry.
+	grantExpiries map[grantKey]*time.Time
+}
+
+// grantKey identifies the grant behind a claim: a user's membership of an organization, or, if datasetId is not
+// empty, their role on a dataset in it.
+type grantKey struct {
+	userId    int64
+	orgId     int64
+	datasetId string
+}
+
+// keepExpiry remembers when the grant under key expires.
+func (c *ClaimsManager) keepExpiry(key grantKey, expiresAt *time.Time) {
+	if c.grantExpiries == nil {
+		c.grantExpiries = map[grantKey]*time.Time{}
+	}
+	c.grantExpiries[key] = expiresAt
 }
 
 func NewClaimsManager(postgresDB PennsievePgAPI, dynamoDB PennsieveDyAPI, token jwt.Token, tokenClientID string, manifestTable string) IdentityManager {
-	return &ClaimsManager{postgresDB, dynamoDB, token, tokenClientID, manifestTable}
+	return &ClaimsManager{
+		PostgresDB:        postgresDB,
+		DynamoDB:          dynamoDB,
+		Token:             token,
+		TokenClientID:     tokenClientID,
+		ManifestTableName: manifestTable,
+	}
+}
+
+// PreloadDataset looks up the current user and their claims on the dataset in a single statement, if PostgresDB
+// implements DatasetAuthorizationAPI. Whatever it finds is returned by GetCurrentUser, GetOrganizationIdForDataset,
+// GetOrgClaim, GetDatasetClaim and GetTeamClaimsForOrg instead of being looked up again. It returns the error if the
+// lookup fails other than with errors.ErrUnsupported or sql.ErrNoRows, so that an outage does not double the
+// queries made.
+func (c *ClaimsManager) PreloadDataset(ctx context.Context, datasetId string) error {
+	api, ok := c.PostgresDB.(DatasetAuthorizationAPI)
+	if !ok {
+		return nil
+	}
+	cognitoUserName, hasKey := c.Token.Get("username")
+	if !hasKey {
+		return nil
+	}
+	clientIdClaim, _ := c.Token.Get("client_id")
+	authorization, err := api.GetDatasetAuthorization(ctx, cognitoUserName.(string), clientIdClaim == c.TokenClientID, datasetId)
+	if err != nil {
+		if errors.Is(err, errors.ErrUnsupported) || errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return fmt.Errorf("unable to preload dataset authorization: %w", err)
+	}
+	c.preloaded = authorization
+	c.preloadedDataset = datasetId
+	return nil
+}
+
+// preloadedFor returns what PreloadDataset looked up if it is for the user and organization, or nil.
+func (c *ClaimsManager) preloadedFor(userId int64, orgId int64) *DatasetAuthorization {
+	if c.preloaded == nil || c.preloaded.User.Id != userId || c.preloaded.OrganizationId != orgId {
+		return nil
+	}
+	return c.preloaded
 }
 
 func (c *ClaimsManager) GetDatasetClaim(ctx context.Context, currentUser *pgdbModels.User, datasetId string, orgInt int64) (*dataset.Claim, error) {
-	datasetClaim, err := c.PostgresDB.GetDatasetClaim(ctx, currentUser, datasetId, orgInt)
+	datasetClaim, err := c.datasetClaim(ctx, currentUser, datasetId, orgInt)
 	if err != nil {
 		return nil, err
 	}
+	c.keepExpiry(grantKey{userId: currentUser.Id, orgId: orgInt, datasetId: datasetId}, datasetClaim.ExpiresAt)
+	return &datasetClaim.Claim, nil
+}
 
-	return datasetClaim, nil
+// datasetClaim returns the preloaded dataset claim if there is one, and otherwise looks it up.
+func (c *ClaimsManager) datasetClaim(ctx context.Context, currentUser *pgdbModels.User, datasetId string, orgInt int64) (*DatasetClaim, error) {
+	if preloaded := c.preloadedFor(currentUser.Id, orgInt); preloaded != nil && preloaded.Dataset != nil && c.preloadedDataset == datasetId {
+		return preloaded.Dataset, nil
+	}
+	return c.PostgresDB.GetDatasetClaim(ctx, currentUser, datasetId, orgInt)
+}
+
+// ManifestNotFoundError is returned by GetManifest when the manifest table has no manifest with the requested id.
+type ManifestNotFoundError struct {
+	ManifestId string
 }
 
+func (e ManifestNotFoundError) Error() string {
+	return fmt.Sprintf("manifest %s not found", e.ManifestId)
+}
+
+// manifestNotFoundMessage is how pennsieve-go-core's GetManifestById reports a missing manifest. It returns no
+// typed error, and GetManifest must tell a missing manifest from a DynamoDB failure.
+const manifestNotFoundMessage = "Manifest not found"
+
 func (c *ClaimsManager) GetManifest(ctx context.Context, manifestID string) (*dydb.ManifestTable, error) {
 	manifest, err := c.DynamoDB.GetManifestById(ctx, c.ManifestTableName, manifestID)
 	if err != nil {
+		if strings.Contains(err.Error(), manifestNotFoundMessage) {
+			return nil, ManifestNotFoundError{ManifestId: manifestID}
+		}
 		return nil, err
 	}
 
@@ -69,12 +188,20 @@ func (c *ClaimsManager) GetUserClaim(ctx context.Context, currentUser *pgdbModel
 }
 
 func (c *ClaimsManager) GetOrgClaim(ctx context.Context, userId int64, orgId int64) (*organization.Claim, error) {
-	orgClaim, err := c.PostgresDB.GetOrganizationClaim(ctx, userId, orgId)
+	orgClaim, err := c.orgClaim(ctx, userId, orgId)
 	if err != nil {
 		return nil, err
 	}
+	c.keepExpiry(grantKey{userId: userId, orgId: orgId}, orgClaim.ExpiresAt)
+	return &orgClaim.Claim, nil
+}
 
-	return orgClaim, nil
+// orgClaim returns the preloaded organization claim if there is one, and otherwise looks it up.
+func (c *ClaimsManager) orgClaim(ctx context.Context, userId int64, orgId int64) (*OrganizationClaim, error) {
+	if preloaded := c.preloadedFor(userId, orgId); preloaded != nil && preloaded.Organization != nil {
+		return preloaded.Organization, nil
+	}
+	return c.PostgresDB.GetOrganizationClaim(ctx, userId, orgId)
 }
 
 func (c *ClaimsManager) GetOrgClaimByNodeId(ctx context.Context, userId int64, orgNodeId string) (*organization.Claim, error) {
@@ -82,7 +209,8 @@ func (c *ClaimsManager) GetOrgClaimByNodeId(ctx context.Context, userId int64, o
 	if err != nil {
 		return nil, fmt.Errorf("error getting orgClaim for user %d, workspace %s: %w", userId, orgNodeId, err)
 	}
-	return orgClaim, nil
+	c.keepExpiry(grantKey{userId: userId, orgId: orgClaim.IntId}, orgClaim.ExpiresAt)
+	return &orgClaim.Claim, nil
 }
 
 func (c *ClaimsManager) GetTeamClaims(ctx context.Context, userId int64) ([]teamUser.Claim, error) {
@@ -95,6 +223,9 @@ func (c *ClaimsManager) GetTeamClaims(ctx context.Context, userId int64) ([]team
 }
 
 func (c *ClaimsManager) GetTeamClaimsForOrg(ctx context.Context, userId int64, orgId int64) ([]teamUser.Claim, error) {
+	if preloaded := c.preloadedFor(userId, orgId); preloaded != nil && preloaded.Organization != nil {
+		return preloaded.Teams, nil
+	}
 	teamClaims, err := c.PostgresDB.GetTeamClaimsForOrg(ctx, userId, orgId)
 	if err != nil {
 		return nil, err
@@ -104,6 +235,9 @@ func (c *ClaimsManager) GetTeamClaimsForOrg(ctx context.Context, userId int64, o
 }
 
 func (c *ClaimsManager) GetOrganizationIdForDataset(ctx context.Context, datasetId string) (int64, error) {
+	if c.preloaded != nil && c.preloaded.OrganizationId != 0 && c.preloadedDataset == datasetId {
+		return c.preloaded.OrganizationId, nil
+	}
 	return c.PostgresDB.GetOrganizationIdForDataset(ctx, datasetId)
 }
 
@@ -124,6 +258,35 @@ func (c *ClaimsManager) GetTokenWorkspace() (TokenWorkspace, bool) {
 
 }
 
+// GetAuthentication returns AuthenticationApiToken for a token from the API token pool, AuthenticationUser, with the
+// identity provider the user signed in through, for one from the user pool, and AuthenticationNodeId if there is no
+// token.
+func (c *ClaimsManager) GetAuthentication() Authentication {
+	if _, hasKey := c.Token.Get("username"); !hasKey {
+		return Authentication{Method: AuthenticationNodeId}
+	}
+	if clientIdClaim, _ := c.Token.Get("client_id"); clientIdClaim == c.TokenClientID {
+		return Authentication{Method: AuthenticationApiToken}
+	}
+	return Authentication{Method: AuthenticationUser, IdentityProvider: identityProvider(c.Token)}
+}
+
+// identityProvider returns the provider in the token's identities claim, which Cognito adds for federated users,
+// or else IdentityProviderCognito. The username is never parsed for a provider, since a password user may choose
+// one that looks federated.
+func identityProvider(token jwt.Token) string {
+	if identities, ok := token.Get("identities"); ok {
+		if list, ok := identities.([]interface{}); ok && len(list) > 0 {
+			if identity, ok := list[0].(map[string]interface{}); ok {
+				if providerName, ok := identity["providerName"].(string); ok && providerName != "" {
+					return providerName
+				}
+			}
+		}
+	}
+	return IdentityProviderCognito
+}
+
 func (c *ClaimsManager) GetActiveOrg(ctx context.Context, currentUser *pgdbModels.User) int64 {
 	tokenOrg, tokenHasOrg := c.GetTokenWorkspace()
 	if tokenHasOrg {
@@ -138,6 +301,9 @@ func (c *ClaimsManager) GetCurrentUser(ctx context.Context) (*pgdbModels.User, e
 	if !hasKey {
 		return nil, errors.New("Unauthorized")
 	}
+	if c.preloaded != nil {
+		return c.preloaded.User, nil
+	}
 
 	// Get Pennsieve User from User Table, or Token Table
 	clientIdClaim, _ := c.Token.Get("client_id") // Key is present or method would have returned before.
@@ -145,6 +311,56 @@ func (c *ClaimsManager) GetCurrentUser(ctx context.Context) (*pgdbModels.User, e
 	return getUser(ctx, c.PostgresDB, cognitoUserName.(string), isFromTokenPool)
 }
 
+func (c *ClaimsManager) GetUserByNodeId(ctx context.Context, nodeId string) (*pgdbModels.User, error) {
+	return c.PostgresDB.GetUserByNodeId(ctx, nodeId)
+}
+
+func (c *ClaimsManager) GetOrganizationIpAllowlist(ctx context.Context, orgId int64) ([]string, error) {
+	return c.PostgresDB.GetOrganizationIpAllowlist(ctx, orgId)
+}
+
+func (c *ClaimsManager) GetOrganizationAuthPolicy(ctx context.Context, orgId int64) (*AuthPolicy, error) {
+	return c.PostgresDB.GetOrganizationAuthPolicy(ctx, orgId)
+}
+
+func (c *ClaimsManager) GetExternalDatasetGrant(ctx context.Context, userId int64, datasetId string, orgId int64) (*ExternalGrant, error) {
+	return c.PostgresDB.GetExternalDatasetGrant(ctx, userId, datasetId, orgId)
+}
+
+func (c *ClaimsManager) GetDatasetIdentity(ctx context.Context, datasetId string, orgId int64) (*DatasetIdentity, error) {
+	return c.PostgresDB.GetDatasetIdentity(ctx, datasetId, orgId)
+}
+
+func (c *ClaimsManager) GetDatasetState(ctx context.Context, datasetId string, orgId int64) (*claims.DatasetState, error) {
+	return c.PostgresDB.GetDatasetState(ctx, datasetId, orgId)
+}
+
+func (c *ClaimsManager) GetDataUseAgreementStatus(ctx context.Context, userId int64, datasetId string, orgId int64) (*DataUseAgreementStatus, error) {
+	return c.PostgresDB.GetDataUseAgreementStatus(ctx, userId, datasetId, orgId)
+}
+
+// GetGrantExpiry returns when the grants behind the organization claim and, if datasetId is not empty, the dataset
+// claim c resolved for the user expire, as they were found together with the claims. It fails if c has not resolved
+// them: the expiry of a grant is only ever that of the claim it is returned with.
+func (c *ClaimsManager) GetGrantExpiry(ctx context.Context, userId int64, datasetId string, orgId int64) (*claims.GrantExpiry, error) {
+	var expiry claims.GrantExpiry
+	organizationExpiry, ok := c.grantExpiries[grantKey{userId: userId, orgId: orgId}]
+	if !ok {
+		return nil, fmt.Errorf("no organization claim resolved for user %d organization %d", userId, orgId)
+	}
+	expiry.Organization = organizationExpiry
+	if datasetId == "" {
+		return &expiry, nil
+	}
+
+	datasetExpiry, ok := c.grantExpiries[grantKey{userId: userId, orgId: orgId, datasetId: datasetId}]
+	if !ok {
+		return nil, fmt.Errorf("no dataset claim resolved for user %d dataset %s", userId, datasetId)
+	}
+	expiry.Dataset = datasetExpiry
+	return &expiry, nil
+}
+
 // getUser returns a Pennsieve user from a cognito ID.
 func getUser(ctx context.Context, q PennsievePgAPI, cognitoId string, isFromTokenPool bool) (*pgdbModels.User, error) {
 
@@ -170,3 +386,24 @@ type TokenWorkspace struct {
 	Id     int64
 	NodeId string
 }
+
+// Authentication methods.
+const (
+	// AuthenticationUser is a Cognito user pool token.
+	AuthenticationUser = "user"
+	// AuthenticationApiToken is a Cognito token pool token, obtained with an API key.
+	AuthenticationApiToken = "api_token"
+	// AuthenticationNodeId is no token: the principal was identified by node id, having been authenticated some other
+	// way, as by a callback token or a direct invocation.
+	AuthenticationNodeId = "node_id"
+)
+
+// IdentityProviderCognito is the identity provider of users who sign in with a Pennsieve password.
+const IdentityProviderCognito = "Cognito"
+
+// Authentication is how a principal authenticated.
+type Authentication struct {
+	Method string
+	// IdentityProvider is the provider an AuthenticationUser signed in through.
+	IdentityProvider string
+}
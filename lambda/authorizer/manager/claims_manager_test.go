@@ -2,8 +2,12 @@ package manager_test
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	claimsClient "github.com/pennsieve/pennsieve-go-api/authorizer/claims"
 	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
 	"github.com/pennsieve/pennsieve-go-api/authorizer/test"
 	"github.com/pennsieve/pennsieve-go-api/authorizer/test/mocks"
@@ -16,6 +20,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"testing"
+	"time"
 )
 
 func TestClaimsManager(t *testing.T) {
@@ -27,6 +32,7 @@ func TestClaimsManager(t *testing.T) {
 		"GetActiveOrg":        testGetActiveOrg,
 		"GetDatasetClaim":     testGetDatasetClaim,
 		"GetManifest":         testGetManifest,
+		"ManifestNotFound":    testGetManifestNotFound,
 		"GetOrgClaim":         testGetOrgClaim,
 		"GetOrgClaimByNodeId": testGetOrgClaimByNodeId,
 		"GetTeamClaims":       testGetTeamClaims,
@@ -142,6 +148,27 @@ func testGetManifest(t *testing.T, params *mocks.ClaimsManagerParams) {
 	assert.Equal(t, expectedManifest, manifest)
 }
 
+func testGetManifestNotFound(t *testing.T, params *mocks.ClaimsManagerParams) {
+	claimsManager := params.BuildClaimsManager()
+	missingManifestId := uuid.NewString()
+	failingManifestId := uuid.NewString()
+	// pennsieve-go-core returns an empty manifest along with these errors.
+	params.MockPennsieveDy.OnGetManifestById(params.ManifestTableName, missingManifestId).
+		Return(&dydb.ManifestTable{}, errors.New("GetItem: Manifest not found.\n"))
+	params.MockPennsieveDy.OnGetManifestById(params.ManifestTableName, failingManifestId).
+		Return(&dydb.ManifestTable{}, errors.New("GetItem: operation error DynamoDB: GetItem, timeout"))
+
+	ctx := context.Background()
+	manifest, err := claimsManager.GetManifest(ctx, missingManifestId)
+	assert.Nil(t, manifest)
+	assert.Equal(t, manager.ManifestNotFoundError{ManifestId: missingManifestId}, err)
+
+	manifest, err = claimsManager.GetManifest(ctx, failingManifestId)
+	assert.Nil(t, manifest)
+	assert.ErrorContains(t, err, "timeout")
+	assert.False(t, errors.As(err, &manager.ManifestNotFoundError{}))
+}
+
 func testGetOrgClaim(t *testing.T, params *mocks.ClaimsManagerParams) {
 	claimsManager := params.BuildClaimsManager()
 
@@ -209,3 +236,188 @@ func testGetTeamClaims(t *testing.T, params *mocks.ClaimsManagerParams) {
 	require.NoError(t, err)
 	assert.Equal(t, expectedClaims, claims)
 }
+
+func TestClaimsManagerPreloadDataset(t *testing.T) {
+	jwt := test.NewJWTBuilder().Build(t)
+	tokenClientId := uuid.NewString()
+	datasetId := fmt.Sprintf("N:dataset:%s", uuid.NewString())
+	currentUser := test.NewUser(101, 2001)
+	orgClaim := &organization.Claim{Role: pgdb.Write, IntId: 2001, NodeId: "N:organization:1"}
+	datasetClaim := &dataset.Claim{Role: role.Editor, NodeId: datasetId, IntId: 555}
+	teamClaims := []teamUser.Claim{{IntId: 1, Name: "publishers"}}
+
+	for name, params := range map[string]struct {
+		authorization *manager.DatasetAuthorization
+		err           error
+		// expectLookups sets up the lookups made one by one.
+		expectLookups func(mockPg *mocks.MockDatasetAuthorizationPgAPI)
+	}{
+		"everything preloaded": {
+			authorization: &manager.DatasetAuthorization{User: currentUser, OrganizationId: 2001, Organization: &manager.OrganizationClaim{Claim: *orgClaim}, Dataset: &manager.DatasetClaim{Claim: *datasetClaim}, Teams: teamClaims},
+			expectLookups: func(*mocks.MockDatasetAuthorizationPgAPI) {},
+		},
+		"dataset claim not preloaded": {
+			authorization: &manager.DatasetAuthorization{User: currentUser, OrganizationId: 2001, Organization: &manager.OrganizationClaim{Claim: *orgClaim}, Teams: teamClaims},
+			expectLookups: func(mockPg *mocks.MockDatasetAuthorizationPgAPI) {
+				mockPg.OnGetDatasetClaim(currentUser, datasetId, int64(2001)).Return(datasetClaim, nil).Once()
+			},
+		},
+		"user not found by the preload": {
+			err: sql.ErrNoRows,
+			expectLookups: func(mockPg *mocks.MockDatasetAuthorizationPgAPI) {
+				mockPg.OnGetByCognitoId(jwt.Username).Return(currentUser, nil).Once()
+				mockPg.OnGetOrganizationIdForDataset(datasetId).Return(int64(2001), nil).Once()
+				mockPg.OnGetOrganizationClaim(currentUser.Id, int64(2001)).Return(orgClaim, nil).Once()
+				mockPg.OnGetDatasetClaim(currentUser, datasetId, int64(2001)).Return(datasetClaim, nil).Once()
+				mockPg.OnGetTeamClaimsForOrg(currentUser.Id, int64(2001)).Return(teamClaims, nil).Once()
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			mockPg := mocks.NewMockDatasetAuthorizationPgAPI()
+			mockPg.OnGetDatasetAuthorization(jwt.Username, false, datasetId).Return(params.authorization, params.err).Once()
+			params.expectLookups(mockPg)
+			claimsManager := manager.NewClaimsManager(mockPg, mocks.NewMockPennsieveDyAPI(), jwt.Token, tokenClientId, "manifests")
+			ctx := context.Background()
+
+			require.NoError(t, claimsManager.(manager.DatasetPreloader).PreloadDataset(ctx, datasetId))
+
+			user, err := claimsManager.GetCurrentUser(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, currentUser, user)
+			orgId, err := claimsManager.GetOrganizationIdForDataset(ctx, datasetId)
+			require.NoError(t, err)
+			assert.Equal(t, int64(2001), orgId)
+			org, err := claimsManager.GetOrgClaim(ctx, user.Id, orgId)
+			require.NoError(t, err)
+			assert.Equal(t, orgClaim, org)
+			claim, err := claimsManager.GetDatasetClaim(ctx, user, datasetId, orgId)
+			require.NoError(t, err)
+			assert.Equal(t, datasetClaim, claim)
+			teams, err := claimsManager.GetTeamClaimsForOrg(ctx, user.Id, orgId)
+			require.NoError(t, err)
+			assert.Equal(t, teamClaims, teams)
+			mockPg.AssertExpectations(t)
+		})
+	}
+}
+
+func TestClaimsManagerPreloadDatasetFails(t *testing.T) {
+	jwt := test.NewJWTBuilder().Build(t)
+	datasetId := fmt.Sprintf("N:dataset:%s", uuid.NewString())
+	mockPg := mocks.NewMockDatasetAuthorizationPgAPI()
+	mockPg.OnGetDatasetAuthorization(jwt.Username, false, datasetId).Return((*manager.DatasetAuthorization)(nil), errors.New("connection refused")).Once()
+	claimsManager := manager.NewClaimsManager(mockPg, mocks.NewMockPennsieveDyAPI(), jwt.Token, uuid.NewString(), "manifests")
+
+	// The failure is returned rather than repeated by looking every claim up one by one.
+	err := claimsManager.(manager.DatasetPreloader).PreloadDataset(context.Background(), datasetId)
+	assert.ErrorContains(t, err, "connection refused")
+	mockPg.AssertExpectations(t)
+}
+
+func TestClaimsManagerPreloadDatasetUnsupported(t *testing.T) {
+	params := mocks.NewClaimsManagerParams(t)
+	currentUser := test.NewUser(101, 2001)
+	claimsManager := params.WithUserQueryMocked(t, currentUser).BuildClaimsManager()
+	ctx := context.Background()
+
+	// Without a DatasetAuthorizationAPI nothing is preloaded, and the user is looked up as usual.
+	require.NoError(t, claimsManager.(manager.DatasetPreloader).PreloadDataset(ctx, "N:dataset:1"))
+	user, err := claimsManager.GetCurrentUser(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, currentUser, user)
+	params.AssertMockExpectations(t)
+}
+
+func TestClaimsManagerGetGrantExpiry(t *testing.T) {
+	jwt := test.NewJWTBuilder().Build(t)
+	currentUser := test.NewUser(101, 2001)
+	datasetId := fmt.Sprintf("N:dataset:%s", uuid.NewString())
+	membershipExpiresAt := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	roleExpiresAt := time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC)
+	mockPg := mocks.NewMockPennsievePgAPI()
+	mockPg.OnGetOrganizationClaim(currentUser.Id, int64(2001)).Return(&manager.OrganizationClaim{
+		Claim:     organization.Claim{Role: pgdb.Write, IntId: 2001, NodeId: "N:organization:1"},
+		ExpiresAt: &membershipExpiresAt,
+	}, nil).Once()
+	mockPg.OnGetDatasetClaim(currentUser, datasetId, int64(2001)).Return(&manager.DatasetClaim{
+		Claim:     dataset.Claim{Role: role.Editor, NodeId: datasetId, IntId: 555},
+		ExpiresAt: &roleExpiresAt,
+	}, nil).Once()
+	claimsManager := manager.NewClaimsManager(mockPg, mocks.NewMockPennsieveDyAPI(), jwt.Token, uuid.NewString(), "manifests")
+	ctx := context.Background()
+
+	_, err := claimsManager.GetOrgClaim(ctx, currentUser.Id, 2001)
+	require.NoError(t, err)
+	_, err = claimsManager.GetDatasetClaim(ctx, currentUser, datasetId, 2001)
+	require.NoError(t, err)
+
+	// The expiries were found with the claims, so Postgres is not queried again.
+	expiry, err := claimsManager.GetGrantExpiry(ctx, currentUser.Id, datasetId, 2001)
+	require.NoError(t, err)
+	assert.Equal(t, &claimsClient.GrantExpiry{Organization: &membershipExpiresAt, Dataset: &roleExpiresAt}, expiry)
+	mockPg.AssertExpectations(t)
+}
+
+func TestClaimsManagerGetGrantExpiryWithoutClaims(t *testing.T) {
+	jwt := test.NewJWTBuilder().Build(t)
+	currentUser := test.NewUser(101, 2001)
+	mockPg := mocks.NewMockPennsievePgAPI()
+	mockPg.OnGetOrganizationClaim(currentUser.Id, int64(2001)).Return(&manager.OrganizationClaim{
+		Claim: organization.Claim{Role: pgdb.Write, IntId: 2001, NodeId: "N:organization:1"},
+	}, nil).Once()
+	claimsManager := manager.NewClaimsManager(mockPg, mocks.NewMockPennsieveDyAPI(), jwt.Token, uuid.NewString(), "manifests")
+	ctx := context.Background()
+
+	// Nothing is looked up to make up for a claim that was not resolved.
+	_, err := claimsManager.GetGrantExpiry(ctx, currentUser.Id, "", 2001)
+	assert.ErrorContains(t, err, "no organization claim resolved")
+
+	_, err = claimsManager.GetOrgClaim(ctx, currentUser.Id, 2001)
+	require.NoError(t, err)
+	_, err = claimsManager.GetGrantExpiry(ctx, currentUser.Id, "N:dataset:1", 2001)
+	assert.ErrorContains(t, err, "no dataset claim resolved")
+	mockPg.AssertExpectations(t)
+}
+
+func TestClaimsManagerGetAuthentication(t *testing.T) {
+	tokenClientId := uuid.NewString()
+	for scenario, params := range map[string]struct {
+		claims   map[string]interface{}
+		expected manager.Authentication
+	}{
+		"no token": {
+			expected: manager.Authentication{Method: manager.AuthenticationNodeId},
+		},
+		"api token": {
+			claims:   map[string]interface{}{"username": uuid.NewString(), "client_id": tokenClientId},
+			expected: manager.Authentication{Method: manager.AuthenticationApiToken},
+		},
+		"password": {
+			claims:   map[string]interface{}{"username": uuid.NewString(), "client_id": uuid.NewString()},
+			expected: manager.Authentication{Method: manager.AuthenticationUser, IdentityProvider: manager.IdentityProviderCognito},
+		},
+		"username that looks federated": {
+			claims:   map[string]interface{}{"username": "UPennSAML_jdoe@upenn.edu", "client_id": uuid.NewString()},
+			expected: manager.Authentication{Method: manager.AuthenticationUser, IdentityProvider: manager.IdentityProviderCognito},
+		},
+		"identities claim": {
+			claims: map[string]interface{}{"username": "upennsaml_jdoe@upenn.edu", "client_id": uuid.NewString(),
+				"identities": []interface{}{map[string]interface{}{"providerName": "UPennSAML", "providerType": "SAML"}}},
+			expected: manager.Authentication{Method: manager.AuthenticationUser, IdentityProvider: "UPennSAML"},
+		},
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			builder := jwt.NewBuilder()
+			for name, value := range params.claims {
+				builder = builder.Claim(name, value)
+			}
+			token, err := builder.Build()
+			require.NoError(t, err)
+
+			claimsManager := manager.NewClaimsManager(mocks.NewMockPennsievePgAPI(), mocks.NewMockPennsieveDyAPI(), token, tokenClientId, "")
+
+			assert.Equal(t, params.expected, claimsManager.GetAuthentication())
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package manager
+
+import (
+	"context"
+	"errors"
+
+	"github.com/pennsieve/pennsieve-go-api/authorizer/claims"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/fault"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/metrics"
+	coreDydb "github.com/pennsieve/pennsieve-go-core/pkg/models/dydb"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/teamUser"
+)
+
+// faultyPgAPI injects faults into the queries made through a PennsievePgAPI, before they are made.
+type faultyPgAPI struct {
+	delegate PennsievePgAPI
+	injector *fault.Injector
+}
+
+// NewFaultyPgAPI returns a PennsievePgAPI that injects the faults of injector into the queries made through
+// delegate. Wrapped by NewTimedPgAPI, an injected timeout is bounded, and reported, like a real one. With a nil
+// injector, it returns delegate itself.
+func NewFaultyPgAPI(delegate PennsievePgAPI, injector *fault.Injector) PennsievePgAPI {
+	if injector == nil {
+		return delegate
+	}
+	return &faultyPgAPI{delegate: delegate, injector: injector}
+}
+
+func (f *faultyPgAPI) GetDatasetClaim(ctx context.Context, user *pgdb.User, datasetNodeId string, organizationId int64) (*DatasetClaim, error) {
+	if err := f.injector.Inject(ctx, metrics.DependencyPostgres, "GetDatasetClaim"); err != nil {
+		return nil, err
+	}
+	return f.delegate.GetDatasetClaim(ctx, user, datasetNodeId, organizationId)
+}
+
+func (f *faultyPgAPI) GetOrganizationClaim(ctx context.Context, userId int64, organizationId int64) (*OrganizationClaim, error) {
+	if err := f.injector.Inject(ctx, metrics.DependencyPostgres, "GetOrganizationClaim"); err != nil {
+		return nil, err
+	}
+	return f.delegate.GetOrganizationClaim(ctx, userId, organizationId)
+}
+
+func (f *faultyPgAPI) GetOrganizationClaimByNodeId(ctx context.Context, userId int64, organizationNodeId string) (*OrganizationClaim, error) {
+	if err := f.injector.Inject(ctx, metrics.DependencyPostgres, "GetOrganizationClaimByNodeId"); err != nil {
+		return nil, err
+	}
+	return f.delegate.GetOrganizationClaimByNodeId(ctx, userId, organizationNodeId)
+}
+
+func (f *faultyPgAPI) GetTeamClaims(ctx context.Context, userId int64) ([]teamUser.Claim, error) {
+	if err := f.injector.Inject(ctx, metrics.DependencyPostgres, "GetTeamClaims"); err != nil {
+		return nil, err
+	}
+	return f.delegate.GetTeamClaims(ctx, userId)
+}
+
+func (f *faultyPgAPI) GetTeamClaimsForOrg(ctx context.Context, userId int64, organizationId int64) ([]teamUser.Claim, error) {
+	if err := f.injector.Inject(ctx, metrics.DependencyPostgres, "GetTeamClaimsForOrg"); err != nil {
+		return nil, err
+	}
+	return f.delegate.GetTeamClaimsForOrg(ctx, userId, organizationId)
+}
+
+func (f *faultyPgAPI) GetOrganizationIdForDataset(ctx context.Context, datasetNodeId string) (int64, error) {
+	if err := f.injector.Inject(ctx, metrics.DependencyPostgres, "GetOrganizationIdForDataset"); err != nil {
+		return 0, err
+	}
+	return f.delegate.GetOrganizationIdForDataset(ctx, datasetNodeId)
+}
+
+func (f *faultyPgAPI) GetUserByCognitoId(ctx context.Context, cognitoId string) (*pgdb.User, error) {
+	if err := f.injector.Inject(ctx, metrics.DependencyPostgres, "GetUserByCognitoId"); err != nil {
+		return nil, err
+	}
+	return f.delegate.GetUserByCognitoId(ctx, cognitoId)
+}
+
+func (f *faultyPgAPI) GetByCognitoId(ctx context.Context, cognitoId string) (*pgdb.User, error) {
+	if err := f.injector.Inject(ctx, metrics.DependencyPostgres, "GetByCognitoId"); err != nil {
+		return nil, err
+	}
+	return f.delegate.GetByCognitoId(ctx, cognitoId)
+}
+
+func (f *faultyPgAPI) GetUserByNodeId(ctx context.Context, nodeId string) (*pgdb.User, error) {
+	if err := f.injector.Inject(ctx, metrics.DependencyPostgres, "GetUserByNodeId"); err != nil {
+		return nil, err
+	}
+	return f.delegate.GetUserByNodeId(ctx, nodeId)
+}
+
+func (f *faultyPgAPI) GetOrganizationIpAllowlist(ctx context.Context, organizationId int64) ([]string, error) {
+	if err := f.injector.Inject(ctx, metrics.DependencyPostgres, "GetOrganizationIpAllowlist"); err != nil {
+		return nil, err
+	}
+	return f.delegate.GetOrganizationIpAllowlist(ctx, organizationId)
+}
+
+func (f *faultyPgAPI) GetOrganizationAuthPolicy(ctx context.Context, organizationId int64) (*AuthPolicy, error) {
+	if err := f.injector.Inject(ctx, metrics.DependencyPostgres, "GetOrganizationAuthPolicy"); err != nil {
+		return nil, err
+	}
+	return f.delegate.GetOrganizationAuthPolicy(ctx, organizationId)
+}
+
+func (f *faultyPgAPI) GetExternalDatasetGrant(ctx context.Context, userId int64, datasetNodeId string, organizationId int64) (*ExternalGrant, error) {
+	if err := f.injector.Inject(ctx, metrics.DependencyPostgres, "GetExternalDatasetGrant"); err != nil {
+		return nil, err
+	}
+	return f.delegate.GetExternalDatasetGrant(ctx, userId, datasetNodeId, organizationId)
+}
+
+func (f *faultyPgAPI) GetDatasetIdentity(ctx context.Context, datasetNodeId string, organizationId int64) (*DatasetIdentity, error) {
+	if err := f.injector.Inject(ctx, metrics.DependencyPostgres, "GetDatasetIdentity"); err != nil {
+		return nil, err
+	}
+	return f.delegate.GetDatasetIdentity(ctx, datasetNodeId, organizationId)
+}
+
+func (f *faultyPgAPI) GetDatasetState(ctx context.Context, datasetNodeId string, organizationId int64) (*claims.DatasetState, error) {
+	if err := f.injector.Inject(ctx, metrics.DependencyPostgres, "GetDatasetState"); err != nil {
+		return nil, err
+	}
+	return f.delegate.GetDatasetState(ctx, datasetNodeId, organizationId)
+}
+
+func (f *faultyPgAPI) GetDataUseAgreementStatus(ctx context.Context, userId int64, datasetNodeId string, organizationId int64) (*DataUseAgreementStatus, error) {
+	if err := f.injector.Inject(ctx, metrics.DependencyPostgres, "GetDataUseAgreementStatus"); err != nil {
+		return nil, err
+	}
+	return f.delegate.GetDataUseAgreementStatus(ctx, userId, datasetNodeId, organizationId)
+}
+
+// GetDatasetAuthorization returns errors.ErrUnsupported if the delegate cannot look it up in a single statement.
+func (f *faultyPgAPI) GetDatasetAuthorization(ctx context.Context, cognitoId string, isFromTokenPool bool, datasetNodeId string) (*DatasetAuthorization, error) {
+	delegate, ok := f.delegate.(DatasetAuthorizationAPI)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	if err := f.injector.Inject(ctx, metrics.DependencyPostgres, "GetDatasetAuthorization"); err != nil {
+		return nil, err
+	}
+	return delegate.GetDatasetAuthorization(ctx, cognitoId, isFromTokenPool, datasetNodeId)
+}
+
+// faultyDyAPI injects faults into the lookups made through a PennsieveDyAPI, before they are made.
+type faultyDyAPI struct {
+	delegate PennsieveDyAPI
+	injector *fault.Injector
+}
+
+// NewFaultyDyAPI returns a PennsieveDyAPI that injects the faults of injector into the lookups made through
+// delegate, as NewFaultyPgAPI does.
+func NewFaultyDyAPI(delegate PennsieveDyAPI, injector *fault.Injector) PennsieveDyAPI {
+	if injector == nil {
+		return delegate
+	}
+	return &faultyDyAPI{delegate: delegate, injector: injector}
+}
+
+func (f *faultyDyAPI) GetManifestById(ctx context.Context, manifestTableName string, manifestId string) (*coreDydb.ManifestTable, error) {
+	if err := f.injector.Inject(ctx, metrics.DependencyDynamoDB, "GetManifestById"); err != nil {
+		return nil, err
+	}
+	return f.delegate.GetManifestById(ctx, manifestTableName, manifestId)
+}
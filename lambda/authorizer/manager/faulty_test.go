@@ -0,0 +1,68 @@
+package manager_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pennsieve/pennsieve-go-api/authorizer/deadline"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/fault"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/metrics"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/test/mocks"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dydb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFaultyPgAPI(t *testing.T) {
+	recorder := mocks.NewMetrics()
+	mockPg := mocks.NewMockPennsievePgAPI()
+	mockPg.OnGetOrganizationIdForDataset("N:dataset:1").Return(int64(1001), nil)
+	injector := fault.NewInjector(fault.Config{Rules: []fault.Rule{
+		{Dependency: metrics.DependencyPostgres, Operation: "GetDatasetClaim", Kind: fault.KindError},
+		{Dependency: metrics.DependencyPostgres, Operation: "GetTeamClaims", Kind: fault.KindTimeout},
+	}})
+	// Faults are injected inside the timeout, as a slow or failing Postgres would be.
+	faulty := manager.NewTimedPgAPI(manager.NewFaultyPgAPI(mockPg, injector), recorder, 10*time.Millisecond)
+
+	orgId, err := faulty.GetOrganizationIdForDataset(context.Background(), "N:dataset:1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1001), orgId)
+
+	_, err = faulty.GetDatasetClaim(context.Background(), nil, "N:dataset:1", orgId)
+	assert.ErrorIs(t, err, fault.ErrInjected)
+	assert.False(t, deadline.IsTimeout(err))
+
+	_, err = faulty.GetTeamClaims(context.Background(), 101)
+	var timeout *deadline.TimeoutError
+	require.ErrorAs(t, err, &timeout)
+	assert.Equal(t, "GetTeamClaims", timeout.Operation)
+
+	_, err = faulty.(manager.DatasetAuthorizationAPI).GetDatasetAuthorization(context.Background(), "cognito-1", false, "N:dataset:1")
+	assert.ErrorIs(t, err, errors.ErrUnsupported)
+	mockPg.AssertExpectations(t)
+
+	assert.Same(t, mockPg, manager.NewFaultyPgAPI(mockPg, nil))
+}
+
+func TestFaultyDyAPI(t *testing.T) {
+	mockDy := mocks.NewMockPennsieveDyAPI()
+	manifest := &dydb.ManifestTable{ManifestId: "manifest-1", DatasetNodeId: "N:dataset:1", OrganizationId: 1001}
+	mockDy.OnGetManifestById("manifests", "manifest-1").Return(manifest, nil)
+	injector := fault.NewInjector(fault.Config{Rules: []fault.Rule{
+		{Dependency: metrics.DependencyDynamoDB, Operation: "GetManifestById", Kind: fault.KindError},
+	}})
+
+	// A failed call never reaches DynamoDB.
+	_, err := manager.NewFaultyDyAPI(mockDy, injector).GetManifestById(context.Background(), "manifests", "manifest-1")
+	assert.ErrorIs(t, err, fault.ErrInjected)
+	mockDy.AssertNotCalled(t, "GetManifestById")
+
+	slow := fault.NewInjector(fault.Config{Rules: []fault.Rule{{Dependency: metrics.DependencyDynamoDB, Kind: fault.KindLatency, LatencyMs: 5}}})
+	actual, err := manager.NewFaultyDyAPI(mockDy, slow).GetManifestById(context.Background(), "manifests", "manifest-1")
+	require.NoError(t, err)
+	assert.Equal(t, manifest, actual)
+	mockDy.AssertExpectations(t)
+}
@@ -0,0 +1,25 @@
+package manager
+
+import (
+	"context"
+
+	pgdbModels "github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+)
+
+// ImpersonatingManager is an IdentityManager that resolves claims for Target on behalf of Impersonator, a
+// super-admin. Only GetCurrentUser differs from the wrapped IdentityManager, so the target's claims are
+// computed exactly as they would be for the target's own request. Token workspace restrictions still come
+// from the impersonator's token.
+type ImpersonatingManager struct {
+	IdentityManager
+	Impersonator *pgdbModels.User
+	Target       *pgdbModels.User
+}
+
+func NewImpersonatingManager(delegate IdentityManager, impersonator *pgdbModels.User, target *pgdbModels.User) *ImpersonatingManager {
+	return &ImpersonatingManager{IdentityManager: delegate, Impersonator: impersonator, Target: target}
+}
+
+func (m *ImpersonatingManager) GetCurrentUser(ctx context.Context) (*pgdbModels.User, error) {
+	return m.Target, nil
+}
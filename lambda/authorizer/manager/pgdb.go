@@ -2,6 +2,9 @@ package manager
 
 import (
 	"context"
+	"time"
+
+	"github.com/pennsieve/pennsieve-go-api/authorizer/claims"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/dataset"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/organization"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
@@ -10,9 +13,11 @@ import (
 
 // PennsievePgAPI is an interface only containing the methods of *pgdb.Queries that are used by the ClaimsManager.
 type PennsievePgAPI interface {
-	GetDatasetClaim(ctx context.Context, user *pgdb.User, datasetNodeId string, organizationId int64) (*dataset.Claim, error)
-	GetOrganizationClaim(ctx context.Context, userId int64, organizationId int64) (*organization.Claim, error)
-	GetOrganizationClaimByNodeId(ctx context.Context, userId int64, organizationNodeId string) (*organization.Claim, error)
+	// GetDatasetClaim returns the user's claim on the dataset and when their role on it expires.
+	GetDatasetClaim(ctx context.Context, user *pgdb.User, datasetNodeId string, organizationId int64) (*DatasetClaim, error)
+	// GetOrganizationClaim returns the user's claim in the organization and when their membership expires.
+	GetOrganizationClaim(ctx context.Context, userId int64, organizationId int64) (*OrganizationClaim, error)
+	GetOrganizationClaimByNodeId(ctx context.Context, userId int64, organizationNodeId string) (*OrganizationClaim, error)
 	// GetTeamClaims is deprecated: it scopes to the user's preferred_org_id. Use GetTeamClaimsForOrg instead.
 	GetTeamClaims(ctx context.Context, userId int64) ([]teamUser.Claim, error)
 	// GetTeamClaimsForOrg returns the user's team claims within the given organization, resolved from the request.
@@ -23,4 +28,98 @@ type PennsievePgAPI interface {
 	GetUserByCognitoId(ctx context.Context, cognitoId string) (*pgdb.User, error)
 	// GetByCognitoId returns a Pennsieve User based on the cognito id in the users table.
 	GetByCognitoId(ctx context.Context, cognitoId string) (*pgdb.User, error)
+	// GetUserByNodeId returns a Pennsieve User based on the user's node id.
+	GetUserByNodeId(ctx context.Context, nodeId string) (*pgdb.User, error)
+	// GetOrganizationIpAllowlist returns the CIDR ranges the organization accepts requests from. Empty means no restriction.
+	GetOrganizationIpAllowlist(ctx context.Context, organizationId int64) ([]string, error)
+	// GetOrganizationAuthPolicy returns how the organization's resources may be authenticated for. An organization
+	// without a policy gets the zero AuthPolicy, which allows everything.
+	GetOrganizationAuthPolicy(ctx context.Context, organizationId int64) (*AuthPolicy, error)
+	// GetExternalDatasetGrant returns the role granted directly to the user on the dataset. It is how collaborators
+	// from outside the dataset's organization get access.
+	GetExternalDatasetGrant(ctx context.Context, userId int64, datasetNodeId string, organizationId int64) (*ExternalGrant, error)
+	// GetDatasetIdentity returns the dataset's id and the node id of its organization, whatever the user's role.
+	GetDatasetIdentity(ctx context.Context, datasetNodeId string, organizationId int64) (*DatasetIdentity, error)
+	// GetDatasetState returns the lifecycle state of the dataset: its status, publication lock and deletion.
+	GetDatasetState(ctx context.Context, datasetNodeId string, organizationId int64) (*claims.DatasetState, error)
+	// GetDataUseAgreementStatus returns whether the dataset requires its data-use agreement to be accepted, and
+	// whether the user has accepted it.
+	GetDataUseAgreementStatus(ctx context.Context, userId int64, datasetNodeId string, organizationId int64) (*DataUseAgreementStatus, error)
+}
+
+// OrganizationClaim is a user's claim in an organization, together with when their membership expires.
+type OrganizationClaim struct {
+	organization.Claim
+	// ExpiresAt is when the membership expires, or nil if it does not.
+	ExpiresAt *time.Time
+}
+
+// DatasetClaim is a user's claim on a dataset, together with when their role on it expires.
+type DatasetClaim struct {
+	dataset.Claim
+	// ExpiresAt is when the user stops having the role in the claim, or nil if they do not. They may keep a lower
+	// role after that.
+	ExpiresAt *time.Time
+}
+
+// DataUseAgreementStatus is whether a user may access a dataset as far as its data-use agreement is concerned.
+type DataUseAgreementStatus struct {
+	// Required is true if the dataset's data-use agreement must be accepted before the dataset is accessed.
+	Required bool
+	// AgreementId identifies the dataset's data-use agreement when Required.
+	AgreementId int64
+	// Accepted is true if the user has accepted that agreement for the dataset.
+	Accepted bool
+}
+
+// DatasetIdentity identifies a dataset, and the organization it belongs to, for claims that do not come from a role
+// on it.
+type DatasetIdentity struct {
+	OrganizationNodeId string
+	DatasetId          int64
+}
+
+// AuthPolicy is an organization's restrictions on how requests for its resources are authenticated. The zero
+// AuthPolicy restricts nothing.
+type AuthPolicy struct {
+	// AllowedIdentityProviders are the identity providers users must have signed in through, e.g. an institution's
+	// SAML provider, or IdentityProviderCognito for a Pennsieve password. Empty allows any.
+	AllowedIdentityProviders []string
+	// ApiTokensDisabled is true if API tokens may not be used.
+	ApiTokensDisabled bool
+	// CallbackTokensDisabled is true if callback tokens may not be used.
+	CallbackTokensDisabled bool
+}
+
+// ExternalGrant is a role on a dataset granted directly to a user, together with the organization that owns the
+// dataset. It is all that gives a user who is not a member of that organization access to the dataset.
+type ExternalGrant struct {
+	OrganizationNodeId string
+	Dataset            dataset.Claim
+	// ExpiresAt is when the grant expires, or nil if it does not.
+	ExpiresAt *time.Time
+}
+
+// DatasetAuthorizationAPI is implemented by PennsievePgAPIs that can look up everything the dataset authorizer reads
+// from Postgres in a single statement. Wrappers whose delegate cannot return errors.ErrUnsupported.
+type DatasetAuthorizationAPI interface {
+	// GetDatasetAuthorization returns the user with the given Cognito id, from the token pool if isFromTokenPool,
+	// together with their claims on the dataset and its organization. Returns sql.ErrNoRows if there is no such user.
+	GetDatasetAuthorization(ctx context.Context, cognitoId string, isFromTokenPool bool, datasetNodeId string) (*DatasetAuthorization, error)
+}
+
+// DatasetAuthorization is what GetCurrentUser, GetOrganizationIdForDataset, GetOrganizationClaim, GetDatasetClaim and
+// GetTeamClaimsForOrg would each return for a user and a dataset. What could not be found is left empty, to be
+// looked up, and reported, one by one.
+type DatasetAuthorization struct {
+	User *pgdb.User
+	// OrganizationId is the organization that owns the dataset, or zero if there is no such dataset.
+	OrganizationId int64
+	// Organization is the user's claim in that organization, or nil if they are not a member or their membership
+	// has expired.
+	Organization *OrganizationClaim
+	// Dataset is the user's claim on the dataset, or nil if the organization has no such dataset.
+	Dataset *DatasetClaim
+	// Teams are the user's teams in the organization. Only set if Organization is.
+	Teams []teamUser.Claim
 }
@@ -0,0 +1,754 @@
+package manager
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/claims"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dataset"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dataset/state"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/organization"
+	pgdbModels "github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/role"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/teamUser"
+	"github.com/pennsieve/pennsieve-go-core/pkg/queries/pgdb"
+	log "github.com/sirupsen/logrus"
+)
+
+// Queries extends *pgdb.Queries with the lookups the authorizer needs that are not part of pennsieve-go-core,
+// and overrides its organization and dataset claims to honor grants that expire. It satisfies PennsievePgAPI.
+type Queries struct {
+	*pgdb.Queries
+	db *sql.DB
+	// grantHorizon is how long before it expires a grant stops counting, so that no result cached for up to
+	// grantHorizon outlives it.
+	grantHorizon time.Duration
+	// authPoliciesOptional reads organizations as having no authentication policy while
+	// pennsieve.organization_auth_policies does not exist.
+	authPoliciesOptional bool
+	// ipAllowlistsOptional reads organizations as having no IP allowlist while
+	// pennsieve.organization_ip_allowlists does not exist.
+	ipAllowlistsOptional bool
+}
+
+// NewQueries returns a *Queries backed by the given connection. Grants that expire within grantHorizon, which
+// should be how long the results are cached for, are treated as expired.
+func NewQueries(db *sql.DB, grantHorizon time.Duration) *Queries {
+	return &Queries{Queries: pgdb.New(db), db: db, grantHorizon: grantHorizon}
+}
+
+// WithOptionalAuthPolicies returns q, reading organizations as having no authentication policy while
+// pennsieve.organization_auth_policies does not exist if optional is true. Otherwise GetOrganizationAuthPolicy
+// fails until the table exists.
+func (q *Queries) WithOptionalAuthPolicies(optional bool) *Queries {
+	q.authPoliciesOptional = optional
+	return q
+}
+
+// WithOptionalIpAllowlists returns q, reading organizations as having no IP allowlist while
+// pennsieve.organization_ip_allowlists does not exist if optional is true. Otherwise GetOrganizationIpAllowlist
+// fails until the table exists.
+func (q *Queries) WithOptionalIpAllowlists(optional bool) *Queries {
+	q.ipAllowlistsOptional = optional
+	return q
+}
+
+// undefinedTable is the Postgres error code for a query on a table that does not exist.
+const undefinedTable = "42P01"
+
+// isUndefinedTable reports whether err is Postgres reporting a query on a table that does not exist.
+func isUndefinedTable(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == undefinedTable
+}
+
+// grantExpiry renders grants' expiry in SQL. It is false while the grant tables lack their expires_at columns,
+// before the migrations that add them have run, and grants are then read as never expiring.
+type grantExpiry bool
+
+// membershipTables are the grant tables with expires_at columns in the pennsieve schema, and datasetGrantTables
+// those in each organization's schema.
+var (
+	membershipTables   = []string{"organization_user"}
+	datasetGrantTables = []string{"dataset_user", "dataset_team"}
+)
+
+// columnsCondition is the SQL condition that every one of tables in the schema named by the SQL expression schema
+// exists and has the column.
+func columnsCondition(schema string, tables []string, column string) string {
+	return "(SELECT bool_and(EXISTS (SELECT 1 FROM pg_attribute a " +
+		"WHERE a.attrelid = to_regclass(format('%I.%I', " + schema + ", t)) AND a.attname = '" + column + "' AND NOT a.attisdropped)) " +
+		"FROM unnest(ARRAY['" + strings.Join(tables, "', '") + "']) t)"
+}
+
+// schemaColumns caches, per schema and column, that the schema's tables have the column. Schemas found without it
+// are checked again on their next lookup, so that a migration takes effect as soon as it has run, and warned about
+// once.
+var schemaColumns struct {
+	sync.Mutex
+	present map[string]bool
+	warned  map[string]bool
+}
+
+// hasColumns returns whether tables in schema all have the column, which the platform's migrations add. Only a
+// schema found to have it is cached, and only for that schema: another organization's schema may not have been
+// migrated yet.
+func (q *Queries) hasColumns(ctx context.Context, schema string, tables []string, column string) (bool, error) {
+	key := schema + "." + column
+	schemaColumns.Lock()
+	defer schemaColumns.Unlock()
+	if schemaColumns.present[key] {
+		return true, nil
+	}
+	var present bool
+	queryStr := "SELECT " + columnsCondition("$1::text", tables, column) + ";"
+	if err := q.db.QueryRowContext(ctx, queryStr, schema).Scan(&present); err != nil {
+		return false, fmt.Errorf("unable to check for %s columns in schema %s: %w", column, schema, err)
+	}
+	if !present {
+		if !schemaColumns.warned[key] {
+			log.WithFields(log.Fields{"schema": schema, "tables": tables, "column": column}).
+				Warn("tables have no such column: read as its default until the migrations run")
+			if schemaColumns.warned == nil {
+				schemaColumns.warned = map[string]bool{}
+			}
+			schemaColumns.warned[key] = true
+		}
+		return false, nil
+	}
+	if schemaColumns.present == nil {
+		schemaColumns.present = map[string]bool{}
+	}
+	schemaColumns.present[key] = true
+	return true, nil
+}
+
+// membershipExpiry returns whether organization memberships can expire.
+func (q *Queries) membershipExpiry(ctx context.Context) (grantExpiry, error) {
+	present, err := q.hasColumns(ctx, "pennsieve", membershipTables, "expires_at")
+	return grantExpiry(present), err
+}
+
+// datasetGrantExpiry returns whether grants on the datasets of the organization can expire.
+func (q *Queries) datasetGrantExpiry(ctx context.Context, organizationId int64) (grantExpiry, error) {
+	present, err := q.hasColumns(ctx, strconv.FormatInt(organizationId, 10), datasetGrantTables, "expires_at")
+	return grantExpiry(present), err
+}
+
+// datasetProtection returns whether the organization's datasets can be marked protected.
+func (q *Queries) datasetProtection(ctx context.Context, organizationId int64) (bool, error) {
+	return q.hasColumns(ctx, strconv.FormatInt(organizationId, 10), []string{"datasets"}, "protected")
+}
+
+// expiresAt is the SQL expression for when the grant in the table with the given alias expires, NULL if never.
+func (e grantExpiry) expiresAt(alias string) string {
+	if !e {
+		return "NULL::timestamp"
+	}
+	return alias + ".expires_at"
+}
+
+// unexpired is the SQL condition, on a table alias, that a grant in that table does not expire within the horizon
+// given as the query parameter with the given index.
+func (e grantExpiry) unexpired(alias string, horizonParam int) string {
+	return e.unexpiredWithin(alias, fmt.Sprintf("$%d", horizonParam))
+}
+
+// unexpiredWithin is unexpired for a horizon, in seconds, given by any SQL expression. The horizon is part of the
+// condition even when grants never expire, so that queries have the same parameters either way.
+func (e grantExpiry) unexpiredWithin(alias string, horizon string) string {
+	return fmt.Sprintf("(%s IS NULL OR %s > now() + make_interval(secs => %s))", e.expiresAt(alias), e.expiresAt(alias), horizon)
+}
+
+// GetOrganizationClaim returns the user's claim in the organization and when their membership expires, or
+// pgdb.OrganizationUserNotFoundError if they are not a member or their membership has expired.
+func (q *Queries) GetOrganizationClaim(ctx context.Context, userId int64, organizationId int64) (*OrganizationClaim, error) {
+	return q.organizationClaim(ctx, userId, "o.id", organizationId)
+}
+
+// GetOrganizationClaimByNodeId is GetOrganizationClaim for the organization with the given node id.
+func (q *Queries) GetOrganizationClaimByNodeId(ctx context.Context, userId int64, organizationNodeId string) (*OrganizationClaim, error) {
+	return q.organizationClaim(ctx, userId, "o.node_id", organizationNodeId)
+}
+
+// organizationClaim returns the user's claim in the organization whose organizationColumn is organizationIdentifier.
+// Like the pennsieve-go-core claim queries, it reads one row per enabled feature flag of the organization, or a
+// single row if it has none, and each row also holds the membership's expiry.
+func (q *Queries) organizationClaim(ctx context.Context, userId int64, organizationColumn string, organizationIdentifier any) (*OrganizationClaim, error) {
+	expiry, err := q.membershipExpiry(ctx)
+	if err != nil {
+		return nil, err
+	}
+	queryStr := fmt.Sprintf("SELECT o.id, o.node_id, ou.permission_bit, %s, f.feature, f.created_at, f.updated_at "+
+		"FROM pennsieve.organization_user ou "+
+		"JOIN pennsieve.organizations o ON o.id = ou.organization_id "+
+		"LEFT JOIN pennsieve.feature_flags f ON f.organization_id = o.id AND f.enabled = true "+
+		"WHERE ou.user_id=$1 AND %s=$2 AND %s;", expiry.expiresAt("ou"), organizationColumn, expiry.unexpired("ou", 3))
+
+	rows, err := q.db.QueryContext(ctx, queryStr, userId, organizationIdentifier, q.grantHorizon.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var claim OrganizationClaim
+	for rows.Next() {
+		var expiresAt sql.NullTime
+		var feature sql.NullString
+		var createdAt, updatedAt sql.NullTime
+		if err := rows.Scan(&claim.IntId, &claim.NodeId, &claim.Role, &expiresAt, &feature, &createdAt, &updatedAt); err != nil {
+			return nil, err
+		}
+		if expiresAt.Valid {
+			claim.ExpiresAt = &expiresAt.Time
+		}
+		// As in pennsieve-go-core, a flag with a missing column is left out of the claim.
+		if feature.Valid && createdAt.Valid && updatedAt.Valid {
+			claim.EnabledFeatures = append(claim.EnabledFeatures, pgdbModels.FeatureFlags{
+				OrganizationId: claim.IntId,
+				Feature:        feature.String,
+				Enabled:        true,
+				CreatedAt:      createdAt.Time,
+				UpdatedAt:      updatedAt.Time,
+			})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if claim.IntId == 0 {
+		return nil, pgdb.OrganizationUserNotFoundError{
+			ErrorMessage: fmt.Sprintf("user id: %d is not a member of organization: %v, or their membership has expired", userId, organizationIdentifier),
+		}
+	}
+	return &claim, nil
+}
+
+// roleGrant is a role on a dataset and when it expires, nil if never.
+type roleGrant struct {
+	role      role.Role
+	expiresAt *time.Time
+}
+
+// GetDatasetClaim returns the highest role that the user has on the dataset: its default role for organization
+// members, the roles of the user's teams, or the user's own role, ignoring grants that have expired, and when the
+// user stops having it. Returns sql.ErrNoRows if there is no such dataset.
+func (q *Queries) GetDatasetClaim(ctx context.Context, user *pgdbModels.User, datasetNodeId string, organizationId int64) (*DatasetClaim, error) {
+	datasetId, grants, err := q.datasetGrants(ctx, user.Id, datasetNodeId, organizationId)
+	if err != nil {
+		return nil, err
+	}
+	highest, expiresAt := highestGrant(grants)
+	return &DatasetClaim{Claim: dataset.Claim{Role: highest, NodeId: datasetNodeId, IntId: datasetId}, ExpiresAt: expiresAt}, nil
+}
+
+// datasetGrants returns the id of the dataset and every grant that gives the user a role on it and has not
+// expired: its default role for organization members, which never expires, the grants to the user's teams, and
+// the grant to the user. Returns sql.ErrNoRows if there is no such dataset.
+func (q *Queries) datasetGrants(ctx context.Context, userId int64, datasetNodeId string, organizationId int64) (int64, []roleGrant, error) {
+	expiry, err := q.datasetGrantExpiry(ctx, organizationId)
+	if err != nil {
+		return 0, nil, err
+	}
+	queryStr := datasetGrantsQuery(expiry, fmt.Sprintf("\"%d\"", organizationId), "$1", "$2", "$3") + ";"
+
+	rows, err := q.db.QueryContext(ctx, queryStr, userId, datasetNodeId, q.grantHorizon.Seconds())
+	if err != nil {
+		return 0, nil, err
+	}
+	defer rows.Close()
+
+	var grantRows []grantRow
+	for rows.Next() {
+		var row grantRow
+		if err := rows.Scan(&row.Id, &row.DefaultRole, &row.Role, &row.ExpiresAt); err != nil {
+			return 0, nil, err
+		}
+		grantRows = append(grantRows, row)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, nil, err
+	}
+	if len(grantRows) == 0 {
+		return 0, nil, sql.ErrNoRows
+	}
+	grants, err := roleGrants(grantRows)
+	return grantRows[0].Id, grants, err
+}
+
+// grantRow is a row of datasetGrantsQuery.
+type grantRow struct {
+	Id          int64   `xml:"id"`
+	DefaultRole *string `xml:"default_role"`
+	Role        *string `xml:"role"`
+	ExpiresAt   *int64  `xml:"expires_at"`
+}
+
+// roleGrants returns the grants in the rows of datasetGrantsQuery for a dataset: its default role, if it has one,
+// and the role of each row that holds a grant.
+func roleGrants(rows []grantRow) ([]roleGrant, error) {
+	grants := []roleGrant{{role: role.None}}
+	if defaultRole := rows[0].DefaultRole; defaultRole != nil {
+		datasetRole, ok := role.RoleFromString(*defaultRole)
+		if !ok {
+			return nil, fmt.Errorf("error mapping dataset role from database string: %s", *defaultRole)
+		}
+		grants = append(grants, roleGrant{role: datasetRole})
+	}
+	for _, row := range rows {
+		if row.Role == nil {
+			continue
+		}
+		grantRole, ok := role.RoleFromString(*row.Role)
+		if !ok {
+			return nil, fmt.Errorf("error mapping dataset role from database string: %s", *row.Role)
+		}
+		grant := roleGrant{role: grantRole}
+		if row.ExpiresAt != nil {
+			expiresAt := time.UnixMicro(*row.ExpiresAt).UTC()
+			grant.expiresAt = &expiresAt
+		}
+		grants = append(grants, grant)
+	}
+	return grants, nil
+}
+
+// highestGrant returns the highest role among grants and when the user stops having it: nil if any grant of that
+// role never expires, and otherwise the latest expiry of those grants.
+func highestGrant(grants []roleGrant) (role.Role, *time.Time) {
+	sort.SliceStable(grants, func(i, j int) bool {
+		return grants[i].role > grants[j].role
+	})
+	highest := grants[0].role
+	var expiresAt *time.Time
+	for _, grant := range grants {
+		if grant.role != highest {
+			break
+		}
+		if grant.expiresAt == nil {
+			return highest, nil
+		}
+		if expiresAt == nil || grant.expiresAt.After(*expiresAt) {
+			expiresAt = grant.expiresAt
+		}
+	}
+	return highest, expiresAt
+}
+
+// datasetAuthorizationUsers select the columns of pgdbModels.User for the user, or for the owner of the API token,
+// with the Cognito id $1, as in GetByCognitoId and GetUserByCognitoId respectively.
+var datasetAuthorizationUsers = map[bool]string{
+	false: "SELECT id, node_id, email, first_name, last_name, is_super_admin, COALESCE(preferred_org_id, -1) AS preferred_org_id " +
+		"FROM pennsieve.users WHERE cognito_id=$1",
+	true: "SELECT u.id, u.node_id, u.email, u.first_name, u.last_name, u.is_super_admin, t.organization_id AS preferred_org_id " +
+		"FROM pennsieve.users u JOIN pennsieve.tokens t ON t.user_id = u.id WHERE t.token=$1",
+}
+
+// datasetGrantsQuery returns the query for the user's grants on a dataset, given SQL for the organization's schema,
+// the user id, the dataset node id and the horizon in seconds. It returns one row per unexpired grant, or a single
+// row with no grant, with the dataset's id and default role, and the grant's role and expiry in microseconds since
+// the epoch. datasetGrants runs it with query parameters, and GetDatasetAuthorization as a format() template, so it
+// must not contain quotes.
+func datasetGrantsQuery(expiry grantExpiry, schema, userId, datasetNodeId, horizon string) string {
+	return "SELECT d.id, d.role AS default_role, g.role, (extract(epoch FROM g.expires_at) * 1000000)::bigint AS expires_at " +
+		"FROM " + schema + ".datasets d LEFT JOIN (" +
+		"SELECT dt.dataset_id, dt.role, " + expiry.expiresAt("dt") + " AS expires_at FROM pennsieve.team_user tu " +
+		"JOIN " + schema + ".dataset_team dt ON dt.team_id = tu.team_id WHERE tu.user_id = " + userId + " AND " + expiry.unexpiredWithin("dt", horizon) + " " +
+		"UNION ALL " +
+		"SELECT du.dataset_id, du.role, " + expiry.expiresAt("du") + " AS expires_at FROM " + schema + ".dataset_user du WHERE du.user_id = " + userId + " AND " + expiry.unexpiredWithin("du", horizon) +
+		") g ON g.dataset_id = d.id WHERE d.node_id = " + datasetNodeId
+}
+
+// datasetGrantsTemplate is datasetGrantsQuery as a format() template taking the organization's schema, the user id,
+// the dataset node id and the horizon.
+func datasetGrantsTemplate(expiry grantExpiry) string {
+	return datasetGrantsQuery(expiry, "%1$I", "%2$s", "%3$L", "%4$s")
+}
+
+// GetDatasetAuthorization looks up the user and their claims on the dataset in one statement. The dataset's grants
+// live in its organization's schema, which is only known once the dataset's organization is, so they are selected
+// by a query built in the statement itself and returned as XML. For the same reason, the statement itself checks
+// whether that schema's grant tables have expires_at columns.
+func (q *Queries) GetDatasetAuthorization(ctx context.Context, cognitoId string, isFromTokenPool bool, datasetNodeId string) (*DatasetAuthorization, error) {
+	expiry, err := q.membershipExpiry(ctx)
+	if err != nil {
+		return nil, err
+	}
+	queryStr := "WITH u AS (" + datasetAuthorizationUsers[isFromTokenPool] + "), " +
+		"m AS (SELECT organization_id FROM pennsieve.dataset_organization WHERE dataset_node_id=$2::text) " +
+		"SELECT u.id, u.node_id, u.email, u.first_name, u.last_name, u.is_super_admin, u.preferred_org_id, " +
+		"m.organization_id, o.node_id, ou.permission_bit, " + expiry.expiresAt("ou") + ", " +
+		"(SELECT json_agg(json_build_object('feature', f.feature, " +
+		"'created_at', (extract(epoch FROM f.created_at) * 1000000)::bigint, " +
+		"'updated_at', (extract(epoch FROM f.updated_at) * 1000000)::bigint)) " +
+		"FROM pennsieve.feature_flags f WHERE f.organization_id = o.id AND f.enabled = true), " +
+		"(SELECT json_agg(json_build_object('id', t.id, 'name', t.name, 'node_id', t.node_id, " +
+		"'permission', ot.permission_bit, 'team_type', ot.system_team_type)) " +
+		"FROM pennsieve.organization_team ot JOIN pennsieve.teams t ON t.id = ot.team_id " +
+		"JOIN pennsieve.team_user tu ON tu.team_id = t.id AND tu.user_id = u.id " +
+		"WHERE ot.organization_id = o.id), " +
+		"CASE WHEN m.organization_id IS NOT NULL THEN query_to_xml(format(" +
+		"CASE WHEN " + columnsCondition("m.organization_id::text", datasetGrantTables, "expires_at") + " " +
+		"THEN '" + datasetGrantsTemplate(true) + "' ELSE '" + datasetGrantsTemplate(false) + "' END, " +
+		"m.organization_id::text, u.id, $2::text, $3::float8), false, false, '') END " +
+		"FROM u LEFT JOIN m ON true " +
+		"LEFT JOIN pennsieve.organization_user ou ON ou.user_id = u.id AND ou.organization_id = m.organization_id AND " + expiry.unexpired("ou", 3) + " " +
+		"LEFT JOIN pennsieve.organizations o ON o.id = ou.organization_id;"
+
+	var authorization DatasetAuthorization
+	var u pgdbModels.User
+	var organizationId sql.NullInt64
+	var organizationNodeId sql.NullString
+	var organizationRole sql.NullInt64
+	var organizationExpiresAt sql.NullTime
+	var featureFlags, teams []byte
+	var grants sql.NullString
+	row := q.db.QueryRowContext(ctx, queryStr, cognitoId, datasetNodeId, q.grantHorizon.Seconds())
+	if err := row.Scan(
+		&u.Id,
+		&u.NodeId,
+		&u.Email,
+		&u.FirstName,
+		&u.LastName,
+		&u.IsSuperAdmin,
+		&u.PreferredOrg,
+		&organizationId,
+		&organizationNodeId,
+		&organizationRole,
+		&organizationExpiresAt,
+		&featureFlags,
+		&teams,
+		&grants); err != nil {
+		return nil, err
+	}
+	authorization.User = &u
+	authorization.OrganizationId = organizationId.Int64
+
+	if organizationNodeId.Valid {
+		claim := OrganizationClaim{Claim: organization.Claim{
+			Role:   pgdbModels.DbPermission(organizationRole.Int64),
+			IntId:  organizationId.Int64,
+			NodeId: organizationNodeId.String,
+		}}
+		if organizationExpiresAt.Valid {
+			claim.ExpiresAt = &organizationExpiresAt.Time
+		}
+		var err error
+		if claim.EnabledFeatures, err = decodeFeatureFlags(featureFlags, organizationId.Int64); err != nil {
+			return nil, err
+		}
+		if authorization.Teams, err = decodeTeamClaims(teams); err != nil {
+			return nil, err
+		}
+		authorization.Organization = &claim
+	}
+
+	if grants.Valid {
+		datasetClaim, err := decodeDatasetGrants(grants.String, datasetNodeId)
+		if err != nil {
+			return nil, err
+		}
+		authorization.Dataset = datasetClaim
+	}
+	return &authorization, nil
+}
+
+// decodeFeatureFlags returns the enabled feature flags aggregated as JSON by GetDatasetAuthorization.
+func decodeFeatureFlags(featureFlags []byte, organizationId int64) ([]pgdbModels.FeatureFlags, error) {
+	if featureFlags == nil {
+		return nil, nil
+	}
+	var rows []struct {
+		Feature   *string `json:"feature"`
+		CreatedAt *int64  `json:"created_at"`
+		UpdatedAt *int64  `json:"updated_at"`
+	}
+	if err := json.Unmarshal(featureFlags, &rows); err != nil {
+		return nil, fmt.Errorf("error decoding feature flags: %w", err)
+	}
+	var flags []pgdbModels.FeatureFlags
+	for _, row := range rows {
+		// As in pennsieve-go-core, a flag with a missing column is left out of the claim.
+		if row.Feature == nil || row.CreatedAt == nil || row.UpdatedAt == nil {
+			continue
+		}
+		flags = append(flags, pgdbModels.FeatureFlags{
+			OrganizationId: organizationId,
+			Feature:        *row.Feature,
+			Enabled:        true,
+			CreatedAt:      time.UnixMicro(*row.CreatedAt).UTC(),
+			UpdatedAt:      time.UnixMicro(*row.UpdatedAt).UTC(),
+		})
+	}
+	return flags, nil
+}
+
+// decodeTeamClaims returns the team claims aggregated as JSON by GetDatasetAuthorization.
+func decodeTeamClaims(teams []byte) ([]teamUser.Claim, error) {
+	if teams == nil {
+		return nil, nil
+	}
+	var rows []struct {
+		Id         int64                   `json:"id"`
+		Name       string                  `json:"name"`
+		NodeId     string                  `json:"node_id"`
+		Permission pgdbModels.DbPermission `json:"permission"`
+		TeamType   *string                 `json:"team_type"`
+	}
+	if err := json.Unmarshal(teams, &rows); err != nil {
+		return nil, fmt.Errorf("error decoding team claims: %w", err)
+	}
+	var claims []teamUser.Claim
+	for _, row := range rows {
+		teamType := "<none>"
+		if row.TeamType != nil {
+			teamType = *row.TeamType
+		}
+		claims = append(claims, teamUser.Claim{
+			IntId:      row.Id,
+			Name:       row.Name,
+			NodeId:     row.NodeId,
+			Permission: row.Permission,
+			TeamType:   teamType,
+		})
+	}
+	return claims, nil
+}
+
+// decodeDatasetGrants returns the dataset claim for the rows of datasetGrantsTemplate, as returned by query_to_xml,
+// or nil if there are none because the organization has no such dataset.
+func decodeDatasetGrants(grants string, datasetNodeId string) (*DatasetClaim, error) {
+	var table struct {
+		Rows []grantRow `xml:"row"`
+	}
+	if err := xml.Unmarshal([]byte(grants), &table); err != nil {
+		return nil, fmt.Errorf("error decoding dataset grants: %w", err)
+	}
+	if len(table.Rows) == 0 {
+		return nil, nil
+	}
+	grantsOnDataset, err := roleGrants(table.Rows)
+	if err != nil {
+		return nil, err
+	}
+	highest, expiresAt := highestGrant(grantsOnDataset)
+	return &DatasetClaim{Claim: dataset.Claim{Role: highest, NodeId: datasetNodeId, IntId: table.Rows[0].Id}, ExpiresAt: expiresAt}, nil
+}
+
+// GetUserByNodeId returns a Pennsieve user by their node ID (e.g. "N:user:...").
+// Returns sql.ErrNoRows if no such user exists.
+func (q *Queries) GetUserByNodeId(ctx context.Context, nodeId string) (*pgdbModels.User, error) {
+	queryStr := "SELECT id, node_id, email, first_name, last_name, is_super_admin, COALESCE(preferred_org_id, -1) as preferred_org_id " +
+		"FROM pennsieve.users WHERE node_id=$1;"
+
+	var u pgdbModels.User
+	row := q.db.QueryRowContext(ctx, queryStr, nodeId)
+	err := row.Scan(
+		&u.Id,
+		&u.NodeId,
+		&u.Email,
+		&u.FirstName,
+		&u.LastName,
+		&u.IsSuperAdmin,
+		&u.PreferredOrg)
+
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// GetOrganizationIpAllowlist returns the CIDR ranges in pennsieve.organization_ip_allowlists for the organization.
+// An empty result means the organization does not restrict source IPs, as it does if the table does not exist and q
+// was created WithOptionalIpAllowlists.
+func (q *Queries) GetOrganizationIpAllowlist(ctx context.Context, organizationId int64) ([]string, error) {
+	queryStr := "SELECT cidr::text FROM pennsieve.organization_ip_allowlists WHERE organization_id=$1;"
+
+	rows, err := q.db.QueryContext(ctx, queryStr, organizationId)
+	if err != nil {
+		if q.ipAllowlistsOptional && isUndefinedTable(err) {
+			log.WithField("organizationId", organizationId).Debug("no organization_ip_allowlists table, reading no allowlist")
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	var allowlist []string
+	for rows.Next() {
+		var cidr string
+		if err := rows.Scan(&cidr); err != nil {
+			return nil, err
+		}
+		allowlist = append(allowlist, cidr)
+	}
+	return allowlist, rows.Err()
+}
+
+// GetOrganizationAuthPolicy returns the organization's row in pennsieve.organization_auth_policies, or the zero
+// AuthPolicy if it has none, or if the table does not exist and q was created WithOptionalAuthPolicies.
+func (q *Queries) GetOrganizationAuthPolicy(ctx context.Context, organizationId int64) (*AuthPolicy, error) {
+	queryStr := "SELECT COALESCE(array_to_json(allowed_identity_providers)::text, '[]'), " +
+		"COALESCE(api_tokens_disabled, false), NOT COALESCE(callback_tokens_allowed, true) " +
+		"FROM pennsieve.organization_auth_policies WHERE organization_id=$1;"
+
+	var policy AuthPolicy
+	var providers string
+	row := q.db.QueryRowContext(ctx, queryStr, organizationId)
+	if err := row.Scan(&providers, &policy.ApiTokensDisabled, &policy.CallbackTokensDisabled); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &AuthPolicy{}, nil
+		}
+		if q.authPoliciesOptional && isUndefinedTable(err) {
+			log.WithField("organizationId", organizationId).Debug("no organization_auth_policies table, reading no policy")
+			return &AuthPolicy{}, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(providers), &policy.AllowedIdentityProviders); err != nil {
+		return nil, fmt.Errorf("invalid allowed_identity_providers for organization %d: %w", organizationId, err)
+	}
+	return &policy, nil
+}
+
+// GetExternalDatasetGrant returns the role in the user's own dataset_user row for the dataset. Unlike
+// GetDatasetClaim, it ignores the dataset's default role for organization members and the roles of the user's
+// teams, neither of which apply to a user outside the organization. Returns sql.ErrNoRows if there is no such row
+// or the grant has expired.
+func (q *Queries) GetExternalDatasetGrant(ctx context.Context, userId int64, datasetNodeId string, organizationId int64) (*ExternalGrant, error) {
+	expiry, err := q.datasetGrantExpiry(ctx, organizationId)
+	if err != nil {
+		return nil, err
+	}
+	queryStr := fmt.Sprintf("SELECT o.node_id, d.id, du.role, %s FROM \"%d\".dataset_user du "+
+		"JOIN \"%d\".datasets d ON d.id = du.dataset_id "+
+		"JOIN pennsieve.organizations o ON o.id = $3 "+
+		"WHERE du.user_id=$1 AND d.node_id=$2 AND %s;", expiry.expiresAt("du"), organizationId, organizationId, expiry.unexpired("du", 4))
+
+	grant := ExternalGrant{Dataset: dataset.Claim{NodeId: datasetNodeId}}
+	var roleString string
+	var expiresAt sql.NullTime
+	row := q.db.QueryRowContext(ctx, queryStr, userId, datasetNodeId, organizationId, q.grantHorizon.Seconds())
+	if err := row.Scan(&grant.OrganizationNodeId, &grant.Dataset.IntId, &roleString, &expiresAt); err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid {
+		grant.ExpiresAt = &expiresAt.Time
+	}
+	datasetRole, ok := role.RoleFromString(roleString)
+	if !ok {
+		return nil, fmt.Errorf("error mapping dataset role from database string: %s", roleString)
+	}
+	grant.Dataset.Role = datasetRole
+	return &grant, nil
+}
+
+// lockedPublicationStatuses are the publication statuses during which a dataset must not change: the request is
+// awaiting review, has been accepted and is being published, or failed and is awaiting a retry.
+var lockedPublicationStatuses = map[string]bool{"requested": true, "accepted": true, "failed": true}
+
+// GetDatasetIdentity returns the id of the dataset in the organization's datasets table and the node id of the
+// organization. Returns sql.ErrNoRows if there is no such dataset.
+func (q *Queries) GetDatasetIdentity(ctx context.Context, datasetNodeId string, organizationId int64) (*DatasetIdentity, error) {
+	queryStr := fmt.Sprintf("SELECT o.node_id, d.id FROM \"%d\".datasets d "+
+		"JOIN pennsieve.organizations o ON o.id = $2 "+
+		"WHERE d.node_id=$1;", organizationId)
+
+	var identity DatasetIdentity
+	row := q.db.QueryRowContext(ctx, queryStr, datasetNodeId, organizationId)
+	if err := row.Scan(&identity.OrganizationNodeId, &identity.DatasetId); err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// GetDatasetState returns the state of the dataset from its row in the organization's datasets table and the
+// status and latest publication request it references. The dataset is not protected while the datasets table has
+// no protected column, before the migration that adds it has run in the organization's schema. Returns
+// sql.ErrNoRows if there is no such dataset.
+func (q *Queries) GetDatasetState(ctx context.Context, datasetNodeId string, organizationId int64) (*claims.DatasetState, error) {
+	protection, err := q.datasetProtection(ctx, organizationId)
+	if err != nil {
+		return nil, err
+	}
+	protected := "false"
+	if protection {
+		protected = "COALESCE(d.protected, false)"
+	}
+	queryStr := fmt.Sprintf("SELECT d.state, COALESCE(s.name, ''), COALESCE(p.publication_status, ''), %s "+
+		"FROM \"%d\".datasets d "+
+		"LEFT JOIN \"%d\".dataset_status s ON s.id = d.status_id "+
+		"LEFT JOIN \"%d\".dataset_publication_status p ON p.id = d.publication_status_id "+
+		"WHERE d.node_id=$1;", protected, organizationId, organizationId, organizationId)
+
+	var datasetState string
+	var datasetStatus claims.DatasetState
+	row := q.db.QueryRowContext(ctx, queryStr, datasetNodeId)
+	if err := row.Scan(&datasetState, &datasetStatus.Status, &datasetStatus.PublicationStatus, &datasetStatus.Protected); err != nil {
+		return nil, err
+	}
+	datasetStatus.PublicationLocked = lockedPublicationStatuses[strings.ToLower(datasetStatus.PublicationStatus)]
+	datasetStatus.Deleted = datasetState == state.DELETING
+	return &datasetStatus, nil
+}
+
+// GetDataUseAgreementStatus returns the status of the dataset's data-use agreement for the user. Acceptance is
+// required when the dataset is protected and references an agreement. The platform records an acceptance in
+// dataset_previewer when the user requests access, and only one of the agreement the dataset currently references
+// counts, so that replacing a dataset's agreement requires users to accept the new one. No dataset requires
+// acceptance while the datasets table has no protected column.
+func (q *Queries) GetDataUseAgreementStatus(ctx context.Context, userId int64, datasetNodeId string, organizationId int64) (*DataUseAgreementStatus, error) {
+	protection, err := q.datasetProtection(ctx, organizationId)
+	if err != nil {
+		return nil, err
+	}
+	if !protection {
+		return &DataUseAgreementStatus{}, nil
+	}
+	queryStr := fmt.Sprintf("SELECT d.data_use_agreement_id, EXISTS ("+
+		"SELECT 1 FROM \"%d\".dataset_previewer p "+
+		"WHERE p.dataset_id = d.id AND p.user_id = $2 AND p.data_use_agreement_id = d.data_use_agreement_id) "+
+		"FROM \"%d\".datasets d "+
+		"WHERE d.node_id=$1 AND d.protected AND d.data_use_agreement_id IS NOT NULL;", organizationId, organizationId)
+
+	status := DataUseAgreementStatus{Required: true}
+	row := q.db.QueryRowContext(ctx, queryStr, datasetNodeId, userId)
+	if err := row.Scan(&status.AgreementId, &status.Accepted); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// Not protected, or no agreement.
+			return &DataUseAgreementStatus{}, nil
+		}
+		return nil, err
+	}
+	return &status, nil
+}
+
+// GetDatasetNodeIdForPackage returns the node id of the dataset containing the given package, which lives in the
+// schema of the organization with the given node id. Returns sql.ErrNoRows if either does not exist.
+func (q *Queries) GetDatasetNodeIdForPackage(ctx context.Context, organizationNodeId string, packageNodeId string) (string, error) {
+	var organizationId int64
+	row := q.db.QueryRowContext(ctx, "SELECT id FROM pennsieve.organizations WHERE node_id=$1;", organizationNodeId)
+	if err := row.Scan(&organizationId); err != nil {
+		return "", err
+	}
+
+	queryStr := fmt.Sprintf("SELECT d.node_id FROM \"%d\".packages p JOIN \"%d\".datasets d ON d.id = p.dataset_id "+
+		"WHERE p.node_id=$1;", organizationId, organizationId)
+
+	var datasetNodeId string
+	row = q.db.QueryRowContext(ctx, queryStr, packageNodeId)
+	if err := row.Scan(&datasetNodeId); err != nil {
+		return "", err
+	}
+	return datasetNodeId, nil
+}
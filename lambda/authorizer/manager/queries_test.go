@@ -0,0 +1,237 @@
+package manager_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/test"
+	pgModels "github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/role"
+	"github.com/pennsieve/pennsieve-go-core/pkg/queries/pgdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// seedOrgId is a workspace in the seed database.
+const seedOrgId int64 = 2
+
+// TestGetDatasetAuthorization checks, against the seed database, that the single statement of
+// GetDatasetAuthorization finds what the lookups it replaces find one by one.
+func TestGetDatasetAuthorization(t *testing.T) {
+	pgDB := connectSeedDB(t)
+
+	for scenario, params := range map[string]struct {
+		// setUp adds what the scenario needs for the user and dataset, and returns the user's Cognito id.
+		setUp         func(t *testing.T, userId int64, datasetId int64) string
+		fromTokenPool bool
+		member        bool
+	}{
+		"user pool member with a dataset role": {
+			setUp: func(t *testing.T, userId int64, datasetId int64) string {
+				test.AddOrgUser(t, pgDB, seedOrgId, userId, pgModels.Write)
+				test.AddDatasetUser(t, pgDB, seedOrgId, datasetId, userId, role.Manager)
+				return testDatasetUserCognitoId
+			},
+			member: true,
+		},
+		"user pool member with an expired dataset role": {
+			setUp: func(t *testing.T, userId int64, datasetId int64) string {
+				test.AddOrgUser(t, pgDB, seedOrgId, userId, pgModels.Write)
+				test.AddDatasetUser(t, pgDB, seedOrgId, datasetId, userId, role.Manager)
+				test.ExpireDatasetUser(t, pgDB, seedOrgId, datasetId, userId, time.Now().Add(-time.Hour))
+				return testDatasetUserCognitoId
+			},
+			member: true,
+		},
+		"user pool member without a dataset role": {
+			setUp: func(t *testing.T, userId int64, datasetId int64) string {
+				test.AddOrgUser(t, pgDB, seedOrgId, userId, pgModels.Read)
+				return testDatasetUserCognitoId
+			},
+			member: true,
+		},
+		"token pool member with a dataset role": {
+			setUp: func(t *testing.T, userId int64, datasetId int64) string {
+				apiKey := uuid.NewString()
+				test.AddOrgUser(t, pgDB, seedOrgId, userId, pgModels.Delete)
+				test.AddAPIToken(t, pgDB, seedOrgId, userId, apiKey, uuid.NewString())
+				test.AddDatasetUser(t, pgDB, seedOrgId, datasetId, userId, role.Editor)
+				return apiKey
+			},
+			fromTokenPool: true,
+			member:        true,
+		},
+		"not a member": {
+			setUp: func(t *testing.T, userId int64, datasetId int64) string {
+				test.AddDatasetUser(t, pgDB, seedOrgId, datasetId, userId, role.Viewer)
+				return testDatasetUserCognitoId
+			},
+		},
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			testUser := test.NewUser(102, seedOrgId)
+			test.AddUser(t, pgDB, testUser, testDatasetUserCognitoId)
+			t.Cleanup(func() {
+				test.DeleteUser(t, pgDB, testUser.Id)
+			})
+			datasetId, datasetNodeId := test.AddDataset(t, pgDB, seedOrgId, "GetDatasetAuthorization")
+			t.Cleanup(func() {
+				test.DeleteDataset(t, pgDB, seedOrgId, datasetNodeId)
+			})
+			cognitoId := params.setUp(t, testUser.Id, datasetId)
+			queries := manager.NewQueries(pgDB, 0)
+			ctx := context.Background()
+
+			authorization, err := queries.GetDatasetAuthorization(ctx, cognitoId, params.fromTokenPool, datasetNodeId)
+			require.NoError(t, err)
+
+			expectedUser := lookUpUser(t, queries, cognitoId, params.fromTokenPool)
+			assert.Equal(t, expectedUser, authorization.User)
+			expectedOrgId, err := queries.GetOrganizationIdForDataset(ctx, datasetNodeId)
+			require.NoError(t, err)
+			assert.Equal(t, expectedOrgId, authorization.OrganizationId)
+			expectedDatasetClaim, err := queries.GetDatasetClaim(ctx, expectedUser, datasetNodeId, expectedOrgId)
+			require.NoError(t, err)
+			assert.Equal(t, expectedDatasetClaim, authorization.Dataset)
+
+			expectedOrgClaim, err := queries.GetOrganizationClaim(ctx, expectedUser.Id, expectedOrgId)
+			if !params.member {
+				assert.ErrorAs(t, err, &pgdb.OrganizationUserNotFoundError{})
+				assert.Nil(t, authorization.Organization)
+				assert.Nil(t, authorization.Teams)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, inUTC(expectedOrgClaim), inUTC(authorization.Organization))
+			expectedTeamClaims, err := queries.GetTeamClaimsForOrg(ctx, expectedUser.Id, expectedOrgId)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, expectedTeamClaims, authorization.Teams)
+		})
+	}
+
+	t.Run("unknown user", func(t *testing.T) {
+		_, err := manager.NewQueries(pgDB, 0).GetDatasetAuthorization(context.Background(), uuid.NewString(), false, "N:dataset:unknown")
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+	})
+}
+
+// TestGetDataUseAgreementStatus checks GetDataUseAgreementStatus against the seed database with the schema files
+// applied.
+func TestGetDataUseAgreementStatus(t *testing.T) {
+	pgDB := connectSeedDB(t)
+
+	for scenario, params := range map[string]struct {
+		// protected is whether the dataset holds protected health information, and agreement whether it has a
+		// data-use agreement.
+		protected bool
+		agreement bool
+		accepted  bool
+		// acceptedEarlier is whether the user accepted an agreement the dataset referenced before its current one.
+		acceptedEarlier bool
+		expected        manager.DataUseAgreementStatus
+	}{
+		"unprotected with agreement":  {agreement: true, expected: manager.DataUseAgreementStatus{}},
+		"protected without agreement": {protected: true, expected: manager.DataUseAgreementStatus{}},
+		"agreement not accepted":      {protected: true, agreement: true, expected: manager.DataUseAgreementStatus{Required: true}},
+		"earlier agreement accepted":  {protected: true, agreement: true, acceptedEarlier: true, expected: manager.DataUseAgreementStatus{Required: true}},
+		"agreement accepted":          {protected: true, agreement: true, accepted: true, expected: manager.DataUseAgreementStatus{Required: true, Accepted: true}},
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			testUser := test.NewUser(103, seedOrgId)
+			test.AddUser(t, pgDB, testUser, uuid.NewString())
+			t.Cleanup(func() {
+				test.DeleteUser(t, pgDB, testUser.Id)
+			})
+			// The agreements are added first so that they are deleted after the dataset referencing them, whose
+			// deletion cascades to the user's acceptances.
+			var earlierId, agreementId int64
+			if params.acceptedEarlier {
+				earlierId = test.AddDataUseAgreement(t, pgDB, seedOrgId)
+				t.Cleanup(func() {
+					test.DeleteDataUseAgreement(t, pgDB, seedOrgId, earlierId)
+				})
+			}
+			if params.agreement {
+				agreementId = test.AddDataUseAgreement(t, pgDB, seedOrgId)
+				t.Cleanup(func() {
+					test.DeleteDataUseAgreement(t, pgDB, seedOrgId, agreementId)
+				})
+			}
+			datasetId, datasetNodeId := test.AddDataset(t, pgDB, seedOrgId, "GetDataUseAgreementStatus")
+			t.Cleanup(func() {
+				test.DeleteDataset(t, pgDB, seedOrgId, datasetNodeId)
+			})
+			if params.protected {
+				test.ProtectDataset(t, pgDB, seedOrgId, datasetId)
+			}
+			if params.acceptedEarlier {
+				test.SetDatasetDataUseAgreement(t, pgDB, seedOrgId, datasetId, earlierId)
+				test.AcceptDataUseAgreement(t, pgDB, seedOrgId, earlierId, datasetId, testUser.Id)
+			}
+			expected := params.expected
+			if params.agreement {
+				test.SetDatasetDataUseAgreement(t, pgDB, seedOrgId, datasetId, agreementId)
+				if params.accepted {
+					test.AcceptDataUseAgreement(t, pgDB, seedOrgId, agreementId, datasetId, testUser.Id)
+				}
+				if expected.Required {
+					expected.AgreementId = agreementId
+				}
+			}
+
+			status, err := manager.NewQueries(pgDB, 0).GetDataUseAgreementStatus(context.Background(), testUser.Id, datasetNodeId, seedOrgId)
+			require.NoError(t, err)
+			assert.Equal(t, &expected, status)
+		})
+	}
+}
+
+// connectSeedDB connects to the seed database named by the POSTGRES_* variables and applies the schema files of the
+// test package.
+func connectSeedDB(t *testing.T) *sql.DB {
+	pgDB, err := pgdb.ConnectENV()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		if err := pgDB.Close(); err != nil {
+			t.Log("error closing test Postgres DB:", err)
+		}
+	})
+	require.NoError(t, pgDB.Ping())
+	test.Migrate(t, pgDB)
+	return pgDB
+}
+
+// testDatasetUserCognitoId is the Cognito id of the user TestGetDatasetAuthorization adds.
+var testDatasetUserCognitoId = uuid.NewString()
+
+// lookUpUser looks up the user with the given Cognito id the way ClaimsManager.GetCurrentUser does.
+func lookUpUser(t *testing.T, queries *manager.Queries, cognitoId string, fromTokenPool bool) *pgModels.User {
+	lookUp := queries.GetByCognitoId
+	if fromTokenPool {
+		lookUp = queries.GetUserByCognitoId
+	}
+	currentUser, err := lookUp(context.Background(), cognitoId)
+	require.NoError(t, err)
+	return currentUser
+}
+
+// inUTC returns claim with its expiry and feature flags' times in UTC, so that claims read by different queries
+// compare equal.
+func inUTC(claim *manager.OrganizationClaim) *manager.OrganizationClaim {
+	inUTC := *claim
+	if claim.ExpiresAt != nil {
+		expiresAt := claim.ExpiresAt.UTC()
+		inUTC.ExpiresAt = &expiresAt
+	}
+	inUTC.EnabledFeatures = nil
+	for _, flag := range claim.EnabledFeatures {
+		flag.CreatedAt = flag.CreatedAt.UTC()
+		flag.UpdatedAt = flag.UpdatedAt.UTC()
+		inUTC.EnabledFeatures = append(inUTC.EnabledFeatures, flag)
+	}
+	return &inUTC
+}
@@ -0,0 +1,32 @@
+package manager
+
+import "context"
+
+// Request describes the inbound request being authorized, for checks that depend on more than the
+// principal and the resource, such as network policy.
+type Request struct {
+	SourceIp string
+	Method   string
+	Route    string
+	// Callback is true when the principal was established by a callback token rather than a Cognito JWT.
+	Callback bool
+	// PurposeOfUse is the purpose of use the client declared, one of the claims.PurposeOfUse* values, or empty.
+	PurposeOfUse string
+	// Uncached is true when API Gateway does not cache the decision for the request, so that it may depend on
+	// headers that are not identity sources.
+	Uncached bool
+}
+
+type requestKey struct{}
+
+// WithRequest returns a copy of ctx carrying request.
+func WithRequest(ctx context.Context, request Request) context.Context {
+	return context.WithValue(ctx, requestKey{}, request)
+}
+
+// RequestFromContext returns the Request stored in ctx by WithRequest. The boolean is false when ctx
+// carries no request, as for direct Lambda-to-Lambda invocations, which have no caller network identity.
+func RequestFromContext(ctx context.Context) (Request, bool) {
+	request, ok := ctx.Value(requestKey{}).(Request)
+	return request, ok
+}
@@ -0,0 +1,171 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/pennsieve/pennsieve-go-api/authorizer/claims"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/deadline"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/metrics"
+	coreDydb "github.com/pennsieve/pennsieve-go-core/pkg/models/dydb"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/teamUser"
+)
+
+// timedPgAPI records the latency of every query made through a PennsievePgAPI, and bounds each by a timeout.
+type timedPgAPI struct {
+	delegate PennsievePgAPI
+	metrics  metrics.Metrics
+	timeout  time.Duration
+}
+
+// NewTimedPgAPI returns a PennsievePgAPI that records the latency of each query made through delegate and gives it
+// at most timeout, or, if zero, whatever remains of the invocation's budget. A query that runs out of time fails
+// with a *deadline.TimeoutError.
+func NewTimedPgAPI(delegate PennsievePgAPI, m metrics.Metrics, timeout time.Duration) PennsievePgAPI {
+	return &timedPgAPI{delegate: delegate, metrics: m, timeout: timeout}
+}
+
+func (t *timedPgAPI) start(ctx context.Context, operation string) (context.Context, func(error) error) {
+	return track(ctx, t.metrics, metrics.DependencyPostgres, operation, t.timeout)
+}
+
+// track starts one call to a dependency: it bounds ctx by timeout and starts timing the call. Call the returned
+// function with the call's error when it completes; it records the latency and returns the error, as a
+// *deadline.TimeoutError if the call ran out of time.
+func track(ctx context.Context, m metrics.Metrics, dependency string, operation string, timeout time.Duration) (context.Context, func(error) error) {
+	stopTracking := metrics.Track(m, dependency, operation)
+	ctx, cancel := deadline.Bound(ctx, timeout)
+	return ctx, func(err error) error {
+		stopTracking()
+		err = deadline.Check(ctx, operation, err)
+		cancel()
+		return err
+	}
+}
+
+func (t *timedPgAPI) GetDatasetClaim(ctx context.Context, user *pgdb.User, datasetNodeId string, organizationId int64) (*DatasetClaim, error) {
+	ctx, done := t.start(ctx, "GetDatasetClaim")
+	result, err := t.delegate.GetDatasetClaim(ctx, user, datasetNodeId, organizationId)
+	return result, done(err)
+}
+
+func (t *timedPgAPI) GetOrganizationClaim(ctx context.Context, userId int64, organizationId int64) (*OrganizationClaim, error) {
+	ctx, done := t.start(ctx, "GetOrganizationClaim")
+	result, err := t.delegate.GetOrganizationClaim(ctx, userId, organizationId)
+	return result, done(err)
+}
+
+func (t *timedPgAPI) GetOrganizationClaimByNodeId(ctx context.Context, userId int64, organizationNodeId string) (*OrganizationClaim, error) {
+	ctx, done := t.start(ctx, "GetOrganizationClaimByNodeId")
+	result, err := t.delegate.GetOrganizationClaimByNodeId(ctx, userId, organizationNodeId)
+	return result, done(err)
+}
+
+func (t *timedPgAPI) GetTeamClaims(ctx context.Context, userId int64) ([]teamUser.Claim, error) {
+	ctx, done := t.start(ctx, "GetTeamClaims")
+	result, err := t.delegate.GetTeamClaims(ctx, userId)
+	return result, done(err)
+}
+
+func (t *timedPgAPI) GetTeamClaimsForOrg(ctx context.Context, userId int64, organizationId int64) ([]teamUser.Claim, error) {
+	ctx, done := t.start(ctx, "GetTeamClaimsForOrg")
+	result, err := t.delegate.GetTeamClaimsForOrg(ctx, userId, organizationId)
+	return result, done(err)
+}
+
+func (t *timedPgAPI) GetOrganizationIdForDataset(ctx context.Context, datasetNodeId string) (int64, error) {
+	ctx, done := t.start(ctx, "GetOrganizationIdForDataset")
+	result, err := t.delegate.GetOrganizationIdForDataset(ctx, datasetNodeId)
+	return result, done(err)
+}
+
+func (t *timedPgAPI) GetUserByCognitoId(ctx context.Context, cognitoId string) (*pgdb.User, error) {
+	ctx, done := t.start(ctx, "GetUserByCognitoId")
+	result, err := t.delegate.GetUserByCognitoId(ctx, cognitoId)
+	return result, done(err)
+}
+
+func (t *timedPgAPI) GetByCognitoId(ctx context.Context, cognitoId string) (*pgdb.User, error) {
+	ctx, done := t.start(ctx, "GetByCognitoId")
+	result, err := t.delegate.GetByCognitoId(ctx, cognitoId)
+	return result, done(err)
+}
+
+func (t *timedPgAPI) GetUserByNodeId(ctx context.Context, nodeId string) (*pgdb.User, error) {
+	ctx, done := t.start(ctx, "GetUserByNodeId")
+	result, err := t.delegate.GetUserByNodeId(ctx, nodeId)
+	return result, done(err)
+}
+
+func (t *timedPgAPI) GetOrganizationIpAllowlist(ctx context.Context, organizationId int64) ([]string, error) {
+	ctx, done := t.start(ctx, "GetOrganizationIpAllowlist")
+	result, err := t.delegate.GetOrganizationIpAllowlist(ctx, organizationId)
+	return result, done(err)
+}
+
+func (t *timedPgAPI) GetOrganizationAuthPolicy(ctx context.Context, organizationId int64) (*AuthPolicy, error) {
+	ctx, done := t.start(ctx, "GetOrganizationAuthPolicy")
+	result, err := t.delegate.GetOrganizationAuthPolicy(ctx, organizationId)
+	return result, done(err)
+}
+
+func (t *timedPgAPI) GetExternalDatasetGrant(ctx context.Context, userId int64, datasetNodeId string, organizationId int64) (*ExternalGrant, error) {
+	ctx, done := t.start(ctx, "GetExternalDatasetGrant")
+	result, err := t.delegate.GetExternalDatasetGrant(ctx, userId, datasetNodeId, organizationId)
+	return result, done(err)
+}
+
+func (t *timedPgAPI) GetDatasetIdentity(ctx context.Context, datasetNodeId string, organizationId int64) (*DatasetIdentity, error) {
+	ctx, done := t.start(ctx, "GetDatasetIdentity")
+	result, err := t.delegate.GetDatasetIdentity(ctx, datasetNodeId, organizationId)
+	return result, done(err)
+}
+
+func (t *timedPgAPI) GetDatasetState(ctx context.Context, datasetNodeId string, organizationId int64) (*claims.DatasetState, error) {
+	ctx, done := t.start(ctx, "GetDatasetState")
+	result, err := t.delegate.GetDatasetState(ctx, datasetNodeId, organizationId)
+	return result, done(err)
+}
+
+func (t *timedPgAPI) GetDataUseAgreementStatus(ctx context.Context, userId int64, datasetNodeId string, organizationId int64) (*DataUseAgreementStatus, error) {
+	ctx, done := t.start(ctx, "GetDataUseAgreementStatus")
+	result, err := t.delegate.GetDataUseAgreementStatus(ctx, userId, datasetNodeId, organizationId)
+	return result, done(err)
+}
+
+// GetDatasetAuthorization returns errors.ErrUnsupported if the delegate cannot look it up in a single statement.
+func (t *timedPgAPI) GetDatasetAuthorization(ctx context.Context, cognitoId string, isFromTokenPool bool, datasetNodeId string) (*DatasetAuthorization, error) {
+	delegate, ok := t.delegate.(DatasetAuthorizationAPI)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	ctx, done := t.start(ctx, "GetDatasetAuthorization")
+	result, err := delegate.GetDatasetAuthorization(ctx, cognitoId, isFromTokenPool, datasetNodeId)
+	return result, done(err)
+}
+
+// timedDyAPI records the latency of every DynamoDB lookup made through a PennsieveDyAPI, and bounds each by a
+// timeout.
+type timedDyAPI struct {
+	delegate PennsieveDyAPI
+	metrics  metrics.Metrics
+	timeout  time.Duration
+}
+
+// NewTimedDyAPI returns a PennsieveDyAPI that records the latency of each lookup made through delegate and bounds
+// it as NewTimedPgAPI does.
+func NewTimedDyAPI(delegate PennsieveDyAPI, m metrics.Metrics, timeout time.Duration) PennsieveDyAPI {
+	return &timedDyAPI{delegate: delegate, metrics: m, timeout: timeout}
+}
+
+func (t *timedDyAPI) start(ctx context.Context, operation string) (context.Context, func(error) error) {
+	return track(ctx, t.metrics, metrics.DependencyDynamoDB, operation, t.timeout)
+}
+
+func (t *timedDyAPI) GetManifestById(ctx context.Context, manifestTableName string, manifestId string) (*coreDydb.ManifestTable, error) {
+	ctx, done := t.start(ctx, "GetManifestById")
+	result, err := t.delegate.GetManifestById(ctx, manifestTableName, manifestId)
+	return result, done(err)
+}
@@ -0,0 +1,79 @@
+package manager_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pennsieve/pennsieve-go-api/authorizer/deadline"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/metrics"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/test"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/test/mocks"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dataset"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dydb"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/role"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimedPgAPI(t *testing.T) {
+	recorder := mocks.NewMetrics()
+	mockPg := mocks.NewMockPennsievePgAPI()
+	currentUser := test.NewUser(101, 1001)
+	datasetClaim := &manager.DatasetClaim{Claim: dataset.Claim{Role: role.Editor, NodeId: "N:dataset:1", IntId: 1}}
+	mockPg.OnGetOrganizationIdForDataset("N:dataset:1").Return(int64(1001), nil)
+	mockPg.OnGetDatasetClaim(currentUser, "N:dataset:1", int64(1001)).Return(datasetClaim, nil)
+	mockPg.OnGetUserByNodeId("N:user:missing").Return(test.NewUser(0, 0), errors.New("connection reset"))
+
+	timed := manager.NewTimedPgAPI(mockPg, recorder, 0)
+	orgId, err := timed.GetOrganizationIdForDataset(context.Background(), "N:dataset:1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1001), orgId)
+	claim, err := timed.GetDatasetClaim(context.Background(), currentUser, "N:dataset:1", orgId)
+	require.NoError(t, err)
+	assert.Equal(t, datasetClaim, claim)
+	_, err = timed.GetUserByNodeId(context.Background(), "N:user:missing")
+	assert.ErrorContains(t, err, "connection reset")
+
+	_, err = timed.(manager.DatasetAuthorizationAPI).GetDatasetAuthorization(context.Background(), "cognito-1", false, "N:dataset:1")
+	assert.ErrorIs(t, err, errors.ErrUnsupported)
+
+	assert.Equal(t, []string{"GetOrganizationIdForDataset", "GetDatasetClaim", "GetUserByNodeId"}, recorder.LatencyOperations(metrics.DependencyPostgres))
+	mockPg.AssertExpectations(t)
+}
+
+func TestTimedDyAPI(t *testing.T) {
+	recorder := mocks.NewMetrics()
+	mockDy := mocks.NewMockPennsieveDyAPI()
+	manifest := &dydb.ManifestTable{ManifestId: "manifest-1", DatasetNodeId: "N:dataset:1", OrganizationId: 1001}
+	mockDy.OnGetManifestById("manifests", "manifest-1").Return(manifest, nil)
+
+	actual, err := manager.NewTimedDyAPI(mockDy, recorder, 0).GetManifestById(context.Background(), "manifests", "manifest-1")
+	require.NoError(t, err)
+	assert.Equal(t, manifest, actual)
+	assert.Equal(t, []string{"GetManifestById"}, recorder.LatencyOperations(metrics.DependencyDynamoDB))
+	mockDy.AssertExpectations(t)
+}
+
+func TestTimedPgAPITimeout(t *testing.T) {
+	recorder := mocks.NewMetrics()
+	mockPg := mocks.NewMockPennsievePgAPI()
+	hung := errors.New("pq: canceling statement due to user request")
+	mockPg.OnGetOrganizationIdForDataset("N:dataset:1").
+		Run(func(args mock.Arguments) {
+			<-args.Get(0).(context.Context).Done()
+		}).
+		Return(int64(0), hung)
+
+	_, err := manager.NewTimedPgAPI(mockPg, recorder, 10*time.Millisecond).GetOrganizationIdForDataset(context.Background(), "N:dataset:1")
+
+	var timeout *deadline.TimeoutError
+	require.ErrorAs(t, err, &timeout)
+	assert.Equal(t, "GetOrganizationIdForDataset", timeout.Operation)
+	assert.ErrorIs(t, err, hung)
+	assert.Equal(t, []string{"GetOrganizationIdForDataset"}, recorder.LatencyOperations(metrics.DependencyPostgres))
+	mockPg.AssertExpectations(t)
+}
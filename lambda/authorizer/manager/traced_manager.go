@@ -0,0 +1,168 @@
+package manager
+
+import (
+	"context"
+
+	"github.com/pennsieve/pennsieve-go-api/authorizer/claims"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/tracing"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dataset"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dydb"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/organization"
+	pgdbModels "github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/teamUser"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/user"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracedManager is an IdentityManager that wraps each call to its delegate in a span, so that a
+// slow or failed authorization shows which lookup caused it.
+type TracedManager struct {
+	IdentityManager
+	tracer trace.Tracer
+}
+
+// NewTracedManager returns a TracedManager that creates spans with tracer around delegate's calls.
+func NewTracedManager(delegate IdentityManager, tracer trace.Tracer) *TracedManager {
+	return &TracedManager{IdentityManager: delegate, tracer: tracer}
+}
+
+func (t *TracedManager) start(ctx context.Context, method string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return t.tracer.Start(ctx, "IdentityManager."+method, trace.WithAttributes(attributes...))
+}
+
+func (t *TracedManager) GetCurrentUser(ctx context.Context) (*pgdbModels.User, error) {
+	ctx, span := t.start(ctx, "GetCurrentUser")
+	currentUser, err := t.IdentityManager.GetCurrentUser(ctx)
+	tracing.End(span, err)
+	return currentUser, err
+}
+
+// PreloadDataset preloads through the delegate, if it is a DatasetPreloader.
+func (t *TracedManager) PreloadDataset(ctx context.Context, datasetId string) error {
+	preloader, ok := t.IdentityManager.(DatasetPreloader)
+	if !ok {
+		return nil
+	}
+	ctx, span := t.start(ctx, "PreloadDataset", attribute.String("pennsieve.dataset_id", datasetId))
+	err := preloader.PreloadDataset(ctx, datasetId)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *TracedManager) GetActiveOrg(ctx context.Context, currentUser *pgdbModels.User) int64 {
+	ctx, span := t.start(ctx, "GetActiveOrg")
+	defer span.End()
+	return t.IdentityManager.GetActiveOrg(ctx, currentUser)
+}
+
+func (t *TracedManager) GetUserClaim(ctx context.Context, currentUser *pgdbModels.User) *user.Claim {
+	ctx, span := t.start(ctx, "GetUserClaim")
+	defer span.End()
+	return t.IdentityManager.GetUserClaim(ctx, currentUser)
+}
+
+func (t *TracedManager) GetDatasetClaim(ctx context.Context, currentUser *pgdbModels.User, datasetId string, orgId int64) (*dataset.Claim, error) {
+	ctx, span := t.start(ctx, "GetDatasetClaim", attribute.String("pennsieve.dataset_id", datasetId), attribute.Int64("pennsieve.org_id", orgId))
+	claim, err := t.IdentityManager.GetDatasetClaim(ctx, currentUser, datasetId, orgId)
+	tracing.End(span, err)
+	return claim, err
+}
+
+func (t *TracedManager) GetOrgClaim(ctx context.Context, userId int64, orgId int64) (*organization.Claim, error) {
+	ctx, span := t.start(ctx, "GetOrgClaim", attribute.Int64("pennsieve.org_id", orgId))
+	claim, err := t.IdentityManager.GetOrgClaim(ctx, userId, orgId)
+	tracing.End(span, err)
+	return claim, err
+}
+
+func (t *TracedManager) GetOrgClaimByNodeId(ctx context.Context, userId int64, orgNodeId string) (*organization.Claim, error) {
+	ctx, span := t.start(ctx, "GetOrgClaimByNodeId", attribute.String("pennsieve.org_node_id", orgNodeId))
+	claim, err := t.IdentityManager.GetOrgClaimByNodeId(ctx, userId, orgNodeId)
+	tracing.End(span, err)
+	return claim, err
+}
+
+func (t *TracedManager) GetTeamClaims(ctx context.Context, userId int64) ([]teamUser.Claim, error) {
+	ctx, span := t.start(ctx, "GetTeamClaims")
+	claims, err := t.IdentityManager.GetTeamClaims(ctx, userId)
+	tracing.End(span, err)
+	return claims, err
+}
+
+func (t *TracedManager) GetTeamClaimsForOrg(ctx context.Context, userId int64, orgId int64) ([]teamUser.Claim, error) {
+	ctx, span := t.start(ctx, "GetTeamClaimsForOrg", attribute.Int64("pennsieve.org_id", orgId))
+	claims, err := t.IdentityManager.GetTeamClaimsForOrg(ctx, userId, orgId)
+	tracing.End(span, err)
+	return claims, err
+}
+
+func (t *TracedManager) GetOrganizationIdForDataset(ctx context.Context, datasetId string) (int64, error) {
+	ctx, span := t.start(ctx, "GetOrganizationIdForDataset", attribute.String("pennsieve.dataset_id", datasetId))
+	orgId, err := t.IdentityManager.GetOrganizationIdForDataset(ctx, datasetId)
+	tracing.End(span, err)
+	return orgId, err
+}
+
+func (t *TracedManager) GetManifest(ctx context.Context, manifestId string) (*dydb.ManifestTable, error) {
+	ctx, span := t.start(ctx, "GetManifest", attribute.String("pennsieve.manifest_id", manifestId))
+	manifest, err := t.IdentityManager.GetManifest(ctx, manifestId)
+	tracing.End(span, err)
+	return manifest, err
+}
+
+func (t *TracedManager) GetUserByNodeId(ctx context.Context, nodeId string) (*pgdbModels.User, error) {
+	ctx, span := t.start(ctx, "GetUserByNodeId", attribute.String("pennsieve.user_node_id", nodeId))
+	found, err := t.IdentityManager.GetUserByNodeId(ctx, nodeId)
+	tracing.End(span, err)
+	return found, err
+}
+
+func (t *TracedManager) GetOrganizationIpAllowlist(ctx context.Context, orgId int64) ([]string, error) {
+	ctx, span := t.start(ctx, "GetOrganizationIpAllowlist", attribute.Int64("pennsieve.org_id", orgId))
+	allowlist, err := t.IdentityManager.GetOrganizationIpAllowlist(ctx, orgId)
+	tracing.End(span, err)
+	return allowlist, err
+}
+
+func (t *TracedManager) GetOrganizationAuthPolicy(ctx context.Context, orgId int64) (*AuthPolicy, error) {
+	ctx, span := t.start(ctx, "GetOrganizationAuthPolicy", attribute.Int64("pennsieve.org_id", orgId))
+	policy, err := t.IdentityManager.GetOrganizationAuthPolicy(ctx, orgId)
+	tracing.End(span, err)
+	return policy, err
+}
+
+func (t *TracedManager) GetExternalDatasetGrant(ctx context.Context, userId int64, datasetId string, orgId int64) (*ExternalGrant, error) {
+	ctx, span := t.start(ctx, "GetExternalDatasetGrant", attribute.String("pennsieve.dataset_id", datasetId), attribute.Int64("pennsieve.org_id", orgId))
+	grant, err := t.IdentityManager.GetExternalDatasetGrant(ctx, userId, datasetId, orgId)
+	tracing.End(span, err)
+	return grant, err
+}
+
+func (t *TracedManager) GetDatasetIdentity(ctx context.Context, datasetId string, orgId int64) (*DatasetIdentity, error) {
+	ctx, span := t.start(ctx, "GetDatasetIdentity", attribute.String("pennsieve.dataset_id", datasetId), attribute.Int64("pennsieve.org_id", orgId))
+	identity, err := t.IdentityManager.GetDatasetIdentity(ctx, datasetId, orgId)
+	tracing.End(span, err)
+	return identity, err
+}
+
+func (t *TracedManager) GetDatasetState(ctx context.Context, datasetId string, orgId int64) (*claims.DatasetState, error) {
+	ctx, span := t.start(ctx, "GetDatasetState", attribute.String("pennsieve.dataset_id", datasetId), attribute.Int64("pennsieve.org_id", orgId))
+	datasetState, err := t.IdentityManager.GetDatasetState(ctx, datasetId, orgId)
+	tracing.End(span, err)
+	return datasetState, err
+}
+
+func (t *TracedManager) GetDataUseAgreementStatus(ctx context.Context, userId int64, datasetId string, orgId int64) (*DataUseAgreementStatus, error) {
+	ctx, span := t.start(ctx, "GetDataUseAgreementStatus", attribute.String("pennsieve.dataset_id", datasetId), attribute.Int64("pennsieve.org_id", orgId))
+	status, err := t.IdentityManager.GetDataUseAgreementStatus(ctx, userId, datasetId, orgId)
+	tracing.End(span, err)
+	return status, err
+}
+
+func (t *TracedManager) GetGrantExpiry(ctx context.Context, userId int64, datasetId string, orgId int64) (*claims.GrantExpiry, error) {
+	ctx, span := t.start(ctx, "GetGrantExpiry", attribute.String("pennsieve.dataset_id", datasetId), attribute.Int64("pennsieve.org_id", orgId))
+	expiry, err := t.IdentityManager.GetGrantExpiry(ctx, userId, datasetId, orgId)
+	tracing.End(span, err)
+	return expiry, err
+}
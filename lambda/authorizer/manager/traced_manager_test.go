@@ -0,0 +1,53 @@
+package manager_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/test"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/test/mocks"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dataset"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/role"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracedManager(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)).Tracer("test")
+
+	managerParams := mocks.NewClaimsManagerParams(t)
+	currentUser := test.NewUser(101, 1001)
+	claimsManager := manager.NewTracedManager(managerParams.WithUserQueryMocked(t, currentUser).BuildClaimsManager(), tracer)
+	datasetClaim := &dataset.Claim{Role: role.Viewer, NodeId: "N:dataset:1", IntId: 1}
+	managerParams.MockPennsievePg.OnGetDatasetClaim(currentUser, "N:dataset:1", int64(1001)).Return(datasetClaim, nil)
+	managerParams.MockPennsievePg.OnGetOrganizationIdForDataset("N:dataset:2").Return(int64(0), errors.New("connection refused"))
+
+	ctx, parent := tracer.Start(context.Background(), "Authorize")
+	actualUser, err := claimsManager.GetCurrentUser(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, currentUser, actualUser)
+	actualClaim, err := claimsManager.GetDatasetClaim(ctx, currentUser, "N:dataset:1", 1001)
+	require.NoError(t, err)
+	assert.Equal(t, datasetClaim, actualClaim)
+	_, err = claimsManager.GetOrganizationIdForDataset(ctx, "N:dataset:2")
+	assert.ErrorContains(t, err, "connection refused")
+	parent.End()
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 4)
+	var names []string
+	for _, span := range spans[:3] {
+		names = append(names, span.Name)
+		assert.Equal(t, parent.SpanContext().SpanID(), span.Parent.SpanID(), "%s should be a child of the request span", span.Name)
+	}
+	assert.Equal(t, []string{"IdentityManager.GetCurrentUser", "IdentityManager.GetDatasetClaim", "IdentityManager.GetOrganizationIdForDataset"}, names)
+	assert.Equal(t, codes.Unset, spans[1].Status.Code)
+	assert.Equal(t, codes.Error, spans[2].Status.Code)
+	managerParams.AssertMockExpectations(t)
+}
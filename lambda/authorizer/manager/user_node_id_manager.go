@@ -0,0 +1,23 @@
+package manager
+
+import (
+	"context"
+
+	pgdbModels "github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+)
+
+// UserNodeIdManager is an IdentityManager whose current user is identified by node id rather than by a Cognito
+// token, for principals that were authenticated some other way: a direct invocation, or a callback token verified
+// by its service's validator. Its delegate should carry no token, so that no token workspace applies.
+type UserNodeIdManager struct {
+	IdentityManager
+	UserNodeId string
+}
+
+func NewUserNodeIdManager(delegate IdentityManager, userNodeId string) *UserNodeIdManager {
+	return &UserNodeIdManager{IdentityManager: delegate, UserNodeId: userNodeId}
+}
+
+func (m *UserNodeIdManager) GetCurrentUser(ctx context.Context) (*pgdbModels.User, error) {
+	return m.IdentityManager.GetUserByNodeId(ctx, m.UserNodeId)
+}
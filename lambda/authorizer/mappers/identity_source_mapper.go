@@ -6,15 +6,17 @@ import (
 	"github.com/pennsieve/pennsieve-go-api/authorizer/helpers"
 )
 
+// MappedIdentitySource is an identity source split into the user token and the resource identity sources, in the
+// order API Gateway delivered them.
 type MappedIdentitySource struct {
-	Token string
-	Other *string
+	Token  string
+	Others []string
 }
 
 type Mapper interface {
 	// Create returns an MappedIdentitySource or a non-nil error if one cannot be created.
 	// The returned MappedIdentitySource.Token will be a non-empty Token including the initial 'Bearer'.
-	// If the returned MappedIdentitySource.Other is non-nil, then it is also non-empty.
+	// Each of the returned MappedIdentitySource.Others is non-empty.
 	Create() (MappedIdentitySource, error)
 }
 
@@ -29,24 +31,24 @@ func NewIdentitySourceMapper(identitySource []string) Mapper {
 func (i *IdentitySourceMapper) Create() (MappedIdentitySource, error) {
 	m := MappedIdentitySource{}
 
-	if idSourceLen := len(i.IdentitySource); idSourceLen == 0 {
+	if len(i.IdentitySource) == 0 {
 		return m, errors.New("identity source empty")
-	} else if idSourceLen > 2 {
-		return m, fmt.Errorf("identity source too long: %d", idSourceLen)
 	}
 
 	for _, source := range i.IdentitySource {
-		if helpers.Matches(source, `Bearer (?P<token>.*)`) {
+		if len(m.Token) == 0 && helpers.Matches(source, `Bearer (?P<token>.*)`) {
 			m.Token = source
 		} else {
-			m.Other = &source
+			m.Others = append(m.Others, source)
 		}
 	}
 	if len(m.Token) == 0 {
 		return m, fmt.Errorf("no valid user token found in %s", i.IdentitySource)
 	}
-	if m.Other != nil && len(*m.Other) == 0 {
-		return m, fmt.Errorf("invalid non-token identity source found in %s", i.IdentitySource)
+	for _, other := range m.Others {
+		if len(other) == 0 {
+			return m, fmt.Errorf("invalid non-token identity source found in %s", i.IdentitySource)
+		}
 	}
 	return m, nil
 }
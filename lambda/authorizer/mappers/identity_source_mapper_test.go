@@ -15,6 +15,8 @@ func TestIdentitySourceMapper(t *testing.T) {
 	userIdentitySource := []string{token}
 	datasetIdentitySource := []string{token, datasetId}
 	datasetIdentitySourceFlippedOrder := []string{datasetId, token}
+	otherDatasetId := "N:dataset:other-uuid"
+	multiResourceIdentitySource := []string{token, datasetId, otherDatasetId}
 
 	// happy path tests
 	for scenario, params := range map[string]struct {
@@ -25,12 +27,16 @@ func TestIdentitySourceMapper(t *testing.T) {
 			Token: token,
 		}},
 		"identity source with additional source": {datasetIdentitySource, mappers.MappedIdentitySource{
-			Token: token,
-			Other: &datasetId,
+			Token:  token,
+			Others: []string{datasetId},
 		}},
 		"identity source with additional source in flipped order": {datasetIdentitySourceFlippedOrder, mappers.MappedIdentitySource{
-			Token: token,
-			Other: &datasetId,
+			Token:  token,
+			Others: []string{datasetId},
+		}},
+		"identity source with several additional sources": {multiResourceIdentitySource, mappers.MappedIdentitySource{
+			Token:  token,
+			Others: []string{datasetId, otherDatasetId},
 		}},
 	} {
 		t.Run(scenario, func(t *testing.T) {
@@ -45,7 +51,7 @@ func TestIdentitySourceMapper(t *testing.T) {
 	userTokenMissingToken := []string{"Bearer"}
 	userTokenMissingTokenWithOtherId := []string{"Bearer", datasetId}
 	otherIdEmpty := []string{token, ""}
-	tooManyIdentitySources := []string{token, datasetId, "someNewUnexpectedId"}
+	oneOfSeveralEmpty := []string{token, datasetId, ""}
 
 	// error tests
 	for scenario, params := range map[string]struct {
@@ -57,7 +63,7 @@ func TestIdentitySourceMapper(t *testing.T) {
 		"user token missing token":                     {userTokenMissingToken, "no valid user token found"},
 		"user token missing token with other param":    {userTokenMissingTokenWithOtherId, "no valid user token found"},
 		"empty non-token source":                       {otherIdEmpty, "invalid non-token identity source found"},
-		"unexpectedly large identity source":           {tooManyIdentitySources, "identity source too long"},
+		"empty one of several non-token sources":       {oneOfSeveralEmpty, "invalid non-token identity source found"},
 		"empty identity source":                        {[]string{}, "identity source empty"},
 	} {
 		t.Run(scenario, func(t *testing.T) {
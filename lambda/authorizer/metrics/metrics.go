@@ -0,0 +1,151 @@
+// Package metrics records authorization decisions and dependency latency. The production
+// implementation writes CloudWatch Embedded Metric Format (EMF) records to stdout, which the Lambda
+// runtime forwards to CloudWatch Logs where they are extracted as metrics without any API calls.
+package metrics
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Dependencies whose latency is recorded.
+const (
+	DependencyJWKS     = "jwks"
+	DependencyPostgres = "postgres"
+	DependencyDynamoDB = "dynamodb"
+	DependencyLambda   = "lambda"
+)
+
+// Decision outcomes.
+const (
+	OutcomeAllow         = "allow"
+	OutcomeDeny          = "deny"
+	OutcomeIndeterminate = "indeterminate"
+)
+
+// Dimensions name the CloudWatch dimensions of a single metric value.
+type Dimensions map[string]string
+
+// Metrics emits metric values. Implementations must be safe for concurrent use.
+type Metrics interface {
+	// Count adds one to the named counter.
+	Count(name string, dimensions Dimensions)
+	// Latency records one duration for the named metric.
+	Latency(name string, dimensions Dimensions, elapsed time.Duration)
+}
+
+// Decision counts one authorization decision made by the given authorizer type (e.g. "dataset" or
+// "callback"). reason is the deny reason code, or empty for allows.
+func Decision(m Metrics, authorizerType string, outcome string, reason string) {
+	m.Count("Decisions", Dimensions{"AuthorizerType": authorizerType, "Outcome": outcome, "Reason": reason})
+}
+
+// Track starts timing one call to a dependency. Call the returned function when the call completes:
+//
+//	defer metrics.Track(m, metrics.DependencyPostgres, "GetDatasetClaim")()
+func Track(m Metrics, dependency string, operation string) func() {
+	start := time.Now()
+	return func() {
+		m.Latency("DependencyLatency", Dimensions{"Dependency": dependency, "Operation": operation}, time.Since(start))
+	}
+}
+
+// CacheLookup counts one lookup in the named process-local cache, which was a hit or a miss.
+func CacheLookup(m Metrics, cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	m.Count("CacheLookups", Dimensions{"Cache": cache, "Result": result})
+}
+
+// CacheEviction counts one entry evicted from the named process-local cache to make room for another.
+func CacheEviction(m Metrics, cache string) {
+	m.Count("CacheEvictions", Dimensions{"Cache": cache})
+}
+
+// StaleClaims counts one request by the given authorizer type that was allowed with last-known-good claims because
+// its claims could not be resolved.
+func StaleClaims(m Metrics, authorizerType string) {
+	m.Count("StaleClaimsServed", Dimensions{"AuthorizerType": authorizerType})
+}
+
+type emfMetrics struct {
+	mu        sync.Mutex
+	out       io.Writer
+	namespace string
+}
+
+// NewEMF returns Metrics that write one EMF record per value to out under the CloudWatch namespace.
+func NewEMF(out io.Writer, namespace string) Metrics {
+	return &emfMetrics{out: out, namespace: namespace}
+}
+
+// NewEMFFromEnv returns EMF Metrics on stdout, in the namespace named by METRICS_NAMESPACE or
+// "Pennsieve/Authorizer" if it is unset.
+func NewEMFFromEnv() Metrics {
+	namespace := os.Getenv("METRICS_NAMESPACE")
+	if namespace == "" {
+		namespace = "Pennsieve/Authorizer"
+	}
+	return NewEMF(os.Stdout, namespace)
+}
+
+func (e *emfMetrics) Count(name string, dimensions Dimensions) {
+	e.emit(name, "Count", 1, dimensions)
+}
+
+func (e *emfMetrics) Latency(name string, dimensions Dimensions, elapsed time.Duration) {
+	e.emit(name, "Milliseconds", float64(elapsed.Microseconds())/1000, dimensions)
+}
+
+type emfMetricDefinition struct {
+	Name string `json:"Name"`
+	Unit string `json:"Unit"`
+}
+
+type emfDirective struct {
+	Namespace  string                `json:"Namespace"`
+	Dimensions [][]string            `json:"Dimensions"`
+	Metrics    []emfMetricDefinition `json:"Metrics"`
+}
+
+type emfMetadata struct {
+	Timestamp         int64          `json:"Timestamp"`
+	CloudWatchMetrics []emfDirective `json:"CloudWatchMetrics"`
+}
+
+func (e *emfMetrics) emit(name string, unit string, value float64, dimensions Dimensions) {
+	record := map[string]interface{}{}
+	dimensionSet := make([]string, 0, len(dimensions))
+	for key, dimensionValue := range dimensions {
+		if dimensionValue == "" {
+			// CloudWatch rejects empty dimension values; "none" keeps the dimension set stable.
+			dimensionValue = "none"
+		}
+		record[key] = dimensionValue
+		dimensionSet = append(dimensionSet, key)
+	}
+	sort.Strings(dimensionSet)
+	record[name] = value
+	record["_aws"] = emfMetadata{
+		Timestamp: time.Now().UnixMilli(),
+		CloudWatchMetrics: []emfDirective{{
+			Namespace:  e.namespace,
+			Dimensions: [][]string{dimensionSet},
+			Metrics:    []emfMetricDefinition{{Name: name, Unit: unit}},
+		}},
+	}
+
+	encoded, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, _ = e.out.Write(append(encoded, '\n'))
+}
@@ -0,0 +1,62 @@
+package metrics_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pennsieve/pennsieve-go-api/authorizer/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeRecords(t *testing.T, out *bytes.Buffer) []map[string]interface{} {
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var record map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &record), line)
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestEMFDecision(t *testing.T) {
+	out := &bytes.Buffer{}
+	metrics.Decision(metrics.NewEMF(out, "Test/Authorizer"), "dataset", metrics.OutcomeDeny, "ip_not_allowed")
+
+	records := decodeRecords(t, out)
+	require.Len(t, records, 1)
+	record := records[0]
+	assert.Equal(t, float64(1), record["Decisions"])
+	assert.Equal(t, "dataset", record["AuthorizerType"])
+	assert.Equal(t, "deny", record["Outcome"])
+	assert.Equal(t, "ip_not_allowed", record["Reason"])
+
+	directive := record["_aws"].(map[string]interface{})["CloudWatchMetrics"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "Test/Authorizer", directive["Namespace"])
+	assert.Equal(t, []interface{}{[]interface{}{"AuthorizerType", "Outcome", "Reason"}}, directive["Dimensions"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"Name": "Decisions", "Unit": "Count"}}, directive["Metrics"])
+}
+
+func TestEMFEmptyDimension(t *testing.T) {
+	out := &bytes.Buffer{}
+	metrics.Decision(metrics.NewEMF(out, "Test/Authorizer"), "user", metrics.OutcomeAllow, "")
+
+	assert.Equal(t, "none", decodeRecords(t, out)[0]["Reason"])
+}
+
+func TestEMFTrack(t *testing.T) {
+	out := &bytes.Buffer{}
+	stop := metrics.Track(metrics.NewEMF(out, "Test/Authorizer"), metrics.DependencyPostgres, "GetDatasetClaim")
+	time.Sleep(2 * time.Millisecond)
+	stop()
+
+	record := decodeRecords(t, out)[0]
+	assert.Equal(t, "postgres", record["Dependency"])
+	assert.Equal(t, "GetDatasetClaim", record["Operation"])
+	assert.GreaterOrEqual(t, record["DependencyLatency"].(float64), float64(2))
+	directive := record["_aws"].(map[string]interface{})["CloudWatchMetrics"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, []interface{}{map[string]interface{}{"Name": "DependencyLatency", "Unit": "Milliseconds"}}, directive["Metrics"])
+}
@@ -0,0 +1,118 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pennsieve/pennsieve-go-api/authorizer/authorizers"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/cache"
+	claimsClient "github.com/pennsieve/pennsieve-go-api/authorizer/claims"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/helpers"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
+)
+
+// LastKnownGood keeps the claims last resolved for each principal and resource, so that read-only requests can
+// still be allowed while Postgres is unavailable. It holds at most cache.DefaultMaxEntries claims. A nil
+// *LastKnownGood keeps nothing.
+type LastKnownGood struct {
+	claims *cache.LRU[string, map[string]interface{}]
+	now    func() time.Time
+}
+
+// NewLastKnownGood returns a LastKnownGood that serves claims resolved at most window ago, or nil if window is
+// zero. Claims are not served once one of their grants has expired.
+func NewLastKnownGood(window time.Duration) *LastKnownGood {
+	if window <= 0 {
+		return nil
+	}
+	return &LastKnownGood{
+		claims: cache.NewLRU[string, map[string]interface{}](cache.DefaultMaxEntries, window),
+		now:    time.Now,
+	}
+}
+
+// WithClock makes the LastKnownGood read the time from now, for tests.
+func (l *LastKnownGood) WithClock(now func() time.Time) *LastKnownGood {
+	l.claims.WithClock(now)
+	l.now = now
+	return l
+}
+
+// Apply takes the outcome of resolving the claims of principal for resource: claims, or err if they could not be
+// resolved. Resolved claims are kept, and denies forget them, so that a fresh deny is never overridden. principal
+// identifies the caller independently of Postgres, e.g. by the username in its token; an empty principal keeps
+// and serves nothing.
+//
+// If err is an *authorizers.IndeterminateError and the request in ctx is read-only and on a route whose decisions
+// are not cached, so that a stale allow lasts no longer than the window, Apply instead returns the claims last
+// resolved for the same principal, resource, source IP and purpose of use within the window, marked
+// claimsClient.LabelStale, and reports that they are stale. Otherwise it returns claims and err unchanged.
+func (l *LastKnownGood) Apply(ctx context.Context, principal string, resource Resource, claims map[string]interface{}, err error) (map[string]interface{}, bool, error) {
+	if l == nil || principal == "" {
+		return claims, false, err
+	}
+	request, _ := manager.RequestFromContext(ctx)
+	key := fmt.Sprintf("%s|%s|%s|%s", principal, request.SourceIp, request.PurposeOfUse, resource.key())
+
+	var indeterminate *authorizers.IndeterminateError
+	switch {
+	case err == nil:
+		l.claims.Add(key, copyClaims(claims))
+		return claims, false, nil
+	case !errors.As(err, &indeterminate):
+		l.claims.Remove(key)
+		return claims, false, err
+	case !helpers.IsReadOnlyMethod(request.Method) || !request.Uncached:
+		return claims, false, err
+	}
+
+	lastKnown, ok := l.claims.Get(key)
+	if !ok || grantsExpire(lastKnown, l.now()) {
+		return claims, false, err
+	}
+	stale := copyClaims(lastKnown)
+	stale[claimsClient.LabelStale] = true
+	return stale, true, nil
+}
+
+// key identifies the resource among those a principal's claims are kept for.
+func (r Resource) key() string {
+	if r.Composite == nil {
+		return fmt.Sprintf("dataset=%s,organization=%s,manifest=%s", r.DatasetNodeId, r.OrganizationNodeId, r.ManifestId)
+	}
+	resources := make([]string, len(r.Composite.Resources))
+	for i, resource := range r.Composite.Resources {
+		resources[i] = fmt.Sprintf("%s:%s:%s", resource.Name, ResourceOf(resource.Authorizer).key(), resource.MinRole)
+	}
+	return "composite(" + strings.Join(resources, ";") + ")"
+}
+
+// grantsExpire reports whether any grant in claims, or in the claims of any of its resources, expires by horizon.
+func grantsExpire(claims map[string]interface{}, horizon time.Time) bool {
+	if expiry, ok := claims[claimsClient.LabelGrantExpiry].(*claimsClient.GrantExpiry); ok && expiry != nil {
+		for _, expiresAt := range []*time.Time{expiry.Organization, expiry.Dataset} {
+			if expiresAt != nil && !expiresAt.After(horizon) {
+				return true
+			}
+		}
+	}
+	resourceClaims, _ := claims[claimsClient.LabelResourceClaims].(map[string]map[string]interface{})
+	for _, resource := range resourceClaims {
+		if grantsExpire(resource, horizon) {
+			return true
+		}
+	}
+	return false
+}
+
+// copyClaims returns a copy of claims that can be added to without changing claims.
+func copyClaims(claims map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(claims)+1)
+	for label, claim := range claims {
+		copied[label] = claim
+	}
+	return copied
+}
@@ -0,0 +1,118 @@
+package pipeline_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pennsieve/pennsieve-go-api/authorizer/authorizers"
+	claimsClient "github.com/pennsieve/pennsieve-go-api/authorizer/claims"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/pipeline"
+	coreAuthorizer "github.com/pennsieve/pennsieve-go-core/pkg/authorizer"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dataset"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/role"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLastKnownGood(t *testing.T) {
+	outage := authorizers.NewIndeterminateError(errors.New("connection refused"))
+	resource := pipeline.Resource{DatasetNodeId: "N:dataset:1"}
+	get := manager.WithRequest(context.Background(), manager.Request{SourceIp: "192.0.2.1", Method: "GET", Uncached: true})
+	resolved := func() map[string]interface{} {
+		return map[string]interface{}{coreAuthorizer.LabelDatasetClaim: &dataset.Claim{Role: role.Viewer, NodeId: "N:dataset:1"}}
+	}
+
+	// Each case is a request made params.at after claims were resolved for user:abc's GET from 192.0.2.1.
+	for name, params := range map[string]struct {
+		ctx           context.Context
+		principal     string
+		resource      pipeline.Resource
+		at            time.Duration
+		expectedStale bool
+	}{
+		"read during outage":        {ctx: get, principal: "user:abc", resource: resource, at: time.Minute, expectedStale: true},
+		"read after the window":     {ctx: get, principal: "user:abc", resource: resource, at: 15 * time.Minute},
+		"write during outage":       {ctx: manager.WithRequest(context.Background(), manager.Request{SourceIp: "192.0.2.1", Method: "PUT", Uncached: true}), principal: "user:abc", resource: resource, at: time.Minute},
+		"no request metadata":       {ctx: context.Background(), principal: "user:abc", resource: resource, at: time.Minute},
+		"another principal":         {ctx: get, principal: "user:def", resource: resource, at: time.Minute},
+		"another resource":          {ctx: get, principal: "user:abc", resource: pipeline.Resource{DatasetNodeId: "N:dataset:2"}, at: time.Minute},
+		"another source ip":         {ctx: manager.WithRequest(context.Background(), manager.Request{SourceIp: "192.0.2.2", Method: "GET", Uncached: true}), principal: "user:abc", resource: resource, at: time.Minute},
+		"another purpose of use":    {ctx: manager.WithRequest(context.Background(), manager.Request{SourceIp: "192.0.2.1", Method: "GET", Uncached: true, PurposeOfUse: "research"}), principal: "user:abc", resource: resource, at: time.Minute},
+		"principal not identifying": {ctx: get, principal: "", resource: resource, at: time.Minute},
+		"cached route":              {ctx: manager.WithRequest(context.Background(), manager.Request{SourceIp: "192.0.2.1", Method: "GET"}), principal: "user:abc", resource: resource, at: time.Minute},
+	} {
+		t.Run(name, func(t *testing.T) {
+			now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+			lastKnownGood := pipeline.NewLastKnownGood(10 * time.Minute).WithClock(func() time.Time { return now })
+			_, _, err := lastKnownGood.Apply(get, "user:abc", resource, resolved(), nil)
+			require.NoError(t, err)
+
+			now = now.Add(params.at)
+			claims, stale, err := lastKnownGood.Apply(params.ctx, params.principal, params.resource, nil, outage)
+
+			assert.Equal(t, params.expectedStale, stale)
+			if params.expectedStale {
+				require.NoError(t, err)
+				assert.Equal(t, true, claims[claimsClient.LabelStale])
+				assert.Equal(t, "N:dataset:1", claims[coreAuthorizer.LabelDatasetClaim].(*dataset.Claim).NodeId)
+			} else {
+				assert.Same(t, outage, err)
+				assert.Nil(t, claims)
+			}
+		})
+	}
+}
+
+func TestLastKnownGoodNeverOverridesDenies(t *testing.T) {
+	outage := authorizers.NewIndeterminateError(errors.New("connection refused"))
+	resource := pipeline.Resource{DatasetNodeId: "N:dataset:1"}
+	get := manager.WithRequest(context.Background(), manager.Request{Method: "GET", Uncached: true})
+	lastKnownGood := pipeline.NewLastKnownGood(10 * time.Minute)
+	_, _, err := lastKnownGood.Apply(get, "user:abc", resource, map[string]interface{}{}, nil)
+	require.NoError(t, err)
+
+	denied := authorizers.NewDenyError(authorizers.ReasonNoDatasetRole, errors.New("user has no access to dataset"))
+	_, stale, err := lastKnownGood.Apply(get, "user:abc", resource, nil, denied)
+	assert.False(t, stale)
+	assert.Same(t, denied, err)
+
+	// The deny also replaces the claims resolved before it.
+	_, stale, err = lastKnownGood.Apply(get, "user:abc", resource, nil, outage)
+	assert.False(t, stale)
+	assert.Same(t, outage, err)
+}
+
+func TestLastKnownGoodExpiringGrants(t *testing.T) {
+	outage := authorizers.NewIndeterminateError(errors.New("connection refused"))
+	resource := pipeline.Resource{DatasetNodeId: "N:dataset:1"}
+	get := manager.WithRequest(context.Background(), manager.Request{Method: "GET", Uncached: true})
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	expiresAt := now.Add(10 * time.Minute)
+	lastKnownGood := pipeline.NewLastKnownGood(time.Hour).WithClock(func() time.Time { return now })
+	_, _, err := lastKnownGood.Apply(get, "user:abc", resource, map[string]interface{}{
+		claimsClient.LabelGrantExpiry: &claimsClient.GrantExpiry{Dataset: &expiresAt},
+	}, nil)
+	require.NoError(t, err)
+
+	now = now.Add(9 * time.Minute)
+	_, stale, _ := lastKnownGood.Apply(get, "user:abc", resource, nil, outage)
+	assert.True(t, stale)
+
+	now = now.Add(time.Minute)
+	_, stale, err = lastKnownGood.Apply(get, "user:abc", resource, nil, outage)
+	assert.False(t, stale)
+	assert.Same(t, outage, err)
+}
+
+func TestLastKnownGoodDisabled(t *testing.T) {
+	lastKnownGood := pipeline.NewLastKnownGood(0)
+	outage := authorizers.NewIndeterminateError(errors.New("connection refused"))
+
+	assert.Nil(t, lastKnownGood)
+	_, stale, err := lastKnownGood.Apply(context.Background(), "user:abc", pipeline.Resource{}, nil, outage)
+	assert.False(t, stale)
+	assert.Same(t, outage, err)
+}
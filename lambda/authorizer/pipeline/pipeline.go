@@ -0,0 +1,155 @@
+// Package pipeline resolves the claims of a principal for a resource request. Every entry point — the HTTP
+// authorizer (Cognito and callback tokens), the WebSocket authorizer, the direct authorizer and authz-explain —
+// goes through Resolve, so that each gets the same checks and the same classification of failures.
+package pipeline
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/authorizers"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/deadline"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/tracing"
+	coreAuthorizer "github.com/pennsieve/pennsieve-go-core/pkg/authorizer"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/organization"
+	pgdbModels "github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+)
+
+// Sources are the stores a principal's claims are resolved from.
+type Sources struct {
+	PostgresDB    manager.PennsievePgAPI
+	DynamoDB      manager.PennsieveDyAPI
+	TokenClientId string
+	ManifestTable string
+}
+
+// CognitoPrincipal returns the principal authenticated by token, a validated Cognito access token from either the
+// user pool or the API token pool.
+func (s Sources) CognitoPrincipal(token jwt.Token) manager.IdentityManager {
+	return manager.NewTracedManager(manager.NewClaimsManager(s.PostgresDB, s.DynamoDB, token, s.TokenClientId, s.ManifestTable), tracing.Tracer())
+}
+
+// NodeIdPrincipal returns the principal with the given user node id, for callers that were authenticated some
+// other way: a direct invocation by a trusted Lambda, or a callback token verified by its service's validator. No
+// token workspace applies to such a principal.
+func (s Sources) NodeIdPrincipal(userNodeId string) manager.IdentityManager {
+	claimsManager := manager.NewClaimsManager(s.PostgresDB, s.DynamoDB, jwt.New(), s.TokenClientId, s.ManifestTable)
+	return manager.NewTracedManager(manager.NewUserNodeIdManager(claimsManager, userNodeId), tracing.Tracer())
+}
+
+// Resource is the resource a request is for. DatasetNodeId takes precedence over ManifestId, and either over
+// OrganizationNodeId; if OrganizationNodeId is given together with a dataset or manifest, it must be the
+// organization that owns it. An empty Resource is a user-only request. A request for several resources instead
+// sets only Composite.
+type Resource struct {
+	DatasetNodeId      string
+	OrganizationNodeId string
+	ManifestId         string
+	Composite          *authorizers.CompositeAuthorizer
+}
+
+// ResourceOf returns the Resource that authorizer, as selected by the authorizer factory, authorizes.
+func ResourceOf(authorizer authorizers.Authorizer) Resource {
+	switch a := authorizer.(type) {
+	case *authorizers.DatasetAuthorizer:
+		return Resource{DatasetNodeId: a.DatasetId}
+	case *authorizers.WorkspaceAuthorizer:
+		return Resource{OrganizationNodeId: a.WorkspaceID}
+	case *authorizers.ManifestAuthorizer:
+		return Resource{ManifestId: a.ManifestID}
+	case *authorizers.CompositeAuthorizer:
+		return Resource{Composite: a}
+	default:
+		return Resource{}
+	}
+}
+
+// Authorizer returns the authorizer that generates claims for the resource.
+func (r Resource) Authorizer() authorizers.Authorizer {
+	switch {
+	case r.Composite != nil:
+		return r.Composite
+	case r.DatasetNodeId != "":
+		return authorizers.NewDatasetAuthorizer(r.DatasetNodeId)
+	case r.ManifestId != "":
+		return authorizers.NewManifestAuthorizer(r.ManifestId)
+	case r.OrganizationNodeId != "":
+		return authorizers.NewWorkspaceAuthorizer(r.OrganizationNodeId)
+	default:
+		return authorizers.NewUserAuthorizer()
+	}
+}
+
+// Scope returns the scope of the resource's authorizer, as recorded in metrics and rate limits.
+func (r Resource) Scope() string {
+	return authorizers.Scope(r.Authorizer())
+}
+
+// Resolve returns the claims of principal for resource, generated in the given authorizer mode. A failure is
+// either an *authorizers.IndeterminateError, when no decision could be reached and the caller must not cache a
+// deny, or a deny, which carries a reason code when it has one (see authorizers.DenyReason). A dependency that
+// timed out always makes the failure indeterminate, even where an authorizer took its error for a deny.
+func Resolve(ctx context.Context, principal manager.IdentityManager, resource Resource, mode string) (map[string]interface{}, error) {
+	claims, err := resolve(ctx, principal, resource, mode)
+	var indeterminate *authorizers.IndeterminateError
+	if deadline.IsTimeout(err) && !errors.As(err, &indeterminate) {
+		return nil, authorizers.NewIndeterminateError(err)
+	}
+	return claims, err
+}
+
+func resolve(ctx context.Context, principal manager.IdentityManager, resource Resource, mode string) (map[string]interface{}, error) {
+	if preloader, ok := principal.(manager.DatasetPreloader); ok && resource.Composite == nil && resource.DatasetNodeId != "" {
+		if err := preloader.PreloadDataset(ctx, resource.DatasetNodeId); err != nil {
+			return nil, authorizers.NewIndeterminateError(err)
+		}
+	}
+	currentUser, err := principal.GetCurrentUser(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, authorizers.NewDenyError(authorizers.ReasonUnknownUser, fmt.Errorf("no user found for principal: %w", err))
+		}
+		return nil, authorizers.NewIndeterminateError(fmt.Errorf("unable to get current user: %w", err))
+	}
+
+	claims, err := resource.Authorizer().GenerateClaims(ctx, &resolvedPrincipal{IdentityManager: principal, user: currentUser}, mode)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkOrganization(resource, claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// checkOrganization denies a request for a dataset or manifest that names an organization other than the one the
+// resource belongs to.
+func checkOrganization(resource Resource, claims map[string]interface{}) error {
+	if resource.OrganizationNodeId == "" || (resource.DatasetNodeId == "" && resource.ManifestId == "") {
+		return nil
+	}
+	orgClaim, ok := claims[coreAuthorizer.LabelOrganizationClaim].(*organization.Claim)
+	if !ok {
+		return authorizers.NewIndeterminateError(errors.New("no organization claim to check the requested organization against"))
+	}
+	if orgClaim.NodeId != resource.OrganizationNodeId {
+		return authorizers.NewDenyError(authorizers.ReasonOrganizationMismatch,
+			fmt.Errorf("requested organization %s does not own the resource; its organization is %s", resource.OrganizationNodeId, orgClaim.NodeId))
+	}
+	return nil
+}
+
+// resolvedPrincipal is principal with its current user already looked up, so that the authorizer does not look
+// it up a second time.
+type resolvedPrincipal struct {
+	manager.IdentityManager
+	user *pgdbModels.User
+}
+
+func (p *resolvedPrincipal) GetCurrentUser(context.Context) (*pgdbModels.User, error) {
+	return p.user, nil
+}
@@ -0,0 +1,249 @@
+package pipeline_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/authorizers"
+	claimsClient "github.com/pennsieve/pennsieve-go-api/authorizer/claims"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/deadline"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/fault"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/metrics"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/pipeline"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/test"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/test/mocks"
+	coreAuthorizer "github.com/pennsieve/pennsieve-go-core/pkg/authorizer"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dataset"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/organization"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/role"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/teamUser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSources() (pipeline.Sources, *mocks.MockPennsievePgAPI) {
+	pg := mocks.NewMockPennsievePgAPI()
+	return pipeline.Sources{
+		PostgresDB:    pg,
+		DynamoDB:      mocks.NewMockPennsieveDyAPI(),
+		TokenClientId: uuid.NewString(),
+		ManifestTable: uuid.NewString(),
+	}, pg
+}
+
+func TestResourceAuthorizer(t *testing.T) {
+	for name, params := range map[string]struct {
+		resource      pipeline.Resource
+		expectedScope string
+	}{
+		"user only":             {resource: pipeline.Resource{}, expectedScope: "user"},
+		"organization":          {resource: pipeline.Resource{OrganizationNodeId: "N:organization:1"}, expectedScope: "workspace"},
+		"dataset":               {resource: pipeline.Resource{DatasetNodeId: "N:dataset:1"}, expectedScope: "dataset"},
+		"dataset with its org":  {resource: pipeline.Resource{DatasetNodeId: "N:dataset:1", OrganizationNodeId: "N:organization:1"}, expectedScope: "dataset"},
+		"manifest":              {resource: pipeline.Resource{ManifestId: "m"}, expectedScope: "manifest"},
+		"manifest with its org": {resource: pipeline.Resource{ManifestId: "m", OrganizationNodeId: "N:organization:1"}, expectedScope: "manifest"},
+		"several resources":     {resource: pipeline.Resource{Composite: &authorizers.CompositeAuthorizer{}}, expectedScope: "composite"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, params.expectedScope, params.resource.Scope())
+		})
+	}
+}
+
+func TestResourceOf(t *testing.T) {
+	assert.Equal(t, pipeline.Resource{DatasetNodeId: "N:dataset:1"}, pipeline.ResourceOf(authorizers.NewDatasetAuthorizer("N:dataset:1")))
+	assert.Equal(t, pipeline.Resource{OrganizationNodeId: "N:organization:1"}, pipeline.ResourceOf(authorizers.NewWorkspaceAuthorizer("N:organization:1")))
+	assert.Equal(t, pipeline.Resource{ManifestId: "m"}, pipeline.ResourceOf(authorizers.NewManifestAuthorizer("m")))
+	assert.Equal(t, pipeline.Resource{}, pipeline.ResourceOf(authorizers.NewUserAuthorizer()))
+	composite := &authorizers.CompositeAuthorizer{}
+	assert.Equal(t, pipeline.Resource{Composite: composite}, pipeline.ResourceOf(composite))
+}
+
+func TestResolveNodeIdPrincipal(t *testing.T) {
+	sources, pg := newSources()
+	currentUser := test.NewUser(101, 1001)
+	orgId := int64(2001)
+	orgNodeId := fmt.Sprintf("N:organization:%s", uuid.NewString())
+	datasetNodeId := fmt.Sprintf("N:dataset:%s", uuid.NewString())
+	datasetClaim := &dataset.Claim{Role: role.Editor, NodeId: datasetNodeId}
+	pg.OnGetUserByNodeId(currentUser.NodeId).Return(currentUser, nil).Once()
+	pg.OnGetOrganizationIdForDataset(datasetNodeId).Return(orgId, nil)
+	pg.OnGetOrganizationClaim(currentUser.Id, orgId).Return(&organization.Claim{Role: pgdb.Read, IntId: orgId, NodeId: orgNodeId}, nil)
+	pg.OnGetDatasetClaim(currentUser, datasetNodeId, orgId).Return(datasetClaim, nil)
+	pg.OnGetDatasetState(datasetNodeId, orgId).Return(&claimsClient.DatasetState{}, nil)
+	pg.OnGetDataUseAgreementStatus(currentUser.Id, datasetNodeId, orgId).Return(&manager.DataUseAgreementStatus{}, nil)
+
+	claims, err := pipeline.Resolve(context.Background(), sources.NodeIdPrincipal(currentUser.NodeId),
+		pipeline.Resource{DatasetNodeId: datasetNodeId, OrganizationNodeId: orgNodeId}, "")
+	require.NoError(t, err)
+
+	assert.Equal(t, datasetClaim, claims[coreAuthorizer.LabelDatasetClaim])
+	pg.AssertExpectations(t)
+}
+
+func TestResolveOrganizationMismatch(t *testing.T) {
+	sources, pg := newSources()
+	currentUser := test.NewUser(101, 1001)
+	orgId := int64(2001)
+	datasetNodeId := fmt.Sprintf("N:dataset:%s", uuid.NewString())
+	pg.OnGetUserByNodeId(currentUser.NodeId).Return(currentUser, nil)
+	pg.OnGetOrganizationIdForDataset(datasetNodeId).Return(orgId, nil)
+	pg.OnGetOrganizationClaim(currentUser.Id, orgId).
+		Return(&organization.Claim{Role: pgdb.Read, IntId: orgId, NodeId: "N:organization:owner"}, nil)
+	pg.OnGetDatasetClaim(currentUser, datasetNodeId, orgId).Return(&dataset.Claim{Role: role.Viewer, NodeId: datasetNodeId}, nil)
+	pg.OnGetDatasetState(datasetNodeId, orgId).Return(&claimsClient.DatasetState{}, nil)
+	pg.OnGetDataUseAgreementStatus(currentUser.Id, datasetNodeId, orgId).Return(&manager.DataUseAgreementStatus{}, nil)
+
+	claims, err := pipeline.Resolve(context.Background(), sources.NodeIdPrincipal(currentUser.NodeId),
+		pipeline.Resource{DatasetNodeId: datasetNodeId, OrganizationNodeId: "N:organization:other"}, "")
+
+	assert.Nil(t, claims)
+	assert.Equal(t, authorizers.ReasonOrganizationMismatch, authorizers.DenyReason(err))
+	pg.AssertExpectations(t)
+}
+
+func TestResolvePrincipalErrors(t *testing.T) {
+	for name, params := range map[string]struct {
+		lookupErr             error
+		expectedReason        string
+		expectedIndeterminate bool
+	}{
+		"unknown user":  {lookupErr: sql.ErrNoRows, expectedReason: authorizers.ReasonUnknownUser},
+		"lookup failed": {lookupErr: errors.New("connection refused"), expectedIndeterminate: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			sources, pg := newSources()
+			userNodeId := fmt.Sprintf("N:user:%s", uuid.NewString())
+			pg.OnGetUserByNodeId(userNodeId).Return((*pgdb.User)(nil), params.lookupErr)
+
+			claims, err := pipeline.Resolve(context.Background(), sources.NodeIdPrincipal(userNodeId), pipeline.Resource{}, "")
+
+			assert.Nil(t, claims)
+			require.ErrorIs(t, err, params.lookupErr)
+			assert.Equal(t, params.expectedReason, authorizers.DenyReason(err))
+			var indeterminate *authorizers.IndeterminateError
+			assert.Equal(t, params.expectedIndeterminate, errors.As(err, &indeterminate))
+			pg.AssertExpectations(t)
+		})
+	}
+}
+
+func TestResolveCognitoPrincipal(t *testing.T) {
+	sources, pg := newSources()
+	username := uuid.NewString()
+	currentUser := test.NewUser(101, 1001)
+	orgNodeId := fmt.Sprintf("N:organization:%s", uuid.NewString())
+	orgClaim := &organization.Claim{Role: pgdb.Write, IntId: 2001, NodeId: orgNodeId}
+	pg.OnGetByCognitoId(username).Return(currentUser, nil).Once()
+	pg.OnGetOrganizationClaimByNodeId(currentUser.Id, orgNodeId).Return(orgClaim, nil)
+	pg.OnGetTeamClaimsForOrg(currentUser.Id, orgClaim.IntId).Return([]teamUser.Claim{}, nil)
+	token := test.NewJWTBuilder().WithUsername(username).Build(t)
+
+	claims, err := pipeline.Resolve(context.Background(), sources.CognitoPrincipal(token.Token), pipeline.Resource{OrganizationNodeId: orgNodeId}, "")
+	require.NoError(t, err)
+
+	assert.Equal(t, orgClaim, claims[coreAuthorizer.LabelOrganizationClaim])
+	pg.AssertExpectations(t)
+}
+
+func TestResolveTimeoutIsIndeterminate(t *testing.T) {
+	sources, pg := newSources()
+	username := uuid.NewString()
+	currentUser := test.NewUser(101, 1001)
+	orgNodeId := fmt.Sprintf("N:organization:%s", uuid.NewString())
+	timeout := &deadline.TimeoutError{Operation: "GetOrganizationClaimByNodeId", Err: errors.New("pq: canceling statement due to user request")}
+	pg.OnGetByCognitoId(username).Return(currentUser, nil)
+	pg.OnGetOrganizationClaimByNodeId(currentUser.Id, orgNodeId).Return((*organization.Claim)(nil), timeout)
+	token := test.NewJWTBuilder().WithUsername(username).Build(t)
+
+	// The workspace authorizer takes a failed organization lookup for a deny; a timed-out one must not be.
+	claims, err := pipeline.Resolve(context.Background(), sources.CognitoPrincipal(token.Token), pipeline.Resource{OrganizationNodeId: orgNodeId}, "")
+
+	assert.Nil(t, claims)
+	assert.ErrorIs(t, err, timeout)
+	var indeterminate *authorizers.IndeterminateError
+	assert.ErrorAs(t, err, &indeterminate)
+	pg.AssertExpectations(t)
+}
+
+// TestResolveInjectedFaults: whichever lookup fails or hangs, Resolve fails closed with an indeterminate error,
+// never a deny that could be cached.
+func TestResolveInjectedFaults(t *testing.T) {
+	for scenario, rule := range map[string]fault.Rule{
+		"user lookup fails":         {Operation: "GetByCognitoId", Kind: fault.KindError},
+		"user lookup hangs":         {Operation: "GetByCognitoId", Kind: fault.KindTimeout},
+		"organization lookup fails": {Operation: "GetOrganizationClaimByNodeId", Kind: fault.KindError},
+		"organization lookup hangs": {Operation: "GetOrganizationClaimByNodeId", Kind: fault.KindTimeout},
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			sources, pg := newSources()
+			username := uuid.NewString()
+			currentUser := test.NewUser(101, 1001)
+			orgNodeId := fmt.Sprintf("N:organization:%s", uuid.NewString())
+			pg.OnGetByCognitoId(username).Return(currentUser, nil).Maybe()
+			pg.OnGetOrganizationClaimByNodeId(currentUser.Id, orgNodeId).Return(&organization.Claim{Role: pgdb.Read}, nil).Maybe()
+			rule.Dependency = metrics.DependencyPostgres
+			injector := fault.NewInjector(fault.Config{Rules: []fault.Rule{rule}})
+			sources.PostgresDB = manager.NewTimedPgAPI(manager.NewFaultyPgAPI(pg, injector), mocks.NewMetrics(), 10*time.Millisecond)
+			token := test.NewJWTBuilder().WithUsername(username).Build(t)
+
+			claims, err := pipeline.Resolve(context.Background(), sources.CognitoPrincipal(token.Token), pipeline.Resource{OrganizationNodeId: orgNodeId}, "")
+
+			assert.Nil(t, claims)
+			assert.ErrorIs(t, err, fault.ErrInjected)
+			assert.Empty(t, authorizers.DenyReason(err))
+			var indeterminate *authorizers.IndeterminateError
+			assert.ErrorAs(t, err, &indeterminate)
+		})
+	}
+}
+
+func TestResolvePreloadsDataset(t *testing.T) {
+	pg := mocks.NewMockDatasetAuthorizationPgAPI()
+	sources := pipeline.Sources{PostgresDB: pg, DynamoDB: mocks.NewMockPennsieveDyAPI(), TokenClientId: uuid.NewString()}
+	username := uuid.NewString()
+	currentUser := test.NewUser(101, 1001)
+	orgId := int64(2001)
+	datasetNodeId := fmt.Sprintf("N:dataset:%s", uuid.NewString())
+	orgClaim := &organization.Claim{Role: pgdb.Read, IntId: orgId, NodeId: "N:organization:owner"}
+	datasetClaim := &dataset.Claim{Role: role.Viewer, NodeId: datasetNodeId}
+	// The user, the dataset's organization and both claims are preloaded together.
+	pg.OnGetDatasetAuthorization(username, false, datasetNodeId).Return(&manager.DatasetAuthorization{
+		User: currentUser, OrganizationId: orgId,
+		Organization: &manager.OrganizationClaim{Claim: *orgClaim}, Dataset: &manager.DatasetClaim{Claim: *datasetClaim},
+	}, nil).Once()
+	pg.OnGetDatasetState(datasetNodeId, orgId).Return(&claimsClient.DatasetState{}, nil)
+	pg.OnGetDataUseAgreementStatus(currentUser.Id, datasetNodeId, orgId).Return(&manager.DataUseAgreementStatus{}, nil)
+	token := test.NewJWTBuilder().WithUsername(username).Build(t)
+
+	claims, err := pipeline.Resolve(context.Background(), sources.CognitoPrincipal(token.Token), pipeline.Resource{DatasetNodeId: datasetNodeId}, "")
+	require.NoError(t, err)
+
+	assert.Equal(t, orgClaim, claims[coreAuthorizer.LabelOrganizationClaim])
+	assert.Equal(t, datasetClaim, claims[coreAuthorizer.LabelDatasetClaim])
+	pg.AssertExpectations(t)
+}
+
+func TestResolvePreloadFailureIsIndeterminate(t *testing.T) {
+	pg := mocks.NewMockDatasetAuthorizationPgAPI()
+	sources := pipeline.Sources{PostgresDB: pg, DynamoDB: mocks.NewMockPennsieveDyAPI(), TokenClientId: uuid.NewString()}
+	username := uuid.NewString()
+	datasetNodeId := fmt.Sprintf("N:dataset:%s", uuid.NewString())
+	pg.OnGetDatasetAuthorization(username, false, datasetNodeId).Return((*manager.DatasetAuthorization)(nil), errors.New("connection refused")).Once()
+	token := test.NewJWTBuilder().WithUsername(username).Build(t)
+
+	claims, err := pipeline.Resolve(context.Background(), sources.CognitoPrincipal(token.Token), pipeline.Resource{DatasetNodeId: datasetNodeId}, "")
+
+	// Nothing is looked up one by one after the single statement fails.
+	assert.Nil(t, claims)
+	var indeterminate *authorizers.IndeterminateError
+	assert.ErrorAs(t, err, &indeterminate)
+	pg.AssertExpectations(t)
+}
@@ -0,0 +1,121 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// maxTakeAttempts bounds the optimistic-concurrency retries of DynamoStore.Take when several
+// Lambda instances update the same bucket at once.
+const maxTakeAttempts = 3
+
+// DynamoAPI is the subset of *dynamodb.Client used by DynamoStore.
+type DynamoAPI interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+}
+
+// DynamoStore keeps buckets in a DynamoDB table with string partition key "bucketKey". Each item
+// holds the remaining "tokens", the "updatedAt" time in Unix milliseconds, a "version" counter for
+// optimistic concurrency, and an "expiresAt" time in Unix seconds after which the bucket would be
+// full again, for use as the table's TTL attribute.
+type DynamoStore struct {
+	client    DynamoAPI
+	tableName string
+}
+
+func NewDynamoStore(client DynamoAPI, tableName string) *DynamoStore {
+	return &DynamoStore{client: client, tableName: tableName}
+}
+
+func (s *DynamoStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (bool, error) {
+	for attempt := 0; attempt < maxTakeAttempts; attempt++ {
+		taken, err := s.tryTake(ctx, key, limit, now)
+		var conflict *types.ConditionalCheckFailedException
+		if errors.As(err, &conflict) {
+			continue
+		}
+		return taken, err
+	}
+	return false, fmt.Errorf("bucket %s updated concurrently %d times", key, maxTakeAttempts)
+}
+
+func (s *DynamoStore) tryTake(ctx context.Context, key string, limit Limit, now time.Time) (bool, error) {
+	output, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(s.tableName),
+		Key:            map[string]types.AttributeValue{"bucketKey": &types.AttributeValueMemberS{Value: key}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	tokens, updated := limit.Burst, now
+	var version float64
+	if output.Item != nil {
+		if tokens, err = numberAttribute(output.Item, "tokens"); err != nil {
+			return false, err
+		}
+		updatedMillis, err := numberAttribute(output.Item, "updatedAt")
+		if err != nil {
+			return false, err
+		}
+		updated = time.UnixMilli(int64(updatedMillis))
+		if version, err = numberAttribute(output.Item, "version"); err != nil {
+			return false, err
+		}
+	}
+
+	tokens = refill(tokens, updated, limit, now)
+	if tokens < 1 {
+		return false, nil
+	}
+	tokens--
+
+	put := &dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item: map[string]types.AttributeValue{
+			"bucketKey": &types.AttributeValueMemberS{Value: key},
+			"tokens":    &types.AttributeValueMemberN{Value: strconv.FormatFloat(tokens, 'f', -1, 64)},
+			"updatedAt": &types.AttributeValueMemberN{Value: strconv.FormatInt(now.UnixMilli(), 10)},
+			"version":   &types.AttributeValueMemberN{Value: strconv.FormatInt(int64(version)+1, 10)},
+			"expiresAt": &types.AttributeValueMemberN{Value: strconv.FormatInt(fullAt(tokens, limit, now).Unix(), 10)},
+		},
+	}
+	if output.Item == nil {
+		put.ConditionExpression = aws.String("attribute_not_exists(bucketKey)")
+	} else {
+		put.ConditionExpression = aws.String("version = :version")
+		put.ExpressionAttributeValues = map[string]types.AttributeValue{
+			":version": &types.AttributeValueMemberN{Value: strconv.FormatInt(int64(version), 10)},
+		}
+	}
+	if _, err := s.client.PutItem(ctx, put); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// fullAt returns when a bucket holding tokens at now will have refilled to limit.Burst. After
+// that the item carries no information and can expire.
+func fullAt(tokens float64, limit Limit, now time.Time) time.Time {
+	if limit.PerSecond <= 0 {
+		return now.Add(24 * time.Hour)
+	}
+	return now.Add(time.Duration((limit.Burst-tokens)/limit.PerSecond*float64(time.Second)) + time.Second)
+}
+
+func numberAttribute(item map[string]types.AttributeValue, name string) (float64, error) {
+	value, ok := item[name].(*types.AttributeValueMemberN)
+	if !ok {
+		return 0, fmt.Errorf("rate limit item attribute %s missing or not a number", name)
+	}
+	return strconv.ParseFloat(value.Value, 64)
+}
@@ -0,0 +1,98 @@
+package ratelimit_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/ratelimit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDynamo stores items by bucketKey and understands the two condition expressions DynamoStore
+// uses. conflicts makes the next PutItem calls fail their condition, as if another instance had
+// updated the bucket in between.
+type fakeDynamo struct {
+	mu        sync.Mutex
+	items     map[string]map[string]types.AttributeValue
+	conflicts int
+	puts      int
+}
+
+func newFakeDynamo() *fakeDynamo {
+	return &fakeDynamo{items: map[string]map[string]types.AttributeValue{}}
+}
+
+func (f *fakeDynamo) GetItem(_ context.Context, params *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := params.Key["bucketKey"].(*types.AttributeValueMemberS).Value
+	return &dynamodb.GetItemOutput{Item: f.items[key]}, nil
+}
+
+func (f *fakeDynamo) PutItem(_ context.Context, params *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.puts++
+	if f.conflicts > 0 {
+		f.conflicts--
+		return nil, &types.ConditionalCheckFailedException{}
+	}
+	key := params.Item["bucketKey"].(*types.AttributeValueMemberS).Value
+	existing, exists := f.items[key]
+	switch *params.ConditionExpression {
+	case "attribute_not_exists(bucketKey)":
+		if exists {
+			return nil, &types.ConditionalCheckFailedException{}
+		}
+	case "version = :version":
+		expected := params.ExpressionAttributeValues[":version"].(*types.AttributeValueMemberN).Value
+		if !exists || existing["version"].(*types.AttributeValueMemberN).Value != expected {
+			return nil, &types.ConditionalCheckFailedException{}
+		}
+	}
+	f.items[key] = params.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func TestDynamoStore(t *testing.T) {
+	client := newFakeDynamo()
+	store := ratelimit.NewDynamoStore(client, "rate-limits")
+	limit := ratelimit.Limit{Burst: 2, PerSecond: 1}
+	start := time.Now()
+
+	for i, expected := range []bool{true, true, false} {
+		taken, err := store.Take(context.Background(), "bucket", limit, start)
+		require.NoError(t, err)
+		assert.Equal(t, expected, taken, "take %d", i)
+	}
+	assert.Equal(t, 2, client.puts, "a refused take should not write")
+
+	taken, err := store.Take(context.Background(), "bucket", limit, start.Add(time.Second))
+	require.NoError(t, err)
+	assert.True(t, taken)
+	assert.Equal(t, "3", client.items["bucket"]["version"].(*types.AttributeValueMemberN).Value)
+}
+
+func TestDynamoStoreConflicts(t *testing.T) {
+	limit := ratelimit.Limit{Burst: 5, PerSecond: 1}
+
+	t.Run("retried", func(t *testing.T) {
+		client := newFakeDynamo()
+		client.conflicts = 2
+		taken, err := ratelimit.NewDynamoStore(client, "rate-limits").Take(context.Background(), "bucket", limit, time.Now())
+		require.NoError(t, err)
+		assert.True(t, taken)
+	})
+
+	t.Run("gives up", func(t *testing.T) {
+		client := newFakeDynamo()
+		client.conflicts = 3
+		_, err := ratelimit.NewDynamoStore(client, "rate-limits").Take(context.Background(), "bucket", limit, time.Now())
+		assert.ErrorContains(t, err, "updated concurrently")
+	})
+}
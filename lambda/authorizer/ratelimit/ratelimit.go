@@ -0,0 +1,136 @@
+// Package ratelimit throttles authorization requests per principal (user, API token or callback
+// service) with token buckets. Buckets live behind the Store interface: DynamoStore shares them
+// across Lambda instances, MemoryStore keeps them in-process for tests and local runs.
+//
+// The authorizer only sees requests that API Gateway has not answered from its cache, so limits
+// bound the rate of uncached authorizations, which is what reaches the database and, once a
+// principal's decisions expire from the cache, what reaches downstream services.
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// Principal kinds.
+const (
+	KindUser     = "user"
+	KindToken    = "token"
+	KindCallback = "callback"
+)
+
+// Principal identifies who a bucket belongs to: the Cognito username of a user or an API token,
+// or a callback service name.
+type Principal struct {
+	Kind string
+	Id   string
+}
+
+func (p Principal) String() string {
+	return p.Kind + ":" + p.Id
+}
+
+// Limit is a token bucket: up to Burst requests at once, refilled at PerSecond requests per second.
+// A Limit with a non-positive Burst does not throttle.
+type Limit struct {
+	Burst     float64 `json:"burst"`
+	PerSecond float64 `json:"perSecond"`
+}
+
+func (l Limit) unlimited() bool {
+	return l.Burst <= 0
+}
+
+// Config selects the Limit for a request. An organization override (keyed by organization node id)
+// wins over a scope override (keyed by authorizer scope, e.g. "dataset" or "callback"), which wins over Default.
+type Config struct {
+	Default Limit            `json:"default"`
+	Scopes  map[string]Limit `json:"scopes,omitempty"`
+	Orgs    map[string]Limit `json:"orgs,omitempty"`
+}
+
+// LimitFor returns the Limit that applies to a request in scope for the given organization.
+// orgNodeId may be empty when the request is not organization-scoped.
+func (c Config) LimitFor(orgNodeId string, scope string) Limit {
+	if limit, ok := c.Orgs[orgNodeId]; ok && orgNodeId != "" {
+		return limit
+	}
+	if limit, ok := c.Scopes[scope]; ok {
+		return limit
+	}
+	return c.Default
+}
+
+// ConfigFromEnv parses the JSON Config in RATE_LIMIT_CONFIG. The boolean is false, and rate limiting
+// should be disabled, when the variable is unset.
+func ConfigFromEnv() (Config, bool, error) {
+	var config Config
+	raw := os.Getenv("RATE_LIMIT_CONFIG")
+	if raw == "" {
+		return config, false, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &config); err != nil {
+		return config, false, fmt.Errorf("invalid RATE_LIMIT_CONFIG: %w", err)
+	}
+	return config, true, nil
+}
+
+// Store holds token buckets.
+type Store interface {
+	// Take refills the bucket named key according to limit as of now, then removes one token if
+	// one is available. It reports whether a token was taken.
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (bool, error)
+}
+
+// Limiter applies a Config to the buckets in a Store.
+type Limiter struct {
+	store     Store
+	config    Config
+	now       func() time.Time
+	throttled atomic.Int64
+}
+
+// NewLimiter returns a Limiter that keeps its buckets in store.
+func NewLimiter(store Store, config Config) *Limiter {
+	return &Limiter{store: store, config: config, now: time.Now}
+}
+
+// Allow reports whether principal may make another request in scope for the organization with
+// the given node id (empty if the request is not organization-scoped). Each principal has a
+// separate bucket per scope and organization.
+func (l *Limiter) Allow(ctx context.Context, principal Principal, orgNodeId string, scope string) (bool, error) {
+	limit := l.config.LimitFor(orgNodeId, scope)
+	if limit.unlimited() {
+		return true, nil
+	}
+	key := fmt.Sprintf("%s|%s|%s", principal, scope, orgNodeId)
+	allowed, err := l.store.Take(ctx, key, limit, l.now())
+	if err != nil {
+		return false, fmt.Errorf("unable to take rate limit token for %s: %w", key, err)
+	}
+	if !allowed {
+		l.throttled.Add(1)
+	}
+	return allowed, nil
+}
+
+// Throttled returns the number of requests this Limiter has refused since it was created.
+func (l *Limiter) Throttled() int64 {
+	return l.throttled.Load()
+}
+
+// refill returns the tokens in a bucket that held tokens at updated, as of now.
+func refill(tokens float64, updated time.Time, limit Limit, now time.Time) float64 {
+	elapsed := now.Sub(updated).Seconds()
+	if elapsed > 0 {
+		tokens += elapsed * limit.PerSecond
+	}
+	if tokens > limit.Burst {
+		tokens = limit.Burst
+	}
+	return tokens
+}
@@ -0,0 +1,126 @@
+package ratelimit_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pennsieve/pennsieve-go-api/authorizer/ratelimit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigLimitFor(t *testing.T) {
+	config := ratelimit.Config{
+		Default: ratelimit.Limit{Burst: 10, PerSecond: 1},
+		Scopes:  map[string]ratelimit.Limit{"manifest": {Burst: 50, PerSecond: 5}},
+		Orgs:    map[string]ratelimit.Limit{"N:organization:big": {Burst: 500, PerSecond: 50}},
+	}
+
+	for scenario, params := range map[string]struct {
+		orgNodeId string
+		scope     string
+		expected  ratelimit.Limit
+	}{
+		"default":               {"N:organization:small", "dataset", config.Default},
+		"scope override":        {"N:organization:small", "manifest", config.Scopes["manifest"]},
+		"org override":          {"N:organization:big", "dataset", config.Orgs["N:organization:big"]},
+		"org wins over scope":   {"N:organization:big", "manifest", config.Orgs["N:organization:big"]},
+		"no org falls to scope": {"", "manifest", config.Scopes["manifest"]},
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			assert.Equal(t, params.expected, config.LimitFor(params.orgNodeId, params.scope))
+		})
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Run("unset", func(t *testing.T) {
+		t.Setenv("RATE_LIMIT_CONFIG", "")
+		_, enabled, err := ratelimit.ConfigFromEnv()
+		assert.NoError(t, err)
+		assert.False(t, enabled)
+	})
+	t.Run("valid", func(t *testing.T) {
+		t.Setenv("RATE_LIMIT_CONFIG", `{"default": {"burst": 20, "perSecond": 2}, "scopes": {"callback": {"burst": 100, "perSecond": 10}}}`)
+		config, enabled, err := ratelimit.ConfigFromEnv()
+		require.NoError(t, err)
+		assert.True(t, enabled)
+		assert.Equal(t, ratelimit.Limit{Burst: 20, PerSecond: 2}, config.Default)
+		assert.Equal(t, ratelimit.Limit{Burst: 100, PerSecond: 10}, config.Scopes["callback"])
+	})
+	t.Run("invalid", func(t *testing.T) {
+		t.Setenv("RATE_LIMIT_CONFIG", `{"default":`)
+		_, enabled, err := ratelimit.ConfigFromEnv()
+		assert.Error(t, err)
+		assert.False(t, enabled)
+	})
+}
+
+func TestMemoryStoreRefill(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+	limit := ratelimit.Limit{Burst: 2, PerSecond: 1}
+	start := time.Now()
+
+	for i, expected := range []bool{true, true, false} {
+		taken, err := store.Take(context.Background(), "bucket", limit, start)
+		require.NoError(t, err)
+		assert.Equal(t, expected, taken, "take %d", i)
+	}
+
+	taken, err := store.Take(context.Background(), "bucket", limit, start.Add(time.Second))
+	require.NoError(t, err)
+	assert.True(t, taken, "a token should have refilled after one second")
+
+	taken, err = store.Take(context.Background(), "bucket", limit, start.Add(time.Hour))
+	require.NoError(t, err)
+	assert.True(t, taken)
+	taken, _ = store.Take(context.Background(), "bucket", limit, start.Add(time.Hour))
+	assert.True(t, taken, "bucket should refill up to burst")
+	taken, _ = store.Take(context.Background(), "bucket", limit, start.Add(time.Hour))
+	assert.False(t, taken, "bucket should not refill past burst")
+}
+
+func TestLimiter(t *testing.T) {
+	config := ratelimit.Config{
+		Default: ratelimit.Limit{Burst: 2, PerSecond: 0.001},
+		Scopes:  map[string]ratelimit.Limit{"user": {}},
+	}
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), config)
+	alice := ratelimit.Principal{Kind: ratelimit.KindUser, Id: "alice"}
+	apiToken := ratelimit.Principal{Kind: ratelimit.KindToken, Id: "alice-api-key"}
+	ctx := context.Background()
+
+	allow := func(principal ratelimit.Principal, org string, scope string) bool {
+		allowed, err := limiter.Allow(ctx, principal, org, scope)
+		require.NoError(t, err)
+		return allowed
+	}
+
+	assert.True(t, allow(alice, "N:organization:1", "dataset"))
+	assert.True(t, allow(alice, "N:organization:1", "dataset"))
+	assert.False(t, allow(alice, "N:organization:1", "dataset"))
+	assert.Equal(t, int64(1), limiter.Throttled())
+
+	assert.True(t, allow(apiToken, "N:organization:1", "dataset"), "API tokens have their own bucket")
+	assert.True(t, allow(alice, "N:organization:2", "dataset"), "each organization has its own bucket")
+	assert.True(t, allow(alice, "N:organization:1", "workspace"), "each scope has its own bucket")
+	for i := 0; i < 10; i++ {
+		assert.True(t, allow(alice, "", "user"), "a zero limit does not throttle")
+	}
+	assert.Equal(t, int64(1), limiter.Throttled())
+}
+
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, ratelimit.Limit, time.Time) (bool, error) {
+	return false, errors.New("table not found")
+}
+
+func TestLimiterStoreError(t *testing.T) {
+	limiter := ratelimit.NewLimiter(failingStore{}, ratelimit.Config{Default: ratelimit.Limit{Burst: 1}})
+	_, err := limiter.Allow(context.Background(), ratelimit.Principal{Kind: ratelimit.KindCallback, Id: "workflow-service"}, "", "callback")
+	assert.ErrorContains(t, err, "table not found")
+	assert.Equal(t, int64(0), limiter.Throttled())
+}
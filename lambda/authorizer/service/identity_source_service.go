@@ -12,14 +12,13 @@ type IdentityService interface {
 }
 
 type IdentitySourceService struct {
-	IdentitySource        []string
-	QueryStringParameters map[string]string
+	Event factory.Event
 }
 
-func NewIdentitySourceService(IdentitySource []string, queryStringParameters map[string]string) IdentityService {
-	return &IdentitySourceService{IdentitySource, queryStringParameters}
+func NewIdentitySourceService(event factory.Event) IdentityService {
+	return &IdentitySourceService{event}
 }
 
 func (i *IdentitySourceService) GetAuthorizer(ctx context.Context) (authorizers.Authorizer, error) {
-	return factory.NewCustomAuthorizerFactory().Build(i.IdentitySource, i.QueryStringParameters)
+	return factory.NewCustomAuthorizerFactory().Build(i.Event)
 }
@@ -0,0 +1,23 @@
+package mocks
+
+import (
+	"sync"
+
+	"github.com/pennsieve/pennsieve-go-api/authorizer/audit"
+)
+
+// AuditLogger is an audit.Logger that keeps every record in memory so tests can assert on them.
+type AuditLogger struct {
+	mu      sync.Mutex
+	Records []audit.Record
+}
+
+func NewAuditLogger() *AuditLogger {
+	return &AuditLogger{}
+}
+
+func (l *AuditLogger) Log(record audit.Record) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.Records = append(l.Records, record)
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/dydb"
 
+	"github.com/pennsieve/pennsieve-go-api/authorizer/claims"
 	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/dataset"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/organization"
@@ -83,3 +84,39 @@ func (m *MockClaimManager) GetTokenWorkspace() (manager.TokenWorkspace, bool) {
 		NodeId: fmt.Sprintf("N:organization:%s", uuid.NewString()),
 	}, true
 }
+
+func (m *MockClaimManager) GetAuthentication() manager.Authentication {
+	return manager.Authentication{Method: manager.AuthenticationApiToken}
+}
+
+func (m *MockClaimManager) GetUserByNodeId(context.Context, string) (*pgdbModels.User, error) {
+	return nil, fmt.Errorf("mock method not implemented")
+}
+
+func (m *MockClaimManager) GetOrganizationIpAllowlist(context.Context, int64) ([]string, error) {
+	return nil, nil
+}
+
+func (m *MockClaimManager) GetOrganizationAuthPolicy(context.Context, int64) (*manager.AuthPolicy, error) {
+	return &manager.AuthPolicy{}, nil
+}
+
+func (m *MockClaimManager) GetDatasetIdentity(context.Context, string, int64) (*manager.DatasetIdentity, error) {
+	return nil, fmt.Errorf("mock method not implemented")
+}
+
+func (m *MockClaimManager) GetDatasetState(context.Context, string, int64) (*claims.DatasetState, error) {
+	return &claims.DatasetState{}, nil
+}
+
+func (m *MockClaimManager) GetDataUseAgreementStatus(context.Context, int64, string, int64) (*manager.DataUseAgreementStatus, error) {
+	return &manager.DataUseAgreementStatus{}, nil
+}
+
+func (m *MockClaimManager) GetGrantExpiry(context.Context, int64, string, int64) (*claims.GrantExpiry, error) {
+	return &claims.GrantExpiry{}, nil
+}
+
+func (m *MockClaimManager) GetExternalDatasetGrant(context.Context, int64, string, int64) (*manager.ExternalGrant, error) {
+	return nil, fmt.Errorf("mock method not implemented")
+}
@@ -0,0 +1,76 @@
+package mocks
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pennsieve/pennsieve-go-api/authorizer/metrics"
+)
+
+// MetricSample is a single value recorded by Metrics.
+type MetricSample struct {
+	Name       string
+	Dimensions metrics.Dimensions
+	Elapsed    time.Duration
+}
+
+// Metrics is a metrics.Metrics that keeps every value in memory so tests can assert on them.
+type Metrics struct {
+	mu        sync.Mutex
+	Counts    []MetricSample
+	Latencies []MetricSample
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{}
+}
+
+func (m *Metrics) Count(name string, dimensions metrics.Dimensions) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Counts = append(m.Counts, MetricSample{Name: name, Dimensions: dimensions})
+}
+
+func (m *Metrics) Latency(name string, dimensions metrics.Dimensions, elapsed time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Latencies = append(m.Latencies, MetricSample{Name: name, Dimensions: dimensions, Elapsed: elapsed})
+}
+
+// CountOf returns how many times the named counter was incremented with exactly the given dimensions.
+func (m *Metrics) CountOf(name string, dimensions metrics.Dimensions) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	count := 0
+	for _, sample := range m.Counts {
+		if sample.Name == name && sameDimensions(sample.Dimensions, dimensions) {
+			count++
+		}
+	}
+	return count
+}
+
+// LatencyOperations returns the Operation dimension of every latency recorded for dependency, in order.
+func (m *Metrics) LatencyOperations(dependency string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var operations []string
+	for _, sample := range m.Latencies {
+		if sample.Dimensions["Dependency"] == dependency {
+			operations = append(operations, sample.Dimensions["Operation"])
+		}
+	}
+	return operations
+}
+
+func sameDimensions(a, b metrics.Dimensions) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		if b[key] != value {
+			return false
+		}
+	}
+	return true
+}
@@ -2,6 +2,8 @@ package mocks
 
 import (
 	"context"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/claims"
+	"github.com/pennsieve/pennsieve-go-api/authorizer/manager"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/dataset"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/organization"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
@@ -22,19 +24,41 @@ func NewMockPennsievePgAPI() *MockPennsievePgAPI {
 
 // Interface methods for manager.PennsievePgAPI
 
-func (m *MockPennsievePgAPI) GetDatasetClaim(ctx context.Context, user *pgdb.User, datasetNodeId string, organizationId int64) (*dataset.Claim, error) {
+// GetDatasetClaim returns what the expectation returns: a *manager.DatasetClaim, or a *dataset.Claim for a role that
+// does not expire.
+func (m *MockPennsievePgAPI) GetDatasetClaim(ctx context.Context, user *pgdb.User, datasetNodeId string, organizationId int64) (*manager.DatasetClaim, error) {
 	args := m.Called(ctx, user, datasetNodeId, organizationId)
-	return args.Get(0).(*dataset.Claim), args.Error(1)
-}
-
-func (m *MockPennsievePgAPI) GetOrganizationClaim(ctx context.Context, userId int64, organizationId int64) (*organization.Claim, error) {
+	if claim, ok := args.Get(0).(*dataset.Claim); ok {
+		if claim == nil {
+			return nil, args.Error(1)
+		}
+		return &manager.DatasetClaim{Claim: *claim}, args.Error(1)
+	}
+	return args.Get(0).(*manager.DatasetClaim), args.Error(1)
+}
+
+// GetOrganizationClaim returns what the expectation returns: a *manager.OrganizationClaim, or a *organization.Claim
+// for a membership that does not expire.
+func (m *MockPennsievePgAPI) GetOrganizationClaim(ctx context.Context, userId int64, organizationId int64) (*manager.OrganizationClaim, error) {
 	args := m.Called(ctx, userId, organizationId)
-	return args.Get(0).(*organization.Claim), args.Error(1)
+	return organizationClaim(args.Get(0)), args.Error(1)
 }
 
-func (m *MockPennsievePgAPI) GetOrganizationClaimByNodeId(ctx context.Context, userId int64, organizationNodeId string) (*organization.Claim, error) {
+// GetOrganizationClaimByNodeId returns what the expectation returns, as GetOrganizationClaim does.
+func (m *MockPennsievePgAPI) GetOrganizationClaimByNodeId(ctx context.Context, userId int64, organizationNodeId string) (*manager.OrganizationClaim, error) {
 	args := m.Called(ctx, userId, organizationNodeId)
-	return args.Get(0).(*organization.Claim), args.Error(1)
+	return organizationClaim(args.Get(0)), args.Error(1)
+}
+
+// organizationClaim returns the claim an expectation returns as a *manager.OrganizationClaim.
+func organizationClaim(returned any) *manager.OrganizationClaim {
+	if claim, ok := returned.(*organization.Claim); ok {
+		if claim == nil {
+			return nil
+		}
+		return &manager.OrganizationClaim{Claim: *claim}
+	}
+	return returned.(*manager.OrganizationClaim)
 }
 
 func (m *MockPennsievePgAPI) GetTeamClaims(ctx context.Context, userId int64) ([]teamUser.Claim, error) {
@@ -62,6 +86,41 @@ func (m *MockPennsievePgAPI) GetByCognitoId(ctx context.Context, cognitoId strin
 	return args.Get(0).(*pgdb.User), args.Error(1)
 }
 
+func (m *MockPennsievePgAPI) GetUserByNodeId(ctx context.Context, nodeId string) (*pgdb.User, error) {
+	args := m.Called(ctx, nodeId)
+	return args.Get(0).(*pgdb.User), args.Error(1)
+}
+
+func (m *MockPennsievePgAPI) GetOrganizationIpAllowlist(ctx context.Context, organizationId int64) ([]string, error) {
+	args := m.Called(ctx, organizationId)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockPennsievePgAPI) GetOrganizationAuthPolicy(ctx context.Context, organizationId int64) (*manager.AuthPolicy, error) {
+	args := m.Called(ctx, organizationId)
+	return args.Get(0).(*manager.AuthPolicy), args.Error(1)
+}
+
+func (m *MockPennsievePgAPI) GetExternalDatasetGrant(ctx context.Context, userId int64, datasetNodeId string, organizationId int64) (*manager.ExternalGrant, error) {
+	args := m.Called(ctx, userId, datasetNodeId, organizationId)
+	return args.Get(0).(*manager.ExternalGrant), args.Error(1)
+}
+
+func (m *MockPennsievePgAPI) GetDatasetIdentity(ctx context.Context, datasetNodeId string, organizationId int64) (*manager.DatasetIdentity, error) {
+	args := m.Called(ctx, datasetNodeId, organizationId)
+	return args.Get(0).(*manager.DatasetIdentity), args.Error(1)
+}
+
+func (m *MockPennsievePgAPI) GetDatasetState(ctx context.Context, datasetNodeId string, organizationId int64) (*claims.DatasetState, error) {
+	args := m.Called(ctx, datasetNodeId, organizationId)
+	return args.Get(0).(*claims.DatasetState), args.Error(1)
+}
+
+func (m *MockPennsievePgAPI) GetDataUseAgreementStatus(ctx context.Context, userId int64, datasetNodeId string, organizationId int64) (*manager.DataUseAgreementStatus, error) {
+	args := m.Called(ctx, userId, datasetNodeId, organizationId)
+	return args.Get(0).(*manager.DataUseAgreementStatus), args.Error(1)
+}
+
 // Helper methods for use in tests to set up expectations for any context.Context value
 
 func (m *MockPennsievePgAPI) OnGetDatasetClaim(user *pgdb.User, datasetNodeId string, organizationId int64) *mock.Call {
@@ -95,3 +154,49 @@ func (m *MockPennsievePgAPI) OnGetUserByCognitoId(cognitoId string) *mock.Call {
 func (m *MockPennsievePgAPI) OnGetByCognitoId(cognitoId string) *mock.Call {
 	return m.On("GetByCognitoId", mock.Anything, cognitoId)
 }
+
+func (m *MockPennsievePgAPI) OnGetUserByNodeId(nodeId string) *mock.Call {
+	return m.On("GetUserByNodeId", mock.Anything, nodeId)
+}
+
+func (m *MockPennsievePgAPI) OnGetOrganizationIpAllowlist(organizationId int64) *mock.Call {
+	return m.On("GetOrganizationIpAllowlist", mock.Anything, organizationId)
+}
+
+func (m *MockPennsievePgAPI) OnGetOrganizationAuthPolicy(organizationId int64) *mock.Call {
+	return m.On("GetOrganizationAuthPolicy", mock.Anything, organizationId)
+}
+
+func (m *MockPennsievePgAPI) OnGetExternalDatasetGrant(userId int64, datasetNodeId string, organizationId int64) *mock.Call {
+	return m.On("GetExternalDatasetGrant", mock.Anything, userId, datasetNodeId, organizationId)
+}
+
+func (m *MockPennsievePgAPI) OnGetDatasetIdentity(datasetNodeId string, organizationId int64) *mock.Call {
+	return m.On("GetDatasetIdentity", mock.Anything, datasetNodeId, organizationId)
+}
+
+func (m *MockPennsievePgAPI) OnGetDatasetState(datasetNodeId string, organizationId int64) *mock.Call {
+	return m.On("GetDatasetState", mock.Anything, datasetNodeId, organizationId)
+}
+
+func (m *MockPennsievePgAPI) OnGetDataUseAgreementStatus(userId int64, datasetNodeId string, organizationId int64) *mock.Call {
+	return m.On("GetDataUseAgreementStatus", mock.Anything, userId, datasetNodeId, organizationId)
+}
+
+// MockDatasetAuthorizationPgAPI is a MockPennsievePgAPI that also implements manager.DatasetAuthorizationAPI.
+type MockDatasetAuthorizationPgAPI struct {
+	*MockPennsievePgAPI
+}
+
+func NewMockDatasetAuthorizationPgAPI() *MockDatasetAuthorizationPgAPI {
+	return &MockDatasetAuthorizationPgAPI{MockPennsievePgAPI: NewMockPennsievePgAPI()}
+}
+
+func (m *MockDatasetAuthorizationPgAPI) GetDatasetAuthorization(ctx context.Context, cognitoId string, isFromTokenPool bool, datasetNodeId string) (*manager.DatasetAuthorization, error) {
+	args := m.Called(ctx, cognitoId, isFromTokenPool, datasetNodeId)
+	return args.Get(0).(*manager.DatasetAuthorization), args.Error(1)
+}
+
+func (m *MockDatasetAuthorizationPgAPI) OnGetDatasetAuthorization(cognitoId string, isFromTokenPool bool, datasetNodeId string) *mock.Call {
+	return m.On("GetDatasetAuthorization", mock.Anything, cognitoId, isFromTokenPool, datasetNodeId)
+}
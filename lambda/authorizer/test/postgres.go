@@ -2,9 +2,13 @@ package test
 
 import (
 	"database/sql"
+	"fmt"
 	"github.com/google/uuid"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/role"
 	"github.com/stretchr/testify/require"
+	"strings"
+	"time"
 )
 
 // AddUser inserts a user into the seed test database. The given user must have id > 3 since the seed database
@@ -51,3 +55,79 @@ func AddAPIToken(t require.TestingT, db *sql.DB, orgId, userId int64, apiKey str
 	require.NoError(t, err, "error inserting (org, user, token, clientId) into tokens (%d, %d, %s, %s)",
 		orgId, userId, apiKey, clientId)
 }
+
+// AddDataset adds a dataset to the given workspace and maps it to the workspace in pennsieve.dataset_organization.
+// It returns the dataset's id and node id.
+func AddDataset(t require.TestingT, db *sql.DB, orgId int64, name string) (int64, string) {
+	datasetNodeId := fmt.Sprintf("N:dataset:%s", uuid.NewString())
+	query := fmt.Sprintf(`INSERT INTO "%d"."datasets" (name, node_id, state, status_id) VALUES ($1, $2, 'READY', 1) RETURNING id`, orgId)
+	var datasetId int64
+	require.NoError(t, db.QueryRow(query, name, datasetNodeId).Scan(&datasetId), "error inserting dataset %s into workspace %d", name, orgId)
+	_, err := db.Exec(`INSERT INTO "pennsieve"."dataset_organization" (dataset_node_id, organization_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+		datasetNodeId, orgId)
+	require.NoError(t, err, "error mapping dataset %s to workspace %d", datasetNodeId, orgId)
+	return datasetId, datasetNodeId
+}
+
+// DeleteDataset deletes a dataset added by AddDataset. Its dataset_user rows are deleted with it.
+func DeleteDataset(t require.TestingT, db *sql.DB, orgId int64, datasetNodeId string) {
+	_, err := db.Exec(fmt.Sprintf(`DELETE FROM "%d"."datasets" WHERE node_id = $1`, orgId), datasetNodeId)
+	require.NoError(t, err, "error deleting dataset %s", datasetNodeId)
+	_, err = db.Exec(`DELETE FROM "pennsieve"."dataset_organization" WHERE dataset_node_id = $1`, datasetNodeId)
+	require.NoError(t, err, "error unmapping dataset %s", datasetNodeId)
+}
+
+// AddDatasetUser gives the user the given role on the dataset in the given workspace.
+func AddDatasetUser(t require.TestingT, db *sql.DB, orgId, datasetId, userId int64, datasetRole role.Role) {
+	roleString := strings.ToLower(datasetRole.String())
+	query := fmt.Sprintf(`INSERT INTO "%d"."dataset_user" (dataset_id, user_id, role, permission_bit) VALUES ($1, $2, $3, $4)`, orgId)
+	_, err := db.Exec(query, datasetId, userId, roleString, pgdb.FromRole(roleString))
+	require.NoError(t, err, "error inserting (dataset, user, role) (%d, %d, %s)", datasetId, userId, roleString)
+}
+
+// AddDataUseAgreement adds a data-use agreement to the given workspace and returns its id.
+func AddDataUseAgreement(t require.TestingT, db *sql.DB, orgId int64) int64 {
+	query := fmt.Sprintf(`INSERT INTO "%d"."data_use_agreements" (name, body, description, is_default)
+			  VALUES ($1, 'Use this data responsibly.', '', false) RETURNING id`, orgId)
+	var agreementId int64
+	require.NoError(t, db.QueryRow(query, uuid.NewString()).Scan(&agreementId),
+		"error inserting data-use agreement into workspace %d", orgId)
+	return agreementId
+}
+
+// DeleteDataUseAgreement deletes a data-use agreement added by AddDataUseAgreement, which must no longer be
+// referenced.
+func DeleteDataUseAgreement(t require.TestingT, db *sql.DB, orgId, agreementId int64) {
+	_, err := db.Exec(fmt.Sprintf(`DELETE FROM "%d"."data_use_agreements" WHERE id = $1`, orgId), agreementId)
+	require.NoError(t, err, "error deleting data-use agreement %d", agreementId)
+}
+
+// SetDatasetDataUseAgreement makes the agreement the data-use agreement of the dataset in the given workspace.
+func SetDatasetDataUseAgreement(t require.TestingT, db *sql.DB, orgId, datasetId, agreementId int64) {
+	query := fmt.Sprintf(`UPDATE "%d"."datasets" SET data_use_agreement_id = $1 WHERE id = $2`, orgId)
+	_, err := db.Exec(query, agreementId, datasetId)
+	require.NoError(t, err, "error setting data-use agreement of dataset %d to %d", datasetId, agreementId)
+}
+
+// ProtectDataset marks the dataset in the given workspace as holding protected health information.
+func ProtectDataset(t require.TestingT, db *sql.DB, orgId, datasetId int64) {
+	query := fmt.Sprintf(`UPDATE "%d"."datasets" SET protected = true WHERE id = $1`, orgId)
+	_, err := db.Exec(query, datasetId)
+	require.NoError(t, err, "error protecting dataset %d", datasetId)
+}
+
+// AcceptDataUseAgreement records that the user accepted the agreement for the dataset in the given workspace the
+// way the platform does, by requesting access to the dataset.
+func AcceptDataUseAgreement(t require.TestingT, db *sql.DB, orgId, agreementId, datasetId, userId int64) {
+	query := fmt.Sprintf(`INSERT INTO "%d"."dataset_previewer" (dataset_id, user_id, embargo_access, data_use_agreement_id)
+			  VALUES ($1, $2, 'Requested', $3)`, orgId)
+	_, err := db.Exec(query, datasetId, userId, agreementId)
+	require.NoError(t, err, "error inserting (dataset, user, agreement) (%d, %d, %d)", datasetId, userId, agreementId)
+}
+
+// ExpireDatasetUser makes the user's role on the dataset in the given workspace expire at expiresAt.
+func ExpireDatasetUser(t require.TestingT, db *sql.DB, orgId, datasetId, userId int64, expiresAt time.Time) {
+	query := fmt.Sprintf(`UPDATE "%d"."dataset_user" SET expires_at = $1 WHERE dataset_id = $2 AND user_id = $3`, orgId)
+	_, err := db.Exec(query, expiresAt, datasetId, userId)
+	require.NoError(t, err, "error setting expiry of (dataset, user) (%d, %d)", datasetId, userId)
+}
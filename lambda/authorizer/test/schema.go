@@ -0,0 +1,72 @@
+package test
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+
+	"github.com/stretchr/testify/require"
+)
+
+// schemaFiles stand in for the platform's Flyway migrations that add what the authorizer reads but the seed
+// database lacks: those of the pennsieve schema, and those of each organization's schema, which name their tables
+// without a schema. Every statement is idempotent.
+//
+//go:embed schema/pennsieve/*.sql schema/organization/*.sql
+var schemaFiles embed.FS
+
+// Migrate applies the schema files to the seed test database: first those of the pennsieve schema, then those of
+// each organization's schema, with that schema first on the search path.
+func Migrate(t require.TestingT, db *sql.DB) {
+	ctx := context.Background()
+	names, err := fs.Glob(schemaFiles, "schema/pennsieve/*.sql")
+	require.NoError(t, err)
+	for _, name := range names {
+		require.NoError(t, applySchemaFile(ctx, db, name, ""), "error applying %s", name)
+	}
+
+	names, err = fs.Glob(schemaFiles, "schema/organization/*.sql")
+	require.NoError(t, err)
+	rows, err := db.QueryContext(ctx, "SELECT o.id FROM pennsieve.organizations o "+
+		"JOIN information_schema.schemata s ON s.schema_name = o.id::text ORDER BY o.id;")
+	require.NoError(t, err, "error listing organization schemas")
+	var organizationIds []int64
+	for rows.Next() {
+		var organizationId int64
+		require.NoError(t, rows.Scan(&organizationId))
+		organizationIds = append(organizationIds, organizationId)
+	}
+	require.NoError(t, rows.Err())
+	require.NoError(t, rows.Close())
+	for _, organizationId := range organizationIds {
+		for _, name := range names {
+			require.NoError(t, applySchemaFile(ctx, db, name, fmt.Sprintf(`"%d", pennsieve`, organizationId)),
+				"error applying %s to organization %d", name, organizationId)
+		}
+	}
+}
+
+// applySchemaFile runs the named file in its own transaction with the given search path, or the connection's if it
+// is empty.
+func applySchemaFile(ctx context.Context, db *sql.DB, name string, searchPath string) error {
+	statements, err := schemaFiles.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if searchPath != "" {
+		if _, err := tx.ExecContext(ctx, "SET LOCAL search_path TO "+searchPath+";"); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, string(statements)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
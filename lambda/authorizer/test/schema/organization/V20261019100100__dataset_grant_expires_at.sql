@@ -0,0 +1,6 @@
+-- When a user's or team's role on a dataset expires. NULL roles never expire.
+ALTER TABLE dataset_user
+    ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP;
+
+ALTER TABLE dataset_team
+    ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP;
@@ -0,0 +1,3 @@
+-- Whether a dataset holds protected health information, so that every request for it must declare a purpose of use.
+ALTER TABLE datasets
+    ADD COLUMN IF NOT EXISTS protected BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,11 @@
+-- CIDR ranges, or single addresses, that members of an organization may connect from. An organization with no
+-- rows accepts any source IP.
+CREATE TABLE IF NOT EXISTS pennsieve.organization_ip_allowlists
+(
+    id              SERIAL PRIMARY KEY,
+    organization_id INTEGER   NOT NULL REFERENCES pennsieve.organizations (id) ON DELETE CASCADE,
+    cidr            CIDR      NOT NULL,
+    description     TEXT,
+    created_at      TIMESTAMP NOT NULL DEFAULT now(),
+    UNIQUE (organization_id, cidr)
+);
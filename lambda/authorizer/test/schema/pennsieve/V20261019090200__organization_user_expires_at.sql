@@ -0,0 +1,3 @@
+-- When a user's membership of an organization expires. NULL memberships never expire.
+ALTER TABLE pennsieve.organization_user
+    ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP;
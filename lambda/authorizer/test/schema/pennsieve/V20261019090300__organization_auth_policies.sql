@@ -0,0 +1,10 @@
+-- How requests for an organization's resources may be authenticated. An organization with no row restricts nothing.
+CREATE TABLE IF NOT EXISTS pennsieve.organization_auth_policies
+(
+    organization_id            INTEGER PRIMARY KEY REFERENCES pennsieve.organizations (id) ON DELETE CASCADE,
+    allowed_identity_providers TEXT[],
+    api_tokens_disabled        BOOLEAN   NOT NULL DEFAULT false,
+    callback_tokens_allowed    BOOLEAN   NOT NULL DEFAULT true,
+    created_at                 TIMESTAMP NOT NULL DEFAULT now(),
+    updated_at                 TIMESTAMP NOT NULL DEFAULT now()
+);
@@ -0,0 +1,114 @@
+// Package tracing configures OpenTelemetry for the authorizer Lambdas. Spans are exported over
+// OTLP/HTTP to the endpoint in the standard OTEL_EXPORTER_OTLP_ENDPOINT (or
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT) variable; when neither is set tracing is a no-op. Trace
+// context is taken from the X-Ray (X-Amzn-Trace-Id) or W3C (traceparent) request headers, so the
+// authorizer's spans join the trace API Gateway started, and trace IDs are generated in X-Ray format.
+package tracing
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/contrib/propagators/aws/xray"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/pennsieve/pennsieve-go-api/authorizer"
+
+// lambdaTraceEnv is set by the Lambda runtime to the X-Ray trace header of the current invocation.
+const lambdaTraceEnv = "_X_AMZN_TRACE_ID"
+
+var provider *sdktrace.TracerProvider
+
+// Enabled reports whether an OTLP endpoint is configured.
+func Enabled() bool {
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// Init installs the global propagator and, if Enabled, a global TracerProvider that exports to the
+// configured OTLP endpoint. It is called once per Lambda cold start.
+func Init(ctx context.Context) error {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(xray.Propagator{}, propagation.TraceContext{}))
+	if !Enabled() {
+		return nil
+	}
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return err
+	}
+	SetProvider(sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithIDGenerator(xray.NewIDGenerator()),
+	))
+	return nil
+}
+
+// SetProvider installs provider as the global TracerProvider, e.g. one backed by an in-memory
+// exporter in tests.
+func SetProvider(tracerProvider *sdktrace.TracerProvider) {
+	provider = tracerProvider
+	otel.SetTracerProvider(tracerProvider)
+}
+
+// Flush exports any buffered spans. The Lambda execution environment is frozen between
+// invocations, so handlers call it before returning.
+func Flush(ctx context.Context) error {
+	if provider == nil {
+		return nil
+	}
+	return provider.ForceFlush(ctx)
+}
+
+// Tracer returns the authorizer's tracer from the global TracerProvider.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Extract returns ctx carrying the remote span context found in headers or, failing that, in the
+// trace header the Lambda runtime provides for the invocation.
+func Extract(ctx context.Context, headers map[string]string) context.Context {
+	carrier := propagation.MapCarrier{}
+	for key, value := range headers {
+		carrier.Set(strings.ToLower(key), value)
+	}
+	if carrier.Get("x-amzn-trace-id") == "" && carrier.Get("traceparent") == "" {
+		if lambdaTrace := os.Getenv(lambdaTraceEnv); lambdaTrace != "" {
+			carrier.Set("x-amzn-trace-id", lambdaTrace)
+		}
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, lowerCaseCarrier{carrier})
+}
+
+// TraceId returns the ID of the trace ctx belongs to, or an empty string if there is none.
+func TraceId(ctx context.Context) string {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.HasTraceID() {
+		return ""
+	}
+	return spanContext.TraceID().String()
+}
+
+// End ends span, first marking it as failed if err is not nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// lowerCaseCarrier looks headers up case-insensitively; propagators ask for canonical names
+// (e.g. "X-Amzn-Trace-Id") but API Gateway HTTP APIs deliver them lower-cased.
+type lowerCaseCarrier struct {
+	propagation.MapCarrier
+}
+
+func (c lowerCaseCarrier) Get(key string) string {
+	return c.MapCarrier.Get(strings.ToLower(key))
+}
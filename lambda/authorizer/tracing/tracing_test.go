@@ -0,0 +1,77 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pennsieve/pennsieve-go-api/authorizer/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+const (
+	xrayHeader  = "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1"
+	xrayTraceId = "5759e988bd862e3fe1be46a994272793"
+	traceparent = "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+)
+
+func TestExtract(t *testing.T) {
+	require.NoError(t, tracing.Init(context.Background()))
+
+	for scenario, params := range map[string]struct {
+		headers   map[string]string
+		lambdaEnv string
+		expected  string
+	}{
+		"api gateway lower-cased x-ray header": {map[string]string{"x-amzn-trace-id": xrayHeader}, "", xrayTraceId},
+		"canonical x-ray header":               {map[string]string{"X-Amzn-Trace-Id": xrayHeader}, "", xrayTraceId},
+		"w3c traceparent":                      {map[string]string{"traceparent": traceparent}, "", "0af7651916cd43dd8448eb211c80319c"},
+		"lambda runtime trace":                 {nil, xrayHeader, xrayTraceId},
+		"header wins over lambda runtime":      {map[string]string{"traceparent": traceparent}, "Root=1-00000000-000000000000000000000001;Parent=53995c3f42cd8ad8;Sampled=1", "0af7651916cd43dd8448eb211c80319c"},
+		"no trace context":                     {map[string]string{"authorization": "Bearer x"}, "", ""},
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			t.Setenv("_X_AMZN_TRACE_ID", params.lambdaEnv)
+			ctx := tracing.Extract(context.Background(), params.headers)
+			assert.Equal(t, params.expected, tracing.TraceId(ctx))
+		})
+	}
+}
+
+func TestSpansJoinRemoteTrace(t *testing.T) {
+	require.NoError(t, tracing.Init(context.Background()))
+	exporter := tracetest.NewInMemoryExporter()
+	tracing.SetProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+
+	ctx := tracing.Extract(context.Background(), map[string]string{"x-amzn-trace-id": xrayHeader})
+	ctx, span := tracing.Tracer().Start(ctx, "Authorize")
+	tracing.End(span, errors.New("connection refused"))
+	require.NoError(t, tracing.Flush(ctx))
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, xrayTraceId, spans[0].SpanContext.TraceID().String())
+	assert.Equal(t, xrayTraceId, tracing.TraceId(ctx))
+	assert.Equal(t, codes.Error, spans[0].Status.Code)
+}
+
+// TestExportToCollector sends a span to the OTLP collector started by docker-compose.test.yml.
+// It is skipped when no collector endpoint is configured.
+func TestExportToCollector(t *testing.T) {
+	if !tracing.Enabled() {
+		t.Skip("OTEL_EXPORTER_OTLP_ENDPOINT not set")
+	}
+	require.NoError(t, tracing.Init(context.Background()))
+
+	ctx, span := tracing.Tracer().Start(context.Background(), "TestExportToCollector")
+	span.End()
+
+	flushCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	assert.NoError(t, tracing.Flush(flushCtx))
+}
@@ -0,0 +1,67 @@
+// Token buckets for per-principal rate limiting in the authorizer (see lambda/authorizer/ratelimit).
+// Items expire once their bucket would have refilled, so the table only holds recently active principals.
+resource "aws_dynamodb_table" "authorizer_rate_limit_table" {
+  name         = "${var.environment_name}-${var.service_name}-authorizer-rate-limits-${data.terraform_remote_state.region.outputs.aws_region_shortname}"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "bucketKey"
+
+  attribute {
+    name = "bucketKey"
+    type = "S"
+  }
+
+  ttl {
+    attribute_name = "expiresAt"
+    enabled        = true
+  }
+
+  server_side_encryption {
+    enabled = true
+  }
+}
+
+// Users, API tokens and organizations denied by every authorizer during incident response (see
+// lambda/authorizer/blocklist). Items are keyed "<kind>:<id>", e.g. "user:N:user:...", and may set expiresAt so that
+// a block lifts itself.
+resource "aws_dynamodb_table" "authorizer_block_list_table" {
+  name         = "${var.environment_name}-${var.service_name}-authorizer-block-list-${data.terraform_remote_state.region.outputs.aws_region_shortname}"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "blockKey"
+
+  attribute {
+    name = "blockKey"
+    type = "S"
+  }
+
+  ttl {
+    attribute_name = "expiresAt"
+    enabled        = true
+  }
+
+  server_side_encryption {
+    enabled = true
+  }
+}
+
+// When each break-glass grant started (see lambda/authorizer/breakglass), so that a grant expires BREAK_GLASS_DURATION
+// after its first request. Items are keyed "<operator>:<dataset>:<justification hash>" and expire 30 days after their
+// grant, so that a justification cannot start a second grant before then.
+resource "aws_dynamodb_table" "authorizer_break_glass_table" {
+  name         = "${var.environment_name}-${var.service_name}-authorizer-break-glass-${data.terraform_remote_state.region.outputs.aws_region_shortname}"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "grantKey"
+
+  attribute {
+    name = "grantKey"
+    type = "S"
+  }
+
+  ttl {
+    attribute_name = "expiresAt"
+    enabled        = true
+  }
+
+  server_side_encryption {
+    enabled = true
+  }
+}
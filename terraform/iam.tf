@@ -112,6 +112,50 @@ data "aws_iam_policy_document" "authorizer_lambda_iam_policy_document" {
 
   }
 
+  statement {
+    sid    = "LambdaAccessToRateLimitTable"
+    effect = "Allow"
+
+    actions = [
+      "dynamodb:DescribeTable",
+      "dynamodb:GetItem",
+      "dynamodb:PutItem",
+    ]
+
+    resources = [
+      aws_dynamodb_table.authorizer_rate_limit_table.arn,
+    ]
+  }
+
+  statement {
+    sid    = "LambdaAccessToBreakGlassTable"
+    effect = "Allow"
+
+    actions = [
+      "dynamodb:DescribeTable",
+      "dynamodb:GetItem",
+      "dynamodb:PutItem",
+    ]
+
+    resources = [
+      aws_dynamodb_table.authorizer_break_glass_table.arn,
+    ]
+  }
+
+  statement {
+    sid    = "LambdaAccessToBlockListTable"
+    effect = "Allow"
+
+    actions = [
+      "dynamodb:DescribeTable",
+      "dynamodb:Scan",
+    ]
+
+    resources = [
+      aws_dynamodb_table.authorizer_block_list_table.arn,
+    ]
+  }
+
   statement {
     sid    = "UploadLambdaPermissions"
     effect = "Allow"
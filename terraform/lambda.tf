@@ -31,6 +31,17 @@ resource "aws_lambda_function" "authorizer_lambda" {
       LOG_LEVEL          = "INFO"
       AUTHORIZER_MODE    = "LEGACY"
       CALLBACK_VALIDATOR_WORKFLOW_SERVICE = data.terraform_remote_state.workflow_service.outputs.callback_validator_lambda_arn,
+      IP_ALLOWLIST_EXEMPT_SUPER_ADMINS    = "false"
+      IP_ALLOWLIST_EXEMPT_CALLBACKS       = "false"
+      IP_ALLOWLISTS_OPTIONAL              = var.ip_allowlists_optional
+      AUTH_POLICIES_OPTIONAL              = var.auth_policies_optional
+      RATE_LIMIT_TABLE                    = aws_dynamodb_table.authorizer_rate_limit_table.name
+      RATE_LIMIT_CONFIG                   = var.rate_limit_config
+      OTEL_EXPORTER_OTLP_ENDPOINT         = var.otlp_endpoint
+      DATASET_LOCK_EXEMPT_ROUTES          = var.dataset_lock_exempt_routes
+      BREAK_GLASS_GROUP                   = var.break_glass_group
+      BREAK_GLASS_TABLE                   = aws_dynamodb_table.authorizer_break_glass_table.name
+      BLOCK_LIST_TABLE                    = aws_dynamodb_table.authorizer_block_list_table.name
     }
   }
 }
@@ -85,6 +96,10 @@ resource "aws_lambda_function" "direct_authorizer_lambda" {
       MANIFEST_TABLE     = data.terraform_remote_state.upload_service_v2.outputs.manifest_table_name,
       LOG_LEVEL          = "INFO"
       AUTHORIZER_MODE    = "LEGACY"
+      OTEL_EXPORTER_OTLP_ENDPOINT = var.otlp_endpoint
+      BLOCK_LIST_TABLE            = aws_dynamodb_table.authorizer_block_list_table.name
+      IP_ALLOWLISTS_OPTIONAL      = var.ip_allowlists_optional
+      AUTH_POLICIES_OPTIONAL      = var.auth_policies_optional
     }
   }
 }
@@ -138,6 +153,14 @@ resource "aws_lambda_function" "websocket_authorizer_lambda" {
       MANIFEST_TABLE     = data.terraform_remote_state.upload_service_v2.outputs.manifest_table_name,
       LOG_LEVEL          = "INFO"
       AUTHORIZER_MODE    = "LEGACY"
+      IP_ALLOWLIST_EXEMPT_SUPER_ADMINS = "false"
+      IP_ALLOWLISTS_OPTIONAL           = var.ip_allowlists_optional
+      AUTH_POLICIES_OPTIONAL           = var.auth_policies_optional
+      RATE_LIMIT_TABLE                 = aws_dynamodb_table.authorizer_rate_limit_table.name
+      RATE_LIMIT_CONFIG                = var.rate_limit_config
+      OTEL_EXPORTER_OTLP_ENDPOINT      = var.otlp_endpoint
+      DATASET_LOCK_EXEMPT_ROUTES       = var.dataset_lock_exempt_routes
+      BLOCK_LIST_TABLE                 = aws_dynamodb_table.authorizer_block_list_table.name
 
       // Optional cross-service compute-node access check. When the
       // WebSocket handshake URL carries `?computeNodeId=...`, the
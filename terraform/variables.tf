@@ -45,3 +45,38 @@ locals {
     environment_name = var.environment_name
   }
 }
+
+// OTLP/HTTP endpoint (e.g. an ADOT collector) that the authorizers export trace spans to.
+// Empty disables tracing.
+variable "otlp_endpoint" {
+  default = ""
+}
+
+// JSON ratelimit.Config for the authorizer, e.g. {"default": {"burst": 50, "perSecond": 10}}.
+// Empty disables rate limiting.
+variable "rate_limit_config" {
+  default = ""
+}
+
+// Comma-separated route keys that may modify datasets locked for publication or deletion,
+// e.g. "POST /datasets/{id}/publication/cancel".
+variable "dataset_lock_exempt_routes" {
+  default = ""
+}
+
+// Cognito group whose members may request break-glass read access to datasets. Empty disables break-glass.
+variable "break_glass_group" {
+  default = ""
+}
+
+// Reads every organization as having no IP allowlist while pennsieve.organization_ip_allowlists does not exist.
+// Leave "false" so that a missing table fails requests instead of lifting every allowlist.
+variable "ip_allowlists_optional" {
+  default = "false"
+}
+
+// Reads every organization as having no authentication policy while pennsieve.organization_auth_policies does not
+// exist. Leave "false" so that a missing table fails requests instead of lifting every policy.
+variable "auth_policies_optional" {
+  default = "false"
+}